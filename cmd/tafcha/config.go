@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/cli"
+)
+
+func newConfigCmd(settings *cli.Settings) *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Print the resolved CLI configuration and where it came from",
+		Long: `Print the resolved CLI configuration and where each value came from.
+
+Defaults are read from ~/.config/tafcha/config.yaml (or
+$XDG_CONFIG_HOME/tafcha/config.yaml, or the file in TAFCHA_CONFIG), then
+overridden by TAFCHA_* environment variables and finally by flags:
+
+  api: https://my-host
+  expiry: 1d
+  timeout: 10s
+  quiet: true`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigCheck(cmd.OutOrStdout(), settings)
+		},
+	}
+
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "check",
+		Short: "Print the resolved configuration and report problems",
+		Long: `Print the resolved configuration and report any validation problems.
+
+No network calls are made.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigCheck(cmd.OutOrStdout(), settings)
+		},
+	})
+
+	return configCmd
+}
+
+// runConfigCheck writes the resolved settings to w and returns an error
+// when any of them are invalid.
+func runConfigCheck(w io.Writer, settings *cli.Settings) error {
+	profile := settings.Profile
+	if profile == "" {
+		profile = "(none)"
+	}
+	defaultExpiry := settings.Expiry
+	if defaultExpiry == "" {
+		defaultExpiry = "(server default)"
+	}
+
+	configFile := settings.ConfigFile
+	if configFile == "" {
+		configFile = "(none)"
+	}
+
+	fmt.Fprintf(w, "config:   %s\n", configFile)
+	fmt.Fprintf(w, "profile:  %s\n", profile)
+	fmt.Fprintf(w, "api:      %s%s\n", settings.APIURL, sourceNote(settings, "api"))
+	fmt.Fprintf(w, "expiry:   %s%s\n", defaultExpiry, sourceNote(settings, "expiry"))
+	fmt.Fprintf(w, "timeout:  %s%s\n", settings.Timeout, sourceNote(settings, "timeout"))
+	fmt.Fprintf(w, "quiet:    %t%s\n", settings.Quiet, sourceNote(settings, "quiet"))
+	if settings.SigningKey != "" {
+		fmt.Fprintln(w, "signing:  key set")
+	}
+	if settings.APIKey != "" {
+		fmt.Fprintln(w, "api key:  set")
+	}
+
+	problems := settings.Validate()
+	if len(problems) == 0 {
+		fmt.Fprintln(w, "\nconfiguration OK")
+		return nil
+	}
+
+	fmt.Fprintln(w, "\nproblems:")
+	for _, p := range problems {
+		fmt.Fprintf(w, "  - %v\n", p)
+	}
+	return fmt.Errorf("configuration has %d problem(s)", len(problems))
+}
+
+// sourceNote formats where a setting came from, e.g. " (from TAFCHA_API)".
+func sourceNote(settings *cli.Settings, key string) string {
+	if src := settings.Sources[key]; src != "" {
+		return " (from " + src + ")"
+	}
+	return ""
+}
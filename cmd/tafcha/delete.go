@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/cli"
+)
+
+func newDeleteCmd(settings *cli.Settings) *cobra.Command {
+	var (
+		deleteAPI     string
+		deleteAPIKey  string
+		deleteTimeout time.Duration
+		token         string
+		from          string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "delete [id-or-url]",
+		Short: "Delete a snippet",
+		Long: `Delete a snippet using the delete token returned when it was created.
+
+The snippet and token can be given with an argument and --token, or read
+from a response saved with "tafcha --json".
+
+Examples:
+  tafcha delete abc123XYZ789 --token <delete-token>
+  echo "hi" | tafcha --json --output-url-file snippet.json
+  tafcha delete --from snippet.json`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var ref string
+			if len(args) == 1 {
+				ref = args[0]
+			}
+
+			if from != "" {
+				saved, err := loadSavedResponse(from)
+				if err != nil {
+					return err
+				}
+				if ref == "" {
+					ref = saved.URL
+				}
+				if token == "" {
+					token = saved.DeleteToken
+				}
+			}
+
+			if ref == "" {
+				return fmt.Errorf("no snippet given - pass an ID, a URL or --from")
+			}
+			if token == "" {
+				return fmt.Errorf("no delete token given - pass --token or --from")
+			}
+
+			base, snippetID, err := parseSnippetRef(ref)
+			if err != nil {
+				return err
+			}
+			if base == "" || cmd.Flags().Changed("api") {
+				base = deleteAPI
+			}
+
+			client := cli.NewClient(base, deleteTimeout)
+			client.SetAPIKey(deleteAPIKey)
+			if err := client.Delete(snippetID, token); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Deleted %s\n", snippetID)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&deleteAPI, "api", "a", settings.APIURL, "API server URL")
+	cmd.Flags().StringVar(&deleteAPIKey, "api-key", settings.APIKey, "API key for servers that require one (X-API-Key)")
+	cmd.Flags().DurationVarP(&deleteTimeout, "timeout", "t", settings.Timeout, "Request timeout")
+	cmd.Flags().StringVar(&token, "token", "", "Delete token returned at creation")
+	cmd.Flags().StringVar(&from, "from", "", "Read the snippet and token from a saved --json response")
+
+	return cmd
+}
+
+// loadSavedResponse reads a create response written by "tafcha --json".
+func loadSavedResponse(path string) (*cli.CreateResponse, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	var resp cli.CreateResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("%s is not a saved tafcha response: %w", path, err)
+	}
+	return &resp, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/cli"
+)
+
+func deleteServer(t *testing.T, deleted *[]string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.Header.Get("X-Delete-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		*deleted = append(*deleted, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func runDelete(t *testing.T, apiURL string, args ...string) error {
+	t.Helper()
+
+	cmd := newDeleteCmd(&cli.Settings{APIURL: apiURL, Timeout: 5 * time.Second})
+	cmd.SetArgs(args)
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	return cmd.Execute()
+}
+
+func TestDeleteCmd_TokenFlag(t *testing.T) {
+	var deleted []string
+	srv := deleteServer(t, &deleted)
+
+	require.NoError(t, runDelete(t, srv.URL, "abc123XYZ789", "--token", "secret"))
+	assert.Equal(t, []string{"/abc123XYZ789"}, deleted)
+
+	require.Error(t, runDelete(t, srv.URL, "abc123XYZ789", "--token", "wrong"))
+	require.Error(t, runDelete(t, srv.URL, "abc123XYZ789"), "token is required")
+}
+
+func TestDeleteCmd_SavedResponse(t *testing.T) {
+	var deleted []string
+	srv := deleteServer(t, &deleted)
+
+	path := filepath.Join(t.TempDir(), "snippet.json")
+	saved, err := formatResult([]*cli.CreateResponse{{
+		ID:          "abc123XYZ789",
+		URL:         srv.URL + "/abc123XYZ789",
+		DeleteToken: "secret",
+	}}, true)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, saved, 0o600))
+
+	// The server comes from the saved URL, not the default API
+	require.NoError(t, runDelete(t, "http://unused.invalid", "--from", path))
+	assert.Equal(t, []string{"/abc123XYZ789"}, deleted)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/cli"
+)
+
+func newExtendCmd(settings *cli.Settings) *cobra.Command {
+	var (
+		extendAPI     string
+		extendAPIKey  string
+		extendTimeout time.Duration
+		extendExpiry  string
+		token         string
+		from          string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "extend [id-or-url]",
+		Short: "Give a snippet a new expiry",
+		Long: `Give a snippet a new expiry, counted from now, using the delete token
+returned when it was created. The new expiry may also be shorter.
+
+The snippet and token can be given with an argument and --token, or read
+from a response saved with "tafcha --json".
+
+Examples:
+  tafcha extend abc123XYZ789 --token <delete-token> --expiry 7d
+  tafcha extend --from snippet.json --expiry 1M`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var ref string
+			if len(args) == 1 {
+				ref = args[0]
+			}
+
+			if from != "" {
+				saved, err := loadSavedResponse(from)
+				if err != nil {
+					return err
+				}
+				if ref == "" {
+					ref = saved.URL
+				}
+				if token == "" {
+					token = saved.DeleteToken
+				}
+			}
+
+			if ref == "" {
+				return fmt.Errorf("no snippet given - pass an ID, a URL or --from")
+			}
+			if token == "" {
+				return fmt.Errorf("no delete token given - pass --token or --from")
+			}
+
+			base, snippetID, err := parseSnippetRef(ref)
+			if err != nil {
+				return err
+			}
+			if base == "" || cmd.Flags().Changed("api") {
+				base = extendAPI
+			}
+
+			client := cli.NewClient(base, extendTimeout)
+			client.SetAPIKey(extendAPIKey)
+			resp, err := client.Extend(snippetID, token, extendExpiry)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Extended %s until %s\n", snippetID, resp.ExpiresAt.Local().Format("2006-01-02 15:04:05"))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&extendAPI, "api", "a", settings.APIURL, "API server URL")
+	cmd.Flags().StringVar(&extendAPIKey, "api-key", settings.APIKey, "API key for servers that require one (X-API-Key)")
+	cmd.Flags().DurationVarP(&extendTimeout, "timeout", "t", settings.Timeout, "Request timeout")
+	cmd.Flags().StringVarP(&extendExpiry, "expiry", "e", settings.Expiry, "New expiry, counted from now (e.g., 1h, 7d, 1M)")
+	cmd.Flags().StringVar(&token, "token", "", "Delete token returned at creation")
+	cmd.Flags().StringVar(&from, "from", "", "Read the snippet and token from a saved --json response")
+
+	return cmd
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/cli"
+)
+
+func TestExtendCmd(t *testing.T) {
+	var gotPath, gotExpiry string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch || r.Header.Get("X-Delete-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		gotPath, gotExpiry = r.URL.Path, r.URL.Query().Get("expiry")
+		w.Write([]byte(`{"id":"abc123XYZ789","expires_at":"2025-06-01T00:00:00Z"}`))
+	}))
+	defer srv.Close()
+
+	var stderr bytes.Buffer
+	run := func(args ...string) error {
+		stderr.Reset()
+		cmd := newExtendCmd(&cli.Settings{APIURL: srv.URL, Timeout: 5 * time.Second, Expiry: "3d"})
+		cmd.SetArgs(args)
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetErr(&stderr)
+		return cmd.Execute()
+	}
+
+	require.NoError(t, run("abc123XYZ789", "--token", "secret", "--expiry", "7d"))
+	assert.Equal(t, "/abc123XYZ789", gotPath)
+	assert.Equal(t, "7d", gotExpiry)
+	assert.Contains(t, stderr.String(), "Extended abc123XYZ789 until")
+
+	require.NoError(t, run("abc123XYZ789", "--token", "secret"))
+	assert.Equal(t, "3d", gotExpiry, "defaults to the configured expiry")
+
+	require.Error(t, run("abc123XYZ789", "--token", "wrong"))
+	require.Error(t, run("abc123XYZ789"), "token is required")
+}
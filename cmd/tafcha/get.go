@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/cli"
+	"github.com/rayenfassatoui/tafcha-cli/internal/id"
+)
+
+func newGetCmd(settings *cli.Settings) *cobra.Command {
+	var (
+		getAPI     string
+		getTimeout time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "get <id-or-url>",
+		Short: "Print a snippet's content",
+		Long: `Download a snippet and print its content to stdout.
+
+The snippet can be given as a bare ID or as a full URL. For a URL the
+server is taken from the URL unless --api is set. A URL fragment, as
+printed by --encrypt, is used as the key to decrypt the content locally.
+Links made with --passphrase also need the passphrase in TAFCHA_PASSPHRASE.
+
+Examples:
+  tafcha get abc123XYZ789
+  tafcha get https://tafcha.dev/abc123XYZ789 > snippet.txt
+  tafcha get 'https://tafcha.dev/abc123XYZ789#<key>'`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ref, fragment, _ := strings.Cut(args[0], "#")
+			base, snippetID, err := parseSnippetRef(ref)
+			if err != nil {
+				return err
+			}
+			if base == "" || cmd.Flags().Changed("api") {
+				base = getAPI
+			}
+
+			var key []byte
+			if fragment != "" {
+				if key, err = resolveFragmentKey(fragment, os.Getenv(passphraseEnv)); err != nil {
+					return err
+				}
+			}
+			return runGet(cmd.OutOrStdout(), cli.NewClient(base, getTimeout), snippetID, key)
+		},
+	}
+
+	cmd.Flags().StringVarP(&getAPI, "api", "a", settings.APIURL, "API server URL")
+	cmd.Flags().DurationVarP(&getTimeout, "timeout", "t", settings.Timeout, "Request timeout")
+
+	return cmd
+}
+
+// resolveFragmentKey returns the decryption key for a URL fragment,
+// deriving it from passphrase when the fragment only holds a salt.
+func resolveFragmentKey(fragment, passphrase string) ([]byte, error) {
+	frag, err := cli.ParseKeyFragment(fragment)
+	if err != nil {
+		return nil, err
+	}
+	if frag.NeedsPassphrase() && passphrase == "" {
+		return nil, fmt.Errorf("snippet is passphrase-protected - set %s", passphraseEnv)
+	}
+	return frag.ResolveKey(passphrase)
+}
+
+// runGet fetches a snippet and copies its content to w, decrypting it
+// first when key is set.
+func runGet(w io.Writer, client *cli.Client, snippetID string, key []byte) error {
+	content, err := client.Get(snippetID)
+	if err != nil {
+		return err
+	}
+	if key != nil {
+		if content, err = cli.Decrypt(key, content); err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(content)
+	return err
+}
+
+// parseSnippetRef extracts the snippet ID from a bare ID or a snippet URL.
+// For URLs it also returns the server base, which keeps any path prefix
+// such as /t/{tenant}.
+func parseSnippetRef(ref string) (base, snippetID string, err error) {
+	ref = strings.TrimSpace(ref)
+
+	if strings.Contains(ref, "://") {
+		u, err := url.Parse(ref)
+		if err != nil || u.Host == "" {
+			return "", "", fmt.Errorf("invalid snippet URL: %q", ref)
+		}
+		path := strings.TrimSuffix(u.Path, "/")
+		idx := strings.LastIndex(path, "/")
+		snippetID = path[idx+1:]
+		base = u.Scheme + "://" + u.Host + path[:max(idx, 0)]
+	} else {
+		snippetID = ref
+	}
+
+	if !id.IsValidCustom(snippetID) {
+		return "", "", fmt.Errorf("invalid snippet ID: %q", snippetID)
+	}
+	return base, snippetID, nil
+}
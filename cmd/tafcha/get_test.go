@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/cli"
+)
+
+func TestParseSnippetRef(t *testing.T) {
+	tests := []struct {
+		ref      string
+		wantBase string
+		wantID   string
+		wantErr  bool
+	}{
+		{ref: "abc123XYZ789", wantID: "abc123XYZ789"},
+		{ref: "https://tafcha.dev/abc123XYZ789", wantBase: "https://tafcha.dev", wantID: "abc123XYZ789"},
+		{ref: "https://tafcha.dev/abc123XYZ789?raw", wantBase: "https://tafcha.dev", wantID: "abc123XYZ789"},
+		{ref: "http://localhost:8080/t/acme/abc123XYZ789/", wantBase: "http://localhost:8080/t/acme", wantID: "abc123XYZ789"},
+		{ref: "my-release-notes", wantID: "my-release-notes"},
+		{ref: "ab", wantErr: true},
+		{ref: "abc123XYZ78!", wantErr: true},
+		{ref: "https://tafcha.dev/", wantErr: true},
+		{ref: "https:///abc123XYZ789", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			base, snippetID, err := parseSnippetRef(tt.ref)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantBase, base)
+			assert.Equal(t, tt.wantID, snippetID)
+		})
+	}
+}
+
+func TestRunGet(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/abc123XYZ789" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("snippet body\n"))
+	}))
+	defer srv.Close()
+
+	client := cli.NewClient(srv.URL, 5*time.Second)
+
+	var out bytes.Buffer
+	require.NoError(t, runGet(&out, client, "abc123XYZ789", nil))
+	assert.Equal(t, "snippet body\n", out.String())
+
+	out.Reset()
+	err := runGet(&out, client, "zzz123XYZ789", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not found or expired")
+	assert.Empty(t, out.String())
+}
+
+func TestRunGet_Encrypted(t *testing.T) {
+	key, err := cli.NewKey()
+	require.NoError(t, err)
+	payload, err := cli.Encrypt(key, []byte("top secret\n"))
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(payload)
+	}))
+	defer srv.Close()
+
+	client := cli.NewClient(srv.URL, 5*time.Second)
+
+	var out bytes.Buffer
+	require.NoError(t, runGet(&out, client, "abc123XYZ789", key))
+	assert.Equal(t, "top secret\n", out.String())
+
+	wrong, err := cli.NewKey()
+	require.NoError(t, err)
+	out.Reset()
+	assert.ErrorIs(t, runGet(&out, client, "abc123XYZ789", wrong), cli.ErrDecrypt)
+	assert.Empty(t, out.String())
+}
+
+func TestResolveFragmentKey(t *testing.T) {
+	kdf := cli.KDFParams{Time: 1, Memory: 64, Threads: 1}
+	salt, err := cli.NewSalt()
+	require.NoError(t, err)
+	want, err := cli.DeriveKey("hunter2", salt, kdf)
+	require.NoError(t, err)
+	fragment := cli.EncodePassphraseFragment(salt, kdf)
+
+	got, err := resolveFragmentKey(fragment, "hunter2")
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	_, err = resolveFragmentKey(fragment, "")
+	assert.ErrorContains(t, err, "TAFCHA_PASSPHRASE")
+
+	key, err := cli.NewKey()
+	require.NoError(t, err)
+	got, err = resolveFragmentKey(cli.EncodeKey(key), "")
+	require.NoError(t, err)
+	assert.Equal(t, key, got)
+}
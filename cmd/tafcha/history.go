@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/cli"
+)
+
+func newHistoryCmd() *cobra.Command {
+	var (
+		limit       int
+		historyJSON bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "List snippets created from this machine",
+		Long: `List snippets created from this machine that have not expired yet,
+newest first.
+
+History is kept in $XDG_DATA_HOME/tafcha/history.jsonl
+(~/.local/share/tafcha/history.jsonl when XDG_DATA_HOME is unset).
+
+Examples:
+  tafcha history
+  tafcha history --limit 5
+  tafcha history --json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := cli.HistoryPath(os.Getenv)
+			if err != nil {
+				return err
+			}
+			return runHistory(cmd.OutOrStdout(), cmd.ErrOrStderr(), path, time.Now(), limit, historyJSON)
+		},
+	}
+
+	cmd.Flags().IntVarP(&limit, "limit", "n", 0, "Show at most this many snippets (0 for all)")
+	cmd.Flags().BoolVar(&historyJSON, "json", false, "Output the entries as JSON")
+
+	return cmd
+}
+
+// runHistory prints the unexpired history entries at path, newest first.
+// Unreadable lines are reported on stderr instead of failing the listing.
+func runHistory(stdout, stderr io.Writer, path string, now time.Time, limit int, asJSON bool) error {
+	entries, skipped, err := cli.ReadHistory(path)
+	if err != nil {
+		return err
+	}
+	if skipped > 0 {
+		fmt.Fprintf(stderr, "warning: skipped %d unreadable line(s) in %s\n", skipped, path)
+	}
+
+	active := make([]cli.HistoryEntry, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].ExpiresAt.After(now) {
+			active = append(active, entries[i])
+		}
+	}
+	if limit > 0 && len(active) > limit {
+		active = active[:limit]
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(active, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encoding history: %w", err)
+		}
+		_, err = stdout.Write(append(data, '\n'))
+		return err
+	}
+
+	if len(active) == 0 {
+		fmt.Fprintln(stdout, "no active snippets")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CREATED\tEXPIRES\tSIZE\tURL\tTITLE")
+	for _, e := range active {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n",
+			e.CreatedAt.Local().Format("2006-01-02 15:04"),
+			e.ExpiresAt.Local().Format("2006-01-02 15:04"),
+			e.SizeBytes,
+			e.URL,
+			e.Title,
+		)
+	}
+	return tw.Flush()
+}
+
+// recordHistory appends a created snippet to the history file. title
+// comes from --frontmatter and may be empty.
+func recordHistory(path string, resp *cli.CreateResponse, title string, size int, now time.Time) error {
+	return cli.AppendHistory(path, cli.HistoryEntry{
+		ID:        resp.ID,
+		URL:       resp.URL,
+		Title:     title,
+		ExpiresAt: resp.ExpiresAt,
+		CreatedAt: now,
+		SizeBytes: size,
+	})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/cli"
+)
+
+func TestRunHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	now := time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, expires := range []time.Duration{-time.Hour, time.Hour, 2 * time.Hour, 3 * time.Hour} {
+		resp := testResult()
+		resp.ID = strings.Repeat(string(rune('a'+i)), 12)
+		resp.URL = "https://tafcha.dev/" + resp.ID
+		resp.ExpiresAt = now.Add(expires)
+		require.NoError(t, recordHistory(path, resp, "", 10*i, now.Add(time.Duration(i)*time.Minute)))
+	}
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, runHistory(&stdout, &stderr, path, now, 2, false))
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	require.Len(t, lines, 3, "header plus two entries")
+	assert.Contains(t, lines[1], "dddddddddddd", "newest first")
+	assert.Contains(t, lines[2], "cccccccccccc")
+	assert.Empty(t, stderr.String())
+
+	stdout.Reset()
+	require.NoError(t, runHistory(&stdout, &stderr, path, now, 0, true))
+	var entries []cli.HistoryEntry
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &entries))
+	require.Len(t, entries, 3, "expired entry is hidden")
+	assert.Equal(t, 30, entries[0].SizeBytes)
+}
+
+func TestRunHistory_CorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte("{broken\n"), 0o600))
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, runHistory(&stdout, &stderr, path, time.Now(), 0, false))
+	assert.Contains(t, stderr.String(), "skipped 1 unreadable line(s)")
+	assert.Equal(t, "no active snippets\n", stdout.String())
+}
+
+func TestRunHistory_Title(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	now := time.Now()
+
+	resp := testResult()
+	resp.ExpiresAt = now.Add(time.Hour)
+	require.NoError(t, recordHistory(path, resp, "Deploy notes", 5, now))
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, runHistory(&stdout, &stderr, path, now, 0, false))
+	assert.Contains(t, stdout.String(), "TITLE")
+	assert.Contains(t, stdout.String(), "Deploy notes")
+}
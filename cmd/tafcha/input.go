@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// errNoInput is returned when neither --content, --file nor piped stdin
+// supplies data.
+var errNoInput = errors.New("no input provided - pipe text to tafcha or pass --content or --file\n\nExample: echo \"hello\" | tafcha")
+
+// stdinPiped reports whether stdin is a pipe or redirected file rather than
+// an interactive terminal.
+func stdinPiped() (bool, error) {
+	stat, err := os.Stdin.Stat()
+	if err != nil {
+		return false, fmt.Errorf("checking stdin: %w", err)
+	}
+	return (stat.Mode() & os.ModeCharDevice) == 0, nil
+}
+
+// readInput resolves the content to upload. When contentSet is true the
+// --content value is used verbatim and stdin is left unread: under cron or CI
+// stdin is rarely a terminal, so it cannot tell us that nothing was piped.
+func readInput(stdin io.Reader, piped bool, content string, contentSet bool) ([]byte, error) {
+	if contentSet {
+		if content == "" {
+			return nil, fmt.Errorf("empty input - nothing to upload")
+		}
+		return []byte(content), nil
+	}
+
+	if !piped {
+		return nil, errNoInput
+	}
+
+	data, err := io.ReadAll(stdin)
+	if err != nil && len(data) > 0 {
+		return data, &partialReadError{n: len(data), err: err}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading stdin: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty input - nothing to upload")
+	}
+	return data, nil
+}
+
+// partialReadError is returned with the data read so far when stdin fails
+// partway, e.g. because the upstream of the pipe died. Nothing is uploaded
+// unless --allow-partial accepts the truncated input.
+type partialReadError struct {
+	n   int
+	err error
+}
+
+func (e *partialReadError) Error() string {
+	return fmt.Sprintf("reading stdin failed after %d bytes, nothing was uploaded (use --allow-partial to upload them): %v", e.n, e.err)
+}
+
+func (e *partialReadError) Unwrap() error { return e.err }
+
+// readFiles reads every --file path in order. Like --content, files leave
+// stdin unread. They cannot be combined with --content, and an unreadable or
+// empty file fails the whole batch before anything is uploaded.
+func readFiles(paths []string, contentSet bool) ([][]byte, error) {
+	if contentSet {
+		return nil, fmt.Errorf("--file cannot be combined with --content")
+	}
+
+	contents := make([][]byte, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		if len(data) == 0 {
+			return nil, fmt.Errorf("%s is empty - nothing to upload", path)
+		}
+		contents = append(contents, data)
+	}
+	return contents, nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/cli"
+)
+
+func TestReadInput(t *testing.T) {
+	tests := []struct {
+		name       string
+		stdin      string
+		piped      bool
+		content    string
+		contentSet bool
+		want       string
+		wantErr    string
+	}{
+		{name: "stdin", stdin: "from pipe\n", piped: true, want: "from pipe\n"},
+		{name: "content flag", content: "quick note", contentSet: true, want: "quick note"},
+		{name: "content flag keeps whitespace", content: "  line 1\n\tline 2\n", contentSet: true, want: "  line 1\n\tline 2\n"},
+		{name: "content wins over stdin", stdin: "x", piped: true, content: "y", contentSet: true, want: "y"},
+		{name: "empty content flag", contentSet: true, wantErr: "empty input"},
+		{name: "empty stdin", piped: true, wantErr: "empty input"},
+		{name: "no input", wantErr: "no input provided"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readInput(strings.NewReader(tt.stdin), tt.piped, tt.content, tt.contentSet)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestReadInput_ShortRead(t *testing.T) {
+	upstream := errors.New("upstream died")
+	stdin := io.MultiReader(strings.NewReader("first half"), iotest.ErrReader(upstream))
+
+	data, err := readInput(stdin, true, "", false)
+
+	var partial *partialReadError
+	require.ErrorAs(t, err, &partial)
+	assert.ErrorIs(t, err, upstream)
+	assert.Contains(t, err.Error(), "after 10 bytes")
+	assert.Contains(t, err.Error(), "nothing was uploaded")
+	assert.Equal(t, "first half", string(data), "the data read so far is kept for --allow-partial")
+
+	// Failing before any data arrived is a plain read error
+	data, err = readInput(iotest.ErrReader(upstream), true, "", false)
+	assert.ErrorIs(t, err, upstream)
+	assert.False(t, errors.As(err, &partial))
+	assert.Nil(t, data)
+}
+
+func TestReadInput_UploadsContentVerbatim(t *testing.T) {
+	var gotBody, gotExpiry string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		gotExpiry = r.URL.Query().Get("expiry")
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"abc","url":"https://tafcha.dev/abc","expires_at":"2026-01-31T22:39:46Z"}`))
+	}))
+	defer srv.Close()
+
+	note := "quick note with \"quotes\", $vars and ünïcode\n"
+	data, err := readInput(strings.NewReader(""), false, note, true)
+	require.NoError(t, err)
+
+	_, err = cli.NewClient(srv.URL, 5*time.Second).Create(data, cli.CreateOptions{Expiry: "1h"})
+	require.NoError(t, err)
+
+	assert.Equal(t, note, gotBody)
+	assert.Equal(t, "1h", gotExpiry)
+}
+
+func TestReadFiles(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.txt")
+	second := filepath.Join(dir, "second.txt")
+	empty := filepath.Join(dir, "empty.txt")
+	missing := filepath.Join(dir, "missing.txt")
+	require.NoError(t, os.WriteFile(first, []byte("one\n"), 0o644))
+	require.NoError(t, os.WriteFile(second, []byte("two\n"), 0o644))
+	require.NoError(t, os.WriteFile(empty, nil, 0o644))
+
+	got, err := readFiles([]string{second, first}, false)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, "two\n", string(got[0]))
+	assert.Equal(t, "one\n", string(got[1]))
+
+	_, err = readFiles([]string{first, missing}, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), missing)
+
+	_, err = readFiles([]string{empty}, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), empty)
+
+	_, err = readFiles([]string{first}, true)
+	assert.ErrorContains(t, err, "--content")
+}
@@ -2,9 +2,14 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -14,16 +19,43 @@ import (
 
 var (
 	// Flags
-	apiURL  string
-	expiry  string
-	timeout time.Duration
-	quiet   bool
+	apiURL      string
+	apiKey      string
+	expiry      string
+	expireAt    string
+	timeout     time.Duration
+	quiet       bool
+	verbose     bool
+	asJSON      bool
+	burn        bool
+	customID    string
+	encrypt     bool
+	passphrase  bool
+	openBrowser bool
+	detectType  bool
+	confirmOver int64
+	assumeYes   bool
+	showQR      bool
+	frontmatter bool
+	content     string
+	partialOK   bool
+	files       []string
+	urlFile     string
 
 	// Version info (set via ldflags)
 	version = "dev"
 )
 
 func main() {
+	// Writes to a closed stdout fail with EPIPE, see stdoutWriter
+	signal.Ignore(syscall.SIGPIPE)
+	stdout := stdoutWriter{w: os.Stdout}
+
+	settings := cli.LoadSettings(os.Getenv)
+	if err := settings.ConfigError(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: ignoring %v\n", err)
+	}
+
 	rootCmd := &cobra.Command{
 		Use:   "tafcha",
 		Short: "Pipe text to get a shareable URL",
@@ -35,61 +67,251 @@ The URL returns the exact plain text when accessed.
 Examples:
   echo "hello world" | tafcha
   cat file.txt | tafcha --expiry 1d
-  tafcha < script.sh --expiry 1w`,
-		RunE:          run,
+  tafcha < script.sh --expiry 1w
+  tafcha -C "quick note" --expiry 1h
+  tafcha --id my-release-notes < NOTES.md
+  tafcha --file notes.txt --file todo.md
+  tafcha --frontmatter --file notes.md
+  tafcha --encrypt < secrets.txt
+  TAFCHA_PASSPHRASE=... tafcha --passphrase < secrets.txt`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd, stdout)
+		},
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		Version:       version,
 	}
+	rootCmd.SetOut(stdout)
 
 	// Flags
-	rootCmd.Flags().StringVarP(&apiURL, "api", "a", "https://tafcha.dev", "API server URL")
-	rootCmd.Flags().StringVarP(&expiry, "expiry", "e", "", "Expiry duration (e.g., 10m, 12h, 3d, 1w)")
-	rootCmd.Flags().DurationVarP(&timeout, "timeout", "t", 30*time.Second, "Request timeout")
-	rootCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Only output the URL (no extra info)")
+	rootCmd.Flags().StringVarP(&apiURL, "api", "a", settings.APIURL, "API server URL")
+	rootCmd.Flags().StringVar(&apiKey, "api-key", settings.APIKey, "API key for servers that require one (X-API-Key)")
+	rootCmd.Flags().StringVarP(&expiry, "expiry", "e", settings.Expiry, "Expiry duration (e.g., 30s, 10m, 12h, 3d, 1w, 1M, 1d12h; m is minutes, M months)")
+	rootCmd.Flags().StringVar(&expireAt, "expire-at", "", "Expire at this RFC 3339 time (e.g., 2025-06-01T00:00:00Z) instead of after --expiry")
+	rootCmd.Flags().DurationVarP(&timeout, "timeout", "t", settings.Timeout, "Request timeout")
+	rootCmd.Flags().BoolVarP(&quiet, "quiet", "q", settings.Quiet, "Only output the URL (no extra info)")
+	rootCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Also print the delete URL")
+	rootCmd.Flags().BoolVar(&asJSON, "json", false, "Output the full result as JSON")
+	rootCmd.Flags().BoolVar(&burn, "burn", false, "Delete the snippet after it is viewed once")
+	rootCmd.Flags().StringVar(&customID, "id", "", "Use this ID instead of a generated one (3-64 of a-z, A-Z, 0-9, - and _)")
+	rootCmd.Flags().BoolVar(&encrypt, "encrypt", false, "Encrypt locally; the key is kept in the URL fragment")
+	rootCmd.Flags().StringVarP(&content, "content", "C", "", "Upload this text instead of reading stdin")
+	rootCmd.Flags().BoolVar(&partialOK, "allow-partial", false, "Upload what was read if reading stdin fails partway, instead of nothing")
+	rootCmd.Flags().StringArrayVarP(&files, "file", "f", nil, "Upload this file; repeat for one snippet per file")
+	rootCmd.Flags().BoolVar(&passphrase, "passphrase", false, "Encrypt with a key derived from $"+passphraseEnv)
+	rootCmd.Flags().BoolVar(&detectType, "detect-type", false, "Send a Content-Type guessed from the content instead of text/plain")
+	rootCmd.Flags().Int64Var(&confirmOver, "confirm-over", 0, "Ask before uploading more than this many bytes (0 never asks)")
+	rootCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Do not ask for confirmation")
+	rootCmd.Flags().BoolVar(&showQR, "qr", false, "Draw a QR code of the URL on stderr")
+	rootCmd.Flags().BoolVar(&frontmatter, "frontmatter", false, "Read and strip leading \"# tafcha-expiry: 1d\" / \"# tafcha-title: ...\" lines")
+	rootCmd.Flags().BoolVar(&openBrowser, "open", false, "Open the new snippet in the default browser")
+	rootCmd.Flags().StringVar(&urlFile, "output-url-file", "", "Also write the output to this file")
+
+	// Subcommands
+	rootCmd.AddCommand(newConfigCmd(settings))
+	rootCmd.AddCommand(newGetCmd(settings))
+	rootCmd.AddCommand(newDeleteCmd(settings))
+	rootCmd.AddCommand(newExtendCmd(settings))
+	rootCmd.AddCommand(newSignCmd(settings))
+	rootCmd.AddCommand(newHistoryCmd())
+	rootCmd.AddCommand(newOpenCmd(settings))
+	rootCmd.AddCommand(newStreamCmd(settings))
+	rootCmd.AddCommand(newServerCmd())
 
 	if err := rootCmd.Execute(); err != nil {
+		// Whoever reads our output stopped reading; nothing to report
+		if errors.Is(err, errStdoutClosed) {
+			os.Exit(0)
+		}
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func run(cmd *cobra.Command, args []string) error {
-	// Check if stdin has data (is a pipe)
-	stat, err := os.Stdin.Stat()
-	if err != nil {
-		return fmt.Errorf("checking stdin: %w", err)
+func run(cmd *cobra.Command, stdout io.Writer) error {
+	var deadline time.Time
+	if expireAt != "" {
+		if cmd.Flags().Changed("expiry") {
+			return errors.New("--expiry and --expire-at cannot be combined")
+		}
+		var err error
+		if deadline, err = parseExpireAt(expireAt, time.Now()); err != nil {
+			return err
+		}
 	}
 
-	if (stat.Mode() & os.ModeCharDevice) != 0 {
-		// stdin is a terminal, not a pipe
-		return fmt.Errorf("no input provided - pipe text to tafcha\n\nExample: echo \"hello\" | tafcha")
+	piped, err := stdinPiped()
+	if err != nil {
+		return err
 	}
 
-	// Read all input from stdin
-	content, err := io.ReadAll(os.Stdin)
+	var inputs [][]byte
+	if len(files) > 0 {
+		inputs, err = readFiles(files, cmd.Flags().Changed("content"))
+	} else {
+		var data []byte
+		data, err = readInput(os.Stdin, piped, content, cmd.Flags().Changed("content"))
+		var partial *partialReadError
+		if partialOK && errors.As(err, &partial) {
+			fmt.Fprintf(os.Stderr, "warning: %v; uploading the %d bytes read so far\n", partial.err, len(data))
+			err = nil
+		}
+		inputs = [][]byte{data}
+	}
 	if err != nil {
-		return fmt.Errorf("reading stdin: %w", err)
+		return err
 	}
 
-	if len(content) == 0 {
-		return fmt.Errorf("empty input - nothing to upload")
+	// Directives are stripped before anything is measured or uploaded
+	metas := make([]cli.Frontmatter, len(inputs))
+	if frontmatter {
+		for i, data := range inputs {
+			metas[i], inputs[i], err = cli.ParseFrontmatter(data)
+			if err != nil {
+				if len(files) > 0 {
+					return fmt.Errorf("%s: %w", files[i], err)
+				}
+				return err
+			}
+		}
 	}
 
-	// Create client and upload
+	var total int64
+	for _, data := range inputs {
+		total += int64(len(data))
+	}
+	if needsConfirmation(total, confirmOver, assumeYes, !piped && isTerminal(os.Stdout)) {
+		ok, err := confirmUpload(os.Stdin, os.Stderr, total)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errUploadCancelled
+		}
+	}
+
+	// A custom ID names a single snippet
+	if customID != "" && len(inputs) > 1 {
+		return errors.New("--id cannot be used with more than one --file")
+	}
+
+	// Create client and upload, one snippet per input
 	client := cli.NewClient(apiURL, timeout)
-	resp, err := client.Create(content, expiry)
+	client.SetAPIKey(apiKey)
+	opts := cli.CreateOptions{Expiry: expiry, ExpireAt: deadline, Burn: burn, ID: customID, Encrypt: encrypt}
+	if passphrase {
+		if opts.Passphrase = os.Getenv(passphraseEnv); opts.Passphrase == "" {
+			return fmt.Errorf("--passphrase needs %s to be set", passphraseEnv)
+		}
+	}
+
+	var resps []*cli.CreateResponse
+	for i, data := range inputs {
+		if detectType {
+			opts.ContentType = cli.DetectContentType(data)
+		}
+		// An explicit --expiry or --expire-at wins over the file's own expiry
+		opts.Expiry = expiry
+		if metas[i].Expiry != "" && !cmd.Flags().Changed("expiry") {
+			opts.Expiry = metas[i].Expiry
+		}
+		if !deadline.IsZero() {
+			opts.Expiry = ""
+		}
+		resp, createErr := client.Create(data, opts)
+		if createErr != nil {
+			err = createErr
+			if len(files) > 0 {
+				err = fmt.Errorf("uploading %s: %w", files[i], createErr)
+			}
+			break
+		}
+		resps = append(resps, resp)
+
+		// History is best-effort and never fails the upload
+		if path, histErr := cli.HistoryPath(os.Getenv); histErr == nil {
+			histErr = recordHistory(path, resp, metas[i].Title, len(data), time.Now())
+			if histErr != nil {
+				fmt.Fprintf(os.Stderr, "warning: could not save history: %v\n", histErr)
+			}
+		}
+	}
+
+	// Output whatever was created, even if a later upload failed
+	if len(resps) > 0 {
+		if writeErr := writeResult(stdout, os.Stderr, resps, urlFile, quiet, asJSON, verbose); writeErr != nil && err == nil {
+			err = writeErr
+		}
+	}
+
+	// QR codes go to stderr so a piped URL stays clean
+	if showQR && !quiet {
+		for _, resp := range resps {
+			if qrErr := writeQR(os.Stderr, resp.URL); qrErr != nil {
+				fmt.Fprintf(os.Stderr, "could not draw QR code: %v\n", qrErr)
+			}
+		}
+	}
+
+	// The URLs are already on stdout, so a missing browser is not an error
+	if openBrowser {
+		browser := cli.NewBrowser()
+		for _, resp := range resps {
+			if openErr := browser.Open(resp.URL); openErr != nil && !quiet {
+				fmt.Fprintf(os.Stderr, "could not open browser: %v\n", openErr)
+			}
+		}
+	}
+	return err
+}
+
+// passphraseEnv holds the passphrase for --passphrase and for reading
+// passphrase-protected links, so it never appears in the process list.
+const passphraseEnv = "TAFCHA_PASSPHRASE"
+
+// parseExpireAt parses the --expire-at timestamp, which must be RFC 3339
+// and after now.
+func parseExpireAt(s string, now time.Time) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
 	if err != nil {
-		return err
+		return time.Time{}, fmt.Errorf("--expire-at must be an RFC 3339 time such as 2025-06-01T00:00:00Z: %q", s)
+	}
+	if !t.After(now) {
+		return time.Time{}, fmt.Errorf("--expire-at %s is in the past", s)
 	}
+	return t, nil
+}
 
-	// Output result
-	if quiet {
-		fmt.Println(resp.URL)
-	} else {
-		fmt.Printf("%s\n", resp.URL)
-		fmt.Fprintf(os.Stderr, "Expires: %s\n", resp.ExpiresAt.Local().Format("2006-01-02 15:04:05"))
+// errUploadCancelled is returned when the size confirmation is declined.
+var errUploadCancelled = errors.New("upload cancelled")
+
+// needsConfirmation reports whether to ask before uploading size bytes.
+// Only interactive sessions are asked, and --yes or a zero threshold skips
+// the question.
+func needsConfirmation(size, threshold int64, yes, interactive bool) bool {
+	return threshold > 0 && size > threshold && !yes && interactive
+}
+
+// confirmUpload asks on out whether to upload size bytes and reads the
+// answer from in. Anything but y or yes declines.
+func confirmUpload(in io.Reader, out io.Writer, size int64) (bool, error) {
+	fmt.Fprintf(out, "Upload %d bytes? [y/N] ", size)
+
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("reading answer: %w", err)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
 	}
+}
 
-	return nil
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
 }
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNeedsConfirmation(t *testing.T) {
+	tests := []struct {
+		name        string
+		size        int64
+		threshold   int64
+		yes         bool
+		interactive bool
+		want        bool
+	}{
+		{name: "over threshold", size: 2048, threshold: 1024, interactive: true, want: true},
+		{name: "at threshold", size: 1024, threshold: 1024, interactive: true},
+		{name: "under threshold", size: 10, threshold: 1024, interactive: true},
+		{name: "disabled", size: 1 << 30, threshold: 0, interactive: true},
+		{name: "yes flag", size: 2048, threshold: 1024, yes: true, interactive: true},
+		{name: "non-interactive", size: 2048, threshold: 1024},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, needsConfirmation(tt.size, tt.threshold, tt.yes, tt.interactive))
+		})
+	}
+}
+
+func TestConfirmUpload(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{input: "y\n", want: true},
+		{input: "YES\n", want: true},
+		{input: " y \r\n", want: true},
+		{input: "n\n"},
+		{input: "\n"},
+		{input: "yep\n"},
+		{input: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var prompt bytes.Buffer
+			got, err := confirmUpload(strings.NewReader(tt.input), &prompt, 2048)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, "Upload 2048 bytes? [y/N] ", prompt.String())
+		})
+	}
+}
+
+func TestParseExpireAt(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	got, err := parseExpireAt("2025-06-01T00:00:00Z", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), got)
+
+	got, err = parseExpireAt("2025-05-01T14:00:00+02:00", now.Add(-time.Minute))
+	require.NoError(t, err)
+	assert.True(t, got.Equal(now))
+
+	for _, s := range []string{"2025-04-30T00:00:00Z", "2025-05-01T12:00:00Z", "2025-06-01", "tomorrow"} {
+		_, err := parseExpireAt(s, now)
+		assert.Error(t, err, s)
+	}
+}
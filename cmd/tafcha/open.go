@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/cli"
+)
+
+func newOpenCmd(settings *cli.Settings) *cobra.Command {
+	var openAPI string
+
+	cmd := &cobra.Command{
+		Use:   "open <id-or-url>",
+		Short: "Open a snippet in the default browser",
+		Long: `Open a snippet in the default browser.
+
+When no browser opener is available the URL is printed instead.
+
+Examples:
+  tafcha open abc123XYZ789
+  tafcha open https://tafcha.dev/abc123XYZ789`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			target, err := snippetPageURL(args[0], openAPI, cmd.Flags().Changed("api"))
+			if err != nil {
+				return err
+			}
+			return runOpen(cmd.OutOrStdout(), cli.NewBrowser(), target)
+		},
+	}
+
+	cmd.Flags().StringVarP(&openAPI, "api", "a", settings.APIURL, "API server URL")
+
+	return cmd
+}
+
+// snippetPageURL resolves a bare ID or snippet URL to the URL to open. A
+// full URL is kept as given, fragment included, unless --api overrides the
+// server.
+func snippetPageURL(ref, apiURL string, apiSet bool) (string, error) {
+	ref = strings.TrimSpace(ref)
+	withoutFragment, fragment, _ := strings.Cut(ref, "#")
+
+	base, snippetID, err := parseSnippetRef(withoutFragment)
+	if err != nil {
+		return "", err
+	}
+	if base != "" && !apiSet {
+		return ref, nil
+	}
+
+	target := strings.TrimSuffix(apiURL, "/") + "/" + snippetID
+	if fragment != "" {
+		target += "#" + fragment
+	}
+	return target, nil
+}
+
+// runOpen opens target in the browser, or prints it to w when there is no
+// browser to open it with.
+func runOpen(w io.Writer, browser *cli.Browser, target string) error {
+	err := browser.Open(target)
+	if errors.Is(err, cli.ErrNoBrowser) {
+		fmt.Fprintln(w, target)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("opening browser: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/cli"
+)
+
+func TestSnippetPageURL(t *testing.T) {
+	tests := []struct {
+		ref    string
+		apiSet bool
+		want   string
+	}{
+		{ref: "abc123XYZ789", want: "http://localhost:8080/abc123XYZ789"},
+		{ref: "https://tafcha.dev/abc123XYZ789", want: "https://tafcha.dev/abc123XYZ789"},
+		{ref: "https://tafcha.dev/abc123XYZ789#key", want: "https://tafcha.dev/abc123XYZ789#key"},
+		{ref: "https://tafcha.dev/abc123XYZ789#key", apiSet: true, want: "http://localhost:8080/abc123XYZ789#key"},
+	}
+
+	for _, tt := range tests {
+		got, err := snippetPageURL(tt.ref, "http://localhost:8080/", tt.apiSet)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+	}
+
+	_, err := snippetPageURL("no.pe", "http://localhost:8080", false)
+	assert.Error(t, err)
+}
+
+func TestRunOpen(t *testing.T) {
+	var opened []string
+	browser := &cli.Browser{
+		GOOS:     "linux",
+		LookPath: func(string) (string, error) { return "/usr/bin/xdg-open", nil },
+		Run: func(name string, args ...string) error {
+			opened = append(opened, args...)
+			return nil
+		},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, runOpen(&out, browser, "https://tafcha.dev/abc123XYZ789"))
+	assert.Equal(t, []string{"https://tafcha.dev/abc123XYZ789"}, opened)
+	assert.Empty(t, out.String())
+
+	// Without an opener the URL is printed and the command still succeeds
+	browser.LookPath = func(string) (string, error) { return "", errors.New("not found") }
+	require.NoError(t, runOpen(&out, browser, "https://tafcha.dev/abc123XYZ789"))
+	assert.Equal(t, "https://tafcha.dev/abc123XYZ789\n", out.String())
+}
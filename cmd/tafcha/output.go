@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	qrcode "github.com/skip2/go-qrcode"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/cli"
+)
+
+// formatResult returns what goes to stdout for created snippets: one URL per
+// line, or with asJSON the response object (an array for several snippets).
+func formatResult(resps []*cli.CreateResponse, asJSON bool) ([]byte, error) {
+	if asJSON {
+		var v any = resps
+		if len(resps) == 1 {
+			v = resps[0]
+		}
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("encoding result: %w", err)
+		}
+		return append(data, '\n'), nil
+	}
+
+	var out []byte
+	for _, resp := range resps {
+		out = append(out, resp.URL+"\n"...)
+	}
+	return out, nil
+}
+
+// writeResult prints the results to stdout and, when path is set, also
+// writes them to that file. Without quiet or JSON output the expiry and
+// tokens go to stderr, plus the delete URL when verbose.
+func writeResult(stdout, stderr io.Writer, resps []*cli.CreateResponse, path string, quiet, asJSON, verbose bool) error {
+	out, err := formatResult(resps, asJSON)
+	if err != nil {
+		return err
+	}
+
+	// Print first so the URL is not lost if the file cannot be written. A
+	// closed stdout still gets the file written and is reported last.
+	_, stdoutErr := stdout.Write(out)
+	if !quiet && !asJSON {
+		for i, resp := range resps {
+			if len(resps) > 1 {
+				if i > 0 {
+					fmt.Fprintln(stderr)
+				}
+				fmt.Fprintf(stderr, "Snippet: %s\n", resp.ID)
+			}
+			expires := resp.LocalExpiry()
+			fmt.Fprintf(stderr, "Expires: %s (in %s)\n", expires.Local().Format("2006-01-02 15:04:05"), formatCountdown(time.Until(expires)))
+			if resp.DeleteToken != "" {
+				fmt.Fprintf(stderr, "Delete token: %s\n", resp.DeleteToken)
+			}
+			if verbose && resp.DeleteURL != "" {
+				fmt.Fprintf(stderr, "Delete URL: %s\n", resp.DeleteURL)
+			}
+			if resp.RemainingViews != nil {
+				fmt.Fprintf(stderr, "Views left: %d\n", *resp.RemainingViews)
+			}
+		}
+	}
+
+	if path != "" {
+		if err := writeFileAtomic(path, out); err != nil {
+			return fmt.Errorf("writing %s: %w", path, err)
+		}
+	}
+	return stdoutErr
+}
+
+// formatCountdown renders the time left until expiry in at most two units,
+// e.g. "2d 23h" or "45m".
+func formatCountdown(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days, hours, minutes := d/(24*time.Hour), d%(24*time.Hour)/time.Hour, d%time.Hour/time.Minute
+	switch {
+	case d < time.Minute:
+		return "under a minute"
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
+
+// writeFileAtomic writes data to a temporary file in the target directory
+// and renames it into place, so readers never see a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// writeQR renders url as a QR code of Unicode half blocks, two modules per
+// character cell, so it fits in a terminal.
+func writeQR(w io.Writer, url string) error {
+	qr, err := qrcode.New(url, qrcode.Medium)
+	if err != nil {
+		return fmt.Errorf("encoding QR code: %w", err)
+	}
+	_, err = io.WriteString(w, qr.ToSmallString(false))
+	return err
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/cli"
+)
+
+func testResult() *cli.CreateResponse {
+	return &cli.CreateResponse{
+		ID:        "abc123XYZ789",
+		URL:       "https://tafcha.dev/abc123XYZ789",
+		ShortCode: "abc123XYZ789",
+		RawURL:    "https://tafcha.dev/abc123XYZ789?raw",
+		ExpiresAt: time.Date(2026, 1, 31, 22, 39, 46, 0, time.UTC),
+	}
+}
+
+func TestWriteResult_URLFile(t *testing.T) {
+	tests := []struct {
+		name       string
+		quiet      bool
+		asJSON     bool
+		wantStderr bool
+	}{
+		{name: "default", wantStderr: true},
+		{name: "quiet", quiet: true},
+		{name: "json", asJSON: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "url.txt")
+			var stdout, stderr bytes.Buffer
+
+			require.NoError(t, writeResult(&stdout, &stderr, []*cli.CreateResponse{testResult()}, path, tt.quiet, tt.asJSON, false))
+
+			got, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, stdout.String(), string(got), "file mirrors stdout")
+			assert.Equal(t, tt.wantStderr, stderr.Len() > 0)
+
+			if tt.asJSON {
+				var decoded cli.CreateResponse
+				require.NoError(t, json.Unmarshal(got, &decoded))
+				assert.Equal(t, *testResult(), decoded)
+			} else {
+				assert.Equal(t, "https://tafcha.dev/abc123XYZ789\n", string(got))
+			}
+		})
+	}
+}
+
+func TestWriteResult_ReplacesExistingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "url.txt")
+	require.NoError(t, os.WriteFile(path, []byte("stale contents that are longer\n"), 0o644))
+
+	require.NoError(t, writeResult(&bytes.Buffer{}, &bytes.Buffer{}, []*cli.CreateResponse{testResult()}, path, true, false, false))
+
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "https://tafcha.dev/abc123XYZ789\n", string(got))
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestWriteResult_UnwritableFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "url.txt")
+	var stdout bytes.Buffer
+
+	err := writeResult(&stdout, &bytes.Buffer{}, []*cli.CreateResponse{testResult()}, path, true, false, false)
+	require.Error(t, err)
+	assert.Equal(t, "https://tafcha.dev/abc123XYZ789\n", stdout.String(), "URL is still printed")
+}
+
+func TestWriteResult_ClosedStdout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "url.txt")
+
+	err := writeResult(errWriter{errStdoutClosed}, &bytes.Buffer{}, []*cli.CreateResponse{testResult()}, path, true, false, false)
+	assert.ErrorIs(t, err, errStdoutClosed)
+
+	// The URL is still saved where it was asked for
+	got, readErr := os.ReadFile(path)
+	require.NoError(t, readErr)
+	assert.Equal(t, "https://tafcha.dev/abc123XYZ789\n", string(got))
+}
+
+func TestWriteResult_Multiple(t *testing.T) {
+	second := testResult()
+	second.ID = "def456UVW012"
+	second.URL = "https://tafcha.dev/def456UVW012"
+	resps := []*cli.CreateResponse{testResult(), second}
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, writeResult(&stdout, &stderr, resps, "", false, false, false))
+	assert.Equal(t, "https://tafcha.dev/abc123XYZ789\nhttps://tafcha.dev/def456UVW012\n", stdout.String())
+	assert.Contains(t, stderr.String(), "Snippet: def456UVW012")
+
+	stdout.Reset()
+	stderr.Reset()
+	require.NoError(t, writeResult(&stdout, &stderr, resps, "", true, false, false))
+	assert.Equal(t, "https://tafcha.dev/abc123XYZ789\nhttps://tafcha.dev/def456UVW012\n", stdout.String())
+	assert.Empty(t, stderr.String())
+
+	stdout.Reset()
+	require.NoError(t, writeResult(&stdout, &stderr, resps, "", false, true, false))
+	var decoded []cli.CreateResponse
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &decoded))
+	require.Len(t, decoded, 2)
+	assert.Equal(t, "def456UVW012", decoded[1].ID)
+}
+
+func TestWriteResult_VerboseDeleteURL(t *testing.T) {
+	resp := testResult()
+	resp.DeleteToken = "secret"
+	resp.DeleteURL = resp.URL + "?token=secret"
+
+	var stderr bytes.Buffer
+	require.NoError(t, writeResult(&bytes.Buffer{}, &stderr, []*cli.CreateResponse{resp}, "", false, false, false))
+	assert.NotContains(t, stderr.String(), "Delete URL")
+
+	stderr.Reset()
+	require.NoError(t, writeResult(&bytes.Buffer{}, &stderr, []*cli.CreateResponse{resp}, "", false, false, true))
+	assert.Contains(t, stderr.String(), "Delete URL: https://tafcha.dev/abc123XYZ789?token=secret\n")
+
+	stderr.Reset()
+	require.NoError(t, writeResult(&bytes.Buffer{}, &stderr, []*cli.CreateResponse{resp}, "", true, false, true))
+	assert.Empty(t, stderr.String(), "quiet wins over verbose")
+}
+
+func TestFormatCountdown(t *testing.T) {
+	assert.Equal(t, "2d 23h", formatCountdown(71*time.Hour+20*time.Minute))
+	assert.Equal(t, "3d 0h", formatCountdown(72*time.Hour-10*time.Second))
+	assert.Equal(t, "5h 12m", formatCountdown(5*time.Hour+12*time.Minute))
+	assert.Equal(t, "45m", formatCountdown(45*time.Minute))
+	assert.Equal(t, "under a minute", formatCountdown(20*time.Second))
+}
+
+func TestWriteQR(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, writeQR(&out, "https://tafcha.dev/abc123XYZ789"))
+
+	lines := strings.Split(strings.TrimRight(out.String(), "\n"), "\n")
+	require.Greater(t, len(lines), 10)
+	assert.Contains(t, out.String(), "█")
+	assert.NotContains(t, out.String(), "https://", "only the code is drawn")
+}
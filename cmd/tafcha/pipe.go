@@ -0,0 +1,26 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"syscall"
+)
+
+// errStdoutClosed is returned for writes to a stdout whose reader went
+// away, e.g. when piped into head. It is not worth an error message.
+var errStdoutClosed = errors.New("stdout closed")
+
+// stdoutWriter reports writes to a closed pipe as errStdoutClosed. SIGPIPE
+// is ignored in main so such writes fail with EPIPE instead of killing the
+// process; wrapping only stdout keeps a broken network connection an error.
+type stdoutWriter struct {
+	w io.Writer
+}
+
+func (s stdoutWriter) Write(p []byte) (int, error) {
+	n, err := s.w.Write(p)
+	if errors.Is(err, syscall.EPIPE) {
+		err = errStdoutClosed
+	}
+	return n, err
+}
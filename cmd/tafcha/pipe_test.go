@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStdoutWriter_ClosedPipe(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	require.NoError(t, err)
+	defer pw.Close()
+
+	out := stdoutWriter{w: pw}
+	_, err = out.Write([]byte("still open\n"))
+	require.NoError(t, err)
+
+	// The reader goes away, like head after its first lines
+	pr.Close()
+	_, err = out.Write([]byte("https://tafcha.dev/abc123XYZ789\n"))
+	assert.ErrorIs(t, err, errStdoutClosed)
+}
+
+func TestStdoutWriter_OtherErrors(t *testing.T) {
+	failing := errors.New("disk full")
+	_, err := stdoutWriter{w: errWriter{failing}}.Write([]byte("x"))
+	assert.ErrorIs(t, err, failing)
+	assert.NotErrorIs(t, err, errStdoutClosed)
+}
+
+type errWriter struct{ err error }
+
+func (w errWriter) Write([]byte) (int, error) { return 0, w.err }
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/config"
+	"github.com/rayenfassatoui/tafcha-cli/internal/server"
+)
+
+func newServerCmd() *cobra.Command {
+	var addr string
+
+	cmd := &cobra.Command{
+		Use:   "server",
+		Short: "Run the Tafcha API server",
+		Long: `Run the Tafcha API server, the same as the tafcha-server binary.
+
+It is configured with the same environment variables, DATABASE_URL, PORT,
+BASE_URL and so on; --addr overrides HOST and PORT. The server stops
+gracefully on Ctrl-C or SIGTERM.
+
+Examples:
+  DATABASE_URL=memory:// tafcha server --addr 127.0.0.1:8080
+  DATABASE_URL=sqlite://tafcha.db tafcha server`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("addr") {
+				if err := setAddr(cfg, addr); err != nil {
+					return err
+				}
+			}
+
+			logger := slog.New(slog.NewTextHandler(cmd.ErrOrStderr(), nil))
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return server.Run(ctx, cfg, logger)
+		},
+	}
+
+	cmd.Flags().StringVar(&addr, "addr", "", "Listen address as host:port, overriding HOST and PORT")
+
+	return cmd
+}
+
+// setAddr points cfg at a host:port listen address. An empty host listens
+// on all interfaces.
+func setAddr(cfg *config.Config, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid --addr %q: %w", addr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("invalid --addr %q: port must be a number from 1 to 65535", addr)
+	}
+	cfg.Host, cfg.Port = host, port
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/config"
+)
+
+func TestSetAddr(t *testing.T) {
+	cfg := &config.Config{Host: "0.0.0.0", Port: 8080}
+
+	require.NoError(t, setAddr(cfg, "127.0.0.1:9000"))
+	assert.Equal(t, "127.0.0.1", cfg.Host)
+	assert.Equal(t, 9000, cfg.Port)
+
+	require.NoError(t, setAddr(cfg, ":3000"))
+	assert.Equal(t, ":3000", cfg.Addr())
+
+	require.NoError(t, setAddr(cfg, "[::1]:3000"))
+	assert.Equal(t, "[::1]:3000", cfg.Addr())
+
+	for _, bad := range []string{"8080", "localhost", "localhost:http", "localhost:0", "localhost:70000"} {
+		assert.Error(t, setAddr(cfg, bad), bad)
+	}
+}
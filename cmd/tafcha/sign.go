@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/cli"
+)
+
+func newSignCmd(settings *cli.Settings) *cobra.Command {
+	var (
+		signAPI string
+		secret  string
+		ttl     time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "sign <id-or-url>",
+		Short: "Print a time-limited link to a snippet",
+		Long: `Print a signed link to a snippet that stops working after --ttl.
+
+The secret must match the server's URL_SIGNING_KEY. It defaults to
+TAFCHA_SIGNING_KEY. No network calls are made.
+
+Examples:
+  tafcha sign abc123XYZ789 --ttl 5m`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if secret == "" {
+				return fmt.Errorf("no signing secret - pass --secret or set TAFCHA_SIGNING_KEY")
+			}
+			if ttl <= 0 {
+				return fmt.Errorf("--ttl must be positive")
+			}
+
+			base, snippetID, err := parseSnippetRef(args[0])
+			if err != nil {
+				return err
+			}
+			if base == "" || cmd.Flags().Changed("api") {
+				base = signAPI
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout(), cli.SignURL(base, snippetID, secret, time.Now().Add(ttl)))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&signAPI, "api", "a", settings.APIURL, "API server URL")
+	cmd.Flags().StringVar(&secret, "secret", settings.SigningKey, "Shared signing secret")
+	cmd.Flags().DurationVar(&ttl, "ttl", 5*time.Minute, "How long the link stays valid")
+
+	return cmd
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/cli"
+)
+
+func newStreamCmd(settings *cli.Settings) *cobra.Command {
+	var (
+		streamAPI     string
+		streamAPIKey  string
+		streamExpiry  string
+		streamTimeout time.Duration
+		opts          cli.StreamOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "stream",
+		Short: "Share stdin live as it is written",
+		Long: `Share stdin live as it is written.
+
+The snippet is created as soon as the first complete line arrives and its
+URL is printed right away. Later lines are appended every --interval, or
+sooner once --flush-bytes are waiting, until stdin is closed. A line still
+being written is held back until its newline. If the server is slow or
+rate limits the stream, reading pauses so the writer is slowed down.
+
+Examples:
+  make test 2>&1 | tafcha stream
+  tail -f app.log | tafcha stream --interval 10s --expiry 1h`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Expiry = streamExpiry
+			client := cli.NewClient(streamAPI, streamTimeout)
+			client.SetAPIKey(streamAPIKey)
+			return client.Stream(os.Stdin, opts, func(resp *cli.CreateResponse) {
+				fmt.Fprintln(cmd.OutOrStdout(), resp.URL)
+			})
+		},
+	}
+
+	cmd.Flags().StringVarP(&streamAPI, "api", "a", settings.APIURL, "API server URL")
+	cmd.Flags().StringVar(&streamAPIKey, "api-key", settings.APIKey, "API key for servers that require one (X-API-Key)")
+	cmd.Flags().StringVarP(&streamExpiry, "expiry", "e", settings.Expiry, "Expiry duration (e.g., 30s, 10m, 12h, 3d, 1w, 1M, 1d12h; m is minutes, M months)")
+	cmd.Flags().DurationVarP(&streamTimeout, "timeout", "t", settings.Timeout, "Timeout for each request")
+	cmd.Flags().DurationVar(&opts.Interval, "interval", cli.DefaultStreamInterval, "How often new lines are sent")
+	cmd.Flags().IntVar(&opts.FlushBytes, "flush-bytes", cli.DefaultStreamFlushBytes, "Send early once this many bytes are waiting")
+
+	return cmd
+}
@@ -0,0 +1,331 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/id"
+	"github.com/rayenfassatoui/tafcha-cli/internal/storage"
+)
+
+// DeleteByCreatorResponse is the response for admin bulk deletion.
+type DeleteByCreatorResponse struct {
+	Deleted int64 `json:"deleted"`
+}
+
+// ImportSnippet is a single snippet in an import request.
+// Content is base64-encoded so arbitrary bytes survive the round trip.
+type ImportSnippet struct {
+	ID        string    `json:"id"`
+	Content   []byte    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ImportRequest is the body of POST /admin/import.
+type ImportRequest struct {
+	Snippets []ImportSnippet `json:"snippets"`
+}
+
+// ImportResponse is the response for a successful import.
+type ImportResponse struct {
+	Imported int `json:"imported"`
+}
+
+// PutSnippetResponse is the response for PUT /admin/snippets/{id}.
+type PutSnippetResponse struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	SizeBytes int       `json:"size_bytes"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Replaced  bool      `json:"replaced"`
+}
+
+// adminAuth requires the configured admin bearer token.
+// Admin routes are unusable when no token is configured.
+func (s *Server) adminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.isAdmin(r) {
+			unauthorized(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isAdmin reports whether the request carries the admin bearer token.
+func (s *Server) isAdmin(r *http.Request) bool {
+	if s.config.AdminToken == "" {
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AdminToken)) == 1
+}
+
+// handleDeleteByCreator handles DELETE /admin/snippets for removing every
+// snippet from a single creator, identified by ?creator_ip= or ?creator=.
+func (s *Server) handleDeleteByCreator(w http.ResponseWriter, r *http.Request) {
+	reqID := middleware.GetReqID(r.Context())
+
+	creator := r.URL.Query().Get("creator")
+	if ip := r.URL.Query().Get("creator_ip"); ip != "" {
+		creator = s.creatorHash(ip)
+	}
+	if creator == "" {
+		badRequestField(w, "creator", "creator_ip or creator is required")
+		return
+	}
+
+	count, err := s.repo.DeleteByCreator(r.Context(), creator)
+	if err != nil {
+		s.logger.Error("failed to delete snippets by creator",
+			"error", err,
+			"creator", creator,
+			"request_id", reqID)
+		internalError(w)
+		return
+	}
+
+	s.logger.Info("audit",
+		"action", "delete_by_creator",
+		"creator", creator,
+		"deleted_count", count,
+		"remote_ip", r.RemoteAddr,
+		"request_id", reqID,
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(DeleteByCreatorResponse{Deleted: count})
+}
+
+// maxImportBodySize bounds the JSON body of an import request.
+const maxImportBodySize = 64 << 20
+
+// handleImport handles POST /admin/import for restoring snippets with their
+// original timestamps. Already-expired snippets are imported as-is and left
+// for the cleanup worker. An import is all or nothing: if a snippet cannot
+// be stored, the ones stored before it are deleted again.
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
+	reqID := middleware.GetReqID(r.Context())
+	repo := s.repoFor(r)
+
+	var req ImportRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxImportBodySize)).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			payloadTooLarge(w, maxImportBodySize)
+			return
+		}
+		badRequest(w, "invalid import body: "+err.Error())
+		return
+	}
+
+	// Validate everything up front so most bad batches fail before anything
+	// is stored
+	seen := make(map[string]bool, len(req.Snippets))
+	for i, snip := range req.Snippets {
+		if err := s.validateImport(snip); err != nil {
+			badRequest(w, fmt.Sprintf("snippet %d: %v", i, err))
+			return
+		}
+		if seen[snip.ID] {
+			badRequest(w, fmt.Sprintf("snippet %d: duplicate id %q", i, snip.ID))
+			return
+		}
+		seen[snip.ID] = true
+	}
+	for i, snip := range req.Snippets {
+		existing, err := repo.GetMeta(r.Context(), snip.ID)
+		if err != nil {
+			s.logger.Error("failed to check import id",
+				"error", err,
+				"snippet_id", snip.ID,
+				"request_id", reqID)
+			internalError(w)
+			return
+		}
+		if existing != nil {
+			conflict(w, fmt.Sprintf("snippet %d: id %q already exists", i, snip.ID))
+			return
+		}
+	}
+
+	// An expired snippet awaiting cleanup, or a concurrent write, can still
+	// take an ID after the check above
+	imported := make([]string, 0, len(req.Snippets))
+	for i, snip := range req.Snippets {
+		_, err := repo.CreateWithTimestamps(r.Context(), &storage.Snippet{
+			ID:        snip.ID,
+			Content:   snip.Content,
+			CreatedAt: snip.CreatedAt,
+			ExpiresAt: snip.ExpiresAt,
+
+			ContentHash: s.hasher.Sum(snip.Content),
+		})
+		if err != nil {
+			s.undoImport(r, repo, imported)
+			if errors.Is(err, storage.ErrConflict) {
+				conflict(w, fmt.Sprintf("snippet %d: id %q already exists", i, snip.ID))
+				return
+			}
+			s.logger.Error("failed to import snippet",
+				"error", err,
+				"snippet_id", snip.ID,
+				"request_id", reqID)
+			internalError(w)
+			return
+		}
+		imported = append(imported, snip.ID)
+	}
+
+	s.logger.Info("audit",
+		"action", "import",
+		"imported_count", len(imported),
+		"remote_ip", r.RemoteAddr,
+		"request_id", reqID,
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ImportResponse{Imported: len(imported)})
+}
+
+// undoImport deletes the snippets a failed import already stored, also when
+// the request was cancelled.
+func (s *Server) undoImport(r *http.Request, repo storage.Repository, ids []string) {
+	ctx := context.WithoutCancel(r.Context())
+	for _, snippetID := range ids {
+		if err := repo.Delete(ctx, snippetID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			s.logger.Error("failed to undo partial import",
+				"error", err,
+				"snippet_id", snippetID,
+				"request_id", middleware.GetReqID(r.Context()))
+		}
+	}
+}
+
+func (s *Server) validateImport(snip ImportSnippet) error {
+	if !id.IsValidCustom(snip.ID) || reservedSegments[strings.ToLower(snip.ID)] {
+		return fmt.Errorf("invalid id %q", snip.ID)
+	}
+	if len(snip.Content) == 0 {
+		return fmt.Errorf("content cannot be empty")
+	}
+	if int64(len(snip.Content)) > s.config.MaxContentSize {
+		return fmt.Errorf("content exceeds maximum size")
+	}
+	if snip.CreatedAt.IsZero() || snip.ExpiresAt.IsZero() {
+		return fmt.Errorf("created_at and expires_at are required")
+	}
+	if !snip.ExpiresAt.After(snip.CreatedAt) {
+		return fmt.Errorf("expires_at must be after created_at")
+	}
+	return nil
+}
+
+// handlePutSnippet handles PUT /admin/snippets/{id} for storing content
+// under a chosen ID. An existing snippet with that ID has its content and
+// expiry replaced; the response is 201 for a new snippet and 200 for a
+// replaced one. With "If-None-Match: *" the snippet is only created if the
+// ID is free, and an existing one yields 412 Precondition Failed.
+func (s *Server) handlePutSnippet(w http.ResponseWriter, r *http.Request) {
+	reqID := middleware.GetReqID(r.Context())
+
+	snippetID := chi.URLParam(r, "id")
+	if !id.IsValidCustom(snippetID) || reservedSegments[strings.ToLower(snippetID)] {
+		invalidID(w)
+		return
+	}
+
+	expiryDuration, ok := s.parseExpiry(w, r)
+	if !ok {
+		return
+	}
+
+	if r.ContentLength > s.config.MaxContentSize {
+		payloadTooLarge(w, s.config.MaxContentSize)
+		return
+	}
+
+	content, err := io.ReadAll(io.LimitReader(r.Body, s.config.MaxContentSize+1))
+	if err != nil {
+		s.logger.Error("failed to read request body",
+			"error", err,
+			"request_id", reqID)
+		internalError(w)
+		return
+	}
+	if int64(len(content)) > s.config.MaxContentSize {
+		payloadTooLarge(w, s.config.MaxContentSize)
+		return
+	}
+	if len(content) == 0 {
+		emptyContent(w)
+		return
+	}
+
+	if expiryDuration == 0 {
+		expiryDuration = s.config.DefaultExpiryFor(int64(len(content)))
+	}
+
+	var snippet *storage.Snippet
+	if r.Header.Get("If-None-Match") == "*" {
+		snippet, err = s.repoFor(r).Create(r.Context(), &storage.Snippet{
+			ID:          snippetID,
+			Content:     content,
+			ExpiresAt:   expiresAtFor(expiryDuration),
+			ContentHash: s.hasher.Sum(content),
+		})
+		if errors.Is(err, storage.ErrConflict) {
+			preconditionFailed(w, "a snippet with this ID already exists")
+			return
+		}
+	} else {
+		snippet, err = s.repoFor(r).Upsert(r.Context(), snippetID, content, expiresAtFor(expiryDuration))
+	}
+	if err != nil {
+		s.logger.Error("failed to upsert snippet",
+			"error", err,
+			"snippet_id", snippetID,
+			"request_id", reqID)
+		internalError(w)
+		return
+	}
+	replaced := snippet.UpdatedAt != nil
+
+	s.logger.Info("audit",
+		"action", "put_snippet",
+		"snippet_id", snippetID,
+		"replaced", replaced,
+		"remote_ip", r.RemoteAddr,
+		"request_id", reqID,
+	)
+
+	status := http.StatusCreated
+	if replaced {
+		status = http.StatusOK
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(PutSnippetResponse{
+		ID:        snippet.ID,
+		URL:       s.snippetURL(r, snippet.ID),
+		SizeBytes: len(snippet.Content),
+		ExpiresAt: snippet.ExpiresAt,
+		Replaced:  replaced,
+	})
+}
@@ -0,0 +1,352 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/storage"
+)
+
+func createFrom(t *testing.T, s *Server, remoteAddr, body string) string {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.RemoteAddr = remoteAddr
+	return decodeCreate(t, serve(s, req)).ID
+}
+
+func TestHandleDeleteByCreator(t *testing.T) {
+	cfg := testConfig()
+	cfg.AdminToken = "s3cret"
+	s, repo := newTestServer(t, cfg)
+
+	spam1 := createFrom(t, s, "203.0.113.7:4000", "spam 1")
+	spam2 := createFrom(t, s, "203.0.113.7:4001", "spam 2")
+	keep := createFrom(t, s, "198.51.100.2:4000", "legit")
+
+	req := httptest.NewRequest(http.MethodDelete, "/admin/snippets?creator_ip=203.0.113.7", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec := serve(s, req)
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp DeleteByCreatorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, int64(2), resp.Deleted)
+
+	assert.NotContains(t, repo.snippets, spam1)
+	assert.NotContains(t, repo.snippets, spam2)
+	assert.Contains(t, repo.snippets, keep)
+}
+
+func TestHandleDeleteByCreator_ByHash(t *testing.T) {
+	cfg := testConfig()
+	cfg.AdminToken = "s3cret"
+	s, repo := newTestServer(t, cfg)
+
+	spam := createFrom(t, s, "203.0.113.7:4000", "spam")
+
+	req := httptest.NewRequest(http.MethodDelete, "/admin/snippets?creator="+s.creatorHash("203.0.113.7"), nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec := serve(s, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, repo.snippets, spam)
+}
+
+func TestCreatorHash_Keyed(t *testing.T) {
+	cfg := testConfig()
+	cfg.CreatorHashKey = "k1"
+	s1, _ := newTestServer(t, cfg)
+	again, _ := newTestServer(t, cfg)
+	cfg = testConfig()
+	cfg.CreatorHashKey = "k2"
+	s2, _ := newTestServer(t, cfg)
+	random, _ := newTestServer(t, testConfig())
+
+	ip := "203.0.113.7"
+	plain := sha256.Sum256([]byte(ip))
+	assert.Equal(t, s1.creatorHash(ip), again.creatorHash(ip))
+	assert.NotEqual(t, s1.creatorHash(ip), s2.creatorHash(ip))
+	assert.NotEqual(t, hex.EncodeToString(plain[:]), random.creatorHash(ip))
+}
+
+func TestHandleDeleteByCreator_MissingCreator(t *testing.T) {
+	cfg := testConfig()
+	cfg.AdminToken = "s3cret"
+	s, _ := newTestServer(t, cfg)
+
+	req := httptest.NewRequest(http.MethodDelete, "/admin/snippets", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec := serve(s, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestAdminAuth(t *testing.T) {
+	tests := []struct {
+		name       string
+		adminToken string
+		header     string
+	}{
+		{"no token configured", "", "Bearer "},
+		{"missing header", "s3cret", ""},
+		{"wrong token", "s3cret", "Bearer wrong"},
+		{"wrong scheme", "s3cret", "Basic s3cret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.AdminToken = tt.adminToken
+			s, repo := newTestServer(t, cfg)
+			id := createFrom(t, s, "203.0.113.7:4000", "content")
+
+			req := httptest.NewRequest(http.MethodDelete, "/admin/snippets?creator_ip=203.0.113.7", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := serve(s, req)
+
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+			assert.Equal(t, ErrCodeUnauthorized, decodeError(t, rec).Code)
+			assert.Contains(t, repo.snippets, id)
+		})
+	}
+}
+
+func TestHandleImport_PreservesTimestamps(t *testing.T) {
+	cfg := testConfig()
+	cfg.AdminToken = "s3cret"
+	s, repo := newTestServer(t, cfg)
+
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	live := ImportSnippet{
+		ID:        "liveSnippet1",
+		Content:   []byte("still valid"),
+		CreatedAt: created,
+		ExpiresAt: time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second),
+	}
+	expired := ImportSnippet{
+		ID:        "deadSnippet1",
+		Content:   []byte("long gone"),
+		CreatedAt: created,
+		ExpiresAt: created.Add(time.Hour),
+	}
+	body, err := json.Marshal(ImportRequest{Snippets: []ImportSnippet{live, expired}})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/import", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec := serve(s, req)
+
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var resp ImportResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, 2, resp.Imported)
+
+	require.Contains(t, repo.snippets, live.ID)
+	assert.True(t, created.Equal(repo.snippets[live.ID].CreatedAt))
+	assert.True(t, live.ExpiresAt.Equal(repo.snippets[live.ID].ExpiresAt))
+	assert.Equal(t, "still valid", string(repo.snippets[live.ID].Content))
+
+	// The expired import is left for the cleanup worker
+	w := NewCleanupWorker(repo, CleanupConfig{Interval: time.Minute},
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+	w.cleanup(context.Background())
+
+	assert.Contains(t, repo.snippets, live.ID)
+	assert.NotContains(t, repo.snippets, expired.ID)
+}
+
+func TestHandleImport_Invalid(t *testing.T) {
+	cfg := testConfig()
+	cfg.AdminToken = "s3cret"
+	s, repo := newTestServer(t, cfg)
+
+	now := time.Now()
+	tests := []struct {
+		name    string
+		snippet ImportSnippet
+	}{
+		{"bad id", ImportSnippet{ID: "no.pe", Content: []byte("x"), CreatedAt: now, ExpiresAt: now.Add(time.Hour)}},
+		{"reserved id", ImportSnippet{ID: "Healthz", Content: []byte("x"), CreatedAt: now, ExpiresAt: now.Add(time.Hour)}},
+		{"empty content", ImportSnippet{ID: "abc123XYZ789", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}},
+		{"missing timestamps", ImportSnippet{ID: "abc123XYZ789", Content: []byte("x")}},
+		{"expires before created", ImportSnippet{ID: "abc123XYZ789", Content: []byte("x"), CreatedAt: now, ExpiresAt: now.Add(-time.Hour)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(ImportRequest{Snippets: []ImportSnippet{tt.snippet}})
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/import", bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer s3cret")
+			rec := serve(s, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Empty(t, repo.snippets)
+		})
+	}
+}
+
+func TestHandleImport_AllOrNothing(t *testing.T) {
+	cfg := testConfig()
+	cfg.AdminToken = "s3cret"
+	s, repo := newTestServer(t, cfg)
+
+	now := time.Now().UTC()
+	snippet := func(id string) ImportSnippet {
+		return ImportSnippet{ID: id, Content: []byte("x"), CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	}
+	repo.snippets["takenSnippet"] = &storage.Snippet{ID: "takenSnippet", Content: []byte("old"), ExpiresAt: now.Add(time.Hour)}
+	// Expired but not cleaned up yet, so only the insert finds it taken
+	repo.snippets["staleSnippet"] = &storage.Snippet{ID: "staleSnippet", Content: []byte("old"), ExpiresAt: now.Add(-time.Hour)}
+
+	tests := []struct {
+		name     string
+		snippets []ImportSnippet
+		status   int
+	}{
+		{"duplicate in batch", []ImportSnippet{snippet("freshSnippet"), snippet("freshSnippet")}, http.StatusBadRequest},
+		{"taken id", []ImportSnippet{snippet("freshSnippet"), snippet("takenSnippet")}, http.StatusConflict},
+		{"taken by expired snippet", []ImportSnippet{snippet("freshSnippet"), snippet("staleSnippet")}, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(ImportRequest{Snippets: tt.snippets})
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/import", bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer s3cret")
+			rec := serve(s, req)
+
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+			assert.NotContains(t, repo.snippets, "freshSnippet")
+			assert.Equal(t, "old", string(repo.snippets["takenSnippet"].Content))
+		})
+	}
+}
+
+func TestHandleCreate_StampsNow(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+
+	before := time.Now()
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "fresh"))
+
+	require.Contains(t, repo.snippets, created.ID)
+	assert.False(t, repo.snippets[created.ID].CreatedAt.Before(before))
+}
+
+func TestHandlePutSnippet(t *testing.T) {
+	cfg := testConfig()
+	cfg.AdminToken = "s3cret"
+	s, repo := newTestServer(t, cfg)
+
+	put := func(body, query string) (*httptest.ResponseRecorder, PutSnippetResponse) {
+		req := httptest.NewRequest(http.MethodPut, "/admin/snippets/reservedID01"+query, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer s3cret")
+		rec := serve(s, req)
+		var resp PutSnippetResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec, resp
+	}
+
+	rec, resp := put("first", "")
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.False(t, resp.Replaced)
+	assert.Equal(t, "reservedID01", resp.ID)
+
+	rec, resp = put("second", "?expiry=1h")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.True(t, resp.Replaced)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), resp.ExpiresAt, time.Minute)
+
+	require.Len(t, repo.snippets, 1)
+	assert.Equal(t, "second", string(repo.snippets["reservedID01"].Content))
+}
+
+func TestHandleCreate_ExpiryNever(t *testing.T) {
+	cfg := testConfig()
+	cfg.AdminToken = "s3cret"
+	s, repo := newTestServer(t, cfg)
+
+	// The public cannot create permanent snippets
+	rec := doRequest(s, http.MethodPost, "/?expiry=never", "forever")
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	errResp := decodeError(t, rec)
+	assert.Equal(t, ErrCodeForbidden, errResp.Code)
+	assert.Equal(t, "expiry", errResp.Details["field"])
+	assert.Empty(t, repo.snippets)
+
+	req := httptest.NewRequest(http.MethodPost, "/?expiry=never", strings.NewReader("forever"))
+	req.Header.Set("Authorization", "Bearer wrong")
+	require.Equal(t, http.StatusForbidden, serve(s, req).Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/?expiry=never", strings.NewReader("forever"))
+	req.Header.Set("Authorization", "Bearer s3cret")
+	created := decodeCreate(t, serve(s, req))
+	assert.True(t, created.ExpiresAt.Equal(storage.NeverExpires))
+	assert.True(t, repo.snippets[created.ID].ExpiresAt.Equal(storage.NeverExpires))
+
+	// Admin PUT accepts it too
+	req = httptest.NewRequest(http.MethodPut, "/admin/snippets/handbook01?expiry=never", strings.NewReader("docs"))
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec = serve(s, req)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.True(t, repo.snippets["handbook01"].ExpiresAt.Equal(storage.NeverExpires))
+}
+
+func TestHandlePutSnippet_IfNoneMatch(t *testing.T) {
+	cfg := testConfig()
+	cfg.AdminToken = "s3cret"
+	s, repo := newTestServer(t, cfg)
+
+	put := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/admin/snippets/reservedID01", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer s3cret")
+		req.Header.Set("If-None-Match", "*")
+		return serve(s, req)
+	}
+
+	rec := put("first")
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Equal(t, "first", string(repo.snippets["reservedID01"].Content))
+
+	rec = put("second")
+	require.Equal(t, http.StatusPreconditionFailed, rec.Code, rec.Body.String())
+	assert.Equal(t, ErrCodePrecondition, decodeError(t, rec).Code)
+	assert.Equal(t, "first", string(repo.snippets["reservedID01"].Content), "existing snippet is untouched")
+}
+
+func TestHandlePutSnippet_Invalid(t *testing.T) {
+	cfg := testConfig()
+	cfg.AdminToken = "s3cret"
+	s, repo := newTestServer(t, cfg)
+
+	for name, target := range map[string]string{
+		"bad id":     "/admin/snippets/x!",
+		"bad expiry": "/admin/snippets/reservedID01?expiry=soon",
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, target, strings.NewReader("content"))
+			req.Header.Set("Authorization", "Bearer s3cret")
+			assert.Equal(t, http.StatusBadRequest, serve(s, req).Code)
+		})
+	}
+	assert.Empty(t, repo.snippets)
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postWithAPIKey(s *Server, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("content"))
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	return serve(s, req)
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	cfg := testConfig()
+	cfg.APIKeys = []string{"key-one", "key-two"}
+	s, repo := newTestServer(t, cfg)
+
+	created := decodeCreate(t, postWithAPIKey(s, "key-one"))
+	assert.Equal(t, http.StatusCreated, postWithAPIKey(s, "key-two").Code)
+
+	for _, key := range []string{"", "key-three", "key-one "} {
+		rec := postWithAPIKey(s, key)
+		require.Equal(t, http.StatusUnauthorized, rec.Code, key)
+		assert.Equal(t, ErrCodeUnauthorized, decodeError(t, rec).Code)
+	}
+	assert.Len(t, repo.snippets, 2)
+
+	// Other writes need a key too, reads do not
+	path := "/" + created.ID
+	assert.Equal(t, http.StatusUnauthorized, doRequest(s, http.MethodDelete, path+"?token="+created.DeleteToken, "").Code)
+	assert.Equal(t, http.StatusOK, doRequest(s, http.MethodGet, path, "").Code)
+}
+
+func TestRateLimitKey(t *testing.T) {
+	cfg := testConfig()
+	cfg.APIKeys = []string{"key-one", "key-two"}
+	s, _ := newTestServer(t, cfg)
+
+	keyFor := func(apiKey string) string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "203.0.113.9:5000"
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		key, err := s.rateLimitKey(req)
+		require.NoError(t, err)
+		return key
+	}
+
+	one, two := keyFor("key-one"), keyFor("key-two")
+	assert.NotEqual(t, one, two)
+	assert.NotContains(t, one, "key-one", "the key itself is not used")
+	assert.Equal(t, one, keyFor("key-one"))
+
+	anonymous := keyFor("")
+	assert.Contains(t, anonymous, "203.0.113.9")
+	assert.Equal(t, anonymous, keyFor("not-a-key"), "unknown keys fall back to the IP")
+}
+
+func TestRateLimit_PerAPIKey(t *testing.T) {
+	cfg := testConfig()
+	cfg.APIKeys = []string{"key-one", "key-two"}
+	cfg.PostRateLimit = 2
+	cfg.GetRateLimit = 1
+	s, _ := newTestServer(t, cfg)
+
+	const addr = "203.0.113.9:5000"
+	for _, key := range cfg.APIKeys {
+		headers := map[string]string{"X-API-Key": key}
+		assert.Equal(t, http.StatusCreated, postFrom(s, addr, headers), key)
+		assert.Equal(t, http.StatusCreated, postFrom(s, addr, headers), key)
+		assert.Equal(t, http.StatusTooManyRequests, postFrom(s, addr, headers), key)
+	}
+
+	// Anonymous reads share the IP's budget
+	getFrom := func(apiKey string) int {
+		req := httptest.NewRequest(http.MethodGet, "/missing", nil)
+		req.RemoteAddr = addr
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		return serve(s, req).Code
+	}
+	assert.Equal(t, http.StatusNotFound, getFrom(""))
+	assert.Equal(t, http.StatusTooManyRequests, getFrom(""))
+	assert.Equal(t, http.StatusNotFound, getFrom("key-one"), "a key gets its own budget for reads too")
+}
+
+func TestAPIKeyAuth_Disabled(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+
+	assert.Equal(t, http.StatusCreated, postWithAPIKey(s, "").Code)
+	assert.Equal(t, http.StatusCreated, postWithAPIKey(s, "anything").Code)
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/storage"
+)
+
+// setCacheHeaders sets ETag, Cache-Control and Expires for a snippet read.
+// It returns true when the request's If-None-Match already matches, in which
+// case the caller should answer 304 without a body.
+func (s *Server) setCacheHeaders(w http.ResponseWriter, r *http.Request, snippet *storage.Snippet, now time.Time) bool {
+	etag := `"` + s.hasher.Sum(snippet.Content) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", cacheControl(snippet, r.URL.Query().Has("sig"), now))
+	w.Header().Set("Expires", snippet.ExpiresAt.UTC().Format(http.TimeFormat))
+
+	return etagMatches(r.Header.Get("If-None-Match"), etag)
+}
+
+// cacheControl returns the Cache-Control value for a snippet read.
+//
+// Plain snippets may be cached publicly until they expire. Reads that count
+// against a view limit must never be served from a cache, and neither may
+// PIN-protected content, or a shared cache would hand it to anyone; appendable
+// snippets and signed links may be cached but are revalidated every time,
+// since the content can grow and the link can lapse.
+func cacheControl(snippet *storage.Snippet, signed bool, now time.Time) string {
+	switch {
+	case snippet.PinHash != "":
+		return "private, no-store"
+	case snippet.MaxViews > 0:
+		return "no-store"
+	case snippet.AppendTokenHash != "" || signed:
+		return "no-cache"
+	}
+
+	maxAge := int64(snippet.ExpiresAt.Sub(now) / time.Second)
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	return "public, max-age=" + strconv.FormatInt(maxAge, 10)
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, using
+// the weak comparison RFC 9110 prescribes for If-None-Match.
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/storage"
+)
+
+func TestHandleGet_NotModified(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "hello world"))
+
+	rec := doRequest(s, http.MethodGet, "/"+created.ID, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	etag := rec.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.Contains(t, rec.Header().Get("Cache-Control"), "public, max-age=")
+	assert.NotEmpty(t, rec.Header().Get("Expires"))
+
+	for _, header := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+		req := httptest.NewRequest(http.MethodGet, "/"+created.ID, nil)
+		req.Header.Set("If-None-Match", header)
+		rec = serve(s, req)
+		assert.Equal(t, http.StatusNotModified, rec.Code, header)
+		assert.Empty(t, rec.Body.String())
+		assert.Equal(t, etag, rec.Header().Get("ETag"))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/"+created.ID, nil)
+	req.Header.Set("If-None-Match", `"stale"`)
+	rec = serve(s, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "hello world", rec.Body.String())
+}
+
+func TestHandleGet_ETagFollowsContent(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "hello world"))
+	before := doRequest(s, http.MethodGet, "/"+created.ID, "").Header().Get("ETag")
+
+	repo.snippets[created.ID].Content = []byte("hello world, again")
+	after := doRequest(s, http.MethodGet, "/"+created.ID, "").Header().Get("ETag")
+	assert.NotEqual(t, before, after)
+}
+
+func TestCacheControl(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		snippet storage.Snippet
+		signed  bool
+		want    string
+	}{
+		{"a day left", storage.Snippet{ExpiresAt: now.Add(24 * time.Hour)}, false, "public, max-age=86400"},
+		{"close to expiry", storage.Snippet{ExpiresAt: now.Add(1500 * time.Millisecond)}, false, "public, max-age=1"},
+		{"already expired", storage.Snippet{ExpiresAt: now.Add(-time.Second)}, false, "public, max-age=0"},
+		{"view limited", storage.Snippet{ExpiresAt: now.Add(time.Hour), MaxViews: 3}, false, "no-store"},
+		{"PIN protected", storage.Snippet{ExpiresAt: now.Add(time.Hour), PinHash: "hash"}, false, "private, no-store"},
+		{"appendable", storage.Snippet{ExpiresAt: now.Add(time.Hour), AppendTokenHash: "h"}, false, "no-cache"},
+		{"signed link", storage.Snippet{ExpiresAt: now.Add(time.Hour)}, true, "no-cache"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, cacheControl(&tt.snippet, tt.signed, now))
+		})
+	}
+}
+
+func TestHandleGet_MaxAgeNearExpiry(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "hello world"))
+	repo.snippets[created.ID].ExpiresAt = time.Now().Add(90 * time.Second)
+
+	rec := doRequest(s, http.MethodGet, "/"+created.ID, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, []string{"public, max-age=89", "public, max-age=90"}, rec.Header().Get("Cache-Control"))
+}
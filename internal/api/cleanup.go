@@ -3,32 +3,86 @@ package api
 import (
 	"context"
 	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rayenfassatoui/tafcha-cli/internal/storage"
 )
 
+// CleanupConfig controls how the cleanup worker behaves.
+type CleanupConfig struct {
+	// Interval between cleanup runs.
+	Interval time.Duration
+
+	// IdleExpiry, when positive, also removes snippets that have not been
+	// accessed within this window. Zero disables idle collection.
+	IdleExpiry time.Duration
+
+	// MinAge protects snippets younger than this from idle collection.
+	MinAge time.Duration
+
+	// BatchSize is the number of expired snippets deleted per statement.
+	// Zero uses defaultCleanupBatchSize.
+	BatchSize int
+
+	// Concurrency is how many delete batches may run at once. Values
+	// below 1 run batches sequentially.
+	Concurrency int
+
+	// LogBatches logs each delete batch's count and the running total at
+	// info level, so long runs over a large backlog show progress.
+	LogBatches bool
+
+	// Jitter, between 0 and 1, delays each run by a random fraction of
+	// Interval up to this much, so replicas started together drift apart
+	// instead of cleaning up at the same moment. Zero runs exactly on
+	// Interval, starting immediately.
+	Jitter float64
+}
+
+// defaultCleanupBatchSize keeps each delete statement short.
+const defaultCleanupBatchSize = 1000
+
+// cleanupBatchPause is how long a lane waits between full batches.
+const cleanupBatchPause = 10 * time.Millisecond
+
+// CleanupStatus is the outcome of the cleanup worker's last finished run.
+type CleanupStatus struct {
+	LastRun     time.Time // zero until a run has finished
+	LastDeleted int64     // expired and idle snippets removed by that run
+	LastError   error     // nil if that run succeeded
+}
+
 // CleanupWorker periodically removes expired snippets.
 type CleanupWorker struct {
-	repo     storage.Repository
-	interval time.Duration
-	logger   *slog.Logger
-	stopCh   chan struct{}
-	doneCh   chan struct{}
+	repo   storage.Repository
+	cfg    CleanupConfig
+	logger *slog.Logger
+	now    func() time.Time
+	stopCh chan struct{}
+	doneCh chan struct{}
+
+	mu        sync.Mutex
+	status    CleanupStatus
+	startedAt time.Time
 }
 
 // NewCleanupWorker creates a new cleanup worker.
-func NewCleanupWorker(repo storage.Repository, interval time.Duration, logger *slog.Logger) *CleanupWorker {
+func NewCleanupWorker(repo storage.Repository, cfg CleanupConfig, logger *slog.Logger) *CleanupWorker {
 	return &CleanupWorker{
-		repo:     repo,
-		interval: interval,
-		logger:   logger,
-		stopCh:   make(chan struct{}),
-		doneCh:   make(chan struct{}),
+		repo:   repo,
+		cfg:    cfg,
+		logger: logger,
+		now:    time.Now,
+		stopCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
 	}
 }
 
-// Start begins the cleanup loop in a goroutine.
+// Start begins the cleanup loop in a goroutine. Cancelling ctx or calling
+// Stop ends the loop and interrupts a run that is still deleting.
 func (w *CleanupWorker) Start(ctx context.Context) {
 	go w.run(ctx)
 }
@@ -36,35 +90,170 @@ func (w *CleanupWorker) Start(ctx context.Context) {
 func (w *CleanupWorker) run(ctx context.Context) {
 	defer close(w.doneCh)
 
-	ticker := time.NewTicker(w.interval)
-	defer ticker.Stop()
+	w.mu.Lock()
+	w.startedAt = w.now()
+	w.mu.Unlock()
+
+	// Stop cancels the context passed to the repository, so a long delete
+	// does not hold up shutdown
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-w.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
 
-	// Run once at startup
-	w.cleanup()
+	// Run once at startup, then every Interval, each offset by the jitter
+	timer := time.NewTimer(w.jitter())
+	defer timer.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
-			w.logger.Info("cleanup worker stopping due to context cancellation")
+			select {
+			case <-w.stopCh:
+				w.logger.Info("cleanup worker stopping")
+			default:
+				w.logger.Info("cleanup worker stopping due to context cancellation")
+			}
 			return
-		case <-w.stopCh:
-			w.logger.Info("cleanup worker stopping")
-			return
-		case <-ticker.C:
-			w.cleanup()
+		case <-timer.C:
+			w.cleanup(ctx)
+			timer.Reset(w.cfg.Interval + w.jitter())
 		}
 	}
 }
 
-func (w *CleanupWorker) cleanup() {
-	count, err := w.repo.DeleteExpired()
+// jitter returns a random delay below Jitter times Interval.
+func (w *CleanupWorker) jitter() time.Duration {
+	limit := int64(w.cfg.Jitter * float64(w.cfg.Interval))
+	if limit <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(limit))
+}
+
+func (w *CleanupWorker) cleanup(ctx context.Context) {
+	start := time.Now()
+	count, err := w.deleteExpired(ctx)
+	if ctx.Err() != nil {
+		w.logger.Info("cleanup interrupted", "deleted_count", count)
+		return
+	}
 	if err != nil {
-		w.logger.Error("failed to delete expired snippets", "error", err)
+		w.logger.Error("failed to delete expired snippets", "error", err, "deleted_count", count)
+		w.record(count, err)
 		return
 	}
+	if count > 0 || w.cfg.LogBatches {
+		w.logger.Info("cleanup completed", "deleted_count", count, "duration", time.Since(start))
+	}
+
+	if w.cfg.IdleExpiry > 0 {
+		idle, err := w.cleanupIdle(ctx)
+		count += idle
+		if err != nil {
+			w.record(count, err)
+			return
+		}
+	}
+	w.record(count, nil)
+}
+
+// record keeps the outcome of a finished run for Status.
+func (w *CleanupWorker) record(deleted int64, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.status = CleanupStatus{LastRun: w.now(), LastDeleted: deleted, LastError: err}
+}
+
+// Status returns the outcome of the last finished run. Runs interrupted by
+// Stop are not recorded.
+func (w *CleanupWorker) Status() CleanupStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+// overdue reports whether no run has finished within twice Interval, counted
+// from the last run or, before the first one, from Start.
+func (w *CleanupWorker) overdue() bool {
+	w.mu.Lock()
+	since := w.status.LastRun
+	if since.IsZero() {
+		since = w.startedAt
+	}
+	w.mu.Unlock()
+
+	return !since.IsZero() && w.now().Sub(since) > 2*w.cfg.Interval
+}
+
+// deleteExpired removes expired snippets in batches, running up to
+// Concurrency batches at once. Each lane keeps deleting until a batch comes
+// back short, meaning no unclaimed expired rows were left for it, or ctx is
+// cancelled.
+func (w *CleanupWorker) deleteExpired(ctx context.Context) (int64, error) {
+	batchSize := w.cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultCleanupBatchSize
+	}
+	lanes := max(w.cfg.Concurrency, 1)
+
+	var (
+		total    atomic.Int64
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for i := 0; i < lanes; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				n, err := w.repo.DeleteExpiredBatch(ctx, batchSize)
+				deleted := total.Add(n)
+				if w.cfg.LogBatches && n > 0 {
+					w.logger.Info("cleanup batch completed",
+						"deleted_count", n,
+						"total_deleted", deleted)
+				}
+				if err != nil {
+					errOnce.Do(func() { firstErr = err })
+					return
+				}
+				if n < int64(batchSize) {
+					return
+				}
+				// Each batch commits on its own, releasing its locks; pause
+				// so waiting requests get in before the next one
+				select {
+				case <-ctx.Done():
+				case <-time.After(cleanupBatchPause):
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return total.Load(), firstErr
+}
+
+// cleanupIdle removes snippets nobody has read within the idle window.
+// Snippets younger than MinAge are always kept, regardless of access.
+func (w *CleanupWorker) cleanupIdle(ctx context.Context) (int64, error) {
+	now := w.now()
+	count, err := w.repo.DeleteIdle(ctx, now.Add(-w.cfg.IdleExpiry), now.Add(-w.cfg.MinAge))
+	if err != nil {
+		w.logger.Error("failed to delete idle snippets", "error", err)
+		return count, err
+	}
 	if count > 0 {
-		w.logger.Info("cleanup completed", "deleted_count", count)
+		w.logger.Info("idle cleanup completed", "deleted_count", count)
 	}
+	return count, nil
 }
 
 // Stop signals the worker to stop and waits for it to finish.
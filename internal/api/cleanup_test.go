@@ -0,0 +1,388 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/storage"
+)
+
+func TestCleanupWorker_IdleExpiry(t *testing.T) {
+	now := time.Now()
+	ago := func(d time.Duration) *time.Time {
+		ts := now.Add(-d)
+		return &ts
+	}
+
+	repo := newStubRepo()
+	repo.snippets = map[string]*storage.Snippet{
+		// Read recently: kept
+		"accessed": {ID: "accessed", CreatedAt: now.Add(-48 * time.Hour), LastAccessedAt: ago(time.Hour)},
+		// Read long ago: collected
+		"stale": {ID: "stale", CreatedAt: now.Add(-48 * time.Hour), LastAccessedAt: ago(30 * time.Hour)},
+		// Never read and old: collected
+		"unread": {ID: "unread", CreatedAt: now.Add(-48 * time.Hour)},
+		// Never read but younger than MinAge: kept
+		"young": {ID: "young", CreatedAt: now.Add(-5 * time.Minute)},
+	}
+	for _, s := range repo.snippets {
+		s.ExpiresAt = now.Add(72 * time.Hour)
+	}
+
+	w := NewCleanupWorker(repo, CleanupConfig{
+		Interval:   time.Minute,
+		IdleExpiry: 24 * time.Hour,
+		MinAge:     10 * time.Minute,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	w.now = func() time.Time { return now }
+
+	w.cleanup(context.Background())
+
+	assert.Contains(t, repo.snippets, "accessed")
+	assert.Contains(t, repo.snippets, "young")
+	assert.NotContains(t, repo.snippets, "stale")
+	assert.NotContains(t, repo.snippets, "unread")
+}
+
+func TestCleanupWorker_IdleExpiryDisabled(t *testing.T) {
+	now := time.Now()
+
+	repo := newStubRepo()
+	repo.snippets = map[string]*storage.Snippet{
+		"unread": {ID: "unread", CreatedAt: now.Add(-48 * time.Hour), ExpiresAt: now.Add(time.Hour)},
+	}
+
+	w := NewCleanupWorker(repo, CleanupConfig{Interval: time.Minute},
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	w.cleanup(context.Background())
+
+	assert.Contains(t, repo.snippets, "unread")
+}
+
+func TestCleanupWorker_ConcurrentBatches(t *testing.T) {
+	const backlog = 5003
+
+	for _, concurrency := range []int{0, 1, 4, 16} {
+		t.Run(fmt.Sprintf("concurrency=%d", concurrency), func(t *testing.T) {
+			now := time.Now()
+			repo := newStubRepo()
+			for i := 0; i < backlog; i++ {
+				id := fmt.Sprintf("expired%05d", i)
+				repo.snippets[id] = &storage.Snippet{ID: id, ExpiresAt: now.Add(-time.Minute)}
+			}
+			repo.snippets["live"] = &storage.Snippet{ID: "live", ExpiresAt: now.Add(time.Hour)}
+
+			w := NewCleanupWorker(repo, CleanupConfig{
+				Interval:    time.Minute,
+				BatchSize:   100,
+				Concurrency: concurrency,
+			}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			count, err := w.deleteExpired(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, int64(backlog), count)
+			assert.Len(t, repo.snippets, 1)
+			assert.Contains(t, repo.snippets, "live")
+		})
+	}
+}
+
+// recordingHandler keeps the message and attributes of every log record.
+type recordingHandler struct {
+	mu      sync.Mutex
+	records []map[string]any
+}
+
+func (h *recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *recordingHandler) Handle(_ context.Context, r slog.Record) error {
+	attrs := map[string]any{"msg": r.Message}
+	r.Attrs(func(a slog.Attr) bool {
+		attrs[a.Key] = a.Value.Any()
+		return true
+	})
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, attrs)
+	return nil
+}
+
+func (h *recordingHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *recordingHandler) WithGroup(string) slog.Handler      { return h }
+
+func TestCleanupWorker_LogBatches(t *testing.T) {
+	now := time.Now()
+	repo := newStubRepo()
+	for i := 0; i < 250; i++ {
+		id := fmt.Sprintf("expired%05d", i)
+		repo.snippets[id] = &storage.Snippet{ID: id, ExpiresAt: now.Add(-time.Minute)}
+	}
+
+	logs := &recordingHandler{}
+	w := NewCleanupWorker(repo, CleanupConfig{
+		Interval:   time.Minute,
+		BatchSize:  100,
+		LogBatches: true,
+	}, slog.New(logs))
+
+	w.cleanup(context.Background())
+
+	require.Len(t, logs.records, 4)
+	for i, want := range []struct{ deleted, total int64 }{{100, 100}, {100, 200}, {50, 250}} {
+		assert.Equal(t, "cleanup batch completed", logs.records[i]["msg"])
+		assert.Equal(t, want.deleted, logs.records[i]["deleted_count"])
+		assert.Equal(t, want.total, logs.records[i]["total_deleted"])
+	}
+	summary := logs.records[3]
+	assert.Equal(t, "cleanup completed", summary["msg"])
+	assert.Equal(t, int64(250), summary["deleted_count"])
+	assert.Contains(t, summary, "duration")
+}
+
+func TestCleanupWorker_LogBatchesDisabled(t *testing.T) {
+	repo := newStubRepo()
+	repo.snippets["expired"] = &storage.Snippet{ID: "expired", ExpiresAt: time.Now().Add(-time.Minute)}
+
+	logs := &recordingHandler{}
+	w := NewCleanupWorker(repo, CleanupConfig{Interval: time.Minute}, slog.New(logs))
+
+	w.cleanup(context.Background())
+
+	require.Len(t, logs.records, 1)
+	assert.Equal(t, "cleanup completed", logs.records[0]["msg"])
+}
+
+// blockingDeleteRepo holds every expired-snippet delete until its context
+// is cancelled, like a long statement against a large backlog.
+type blockingDeleteRepo struct {
+	*stubRepo
+	started chan struct{}
+}
+
+func (r *blockingDeleteRepo) DeleteExpiredBatch(ctx context.Context, limit int) (int64, error) {
+	close(r.started)
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+func TestCleanupWorker_StopCancelsDelete(t *testing.T) {
+	repo := &blockingDeleteRepo{stubRepo: newStubRepo(), started: make(chan struct{})}
+	logs := &recordingHandler{}
+	w := NewCleanupWorker(repo, CleanupConfig{Interval: time.Minute}, slog.New(logs))
+
+	w.Start(context.Background())
+	<-repo.started
+
+	stopped := make(chan struct{})
+	go func() {
+		w.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not interrupt the running delete")
+	}
+
+	logs.mu.Lock()
+	defer logs.mu.Unlock()
+	require.Len(t, logs.records, 2)
+	assert.Equal(t, "cleanup interrupted", logs.records[0]["msg"])
+	assert.Equal(t, "cleanup worker stopping", logs.records[1]["msg"])
+}
+
+func TestCleanupWorker_DeleteExpiredStopsWhenCancelled(t *testing.T) {
+	now := time.Now()
+	repo := newStubRepo()
+	for i := 0; i < 10; i++ {
+		id := fmt.Sprintf("expired%05d", i)
+		repo.snippets[id] = &storage.Snippet{ID: id, ExpiresAt: now.Add(-time.Minute)}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	logs := &recordingHandler{}
+	w := NewCleanupWorker(repo, CleanupConfig{
+		Interval:   time.Minute,
+		BatchSize:  2,
+		LogBatches: true,
+	}, slog.New(cancelOnLog{logs, cancel}))
+
+	count, err := w.deleteExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count, "no batch runs after cancellation")
+	assert.Len(t, repo.snippets, 8)
+}
+
+// cancelOnLog cancels a context as soon as anything is logged.
+type cancelOnLog struct {
+	*recordingHandler
+	cancel context.CancelFunc
+}
+
+func (h cancelOnLog) Handle(ctx context.Context, r slog.Record) error {
+	h.cancel()
+	return h.recordingHandler.Handle(ctx, r)
+}
+
+func TestCleanupWorker_JitterBounds(t *testing.T) {
+	w := NewCleanupWorker(newStubRepo(), CleanupConfig{Interval: time.Minute, Jitter: 0.1},
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	for range 1000 {
+		d := w.jitter()
+		assert.GreaterOrEqual(t, d, time.Duration(0))
+		assert.Less(t, d, 6*time.Second)
+	}
+
+	w.cfg.Jitter = 0
+	assert.Zero(t, w.jitter())
+}
+
+// countingDeleteRepo counts expired-snippet delete batches.
+type countingDeleteRepo struct {
+	*stubRepo
+	batches atomic.Int64
+}
+
+func (r *countingDeleteRepo) DeleteExpiredBatch(ctx context.Context, limit int) (int64, error) {
+	r.batches.Add(1)
+	return r.stubRepo.DeleteExpiredBatch(ctx, limit)
+}
+
+func TestCleanupWorker_JitteredRunsStillFire(t *testing.T) {
+	repo := &countingDeleteRepo{stubRepo: newStubRepo()}
+	w := NewCleanupWorker(repo, CleanupConfig{Interval: 10 * time.Millisecond, Jitter: 1},
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	w.Start(context.Background())
+	defer w.Stop()
+
+	assert.Eventually(t, func() bool { return repo.batches.Load() >= 3 },
+		5*time.Second, 5*time.Millisecond)
+}
+
+// failingDeleteRepo fails expired-snippet deletes while fail is set.
+type failingDeleteRepo struct {
+	*stubRepo
+	fail bool
+}
+
+func (r *failingDeleteRepo) DeleteExpiredBatch(ctx context.Context, limit int) (int64, error) {
+	if r.fail {
+		return 0, errors.New("database unavailable")
+	}
+	return r.stubRepo.DeleteExpiredBatch(ctx, limit)
+}
+
+func TestCleanupWorker_Status(t *testing.T) {
+	now := time.Now()
+	repo := &failingDeleteRepo{stubRepo: newStubRepo()}
+	repo.snippets["expired"] = &storage.Snippet{ID: "expired", ExpiresAt: now.Add(-time.Minute)}
+
+	w := NewCleanupWorker(repo, CleanupConfig{Interval: time.Minute},
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+	w.now = func() time.Time { return now }
+
+	assert.Equal(t, CleanupStatus{}, w.Status(), "nothing recorded before the first run")
+
+	w.cleanup(context.Background())
+	assert.Equal(t, CleanupStatus{LastRun: now, LastDeleted: 1}, w.Status())
+
+	now = now.Add(time.Minute)
+	repo.fail = true
+	w.cleanup(context.Background())
+	status := w.Status()
+	assert.Equal(t, now, status.LastRun)
+	assert.Zero(t, status.LastDeleted)
+	assert.EqualError(t, status.LastError, "database unavailable")
+
+	now = now.Add(time.Minute)
+	repo.fail = false
+	w.cleanup(context.Background())
+	assert.Equal(t, CleanupStatus{LastRun: now}, w.Status(), "a successful run clears the error")
+}
+
+func TestCleanupWorker_Overdue(t *testing.T) {
+	now := time.Now()
+	w := NewCleanupWorker(newStubRepo(), CleanupConfig{Interval: time.Minute},
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+	w.now = func() time.Time { return now }
+
+	assert.False(t, w.overdue(), "never started")
+
+	w.startedAt = now
+	now = now.Add(90 * time.Second)
+	assert.False(t, w.overdue(), "first run still due")
+	now = now.Add(time.Minute)
+	assert.True(t, w.overdue(), "no run within twice the interval of starting")
+
+	w.cleanup(context.Background())
+	assert.False(t, w.overdue())
+	now = now.Add(2*time.Minute + time.Second)
+	assert.True(t, w.overdue())
+}
+
+func TestHandleReadyz_CleanupStatus(t *testing.T) {
+	now := time.Now()
+	s, stub := newTestServer(t, testConfig())
+	repo := &failingDeleteRepo{stubRepo: stub}
+	worker := NewCleanupWorker(repo, CleanupConfig{Interval: time.Minute},
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+	worker.now = func() time.Time { return now }
+	worker.startedAt = now
+
+	readyz := func() ReadyzResponse {
+		t.Helper()
+		rec := doRequest(s, http.MethodGet, "/readyz", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp ReadyzResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		return resp
+	}
+
+	resp := readyz()
+	assert.Equal(t, "ok", resp.Status)
+	assert.Nil(t, resp.Cleanup, "no cleanup status without a worker")
+
+	s.SetCleanupWorker(worker)
+	resp = readyz()
+	assert.Equal(t, "ok", resp.Status)
+	require.NotNil(t, resp.Cleanup)
+	assert.Nil(t, resp.Cleanup.LastRunAt)
+
+	stub.snippets["expired"] = &storage.Snippet{ID: "expired", ExpiresAt: now.Add(-time.Minute)}
+	worker.cleanup(context.Background())
+	resp = readyz()
+	assert.Equal(t, "ok", resp.Status)
+	require.NotNil(t, resp.Cleanup.LastRunAt)
+	assert.True(t, now.Equal(*resp.Cleanup.LastRunAt))
+	assert.Equal(t, int64(1), resp.Cleanup.LastDeletedCount)
+
+	repo.fail = true
+	worker.cleanup(context.Background())
+	resp = readyz()
+	assert.Equal(t, "degraded", resp.Status)
+	assert.Equal(t, "database unavailable", resp.Cleanup.LastError)
+
+	repo.fail = false
+	worker.cleanup(context.Background())
+	assert.Equal(t, "ok", readyz().Status)
+
+	now = now.Add(3 * time.Minute)
+	resp = readyz()
+	assert.Equal(t, "degraded", resp.Status)
+	assert.Contains(t, resp.Message, "interval")
+}
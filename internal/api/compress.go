@@ -0,0 +1,149 @@
+package api
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// compressMiddleware compresses responses for clients that accept gzip or
+// deflate. Bodies shorter than CompressMinSize are sent as is, as are
+// non-200 responses and ones that already carry a Content-Encoding.
+func (s *Server) compressMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := acceptedEncoding(r)
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{
+			ResponseWriter: w,
+			encoding:       encoding,
+			minSize:        s.config.CompressMinSize,
+		}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// compressWriter buffers the start of a response body until it knows
+// whether the body reaches minSize, then either compresses it or writes it
+// through unchanged.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int64
+
+	status      int
+	buf         []byte
+	enc         io.WriteCloser
+	passthrough bool
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.status != 0 || cw.passthrough {
+		return
+	}
+	if status != http.StatusOK || cw.Header().Get("Content-Encoding") != "" {
+		cw.passthrough = true
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.status = status
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 && !cw.passthrough {
+		cw.WriteHeader(http.StatusOK)
+	}
+	switch {
+	case cw.passthrough:
+		return cw.ResponseWriter.Write(p)
+	case cw.enc != nil:
+		return cw.enc.Write(p)
+	}
+
+	cw.buf = append(cw.buf, p...)
+	if int64(len(cw.buf)) >= cw.minSize {
+		if err := cw.startCompression(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// startCompression sends the headers and the buffered body through the
+// encoder. A strong ETag is weakened, since the compressed bytes differ from
+// the identity representation it was computed for.
+func (cw *compressWriter) startCompression() error {
+	h := cw.Header()
+	h.Set("Content-Encoding", cw.encoding)
+	h.Del("Content-Length")
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	if cw.encoding == "gzip" {
+		cw.enc = gzip.NewWriter(cw.ResponseWriter)
+	} else {
+		cw.enc, _ = flate.NewWriter(cw.ResponseWriter, flate.DefaultCompression)
+	}
+	buf := cw.buf
+	cw.buf = nil
+	_, err := cw.enc.Write(buf)
+	return err
+}
+
+// Close finishes the compressed stream, or writes out a body that stayed
+// under minSize.
+func (cw *compressWriter) Close() error {
+	switch {
+	case cw.passthrough:
+		return nil
+	case cw.enc != nil:
+		return cw.enc.Close()
+	case cw.status != 0:
+		cw.ResponseWriter.WriteHeader(cw.status)
+		_, err := cw.ResponseWriter.Write(cw.buf)
+		return err
+	}
+	return nil
+}
+
+// gzipMagic starts every gzip stream: the two ID bytes and the deflate
+// compression method (RFC 1952).
+var gzipMagic = []byte{0x1f, 0x8b, 0x08}
+
+// errGunzipTooLarge is returned by sniffGzip for a body that decompresses
+// to more than the limit.
+var errGunzipTooLarge = errors.New("decompressed content too large")
+
+// sniffGzip decompresses content if it is a complete gzip stream, reading
+// at most limit bytes of output so a small body cannot expand without
+// bound. Content that merely starts with the magic bytes but is not valid
+// gzip is returned unchanged.
+func sniffGzip(content []byte, limit int64) ([]byte, error) {
+	if !bytes.HasPrefix(content, gzipMagic) {
+		return content, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return content, nil
+	}
+	out, err := io.ReadAll(io.LimitReader(zr, limit+1))
+	if int64(len(out)) > limit {
+		return nil, errGunzipTooLarge
+	}
+	if err != nil {
+		return content, nil
+	}
+	return out, nil
+}
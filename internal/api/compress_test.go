@@ -0,0 +1,188 @@
+package api
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getWithEncoding(s *Server, target, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	return serve(s, req)
+}
+
+func TestHandleGet_Gzip(t *testing.T) {
+	cfg := testConfig()
+	cfg.CompressMinSize = 100
+	s, _ := newTestServer(t, cfg)
+	content := strings.Repeat("compress me please\n", 50)
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", content))
+
+	rec := getWithEncoding(s, "/"+created.ID, "gzip, deflate")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+	assert.Contains(t, rec.Header().Values("Vary"), "Accept-Encoding")
+	assert.Less(t, rec.Body.Len(), len(content))
+
+	zr, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(body))
+}
+
+func TestHandleGet_Deflate(t *testing.T) {
+	cfg := testConfig()
+	cfg.CompressMinSize = 100
+	s, _ := newTestServer(t, cfg)
+	content := strings.Repeat("deflate me\n", 50)
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", content))
+
+	rec := getWithEncoding(s, "/"+created.ID, "gzip;q=0, deflate")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "deflate", rec.Header().Get("Content-Encoding"))
+
+	body, err := io.ReadAll(flate.NewReader(rec.Body))
+	require.NoError(t, err)
+	assert.Equal(t, content, string(body))
+}
+
+func TestHandleGet_CompressMinSize(t *testing.T) {
+	cfg := testConfig()
+	cfg.CompressMinSize = 100
+	s, _ := newTestServer(t, cfg)
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "tiny"))
+
+	rec := getWithEncoding(s, "/"+created.ID, "gzip")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "tiny", rec.Body.String())
+}
+
+func TestHandleGet_NoAcceptEncoding(t *testing.T) {
+	cfg := testConfig()
+	cfg.CompressMinSize = 100
+	s, _ := newTestServer(t, cfg)
+	content := strings.Repeat("x", 500)
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", content))
+
+	for _, header := range []string{"", "identity", "br", "gzip;q=0"} {
+		rec := getWithEncoding(s, "/"+created.ID, header)
+		assert.Empty(t, rec.Header().Get("Content-Encoding"), header)
+		assert.Equal(t, content, rec.Body.String(), header)
+	}
+}
+
+func TestHealthz_NotCompressed(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+
+	rec := getWithEncoding(s, "/healthz", "gzip")
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+}
+
+func TestHandleGet_NotModifiedUncompressed(t *testing.T) {
+	cfg := testConfig()
+	cfg.CompressMinSize = 100
+	s, _ := newTestServer(t, cfg)
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", strings.Repeat("x", 500)))
+	etag := doRequest(s, http.MethodGet, "/"+created.ID, "").Header().Get("ETag")
+
+	req := httptest.NewRequest(http.MethodGet, "/"+created.ID, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("If-None-Match", etag)
+	rec := serve(s, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Empty(t, rec.Body.String())
+}
+
+func TestHandleGet_CompressedETagIsWeak(t *testing.T) {
+	cfg := testConfig()
+	cfg.CompressMinSize = 100
+	s, _ := newTestServer(t, cfg)
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", strings.Repeat("x", 500)))
+	etag := doRequest(s, http.MethodGet, "/"+created.ID, "").Header().Get("ETag")
+
+	rec := getWithEncoding(s, "/"+created.ID, "gzip")
+	require.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "W/"+etag, rec.Header().Get("ETag"))
+
+	// The weak tag still revalidates
+	req := httptest.NewRequest(http.MethodGet, "/"+created.ID, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	assert.Equal(t, http.StatusNotModified, serve(s, req).Code)
+}
+
+func gzipBytes(t *testing.T, data string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestHandleCreate_SniffGzip(t *testing.T) {
+	cfg := testConfig()
+	cfg.SniffGzip = true
+	s, repo := newTestServer(t, cfg)
+
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", string(gzipBytes(t, "compressed by mistake"))))
+	assert.Equal(t, "compressed by mistake", string(repo.snippets[created.ID].Content))
+
+	// Starts like gzip but is not a gzip stream, so it is stored as sent
+	raw := "\x1f\x8b\x08 looks like gzip but is not"
+	created = decodeCreate(t, doRequest(s, http.MethodPost, "/", raw))
+	assert.Equal(t, raw, string(repo.snippets[created.ID].Content))
+
+	// A truncated stream is not valid gzip either
+	truncated := gzipBytes(t, strings.Repeat("cut short ", 20))
+	truncated = truncated[:len(truncated)-6]
+	created = decodeCreate(t, doRequest(s, http.MethodPost, "/", string(truncated)))
+	assert.Equal(t, truncated, repo.snippets[created.ID].Content)
+}
+
+func TestHandleCreate_SniffGzipBomb(t *testing.T) {
+	cfg := testConfig()
+	cfg.SniffGzip = true
+	s, repo := newTestServer(t, cfg)
+
+	bomb := gzipBytes(t, strings.Repeat("0", 1<<16))
+	require.Less(t, int64(len(bomb)), cfg.MaxContentSize)
+
+	rec := doRequest(s, http.MethodPost, "/", string(bomb))
+	require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Empty(t, repo.snippets)
+}
+
+func TestHandleCreate_SniffGzipOff(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+
+	body := gzipBytes(t, "kept compressed")
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", string(body)))
+	assert.Equal(t, body, repo.snippets[created.ID].Content)
+
+	// A declared encoding is not second-guessed either
+	cfg := testConfig()
+	cfg.SniffGzip = true
+	s, repo = newTestServer(t, cfg)
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Encoding", "identity")
+	created = decodeCreate(t, serve(s, req))
+	assert.Equal(t, body, repo.snippets[created.ID].Content)
+}
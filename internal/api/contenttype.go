@@ -0,0 +1,60 @@
+package api
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+// defaultContentType is what snippets without a declared type are served as.
+const defaultContentType = "text/plain"
+
+// snippetContentTypes maps the media types accepted in
+// X-Snippet-Content-Type to the Content-Type they are served with. HTML is
+// accepted but served as plain text so it is shown, never rendered.
+var snippetContentTypes = map[string]string{
+	"text/plain":       "text/plain; charset=utf-8",
+	"application/json": "application/json",
+	"text/csv":         "text/csv; charset=utf-8",
+	"text/markdown":    "text/markdown; charset=utf-8",
+	"text/html":        "text/plain; charset=utf-8",
+}
+
+// parseSnippetContentType reads the optional X-Snippet-Content-Type header.
+// It returns "" for the default text/plain, so that only other types are
+// stored.
+func parseSnippetContentType(r *http.Request) (string, error) {
+	header := r.Header.Get("X-Snippet-Content-Type")
+	if header == "" {
+		return "", nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return "", fmt.Errorf("invalid content type %q", header)
+	}
+	if _, ok := snippetContentTypes[mediaType]; !ok {
+		return "", fmt.Errorf("unsupported content type %q (expected text/plain, application/json, text/csv, text/markdown or text/html)", mediaType)
+	}
+	if mediaType == defaultContentType {
+		return "", nil
+	}
+	return mediaType, nil
+}
+
+// servedContentType returns the Content-Type header for a stored type.
+func servedContentType(stored string) string {
+	if served, ok := snippetContentTypes[stored]; ok {
+		return served
+	}
+	return snippetContentTypes[defaultContentType]
+}
+
+// declaredContentType returns the stored type for display, defaulting to
+// text/plain.
+func declaredContentType(stored string) string {
+	if stored == "" {
+		return defaultContentType
+	}
+	return stored
+}
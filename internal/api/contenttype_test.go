@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createWithType(t *testing.T, s *Server, contentType, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("X-Snippet-Content-Type", contentType)
+	return serve(s, req)
+}
+
+func TestSnippetContentType_Served(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+
+	tests := map[string]string{
+		"application/json":             "application/json",
+		"text/csv":                     "text/csv; charset=utf-8",
+		"text/markdown; charset=utf-8": "text/markdown; charset=utf-8",
+		"TEXT/HTML":                    "text/plain; charset=utf-8",
+		"text/plain":                   "text/plain; charset=utf-8",
+	}
+	for declared, want := range tests {
+		created := decodeCreate(t, createWithType(t, s, declared, `{"a":1}`))
+
+		rec := doRequest(s, http.MethodGet, "/"+created.ID, "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, want, rec.Header().Get("Content-Type"), declared)
+		assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+		assert.Equal(t, `{"a":1}`, rec.Body.String())
+
+		head := serve(s, httptest.NewRequest(http.MethodHead, "/"+created.ID, nil))
+		assert.Equal(t, want, head.Header().Get("Content-Type"), declared)
+	}
+}
+
+func TestSnippetContentType_Default(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "plain"))
+
+	rec := doRequest(s, http.MethodGet, "/"+created.ID, "")
+	assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+
+	rec = doRequest(s, http.MethodGet, "/"+created.ID+"/meta", "")
+	var meta MetaResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &meta))
+	assert.Equal(t, "text/plain", meta.ContentType)
+}
+
+func TestSnippetContentType_Meta(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+	created := decodeCreate(t, createWithType(t, s, "text/csv", "a,b\n1,2\n"))
+
+	rec := doRequest(s, http.MethodGet, "/"+created.ID+"/meta", "")
+	var meta MetaResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &meta))
+	assert.Equal(t, "text/csv", meta.ContentType)
+}
+
+func TestSnippetContentType_Rejected(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+
+	for _, declared := range []string{"application/javascript", "image/svg+xml", "not a type"} {
+		rec := createWithType(t, s, declared, "alert(1)")
+		require.Equal(t, http.StatusBadRequest, rec.Code, declared)
+		assert.Equal(t, "content_type", decodeError(t, rec).Details["field"])
+	}
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// corsMaxAge is how many seconds browsers may cache a preflight answer.
+const corsMaxAge = "600"
+
+// corsAllowedMethods are the methods browser scripts may use.
+const corsAllowedMethods = "GET, HEAD, POST, PATCH, DELETE"
+
+// corsAllowedHeaders are the request headers the API reads beyond the ones
+// browsers always allow.
+var corsAllowedHeaders = strings.Join([]string{
+	"Authorization",
+	"Content-Encoding",
+	"Content-Type",
+	"Idempotency-Key",
+	"X-API-Key",
+	"If-None-Match",
+	"X-Append-Token",
+	"X-Custom-ID",
+	"X-Delete-Token",
+	"X-Pin",
+	"X-Snippet-Content-Type",
+}, ", ")
+
+// corsExposedHeaders are the response headers scripts may read beyond the
+// ones browsers always expose.
+var corsExposedHeaders = strings.Join([]string{
+	"Content-Disposition",
+	"ETag",
+	"Idempotent-Replayed",
+	"Retry-After",
+	"X-Append-Token",
+	"X-Creation-Receipt",
+	"X-Delete-Token",
+	"X-Expires-At",
+	"X-Quota-Limit",
+	"X-Quota-Remaining",
+	"X-Quota-Used",
+	"X-RateLimit-Limit",
+	"X-RateLimit-Remaining",
+	"X-RateLimit-Reset",
+}, ", ")
+
+// corsMiddleware lets browser scripts from CORSAllowedOrigins call the
+// API. Preflight requests from an allowed origin are answered here, before
+// routing and rate limiting; other origins get no CORS headers, so the
+// browser blocks them.
+func (s *Server) corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Responses differ by origin, so caches must keep them apart
+		w.Header().Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if preflight {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if !s.corsAllowed(origin) {
+			if preflight {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if preflight {
+			w.Header().Set("Access-Control-Allow-Methods", corsAllowedMethods)
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+			w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// corsAllowed reports whether origin is in CORSAllowedOrigins.
+func (s *Server) corsAllowed(origin string) bool {
+	if slices.Contains(s.config.CORSAllowedOrigins, "*") {
+		return true
+	}
+	return slices.ContainsFunc(s.config.CORSAllowedOrigins, func(allowed string) bool {
+		return strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin)
+	})
+}
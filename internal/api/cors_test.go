@@ -0,0 +1,103 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func corsServer(t *testing.T, origins ...string) *Server {
+	t.Helper()
+
+	cfg := testConfig()
+	cfg.CORSAllowedOrigins = origins
+	s, _ := newTestServer(t, cfg)
+	return s
+}
+
+func TestCORS_AllowedOrigin(t *testing.T) {
+	s := corsServer(t, "https://app.example.com")
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("content"))
+	req.Header.Set("Origin", "https://app.example.com")
+	rec := serve(s, req)
+
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, rec.Header().Get("Access-Control-Expose-Headers"), "X-Delete-Token")
+	assert.Contains(t, rec.Header().Values("Vary"), "Origin")
+
+	created := decodeCreate(t, rec)
+	req = httptest.NewRequest(http.MethodGet, "/"+created.ID, nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rec = serve(s, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORS_DisallowedOrigin(t *testing.T) {
+	s := corsServer(t, "https://app.example.com")
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("content"))
+	req.Header.Set("Origin", "https://evil.example.com")
+	rec := serve(s, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code, "the browser, not the server, blocks the response")
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+
+	req = httptest.NewRequest(http.MethodOptions, "/", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	rec = serve(s, req)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Methods"))
+}
+
+func TestCORS_Preflight(t *testing.T) {
+	s := corsServer(t, "https://app.example.com")
+
+	for _, path := range []string{"/", "/AlNqaGNP4POi"} {
+		req := httptest.NewRequest(http.MethodOptions, path, nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		req.Header.Set("Access-Control-Request-Headers", "x-pin, x-snippet-content-type")
+		rec := serve(s, req)
+
+		require.Equal(t, http.StatusNoContent, rec.Code, path)
+		assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Contains(t, rec.Header().Get("Access-Control-Allow-Methods"), "POST")
+		assert.Contains(t, rec.Header().Get("Access-Control-Allow-Methods"), "GET")
+		assert.Contains(t, rec.Header().Get("Access-Control-Allow-Headers"), "X-Pin")
+		assert.Contains(t, rec.Header().Get("Access-Control-Allow-Headers"), "X-Snippet-Content-Type")
+		assert.NotEmpty(t, rec.Header().Get("Access-Control-Max-Age"))
+	}
+}
+
+func TestCORS_Wildcard(t *testing.T) {
+	s := corsServer(t, "*")
+
+	req := httptest.NewRequest(http.MethodOptions, "/", nil)
+	req.Header.Set("Origin", "http://localhost:5173")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	rec := serve(s, req)
+
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "http://localhost:5173", rec.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORS_DisabledByDefault(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+
+	req := httptest.NewRequest(http.MethodOptions, "/", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	rec := serve(s, req)
+
+	assert.NotEqual(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, rec.Header().Values("Vary"))
+}
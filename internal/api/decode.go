@@ -0,0 +1,61 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"net/http"
+)
+
+// errNotBase64 is returned when a snippet cannot be served with
+// ?decode=base64.
+var errNotBase64 = errors.New("snippet content is not valid base64")
+
+// base64Encodings are tried in order by decodeBase64.
+var base64Encodings = []*base64.Encoding{
+	base64.StdEncoding,
+	base64.RawStdEncoding,
+	base64.URLEncoding,
+	base64.RawURLEncoding,
+}
+
+// decodeBase64 decodes content stored as base64 text. Standard and URL-safe
+// alphabets are accepted, with or without padding, and line breaks such as
+// those of `base64` output are ignored.
+func decodeBase64(content []byte) ([]byte, error) {
+	compact := bytes.Join(bytes.Fields(content), nil)
+	if len(compact) == 0 {
+		return nil, errNotBase64
+	}
+	for _, enc := range base64Encodings {
+		if decoded, err := enc.DecodeString(string(compact)); err == nil {
+			return decoded, nil
+		}
+	}
+	return nil, errNotBase64
+}
+
+// checkDecode validates ?decode= and, when it is set, that the snippet can
+// be decoded. The check uses Peek so that a rejected request does not use up
+// a view. It writes a 400 and returns false on failure.
+func (s *Server) checkDecode(w http.ResponseWriter, r *http.Request, snippetID string) bool {
+	switch r.URL.Query().Get("decode") {
+	case "":
+		return true
+	case "base64":
+	default:
+		badRequestField(w, "decode", "unsupported decoding, expected base64")
+		return false
+	}
+
+	snippet, err := s.repoFor(r).Peek(r.Context(), snippetID)
+	if err != nil || snippet == nil {
+		// Let the regular fetch report it
+		return true
+	}
+	if _, err := decodeBase64(snippet.Content); err != nil {
+		badRequestField(w, "decode", err.Error())
+		return false
+	}
+	return true
+}
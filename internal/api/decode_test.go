@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleGet_DecodeBase64(t *testing.T) {
+	cfg := testConfig()
+	cfg.DetectBinary = false
+	s, _ := newTestServer(t, cfg)
+
+	binary := []byte{0x89, 'P', 'N', 'G', 0x00, 0x01, 0xfe, 0xff}
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", base64.StdEncoding.EncodeToString(binary)))
+
+	rec := doRequest(s, http.MethodGet, "/"+created.ID+"?decode=base64", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, binary, rec.Body.Bytes())
+	assert.Equal(t, "application/octet-stream", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), "attachment")
+
+	text := doRequest(s, http.MethodGet, "/"+created.ID, "")
+	assert.Equal(t, base64.StdEncoding.EncodeToString(binary), text.Body.String())
+	assert.NotEqual(t, text.Header().Get("ETag"), rec.Header().Get("ETag"))
+}
+
+func TestHandleGet_DecodeBase64Text(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+	// Wrapped, unpadded URL-safe base64 of "hello, world??"
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "aGVsbG8sIHdv\ncmxkPz8"))
+
+	rec := doRequest(s, http.MethodGet, "/"+created.ID+"?decode=base64", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "hello, world??", rec.Body.String())
+	assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+}
+
+func TestHandleGet_DecodeInvalid(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/?max_views=1", "not base64!"))
+
+	rec := doRequest(s, http.MethodGet, "/"+created.ID+"?decode=base64", "")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "decode", decodeError(t, rec).Details["field"])
+	assert.Equal(t, 0, repo.snippets[created.ID].ViewCount, "a rejected decode must not use up a view")
+
+	rec = doRequest(s, http.MethodGet, "/"+created.ID+"?decode=hex", "")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "decode", decodeError(t, rec).Details["field"])
+
+	rec = doRequest(s, http.MethodGet, "/"+created.ID, "")
+	assert.Equal(t, "not base64!", rec.Body.String())
+}
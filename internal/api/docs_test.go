@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	var spec struct {
+		OpenAPI    string                    `yaml:"openapi"`
+		Paths      map[string]map[string]any `yaml:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]struct {
+					Enum []string `yaml:"enum"`
+				} `yaml:"properties"`
+			} `yaml:"schemas"`
+		} `yaml:"components"`
+	}
+	require.NoError(t, yaml.Unmarshal(openAPISpec, &spec))
+
+	assert.True(t, strings.HasPrefix(spec.OpenAPI, "3."))
+	assert.Contains(t, spec.Paths["/"], "post")
+	assert.Contains(t, spec.Paths["/{id}"], "get")
+	assert.Contains(t, spec.Paths["/{id}"], "delete")
+
+	codes := spec.Components.Schemas["APIError"].Properties["code"].Enum
+	assert.ElementsMatch(t, []string{
+		ErrCodeBadRequest, ErrCodeNotFound, ErrCodeTooLarge, ErrCodeRateLimited,
+		ErrCodeInternalError, ErrCodeInvalidExpiry, ErrCodeEmptyContent, ErrCodeInvalidID,
+		ErrCodeUnauthorized, ErrCodeUnsupported, ErrCodeForbidden, ErrCodePrecondition,
+		ErrCodeConflict, ErrCodePinRequired, ErrCodeLocked, ErrCodeQuotaExceeded,
+		ErrCodeKeyReused,
+	}, codes, "the spec lists every error code")
+}
+
+func TestHandleOpenAPI(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+
+	rec := doRequest(s, http.MethodGet, "/openapi.yaml", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/yaml", rec.Header().Get("Content-Type"))
+	assert.Equal(t, openAPISpec, rec.Body.Bytes())
+
+	rec = doRequest(s, http.MethodGet, "/docs", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, rec.Body.String(), `url: "/openapi.yaml"`)
+}
+
+func TestHandleOpenAPI_NotRateLimited(t *testing.T) {
+	cfg := testConfig()
+	cfg.GetRateLimit = 1
+	s, _ := newTestServer(t, cfg)
+
+	for range 3 {
+		assert.Equal(t, http.StatusOK, doRequest(s, http.MethodGet, "/openapi.yaml", "").Code)
+		assert.Equal(t, http.StatusOK, doRequest(s, http.MethodGet, "/docs", "").Code)
+	}
+}
@@ -4,6 +4,8 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 // Error codes for API responses.
@@ -16,14 +18,28 @@ const (
 	ErrCodeInvalidExpiry  = "INVALID_EXPIRY"
 	ErrCodeEmptyContent   = "EMPTY_CONTENT"
 	ErrCodeInvalidID      = "INVALID_ID"
+	ErrCodeUnauthorized   = "UNAUTHORIZED"
+	ErrCodeUnsupported    = "UNSUPPORTED_MEDIA_TYPE"
+	ErrCodeForbidden      = "FORBIDDEN"
+	ErrCodePrecondition   = "PRECONDITION_FAILED"
+	ErrCodeConflict       = "CONFLICT"
+	ErrCodePinRequired    = "PIN_REQUIRED"
+	ErrCodeLocked         = "LOCKED"
+	ErrCodeQuotaExceeded  = "QUOTA_EXCEEDED"
+	ErrCodeKeyReused      = "IDEMPOTENCY_KEY_REUSED"
 )
 
 // APIError represents an error response.
 type APIError struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Details ErrorDetails `json:"details,omitempty"`
 }
 
+// ErrorDetails carries machine-readable context for an error, such as the
+// offending field and its allowed range.
+type ErrorDetails map[string]string
+
 // ErrorResponse is the JSON structure for error responses.
 type ErrorResponse struct {
 	Error APIError `json:"error"`
@@ -31,6 +47,11 @@ type ErrorResponse struct {
 
 // writeError sends a JSON error response.
 func writeError(w http.ResponseWriter, statusCode int, code, message string) {
+	writeErrorDetails(w, statusCode, code, message, nil)
+}
+
+// writeErrorDetails sends a JSON error response with optional details.
+func writeErrorDetails(w http.ResponseWriter, statusCode int, code, message string, details ErrorDetails) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 
@@ -38,6 +59,7 @@ func writeError(w http.ResponseWriter, statusCode int, code, message string) {
 		Error: APIError{
 			Code:    code,
 			Message: message,
+			Details: details,
 		},
 	}
 
@@ -50,6 +72,11 @@ func badRequest(w http.ResponseWriter, message string) {
 	writeError(w, http.StatusBadRequest, ErrCodeBadRequest, message)
 }
 
+func badRequestField(w http.ResponseWriter, field, message string) {
+	writeErrorDetails(w, http.StatusBadRequest, ErrCodeBadRequest, message,
+		ErrorDetails{"field": field})
+}
+
 func notFound(w http.ResponseWriter) {
 	writeError(w, http.StatusNotFound, ErrCodeNotFound, "snippet not found or expired")
 }
@@ -69,8 +96,8 @@ func internalError(w http.ResponseWriter) {
 		"an internal error occurred")
 }
 
-func invalidExpiry(w http.ResponseWriter, message string) {
-	writeError(w, http.StatusBadRequest, ErrCodeInvalidExpiry, message)
+func invalidExpiry(w http.ResponseWriter, message string, details ErrorDetails) {
+	writeErrorDetails(w, http.StatusBadRequest, ErrCodeInvalidExpiry, message, details)
 }
 
 func emptyContent(w http.ResponseWriter) {
@@ -82,3 +109,47 @@ func invalidID(w http.ResponseWriter) {
 	writeError(w, http.StatusBadRequest, ErrCodeInvalidID, 
 		"invalid snippet ID format")
 }
+
+func unauthorized(w http.ResponseWriter) {
+	writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized,
+		"missing or invalid credentials")
+}
+
+func unsupportedMediaType(w http.ResponseWriter, message string) {
+	writeError(w, http.StatusUnsupportedMediaType, ErrCodeUnsupported, message)
+}
+
+func forbidden(w http.ResponseWriter, message string) {
+	writeError(w, http.StatusForbidden, ErrCodeForbidden, message)
+}
+
+func pinRequired(w http.ResponseWriter) {
+	writeError(w, http.StatusUnauthorized, ErrCodePinRequired,
+		"this snippet needs a PIN in the X-Pin header")
+}
+
+// locked writes 423 Locked with a Retry-After of wait, rounded up.
+func locked(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+	writeError(w, http.StatusLocked, ErrCodeLocked, "too many wrong PINs, try again later")
+}
+
+// quotaExceeded writes 429 with a Retry-After of wait, rounded up.
+func quotaExceeded(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+	writeError(w, http.StatusTooManyRequests, ErrCodeQuotaExceeded,
+		"upload quota exceeded, try again later")
+}
+
+func conflict(w http.ResponseWriter, message string) {
+	writeError(w, http.StatusConflict, ErrCodeConflict, message)
+}
+
+func preconditionFailed(w http.ResponseWriter, message string) {
+	writeError(w, http.StatusPreconditionFailed, ErrCodePrecondition, message)
+}
+
+func idempotencyKeyReused(w http.ResponseWriter) {
+	writeError(w, http.StatusUnprocessableEntity, ErrCodeKeyReused,
+		"Idempotency-Key was already used with different content")
+}
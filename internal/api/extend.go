@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/id"
+	"github.com/rayenfassatoui/tafcha-cli/internal/storage"
+)
+
+// ExtendResponse is the response for PATCH /{id}.
+type ExtendResponse struct {
+	ID        string    `json:"id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	ExpiresIn int64     `json:"expires_in"`
+}
+
+// handleExtend handles PATCH /{id}?expiry=7d, which gives a snippet a new
+// expiry counted from now. It needs the snippet's delete token, like
+// DELETE, or the admin token.
+func (s *Server) handleExtend(w http.ResponseWriter, r *http.Request) {
+	reqID := middleware.GetReqID(r.Context())
+	snippetID := chi.URLParam(r, "id")
+
+	if !id.IsValidCustom(snippetID) {
+		invalidID(w)
+		return
+	}
+
+	admin := s.isAdmin(r)
+	token := deleteToken(r)
+	if token == "" && !admin {
+		unauthorized(w)
+		return
+	}
+
+	q := r.URL.Query()
+	if q.Get("expiry") == "" && q.Get("expire_at") == "" {
+		invalidExpiry(w, "expiry is required", ErrorDetails{"field": "expiry"})
+		return
+	}
+	expiryDuration, ok := s.parseExpiry(w, r)
+	if !ok {
+		return
+	}
+
+	// Peek so a rejected extension does not use up a view
+	repo := s.repoFor(r)
+	snippet, err := repo.Peek(r.Context(), snippetID)
+	if err != nil {
+		s.logger.Error("failed to fetch snippet",
+			"error", err,
+			"snippet_id", snippetID,
+			"request_id", reqID)
+		internalError(w)
+		return
+	}
+	if snippet == nil {
+		notFound(w)
+		return
+	}
+	if !admin && !tokenMatches(snippet.DeleteTokenHash, token) {
+		unauthorized(w)
+		return
+	}
+
+	expiresAt := expiresAtFor(expiryDuration)
+	err = repo.Extend(r.Context(), snippetID, expiresAt)
+	if errors.Is(err, storage.ErrNotFound) {
+		notFound(w)
+		return
+	}
+	if err != nil {
+		s.logger.Error("failed to extend snippet",
+			"error", err,
+			"snippet_id", snippetID,
+			"request_id", reqID)
+		internalError(w)
+		return
+	}
+
+	s.logger.Info("snippet extended",
+		"snippet_id", snippetID,
+		"expires_at", expiresAt,
+		"request_id", reqID,
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ExtendResponse{
+		ID:        snippetID,
+		ExpiresAt: expiresAt,
+		ExpiresIn: max(int64(time.Until(expiresAt)/time.Second), 0),
+	})
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func extendSnippet(s *Server, id, query string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPatch, "/"+id+query, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return serve(s, req)
+}
+
+func TestHandleExtend(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/?expiry=1h", "keep me longer"))
+
+	rec := extendSnippet(s, created.ID, "?expiry=7d", map[string]string{"X-Delete-Token": created.DeleteToken})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp ExtendResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, created.ID, resp.ID)
+	assert.WithinDuration(t, time.Now().Add(7*24*time.Hour), resp.ExpiresAt, time.Minute)
+	assert.InDelta(t, 7*24*3600, resp.ExpiresIn, 60)
+	assert.True(t, repo.snippets[created.ID].ExpiresAt.Equal(resp.ExpiresAt))
+}
+
+func TestHandleExtend_Rejected(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/?expiry=1h", "keep me"))
+	token := map[string]string{"X-Delete-Token": created.DeleteToken}
+
+	tests := []struct {
+		name    string
+		id      string
+		query   string
+		headers map[string]string
+		status  int
+	}{
+		{"no token", created.ID, "?expiry=7d", nil, http.StatusUnauthorized},
+		{"wrong token", created.ID, "?expiry=7d", map[string]string{"X-Delete-Token": "wrong"}, http.StatusUnauthorized},
+		{"no expiry", created.ID, "", token, http.StatusBadRequest},
+		{"above maximum", created.ID, "?expiry=60d", token, http.StatusBadRequest},
+		{"never without admin", created.ID, "?expiry=never", token, http.StatusForbidden},
+		{"unknown snippet", "abc123XYZ789", "?expiry=7d", token, http.StatusNotFound},
+		{"invalid id", "bad!", "?expiry=7d", token, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := extendSnippet(s, tt.id, tt.query, tt.headers)
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+	assert.WithinDuration(t, time.Now().Add(time.Hour), repo.snippets[created.ID].ExpiresAt, time.Minute)
+}
+
+func TestHandleExtend_AdminToken(t *testing.T) {
+	cfg := testConfig()
+	cfg.AdminToken = "s3cret"
+	s, repo := newTestServer(t, cfg)
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/?expiry=1h", "keep me"))
+
+	rec := extendSnippet(s, created.ID, "?expiry=2d", map[string]string{"Authorization": "Bearer s3cret"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.WithinDuration(t, time.Now().Add(48*time.Hour), repo.snippets[created.ID].ExpiresAt, time.Minute)
+}
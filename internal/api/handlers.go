@@ -3,21 +3,61 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 
+	"github.com/rayenfassatoui/tafcha-cli/internal/config"
 	"github.com/rayenfassatoui/tafcha-cli/internal/expiry"
 	"github.com/rayenfassatoui/tafcha-cli/internal/id"
+	"github.com/rayenfassatoui/tafcha-cli/internal/storage"
+	"github.com/rayenfassatoui/tafcha-cli/internal/transform"
 )
 
 // CreateResponse is the response for successful snippet creation.
 type CreateResponse struct {
 	ID        string    `json:"id"`
 	URL       string    `json:"url"`
+	ShortCode string    `json:"short_code"`
+	RawURL    string    `json:"raw_url"`
+	ExpiresAt time.Time `json:"expires_at"`
+
+	// ExpiresIn is the seconds left until ExpiresAt when the response was
+	// sent, for clients that cannot trust their clock or parse RFC 3339.
+	ExpiresIn int64 `json:"expires_in"`
+
+	// AppendToken is only returned for snippets created with ?appendable=true.
+	AppendToken string `json:"append_token,omitempty"`
+
+	// DeleteToken authorizes DELETE /{id}. It is not returned when an
+	// existing snippet is handed back for duplicate content.
+	DeleteToken string `json:"delete_token,omitempty"`
+
+	// DeleteURL is the snippet URL with the delete token in ?token=, ready
+	// for DELETE. Set whenever DeleteToken is.
+	DeleteURL string `json:"delete_url,omitempty"`
+
+	// RemainingViews is set for snippets created with ?burn or ?max_views.
+	RemainingViews *int `json:"remaining_views,omitempty"`
+
+	// CreationReceipt proves who created the snippet, see
+	// POST /verify-receipt. Only set when RECEIPT_SIGNING_KEY is configured.
+	CreationReceipt string `json:"creation_receipt,omitempty"`
+}
+
+// AppendResponse is the response for a successful append.
+type AppendResponse struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	SizeBytes int       `json:"size_bytes"`
 	ExpiresAt time.Time `json:"expires_at"`
 }
 
@@ -25,24 +65,85 @@ type CreateResponse struct {
 func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
 	reqID := middleware.GetReqID(r.Context())
 
-	// Parse expiry from query parameter or use default
-	expiryDuration := s.config.DefaultExpiry
-	if expiryStr := r.URL.Query().Get("expiry"); expiryStr != "" {
-		parsed, err := expiry.Parse(expiryStr)
-		if err != nil {
-			invalidExpiry(w, err.Error())
+	// Parse expiry from query parameter; without one the default depends
+	// on the upload size and is resolved once the content is final
+	expiryDuration, ok := s.parseExpiry(w, r)
+	if !ok {
+		return
+	}
+
+	// Optional view limit; ?burn=true is shorthand for max_views=1
+	maxViews, err := parseMaxViews(r)
+	if err != nil {
+		badRequestField(w, "max_views", err.Error())
+		return
+	}
+
+	// Optional PIN readers must send in X-Pin. Wrong PINs must not use up
+	// views, so PIN protection and view limits do not mix.
+	pin, err := parsePin(r)
+	if err != nil {
+		badRequestField(w, "pin", err.Error())
+		return
+	}
+	if pin != "" && maxViews > 0 {
+		badRequestField(w, "pin", "a PIN cannot be combined with burn or max_views")
+		return
+	}
+
+	// Optional caller-chosen ID instead of a generated one
+	customID, err := parseCustomID(r)
+	if err != nil {
+		badRequestField(w, "id", err.Error())
+		return
+	}
+
+	// Optional declared media type, served back on GET
+	contentType, err := parseSnippetContentType(r)
+	if err != nil {
+		badRequestField(w, "content_type", err.Error())
+		return
+	}
+
+	// Optional key under which a retry gets this create replayed
+	idempotencyKey, err := s.parseIdempotencyKey(r)
+	if err != nil {
+		badRequestField(w, "Idempotency-Key", err.Error())
+		return
+	}
+
+	// Resolve optional template before reading the body
+	var tmpl *config.Template
+	if name := r.URL.Query().Get("template"); name != "" {
+		t, ok := s.config.Templates[name]
+		if !ok {
+			badRequestField(w, "template", "unknown template: "+name)
 			return
 		}
+		tmpl = &t
+	}
 
-		if err := expiry.Validate(parsed, s.config.MinExpiry, s.config.MaxExpiry); err != nil {
-			invalidExpiry(w, err.Error())
+	// Resolve optional content transforms, e.g. ?transform=wrap:80
+	var transformFn transform.Func
+	if spec := r.URL.Query().Get("transform"); spec != "" {
+		fn, err := transform.Parse(spec)
+		if err != nil {
+			badRequestField(w, "transform", err.Error())
 			return
 		}
+		transformFn = fn
+	}
 
-		expiryDuration = parsed
+	// Reject a declared oversized body before reading any of it. Nothing
+	// has read the body yet, so for "Expect: 100-continue" requests the
+	// server never sends 100 Continue and the client never streams it.
+	if r.ContentLength > s.config.MaxContentSize {
+		payloadTooLarge(w, s.config.MaxContentSize)
+		return
 	}
 
-	// Read body with size limit
+	// Read body with size limit; this catches chunked bodies that declare
+	// no length
 	limitedReader := io.LimitReader(r.Body, s.config.MaxContentSize+1)
 	content, err := io.ReadAll(limitedReader)
 	if err != nil {
@@ -59,14 +160,113 @@ func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Undo gzip from clients that compress without saying so
+	if s.config.SniffGzip && r.Header.Get("Content-Encoding") == "" {
+		if content, err = sniffGzip(content, s.config.MaxContentSize); err != nil {
+			payloadTooLarge(w, s.config.MaxContentSize)
+			return
+		}
+	}
+
 	// Check for empty content
 	if len(content) == 0 {
 		emptyContent(w)
 		return
 	}
 
-	// Generate unique ID
-	snippetID, err := s.idGenerator.Generate()
+	// Transform the submitted content before any template is added
+	if transformFn != nil {
+		content, err = transformFn(content)
+		if err != nil {
+			badRequestField(w, "transform", err.Error())
+			return
+		}
+		if int64(len(content)) > s.config.MaxContentSize {
+			payloadTooLarge(w, s.config.MaxContentSize)
+			return
+		}
+	}
+
+	// Wrap content in the requested template; the limit applies to the result
+	if tmpl != nil {
+		content = applyTemplate(*tmpl, content)
+		if int64(len(content)) > s.config.MaxContentSize {
+			payloadTooLarge(w, s.config.MaxContentSize)
+			return
+		}
+	}
+
+	if expiryDuration == 0 {
+		expiryDuration = s.config.DefaultExpiryFor(int64(len(content)))
+	}
+
+	creator := s.creatorHash(clientIP(r))
+	contentHash := s.hasher.Sum(content)
+	expiresAt := expiresAtFor(expiryDuration)
+
+	// Return an existing snippet instead of storing a duplicate: the
+	// creator's own with UniqueContentPerCreator, anyone's with ?dedupe=true.
+	// Appendable, view-limited, custom-ID and PIN snippets are skipped since
+	// the caller expects a fresh token, view budget, the chosen ID or a PIN.
+	dedupe := s.config.DedupeEnabled && r.URL.Query().Get("dedupe") == "true"
+	if (s.config.UniqueContentPerCreator || dedupe) && maxViews == 0 && customID == "" && pin == "" &&
+		r.URL.Query().Get("appendable") != "true" {
+		lookupCreator := creator
+		if dedupe {
+			lookupCreator = ""
+		}
+		existing, err := s.repoFor(r).FindByContent(r.Context(), lookupCreator, contentHash)
+		if err != nil {
+			s.logger.Error("failed to look up duplicate content",
+				"error", err,
+				"request_id", reqID)
+			internalError(w)
+			return
+		}
+		// A match needs the same content type, and another creator's snippet
+		// must be neither PIN-protected nor still open for appends
+		if existing != nil && (existing.ContentType != contentType ||
+			existing.Creator != creator && (existing.PinHash != "" || existing.AppendTokenHash != "")) {
+			existing = nil
+		}
+		// A deduplicated snippet lives at least as long as requested
+		if dedupe && existing != nil && existing.ExpiresAt.Before(expiresAt) {
+			switch err := s.repoFor(r).Extend(r.Context(), existing.ID, expiresAt); {
+			case errors.Is(err, storage.ErrNotFound):
+				// It expired in the meantime; store the content anew
+				existing = nil
+			case err != nil:
+				s.logger.Error("failed to extend duplicate snippet",
+					"error", err,
+					"snippet_id", existing.ID,
+					"request_id", reqID)
+				internalError(w)
+				return
+			default:
+				existing.ExpiresAt = expiresAt
+			}
+		}
+		if existing != nil {
+			s.logger.Info("returning existing snippet for duplicate content",
+				"snippet_id", existing.ID,
+				"request_id", reqID,
+			)
+			// Someone else's snippet is only pointed to: no 201 and no
+			// creation receipt, which would claim the caller created it
+			if existing.Creator != creator {
+				s.writeCreateResponse(w, r, existing, http.StatusOK, "", "")
+				return
+			}
+			s.writeCreated(w, r, existing, "", "")
+			return
+		}
+	}
+
+	// Generate unique ID unless the caller chose one
+	snippetID := customID
+	if snippetID == "" {
+		snippetID, err = s.idGenerator.Generate()
+	}
 	if err != nil {
 		s.logger.Error("failed to generate ID", 
 			"error", err, 
@@ -75,11 +275,92 @@ func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Calculate expiry time
-	expiresAt := time.Now().Add(expiryDuration)
+	newSnippet := &storage.Snippet{
+		ID:        snippetID,
+		Content:   content,
+		ExpiresAt: expiresAt,
+		Creator:   creator,
+		MaxViews:  maxViews,
 
-	// Store snippet
-	snippet, err := s.repo.Create(snippetID, content, expiresAt)
+		ContentHash: contentHash,
+		ContentType: contentType,
+	}
+	if pin != "" {
+		if newSnippet.PinHash, err = pinHash(pin); err != nil {
+			s.logger.Error("failed to hash PIN",
+				"error", err,
+				"request_id", reqID)
+			internalError(w)
+			return
+		}
+	}
+
+	// Every snippet gets a secret its creator needs to delete it
+	deleteToken, err := newToken()
+	if err != nil {
+		s.logger.Error("failed to generate delete token",
+			"error", err,
+			"request_id", reqID)
+		internalError(w)
+		return
+	}
+	newSnippet.DeleteTokenHash = tokenHash(deleteToken)
+
+	// Appendable snippets get a secret the creator needs for later appends
+	var appendToken string
+	if r.URL.Query().Get("appendable") == "true" {
+		appendToken, err = newToken()
+		if err != nil {
+			s.logger.Error("failed to generate append token",
+				"error", err,
+				"request_id", reqID)
+			internalError(w)
+			return
+		}
+		newSnippet.AppendTokenHash = tokenHash(appendToken)
+	}
+
+	// Count the upload against the creator's quota, giving it back if the
+	// snippet is not stored
+	var quotaCost int64
+	if s.quota != nil {
+		quotaCost = s.quota.cost(len(content))
+		used, reset, ok := s.quota.reserve(creator, quotaCost)
+		s.quota.setHeaders(w, used)
+		if !ok {
+			quotaExceeded(w, time.Until(reset))
+			return
+		}
+	}
+
+	// Store snippet, once per Idempotency-Key
+	var snippet *storage.Snippet
+	var replay *idempotentCreate
+	if idempotencyKey != "" {
+		call := s.newIdempotentCall(r, creator, idempotencyKey, content)
+		snippet, replay, err = s.createIdempotent(r.Context(), s.repoFor(r), call, newSnippet, appendToken, deleteToken)
+	} else {
+		snippet, err = s.repoFor(r).Create(r.Context(), newSnippet)
+	}
+	if (err != nil || replay != nil) && s.quota != nil {
+		s.quota.setHeaders(w, s.quota.release(creator, quotaCost))
+	}
+	if replay != nil {
+		s.replayCreate(w, r, replay)
+		return
+	}
+	if errors.Is(err, errIdempotencyKeyReused) {
+		idempotencyKeyReused(w)
+		return
+	}
+	if errors.Is(err, storage.ErrConflict) {
+		if r.Header.Get("If-None-Match") == "*" {
+			preconditionFailed(w, "a snippet with this ID already exists")
+			return
+		}
+		conflict(w, "a snippet with this ID already exists")
+		return
+	}
 	if err != nil {
 		s.logger.Error("failed to store snippet", 
 			"error", err, 
@@ -95,31 +376,430 @@ func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
 		"request_id", reqID,
 	)
 
-	// Build response
+	s.writeCreated(w, r, snippet, appendToken, deleteToken)
+}
+
+// parseExpiry reads and validates ?expiry=. It returns 0 when the parameter
+// is absent and writes an error response and returns false when it is
+// invalid.
+func (s *Server) parseExpiry(w http.ResponseWriter, r *http.Request) (time.Duration, bool) {
+	expiryStr := r.URL.Query().Get("expiry")
+	if expireAt := r.URL.Query().Get("expire_at"); expireAt != "" {
+		if expiryStr != "" {
+			invalidExpiry(w, "expiry and expire_at cannot be combined", ErrorDetails{"field": "expire_at"})
+			return 0, false
+		}
+		return s.parseExpireAt(w, expireAt)
+	}
+	if expiryStr == "" {
+		return 0, true
+	}
+
+	now := time.Now()
+	if loc := s.config.ExpiryLocation; loc != nil {
+		now = now.In(loc)
+	}
+	parsed, err := expiry.ParseAt(expiryStr, now)
+	if err != nil {
+		invalidExpiry(w, err.Error(), ErrorDetails{"field": "expiry", "value": expiryStr})
+		return 0, false
+	}
+
+	if parsed == expiry.Never {
+		if !s.isAdmin(r) {
+			writeErrorDetails(w, http.StatusForbidden, ErrCodeForbidden,
+				"expiry=never requires the admin token", ErrorDetails{"field": "expiry", "value": expiryStr})
+			return 0, false
+		}
+		return parsed, true
+	}
+
+	if err := expiry.CheckUnit(expiryStr, s.config.AllowedExpiryUnits); err != nil {
+		invalidExpiry(w, err.Error(), ErrorDetails{
+			"field":   "expiry",
+			"value":   expiryStr,
+			"allowed": strings.Join(s.config.AllowedExpiryUnits, ","),
+		})
+		return 0, false
+	}
+
+	if err := expiry.Validate(parsed, s.config.MinExpiry, s.config.MaxExpiry); err != nil {
+		invalidExpiry(w, err.Error(), ErrorDetails{
+			"field": "expiry",
+			"value": expiryStr,
+			"min":   expiry.Format(s.config.MinExpiry),
+			"max":   expiry.Format(s.config.MaxExpiry),
+		})
+		return 0, false
+	}
+
+	return parsed, true
+}
+
+// parseExpireAt converts an RFC 3339 ?expire_at= timestamp to the duration
+// left until it, which must be within the configured bounds.
+func (s *Server) parseExpireAt(w http.ResponseWriter, value string) (time.Duration, bool) {
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		invalidExpiry(w, "expire_at must be an RFC 3339 timestamp, e.g. 2025-06-01T00:00:00Z",
+			ErrorDetails{"field": "expire_at", "value": value})
+		return 0, false
+	}
+
+	d := time.Until(at)
+	if d <= 0 {
+		invalidExpiry(w, "expire_at is in the past", ErrorDetails{"field": "expire_at", "value": value})
+		return 0, false
+	}
+	if err := expiry.Validate(d, s.config.MinExpiry, s.config.MaxExpiry); err != nil {
+		invalidExpiry(w, err.Error(), ErrorDetails{
+			"field": "expire_at",
+			"value": value,
+			"min":   expiry.Format(s.config.MinExpiry),
+			"max":   expiry.Format(s.config.MaxExpiry),
+		})
+		return 0, false
+	}
+	return d, true
+}
+
+// expiresAtFor returns when a snippet stored now for d expires, mapping
+// expiry.Never to storage.NeverExpires.
+func expiresAtFor(d time.Duration) time.Time {
+	if d == expiry.Never {
+		return storage.NeverExpires
+	}
+	return time.Now().Add(d)
+}
+
+// writeCreated sends the 201 response for a created snippet, as JSON or as
+// plain text depending on the Accept header.
+func (s *Server) writeCreated(w http.ResponseWriter, r *http.Request, snippet *storage.Snippet, appendToken, deleteToken string) {
+	s.writeCreateResponse(w, r, snippet, http.StatusCreated, appendToken, deleteToken)
+}
+
+// writeCreateResponse sends a create response with status. Only a 201 for
+// the caller's own snippet carries a creation receipt.
+func (s *Server) writeCreateResponse(w http.ResponseWriter, r *http.Request, snippet *storage.Snippet, status int, appendToken, deleteToken string) {
 	resp := CreateResponse{
 		ID:        snippet.ID,
-		URL:       s.config.BaseURL + "/" + snippet.ID,
+		URL:       s.snippetURL(r, snippet.ID),
+		ShortCode: snippet.ID,
+		RawURL:    s.rawURL(r, snippet.ID),
 		ExpiresAt: snippet.ExpiresAt,
+		ExpiresIn: max(int64(time.Until(snippet.ExpiresAt)/time.Second), 0),
+
+		AppendToken: appendToken,
+		DeleteToken: deleteToken,
+	}
+	if deleteToken != "" {
+		resp.DeleteURL = s.deleteURL(r, snippet.ID, deleteToken)
+	}
+	if snippet.MaxViews > 0 {
+		remaining := snippet.MaxViews - snippet.ViewCount
+		resp.RemainingViews = &remaining
+	}
+	if s.config.ReceiptSigningKey != "" && status == http.StatusCreated {
+		signed, err := signReceipt([]byte(s.config.ReceiptSigningKey), receipt{
+			ID:        snippet.ID,
+			CreatedAt: snippet.CreatedAt,
+			SizeBytes: int64(len(snippet.Content)),
+		})
+		if err != nil {
+			s.logger.Error("failed to sign creation receipt", "error", err, "snippet_id", snippet.ID)
+			internalError(w)
+			return
+		}
+		resp.CreationReceipt = signed
+	}
+
+	// Plain-text clients get just the URL; the tokens move to headers so
+	// they are not lost.
+	if wantsPlainText(r) {
+		if appendToken != "" {
+			w.Header().Set("X-Append-Token", appendToken)
+		}
+		if deleteToken != "" {
+			w.Header().Set("X-Delete-Token", deleteToken)
+		}
+		if resp.CreationReceipt != "" {
+			w.Header().Set("X-Creation-Receipt", resp.CreationReceipt)
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		io.WriteString(w, resp.URL+"\n")
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(resp)
 }
 
+// handleAppend handles POST /{id}/append for adding content to an
+// appendable snippet. The append token must be sent in X-Append-Token.
+//
+// Whether an append moves the expiry is controlled by AppendResetsExpiry;
+// see storage.AppendExpiry for the exact rule.
+func (s *Server) handleAppend(w http.ResponseWriter, r *http.Request) {
+	reqID := middleware.GetReqID(r.Context())
+	snippetID := chi.URLParam(r, "id")
+
+	if !id.IsValidCustom(snippetID) {
+		invalidID(w)
+		return
+	}
+
+	token := r.Header.Get("X-Append-Token")
+	if token == "" {
+		unauthorized(w)
+		return
+	}
+
+	// As on create, a declared oversized body is rejected unread; chunked
+	// bodies are caught by the limited read below
+	if r.ContentLength > s.config.MaxContentSize {
+		payloadTooLarge(w, s.config.MaxContentSize)
+		return
+	}
+
+	content, err := io.ReadAll(io.LimitReader(r.Body, s.config.MaxContentSize+1))
+	if err != nil {
+		s.logger.Error("failed to read request body",
+			"error", err,
+			"request_id", reqID)
+		internalError(w)
+		return
+	}
+	if int64(len(content)) > s.config.MaxContentSize {
+		payloadTooLarge(w, s.config.MaxContentSize)
+		return
+	}
+	if len(content) == 0 {
+		emptyContent(w)
+		return
+	}
+
+	snippet, err := s.repoFor(r).Append(r.Context(), snippetID, storage.AppendRequest{
+		TokenHash:   tokenHash(token),
+		Content:     content,
+		MaxSize:     s.config.MaxContentSize,
+		ResetExpiry: s.config.AppendResetsExpiry,
+	})
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		notFound(w)
+		return
+	case errors.Is(err, storage.ErrTokenMismatch):
+		unauthorized(w)
+		return
+	case errors.Is(err, storage.ErrTooLarge):
+		payloadTooLarge(w, s.config.MaxContentSize)
+		return
+	case err != nil:
+		s.logger.Error("failed to append to snippet",
+			"error", err,
+			"snippet_id", snippetID,
+			"request_id", reqID)
+		internalError(w)
+		return
+	}
+
+	s.live.publish(liveKey(tenantFromContext(r.Context()), snippetID),
+		liveChunk{Data: content, End: len(snippet.Content)})
+
+	s.logger.Info("snippet appended",
+		"snippet_id", snippet.ID,
+		"appended_bytes", len(content),
+		"size_bytes", len(snippet.Content),
+		"request_id", reqID,
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(AppendResponse{
+		ID:        snippet.ID,
+		URL:       s.snippetURL(r, snippet.ID),
+		SizeBytes: len(snippet.Content),
+		ExpiresAt: snippet.ExpiresAt,
+	})
+}
+
+// snippetURL returns the canonical view link for a snippet, including the
+// tenant's host or path prefix when tenancy is enabled.
+func (s *Server) snippetURL(r *http.Request, snippetID string) string {
+	tenant := tenantFromContext(r.Context())
+	if tenant == "" {
+		return s.config.BaseURL + "/" + snippetID
+	}
+
+	switch s.config.TenancyMode {
+	case config.TenancyHost:
+		scheme, _, _ := strings.Cut(s.config.BaseURL, "://")
+		return scheme + "://" + r.Host + "/" + snippetID
+	case config.TenancyPath:
+		return s.config.BaseURL + tenantPathPrefix + tenant + "/" + snippetID
+	default:
+		return s.config.BaseURL + "/" + snippetID
+	}
+}
+
+// rawURL returns the link that always serves a snippet's bytes inline.
+func (s *Server) rawURL(r *http.Request, snippetID string) string {
+	return s.snippetURL(r, snippetID) + "?raw"
+}
+
+// deleteURL returns the snippet URL carrying its delete token.
+func (s *Server) deleteURL(r *http.Request, snippetID, token string) string {
+	return s.snippetURL(r, snippetID) + "?token=" + url.QueryEscape(token)
+}
+
+// parseMaxViews reads the optional view limit from ?max_views or ?burn.
+// It returns 0 when the snippet may be viewed any number of times.
+func parseMaxViews(r *http.Request) (int, error) {
+	query := r.URL.Query()
+	burn := query.Get("burn") == "true"
+
+	raw := query.Get("max_views")
+	if raw == "" {
+		if burn {
+			return 1, nil
+		}
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("max_views must be a positive integer")
+	}
+	if burn && n != 1 {
+		return 0, fmt.Errorf("burn=true cannot be combined with max_views=%d", n)
+	}
+	return n, nil
+}
+
+// parseCustomID reads the optional caller-chosen ID from ?id or the
+// X-Custom-ID header. It returns "" when the ID should be generated.
+func parseCustomID(r *http.Request) (string, error) {
+	customID := r.URL.Query().Get("id")
+	if customID == "" {
+		customID = r.Header.Get("X-Custom-ID")
+	}
+	if customID == "" {
+		return "", nil
+	}
+	if !id.IsValidCustom(customID) {
+		return "", fmt.Errorf("id must be %d-%d characters of letters, digits, '-' or '_'",
+			id.MinCustomLength, id.MaxCustomLength)
+	}
+	if reservedSegments[strings.ToLower(customID)] {
+		return "", fmt.Errorf("id %q is reserved", customID)
+	}
+	return customID, nil
+}
+
+// applyTemplate wraps content with the template's header and footer.
+func applyTemplate(t config.Template, content []byte) []byte {
+	out := make([]byte, 0, len(t.Header)+len(content)+len(t.Footer))
+	out = append(out, t.Header...)
+	out = append(out, content...)
+	out = append(out, t.Footer...)
+	return out
+}
+
+// handleDelete handles DELETE /{id}. The snippet's delete token must be sent
+// as "Authorization: Bearer <token>", in X-Delete-Token or, as in the
+// delete_url returned at creation, in ?token=.
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	reqID := middleware.GetReqID(r.Context())
+	snippetID := chi.URLParam(r, "id")
+
+	if !id.IsValidCustom(snippetID) {
+		invalidID(w)
+		return
+	}
+
+	token := deleteToken(r)
+	if token == "" {
+		unauthorized(w)
+		return
+	}
+
+	// Peek so a rejected delete does not use up a view
+	repo := s.repoFor(r)
+	snippet, err := repo.Peek(r.Context(), snippetID)
+	if err != nil {
+		s.logger.Error("failed to fetch snippet",
+			"error", err,
+			"snippet_id", snippetID,
+			"request_id", reqID)
+		internalError(w)
+		return
+	}
+	if snippet == nil {
+		notFound(w)
+		return
+	}
+	if !tokenMatches(snippet.DeleteTokenHash, token) {
+		unauthorized(w)
+		return
+	}
+
+	err = repo.Delete(r.Context(), snippetID)
+	if errors.Is(err, storage.ErrNotFound) {
+		notFound(w)
+		return
+	}
+	if err != nil {
+		s.logger.Error("failed to delete snippet",
+			"error", err,
+			"snippet_id", snippetID,
+			"request_id", reqID)
+		internalError(w)
+		return
+	}
+
+	s.logger.Info("snippet deleted",
+		"snippet_id", snippetID,
+		"request_id", reqID,
+	)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteToken returns the delete token sent with r: a bearer token, an
+// X-Delete-Token header or a ?token= parameter, in that order of precedence.
+func deleteToken(r *http.Request) string {
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return bearer
+	}
+	if header := r.Header.Get("X-Delete-Token"); header != "" {
+		return header
+	}
+	return r.URL.Query().Get("token")
+}
+
 // handleGet handles GET /{id} for retrieving snippets.
 func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
 	reqID := middleware.GetReqID(r.Context())
 	snippetID := chi.URLParam(r, "id")
 
 	// Validate ID format
-	if !id.IsValid(snippetID) {
+	if !id.IsValidCustom(snippetID) {
 		invalidID(w)
 		return
 	}
 
+	if !s.checkSignedURL(w, r, snippetID) {
+		return
+	}
+
+	if !s.checkDecode(w, r, snippetID) {
+		return
+	}
+
 	// Fetch snippet
-	snippet, err := s.repo.Get(snippetID)
+	snippet, err := s.repoFor(r).Get(r.Context(), snippetID)
 	if err != nil {
 		s.logger.Error("failed to fetch snippet", 
 			"error", err, 
@@ -134,19 +814,59 @@ func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !s.checkPin(w, r, snippet) {
+		return
+	}
+
 	s.logger.Info("snippet retrieved",
 		"snippet_id", snippet.ID,
 		"size_bytes", len(snippet.Content),
 		"request_id", reqID,
 	)
 
-	// Return raw content as text/plain
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	// ?decode=base64 serves the decoded bytes. They are usually binary, so
+	// they are always sniffed, even with DetectBinary off.
+	decode := r.URL.Query().Get("decode") == "base64"
+	if decode {
+		decoded, err := decodeBase64(snippet.Content)
+		if err != nil {
+			badRequestField(w, "decode", err.Error())
+			return
+		}
+		served := *snippet
+		served.Content = decoded
+		snippet = &served
+	}
+
+	if s.setCacheHeaders(w, r, snippet, time.Now()) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	// Return raw content as text/plain, or as a download when it is binary.
+	// ?raw always serves the bytes inline.
+	// A type declared at creation is served as is, unless the content is
+	// being decoded.
+	_, raw := r.URL.Query()["raw"]
+	switch {
+	case snippet.ContentType != "" && !decode:
+		w.Header().Set("Content-Type", servedContentType(snippet.ContentType))
+	case (s.config.DetectBinary || decode) && !raw && !isText(snippet.Content):
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+snippet.ID+`"`)
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
 	w.Header().Set("X-Content-Type-Options", "nosniff")
 	w.WriteHeader(http.StatusOK)
 	w.Write(snippet.Content)
 }
 
+// isText reports whether content sniffs as some kind of text.
+func isText(content []byte) bool {
+	return strings.HasPrefix(http.DetectContentType(content), "text/")
+}
+
 // handleHealthz handles GET /healthz for liveness probes.
 func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -159,7 +879,23 @@ type Pinger interface {
 	Ping(ctx context.Context) error
 }
 
-// handleReadyz handles GET /readyz for readiness probes.
+// ReadyzResponse is the response for GET /readyz.
+type ReadyzResponse struct {
+	Status  string         `json:"status"` // ok or degraded
+	Message string         `json:"message,omitempty"`
+	Cleanup *CleanupReport `json:"cleanup,omitempty"`
+}
+
+// CleanupReport describes the cleanup worker's last finished run.
+type CleanupReport struct {
+	LastRunAt        *time.Time `json:"last_run_at"` // null before the first run
+	LastDeletedCount int64      `json:"last_deleted_count"`
+	LastError        string     `json:"last_error,omitempty"`
+}
+
+// handleReadyz handles GET /readyz for readiness probes. A failing database
+// makes the instance unready. Cleanup problems only report it degraded,
+// since it can still serve requests.
 func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
 	// Check database connectivity if repo supports Ping
 	if pinger, ok := s.repo.(Pinger); ok {
@@ -172,7 +908,24 @@ func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	resp := ReadyzResponse{Status: "ok"}
+	if s.cleanup != nil {
+		status := s.cleanup.Status()
+		report := &CleanupReport{LastDeletedCount: status.LastDeleted}
+		if !status.LastRun.IsZero() {
+			report.LastRunAt = &status.LastRun
+		}
+		switch {
+		case status.LastError != nil:
+			report.LastError = status.LastError.Error()
+			resp.Status, resp.Message = "degraded", "last cleanup run failed"
+		case s.cleanup.overdue():
+			resp.Status, resp.Message = "degraded", "cleanup has not run within twice its interval"
+		}
+		resp.Cleanup = report
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"status":"ok"}`))
+	json.NewEncoder(w).Encode(resp)
 }
@@ -0,0 +1,1266 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/config"
+	"github.com/rayenfassatoui/tafcha-cli/internal/storage"
+)
+
+// stubRepo is a minimal in-memory storage.Repository for handler tests.
+// Snippets of the default tenant are keyed by ID, others by "tenant/ID".
+type stubRepo struct {
+	mu       *sync.Mutex
+	snippets map[string]*storage.Snippet
+	tenant   string
+
+	// idempotencyMu is held while create runs, apart from mu which create
+	// takes itself
+	idempotencyMu *sync.Mutex
+	idempotency   map[string][]byte
+}
+
+func newStubRepo() *stubRepo {
+	return &stubRepo{
+		mu:            &sync.Mutex{},
+		snippets:      make(map[string]*storage.Snippet),
+		idempotencyMu: &sync.Mutex{},
+		idempotency:   make(map[string][]byte),
+	}
+}
+
+func stubKey(tenant, id string) string {
+	if tenant == "" {
+		return id
+	}
+	return tenant + "/" + id
+}
+
+func (r *stubRepo) WithTenant(tenant string) storage.Repository {
+	scoped := *r
+	scoped.tenant = tenant
+	return &scoped
+}
+
+func (r *stubRepo) Create(ctx context.Context, snippet *storage.Snippet) (*storage.Snippet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := stubKey(r.tenant, snippet.ID)
+	if _, ok := r.snippets[key]; ok {
+		return nil, storage.ErrConflict
+	}
+	snippet.Tenant = r.tenant
+	snippet.CreatedAt = time.Now()
+	r.snippets[key] = snippet
+	return snippet, nil
+}
+
+func (r *stubRepo) CreateWithTimestamps(ctx context.Context, snippet *storage.Snippet) (*storage.Snippet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := stubKey(r.tenant, snippet.ID)
+	if _, ok := r.snippets[key]; ok {
+		return nil, storage.ErrConflict
+	}
+	snippet.Tenant = r.tenant
+	r.snippets[key] = snippet
+	return snippet, nil
+}
+
+func (r *stubRepo) Upsert(ctx context.Context, id string, content []byte, expiresAt time.Time) (*storage.Snippet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	key := stubKey(r.tenant, id)
+	s, ok := r.snippets[key]
+	if !ok {
+		s = &storage.Snippet{Tenant: r.tenant, ID: id, CreatedAt: now}
+		r.snippets[key] = s
+	} else {
+		s.UpdatedAt = &now
+		s.ContentHash = ""
+		s.ViewCount, s.MaxViews = 0, 0
+		s.AppendTokenHash = ""
+		s.PinHash = ""
+		s.ContentType = ""
+	}
+	s.Content = content
+	s.ExpiresAt = expiresAt
+	return s, nil
+}
+
+func (r *stubRepo) Get(ctx context.Context, id string) (*storage.Snippet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := stubKey(r.tenant, id)
+	s, ok := r.snippets[key]
+	if !ok || s.IsExpired() {
+		return nil, nil
+	}
+	now := time.Now()
+	s.LastAccessedAt = &now
+	s.ViewCount++
+	if s.MaxViews > 0 && s.ViewCount >= s.MaxViews {
+		delete(r.snippets, key)
+	}
+	return s, nil
+}
+
+func (r *stubRepo) Peek(ctx context.Context, id string) (*storage.Snippet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.snippets[stubKey(r.tenant, id)]
+	if !ok || s.IsExpired() {
+		return nil, nil
+	}
+	return s, nil
+}
+
+func (r *stubRepo) GetMeta(ctx context.Context, id string) (*storage.SnippetMeta, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.snippets[stubKey(r.tenant, id)]
+	if !ok || s.IsExpired() {
+		return nil, nil
+	}
+	return &storage.SnippetMeta{
+		Tenant:    s.Tenant,
+		ID:        s.ID,
+		ExpiresAt: s.ExpiresAt,
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+		SizeBytes: int64(len(s.Content)),
+		ViewCount: s.ViewCount,
+
+		ContentType: s.ContentType,
+	}, nil
+}
+
+func (r *stubRepo) Stats(ctx context.Context) (*storage.Stats, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var st storage.Stats
+	for _, s := range r.snippets {
+		if s.IsExpired() {
+			continue
+		}
+		st.ActiveSnippets++
+		st.TotalBytes += int64(len(s.Content))
+		if s.ExpiresAt.Before(time.Now().Add(time.Hour)) {
+			st.ExpiringNextHour++
+		}
+	}
+	return &st, nil
+}
+
+func (r *stubRepo) FindByContent(ctx context.Context, creator, contentHash string) (*storage.Snippet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var found *storage.Snippet
+	for _, s := range r.snippets {
+		if s.Tenant != r.tenant || (creator != "" && s.Creator != creator) || s.ContentHash != contentHash || s.MaxViews > 0 || s.IsExpired() {
+			continue
+		}
+		if found == nil || s.CreatedAt.After(found.CreatedAt) {
+			found = s
+		}
+	}
+	return found, nil
+}
+
+func (r *stubRepo) Append(ctx context.Context, id string, req storage.AppendRequest) (*storage.Snippet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.snippets[stubKey(r.tenant, id)]
+	if !ok || s.IsExpired() {
+		return nil, storage.ErrNotFound
+	}
+	if s.AppendTokenHash == "" || s.AppendTokenHash != req.TokenHash {
+		return nil, storage.ErrTokenMismatch
+	}
+	if int64(len(s.Content)+len(req.Content)) > req.MaxSize {
+		return nil, storage.ErrTooLarge
+	}
+
+	now := time.Now()
+	s.ExpiresAt = storage.AppendExpiry(s, req.ResetExpiry, now)
+	s.UpdatedAt = &now
+	s.Content = append(s.Content, req.Content...)
+	s.ContentHash = ""
+	return s, nil
+}
+
+func (r *stubRepo) Extend(ctx context.Context, id string, newExpiry time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.snippets[stubKey(r.tenant, id)]
+	if !ok || s.IsExpired() {
+		return storage.ErrNotFound
+	}
+	s.ExpiresAt = newExpiry
+	return nil
+}
+
+func (r *stubRepo) GetOrSetIdempotent(ctx context.Context, key string, ttl time.Duration, create func() ([]byte, error)) ([]byte, bool, error) {
+	r.idempotencyMu.Lock()
+	defer r.idempotencyMu.Unlock()
+
+	k := stubKey(r.tenant, key)
+	if result, ok := r.idempotency[k]; ok {
+		return result, true, nil
+	}
+	result, err := create()
+	if err != nil {
+		return nil, false, err
+	}
+	r.idempotency[k] = result
+	return result, false, nil
+}
+
+func (r *stubRepo) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := stubKey(r.tenant, id)
+	if _, ok := r.snippets[key]; !ok {
+		return storage.ErrNotFound
+	}
+	delete(r.snippets, key)
+	return nil
+}
+
+func (r *stubRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var count int64
+	for id, s := range r.snippets {
+		if s.IsExpired() {
+			delete(r.snippets, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *stubRepo) DeleteExpiredBatch(ctx context.Context, limit int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var count int64
+	for id, s := range r.snippets {
+		if count == int64(limit) {
+			break
+		}
+		if s.IsExpired() {
+			delete(r.snippets, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *stubRepo) DeleteIdle(ctx context.Context, accessedBefore, createdBefore time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var count int64
+	for id, s := range r.snippets {
+		lastSeen := s.CreatedAt
+		if s.LastAccessedAt != nil {
+			lastSeen = *s.LastAccessedAt
+		}
+		if lastSeen.Before(accessedBefore) && s.CreatedAt.Before(createdBefore) {
+			delete(r.snippets, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *stubRepo) DeleteByCreator(ctx context.Context, creator string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var count int64
+	for id, s := range r.snippets {
+		if s.Creator == creator {
+			delete(r.snippets, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *stubRepo) Close() {}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		BaseURL:        "http://tafcha.test",
+		MaxContentSize: 1024,
+		DefaultExpiry:  72 * time.Hour,
+		MinExpiry:      10 * time.Minute,
+		MaxExpiry:      30 * 24 * time.Hour,
+		PostRateLimit:  1000,
+		GetRateLimit:   1000,
+
+		ContentHashAlgo: "sha256",
+	}
+}
+
+func newTestServer(t *testing.T, cfg *config.Config) (*Server, *stubRepo) {
+	t.Helper()
+
+	repo := newStubRepo()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewServer(cfg, repo, logger), repo
+}
+
+func TestHandlers_MemoryRepository(t *testing.T) {
+	repo := storage.NewMemoryRepository(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	s := NewServer(testConfig(), repo, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	resp := decodeCreate(t, doRequest(s, http.MethodPost, "/", "stored in memory"))
+
+	rec := doRequest(s, http.MethodGet, "/"+resp.ID, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "stored in memory", rec.Body.String())
+
+	req := httptest.NewRequest(http.MethodDelete, "/"+resp.ID, nil)
+	req.Header.Set("X-Delete-Token", resp.DeleteToken)
+	require.Equal(t, http.StatusNoContent, serve(s, req).Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(s, http.MethodGet, "/"+resp.ID, "").Code)
+}
+
+func doRequest(s *Server, method, target, body string) *httptest.ResponseRecorder {
+	return serve(s, httptest.NewRequest(method, target, strings.NewReader(body)))
+}
+
+func serve(s *Server, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func decodeCreate(t *testing.T, rec *httptest.ResponseRecorder) CreateResponse {
+	t.Helper()
+
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var resp CreateResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp
+}
+
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) APIError {
+	t.Helper()
+
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp.Error
+}
+
+func TestHandleCreate_ExpiresIn(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+
+	resp := decodeCreate(t, doRequest(s, http.MethodPost, "/?expiry=1h", "content"))
+
+	assert.InDelta(t, 3600, resp.ExpiresIn, 5)
+	assert.WithinDuration(t, time.Now().Add(time.Duration(resp.ExpiresIn)*time.Second), resp.ExpiresAt, 5*time.Second)
+}
+
+func TestHandleCreate_ExpiryOutOfRangeDetails(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+
+	for _, value := range []string{"1m", "5w"} {
+		rec := doRequest(s, http.MethodPost, "/?expiry="+value, "content")
+
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		apiErr := decodeError(t, rec)
+		assert.Equal(t, ErrCodeInvalidExpiry, apiErr.Code)
+		assert.Equal(t, ErrorDetails{
+			"field": "expiry",
+			"value": value,
+			"min":   "10m",
+			"max":   "1M",
+		}, apiErr.Details)
+	}
+}
+
+func TestHandleCreate_ExpiryMalformedDetails(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+
+	rec := doRequest(s, http.MethodPost, "/?expiry=soon", "content")
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, ErrorDetails{"field": "expiry", "value": "soon"}, decodeError(t, rec).Details)
+}
+
+func TestHandleCreate_AllowedExpiryUnits(t *testing.T) {
+	cfg := testConfig()
+	cfg.AllowedExpiryUnits = []string{"h", "d"}
+	s, _ := newTestServer(t, cfg)
+
+	rec := doRequest(s, http.MethodPost, "/?expiry=30m", "content")
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	apiErr := decodeError(t, rec)
+	assert.Equal(t, ErrCodeInvalidExpiry, apiErr.Code)
+	assert.Contains(t, apiErr.Message, "h, d")
+	assert.Equal(t, ErrorDetails{"field": "expiry", "value": "30m", "allowed": "h,d"}, apiErr.Details)
+
+	for _, value := range []string{"2h", "3d"} {
+		rec := doRequest(s, http.MethodPost, "/?expiry="+value, "content")
+		assert.Equal(t, http.StatusCreated, rec.Code, value)
+	}
+}
+
+func TestErrorResponse_OmitsEmptyDetails(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+
+	rec := doRequest(s, http.MethodPost, "/", "")
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "details")
+}
+
+func TestHandleCreate_Template(t *testing.T) {
+	cfg := testConfig()
+	cfg.Templates = map[string]config.Template{
+		"incident": {Header: "== Incident ==\n", Footer: "\n== End =="},
+	}
+	s, repo := newTestServer(t, cfg)
+
+	rec := doRequest(s, http.MethodPost, "/?template=incident", "db down")
+	resp := decodeCreate(t, rec)
+
+	snippet, err := repo.Get(context.Background(), resp.ID)
+	require.NoError(t, err)
+	require.NotNil(t, snippet)
+	assert.Equal(t, "== Incident ==\ndb down\n== End ==", string(snippet.Content))
+}
+
+func TestHandleCreate_UnknownTemplate(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+
+	rec := doRequest(s, http.MethodPost, "/?template=missing", "content")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, ErrCodeBadRequest, decodeError(t, rec).Code)
+	assert.Empty(t, repo.snippets)
+}
+
+func TestHandleCreate_TemplateExceedsLimit(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxContentSize = 16
+	cfg.Templates = map[string]config.Template{
+		"big": {Header: "0123456789", Footer: "0123456789"},
+	}
+	s, _ := newTestServer(t, cfg)
+
+	rec := doRequest(s, http.MethodPost, "/?template=big", "short")
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestHandleCreate_DeclaredLengthTooLarge(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxContentSize = 16
+	s, repo := newTestServer(t, cfg)
+
+	body := &countingReader{r: strings.NewReader(strings.Repeat("x", 64))}
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.ContentLength = 64
+
+	rec := serve(s, req)
+
+	require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Equal(t, ErrCodeTooLarge, decodeError(t, rec).Code)
+	assert.Zero(t, body.n, "the body is not read")
+	assert.Empty(t, repo.snippets)
+}
+
+func TestHandleCreate_ChunkedTooLarge(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxContentSize = 16
+	s, repo := newTestServer(t, cfg)
+
+	body := &countingReader{r: strings.NewReader(strings.Repeat("x", 4096))}
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.ContentLength = -1 // chunked, no declared length
+
+	rec := serve(s, req)
+
+	require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Equal(t, ErrCodeTooLarge, decodeError(t, rec).Code)
+	assert.LessOrEqual(t, body.n, 17, "reading stops one byte past the limit")
+	assert.Empty(t, repo.snippets)
+
+	// A chunked body within the limit is accepted
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("fits"))
+	req.ContentLength = -1
+	assert.Equal(t, http.StatusCreated, serve(s, req).Code)
+}
+
+func TestHandleAppend_DeclaredLengthTooLarge(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxContentSize = 16
+	s, repo := newTestServer(t, cfg)
+	created := createAppendable(t, s, "line 1\n")
+
+	body := &countingReader{r: strings.NewReader(strings.Repeat("x", 64))}
+	req := httptest.NewRequest(http.MethodPost, "/"+created.ID+"/append", body)
+	req.Header.Set("X-Append-Token", created.AppendToken)
+	req.ContentLength = 64
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, serve(s, req).Code)
+	assert.Zero(t, body.n)
+	assert.Equal(t, "line 1\n", string(repo.snippets[created.ID].Content))
+}
+
+func TestHandleCreate_TransformWrap(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+
+	rec := doRequest(s, http.MethodPost, "/?transform=wrap:20", "short\nthe quick brown fox jumps over the lazy dog")
+	resp := decodeCreate(t, rec)
+
+	snippet, err := repo.Get(context.Background(), resp.ID)
+	require.NoError(t, err)
+	require.NotNil(t, snippet)
+	assert.Equal(t, "short\nthe quick brown fox\njumps over the lazy\ndog", string(snippet.Content))
+}
+
+func TestHandleCreate_ExpireAt(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+
+	at := time.Now().Add(36 * time.Hour).UTC().Truncate(time.Second)
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/?expire_at="+at.Format(time.RFC3339), "until then"))
+	assert.WithinDuration(t, at, created.ExpiresAt, time.Second)
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"with expiry", "expiry=1d&expire_at=" + at.Format(time.RFC3339)},
+		{"in the past", "expire_at=" + time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)},
+		{"below minimum", "expire_at=" + time.Now().Add(time.Minute).UTC().Format(time.RFC3339)},
+		{"above maximum", "expire_at=" + time.Now().Add(60*24*time.Hour).UTC().Format(time.RFC3339)},
+		{"not rfc3339", "expire_at=2025-06-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doRequest(s, http.MethodPost, "/?"+tt.query, "content")
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+			errResp := decodeError(t, rec)
+			assert.Equal(t, ErrCodeInvalidExpiry, errResp.Code)
+			assert.Equal(t, "expire_at", errResp.Details["field"])
+		})
+	}
+	assert.Len(t, repo.snippets, 1)
+}
+
+func TestHandleCreate_ExpiryEndOfDay(t *testing.T) {
+	cfg := testConfig()
+	cfg.MinExpiry = time.Second
+	cfg.ExpiryLocation = time.FixedZone("UTC-5", -5*60*60)
+	s, _ := newTestServer(t, cfg)
+
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/?expiry=eod", "until tonight"))
+
+	year, month, day := time.Now().In(cfg.ExpiryLocation).Date()
+	midnight := time.Date(year, month, day+1, 0, 0, 0, 0, cfg.ExpiryLocation)
+	assert.WithinDuration(t, midnight, created.ExpiresAt, time.Second)
+}
+
+func TestHandleCreate_TransformMinifyJSON(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/?transform=minify-json", "{\n  \"id\": 7,\n  \"tags\": [\"a\", \"b\"]\n}\n"))
+	assert.Equal(t, `{"id":7,"tags":["a","b"]}`, string(repo.snippets[created.ID].Content))
+
+	rec := doRequest(s, http.MethodPost, "/?transform=minify-json", "{\"id\": 7,")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "transform", decodeError(t, rec).Details["field"])
+	assert.Len(t, repo.snippets, 1)
+}
+
+func TestHandleCreate_ExpiryTiers(t *testing.T) {
+	cfg := testConfig()
+	cfg.ExpiryTiers = []config.ExpiryTier{
+		{MaxSize: 16, Expiry: 7 * 24 * time.Hour},
+		{MaxSize: 512, Expiry: time.Hour},
+	}
+	s, _ := newTestServer(t, cfg)
+
+	small := decodeCreate(t, doRequest(s, http.MethodPost, "/", "tiny"))
+	large := decodeCreate(t, doRequest(s, http.MethodPost, "/", strings.Repeat("x", 600)))
+	medium := decodeCreate(t, doRequest(s, http.MethodPost, "/", strings.Repeat("x", 100)))
+	explicit := decodeCreate(t, doRequest(s, http.MethodPost, "/?expiry=2d", "tiny"))
+
+	assert.WithinDuration(t, time.Now().Add(7*24*time.Hour), small.ExpiresAt, time.Minute)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), medium.ExpiresAt, time.Minute)
+	assert.WithinDuration(t, time.Now().Add(cfg.DefaultExpiry), large.ExpiresAt, time.Minute, "larger than every tier")
+	assert.WithinDuration(t, time.Now().Add(48*time.Hour), explicit.ExpiresAt, time.Minute, "?expiry= wins over tiers")
+}
+
+func TestHandleCreate_InvalidTransform(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+
+	rec := doRequest(s, http.MethodPost, "/?transform=wrap:5", "content")
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "transform", decodeError(t, rec).Details["field"])
+	assert.Empty(t, repo.snippets)
+}
+
+func TestHandleCreate_UniqueContentPerCreator(t *testing.T) {
+	cfg := testConfig()
+	cfg.UniqueContentPerCreator = true
+	s, repo := newTestServer(t, cfg)
+
+	first := createFrom(t, s, "203.0.113.7:1234", "same content")
+
+	// Identical content from the same creator returns the existing snippet
+	assert.Equal(t, first, createFrom(t, s, "203.0.113.7:4321", "same content"))
+	assert.Len(t, repo.snippets, 1)
+
+	// Distinct content and other creators get new snippets
+	assert.NotEqual(t, first, createFrom(t, s, "203.0.113.7:1234", "other content"))
+	assert.NotEqual(t, first, createFrom(t, s, "198.51.100.1:1234", "same content"))
+	assert.Len(t, repo.snippets, 3)
+}
+
+func TestHandleCreate_DuplicateContentAllowedByDefault(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+
+	first := createFrom(t, s, "203.0.113.7:1234", "same content")
+	second := createFrom(t, s, "203.0.113.7:1234", "same content")
+
+	assert.NotEqual(t, first, second)
+	assert.Len(t, repo.snippets, 2)
+	assert.Equal(t, repo.snippets[first].ContentHash, repo.snippets[second].ContentHash)
+}
+
+func TestHandleCreate_UniqueContentIgnoresAppendedSnippets(t *testing.T) {
+	cfg := testConfig()
+	cfg.UniqueContentPerCreator = true
+	s, _ := newTestServer(t, cfg)
+
+	created := createAppendable(t, s, "line 1\n")
+	require.Equal(t, http.StatusOK, appendTo(s, created.ID, created.AppendToken, "line 2\n").Code)
+
+	// The appended snippet no longer matches its original content
+	assert.NotEqual(t, created.ID, decodeCreate(t, doRequest(s, http.MethodPost, "/", "line 1\n")).ID)
+}
+
+func dedupeFrom(t *testing.T, s *Server, remoteAddr, query, body string) CreateResponse {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/?dedupe=true"+query, strings.NewReader(body))
+	req.RemoteAddr = remoteAddr
+	rec := serve(s, req)
+
+	// Another creator's snippet is answered 200 rather than 201
+	require.Contains(t, []int{http.StatusCreated, http.StatusOK}, rec.Code, rec.Body.String())
+	var resp CreateResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestHandleCreate_Dedupe(t *testing.T) {
+	cfg := testConfig()
+	cfg.DedupeEnabled = true
+	s, repo := newTestServer(t, cfg)
+
+	first := dedupeFrom(t, s, "203.0.113.7:1234", "", "same content")
+
+	// A hit returns the existing snippet, whoever created it
+	hit := dedupeFrom(t, s, "198.51.100.1:1234", "", "same content")
+	assert.Equal(t, first.ID, hit.ID)
+	assert.Empty(t, hit.DeleteToken, "the delete token stays with the creator")
+	assert.Len(t, repo.snippets, 1)
+
+	// Misses: other content, and creates without ?dedupe=true
+	assert.NotEqual(t, first.ID, dedupeFrom(t, s, "198.51.100.1:1234", "", "other content").ID)
+	assert.NotEqual(t, first.ID, createFrom(t, s, "198.51.100.1:1234", "same content"))
+	assert.Len(t, repo.snippets, 3)
+}
+
+func TestHandleCreate_DedupeOtherCreatorGetsNoReceipt(t *testing.T) {
+	cfg := testConfig()
+	cfg.DedupeEnabled = true
+	cfg.ReceiptSigningKey = "receipt-key"
+	s, _ := newTestServer(t, cfg)
+
+	post := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/?dedupe=true", strings.NewReader("same content"))
+		req.RemoteAddr = remoteAddr
+		return serve(s, req)
+	}
+
+	first := decodeCreate(t, post("203.0.113.7:1234"))
+	require.NotEmpty(t, first.CreationReceipt)
+
+	rec := post("198.51.100.1:1234")
+	require.Equal(t, http.StatusOK, rec.Code, "nothing was created")
+	var hit CreateResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &hit))
+	assert.Equal(t, first.ID, hit.ID)
+	assert.Empty(t, hit.CreationReceipt, "no receipt for someone else's snippet")
+	assert.Empty(t, hit.DeleteToken)
+
+	// The creator's own hit still gets one
+	own := decodeCreate(t, post("203.0.113.7:1234"))
+	assert.Equal(t, first.ID, own.ID)
+	assert.NotEmpty(t, own.CreationReceipt)
+}
+
+func TestHandleCreate_DedupeDisabled(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+
+	first := dedupeFrom(t, s, "203.0.113.7:1234", "", "same content")
+	second := dedupeFrom(t, s, "198.51.100.1:1234", "", "same content")
+
+	assert.NotEqual(t, first.ID, second.ID, "?dedupe=true is ignored")
+	assert.Len(t, repo.snippets, 2)
+}
+
+func TestHandleCreate_DedupeExtendsExpiry(t *testing.T) {
+	cfg := testConfig()
+	cfg.DedupeEnabled = true
+	s, repo := newTestServer(t, cfg)
+
+	first := dedupeFrom(t, s, "203.0.113.7:1234", "&expiry=1h", "same content")
+
+	// A longer expiry extends the existing snippet
+	longer := dedupeFrom(t, s, "198.51.100.1:1234", "&expiry=2d", "same content")
+	require.Equal(t, first.ID, longer.ID)
+	assert.WithinDuration(t, time.Now().Add(48*time.Hour), longer.ExpiresAt, time.Minute)
+	assert.WithinDuration(t, time.Now().Add(48*time.Hour), repo.snippets[stubKey("", first.ID)].ExpiresAt, time.Minute)
+
+	// A shorter one leaves it alone
+	shorter := dedupeFrom(t, s, "198.51.100.1:1234", "&expiry=1h", "same content")
+	require.Equal(t, first.ID, shorter.ID)
+	assert.WithinDuration(t, time.Now().Add(48*time.Hour), shorter.ExpiresAt, time.Minute)
+}
+
+func TestHandleCreate_DedupeSkipsExpiredAndProtected(t *testing.T) {
+	cfg := testConfig()
+	cfg.DedupeEnabled = true
+	s, repo := newTestServer(t, cfg)
+
+	expired := dedupeFrom(t, s, "203.0.113.7:1234", "", "same content")
+	repo.snippets[stubKey("", expired.ID)].ExpiresAt = time.Now().Add(-time.Minute)
+
+	fresh := dedupeFrom(t, s, "198.51.100.1:1234", "", "same content")
+	assert.NotEqual(t, expired.ID, fresh.ID, "an expired snippet is not reused")
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("secret content"))
+	req.RemoteAddr = "203.0.113.7:1234"
+	req.Header.Set("X-Pin", "1234")
+	pinned := decodeCreate(t, serve(s, req))
+	assert.NotEqual(t, pinned.ID, dedupeFrom(t, s, "198.51.100.1:1234", "", "secret content").ID)
+}
+
+func TestHandleGet_TextInline(t *testing.T) {
+	cfg := testConfig()
+	cfg.DetectBinary = true
+	s, _ := newTestServer(t, cfg)
+
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "hello world\n"))
+	rec := doRequest(s, http.MethodGet, "/"+created.ID, "")
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Empty(t, rec.Header().Get("Content-Disposition"))
+	assert.Equal(t, "hello world\n", rec.Body.String())
+}
+
+func TestHandleGet_BinaryAttachment(t *testing.T) {
+	cfg := testConfig()
+	cfg.DetectBinary = true
+	s, _ := newTestServer(t, cfg)
+
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", png))
+	rec := doRequest(s, http.MethodGet, "/"+created.ID, "")
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/octet-stream", rec.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="`+created.ID+`"`, rec.Header().Get("Content-Disposition"))
+	assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, png, rec.Body.String())
+}
+
+func TestHandleGet_BinaryDetectionDisabled(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "\x00\x01\x02\x03"))
+	rec := doRequest(s, http.MethodGet, "/"+created.ID, "")
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Empty(t, rec.Header().Get("Content-Disposition"))
+}
+
+func TestHandleCreate_LinkForms(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+
+	resp := decodeCreate(t, doRequest(s, http.MethodPost, "/", "share me"))
+
+	assert.Equal(t, resp.ID, resp.ShortCode)
+	assert.Equal(t, "http://tafcha.test/"+resp.ShortCode, resp.URL)
+	assert.Equal(t, resp.URL+"?raw", resp.RawURL)
+
+	// Every form resolves to the same content
+	for _, link := range []string{resp.URL, resp.RawURL} {
+		rec := doRequest(s, http.MethodGet, strings.TrimPrefix(link, "http://tafcha.test"), "")
+		require.Equal(t, http.StatusOK, rec.Code, link)
+		assert.Equal(t, "share me", rec.Body.String(), link)
+	}
+}
+
+func TestHandleCreate_AcceptNegotiation(t *testing.T) {
+	tests := []struct {
+		name      string
+		accept    string
+		wantPlain bool
+	}{
+		{name: "no accept header", accept: ""},
+		{name: "json", accept: "application/json"},
+		{name: "wildcard", accept: "*/*"},
+		{name: "plain text", accept: "text/plain", wantPlain: true},
+		{name: "plain text preferred", accept: "application/json;q=0.5, text/plain", wantPlain: true},
+		{name: "json preferred", accept: "text/plain;q=0.2, application/json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestServer(t, testConfig())
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("negotiate me"))
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := serve(s, req)
+			require.Equal(t, http.StatusCreated, rec.Code)
+
+			if tt.wantPlain {
+				assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+				assert.Regexp(t, `^http://tafcha\.test/\w+\n$`, rec.Body.String())
+				return
+			}
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			resp := decodeCreate(t, rec)
+			assert.Equal(t, "http://tafcha.test/"+resp.ID, resp.URL)
+		})
+	}
+}
+
+func TestHandleCreate_PlainTextAppendToken(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+
+	req := httptest.NewRequest(http.MethodPost, "/?appendable=true", strings.NewReader("log\n"))
+	req.Header.Set("Accept", "text/plain")
+	rec := serve(s, req)
+
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("X-Append-Token"))
+}
+
+func TestHandleGet_RawBypassesBinaryDetection(t *testing.T) {
+	cfg := testConfig()
+	cfg.DetectBinary = true
+	s, _ := newTestServer(t, cfg)
+
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "\x00\x01\x02\x03"))
+	rec := doRequest(s, http.MethodGet, "/"+created.ID+"?raw", "")
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Empty(t, rec.Header().Get("Content-Disposition"))
+}
+
+func createAppendable(t *testing.T, s *Server, body string) CreateResponse {
+	t.Helper()
+
+	resp := decodeCreate(t, doRequest(s, http.MethodPost, "/?appendable=true&expiry=1h", body))
+	require.NotEmpty(t, resp.AppendToken)
+	return resp
+}
+
+func appendTo(s *Server, snippetID, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/"+snippetID+"/append", strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("X-Append-Token", token)
+	}
+	return serve(s, req)
+}
+
+func TestHandleAppend(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+	created := createAppendable(t, s, "line 1\n")
+
+	rec := appendTo(s, created.ID, created.AppendToken, "line 2\n")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp AppendResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, created.ID, resp.ID)
+	assert.Equal(t, len("line 1\nline 2\n"), resp.SizeBytes)
+	assert.Equal(t, "line 1\nline 2\n", string(repo.snippets[created.ID].Content))
+}
+
+func TestHandleAppend_PreservesExpiry(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+	created := createAppendable(t, s, "start")
+
+	// Pretend the snippet is half-way through its life
+	snippet := repo.snippets[created.ID]
+	snippet.CreatedAt = snippet.CreatedAt.Add(-30 * time.Minute)
+	snippet.ExpiresAt = snippet.ExpiresAt.Add(-30 * time.Minute)
+	original := snippet.ExpiresAt
+
+	rec := appendTo(s, created.ID, created.AppendToken, " more")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	assert.True(t, original.Equal(repo.snippets[created.ID].ExpiresAt))
+}
+
+func TestHandleAppend_ResetsExpiry(t *testing.T) {
+	cfg := testConfig()
+	cfg.AppendResetsExpiry = true
+	s, repo := newTestServer(t, cfg)
+	created := createAppendable(t, s, "start")
+
+	snippet := repo.snippets[created.ID]
+	snippet.CreatedAt = snippet.CreatedAt.Add(-30 * time.Minute)
+	snippet.ExpiresAt = snippet.ExpiresAt.Add(-30 * time.Minute)
+
+	before := time.Now()
+	rec := appendTo(s, created.ID, created.AppendToken, " more")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// The original one-hour TTL now counts from the append
+	expiresAt := repo.snippets[created.ID].ExpiresAt
+	assert.WithinDuration(t, before.Add(time.Hour), expiresAt, 5*time.Second)
+
+	// A second append keeps the same TTL rather than accumulating
+	rec = appendTo(s, created.ID, created.AppendToken, " again")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), repo.snippets[created.ID].ExpiresAt, 5*time.Second)
+}
+
+func TestHandleAppend_Rejections(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxContentSize = 16
+	s, _ := newTestServer(t, cfg)
+
+	appendable := createAppendable(t, s, "0123456789")
+	plain := decodeCreate(t, doRequest(s, http.MethodPost, "/", "not appendable"))
+
+	assert.Equal(t, http.StatusUnauthorized, appendTo(s, appendable.ID, "", "x").Code)
+	assert.Equal(t, http.StatusUnauthorized, appendTo(s, appendable.ID, "wrong", "x").Code)
+	assert.Equal(t, http.StatusUnauthorized, appendTo(s, plain.ID, appendable.AppendToken, "x").Code)
+	assert.Equal(t, http.StatusNotFound, appendTo(s, "abc123XYZ789", appendable.AppendToken, "x").Code)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, appendTo(s, appendable.ID, appendable.AppendToken, "0123456789").Code)
+	assert.Equal(t, http.StatusBadRequest, appendTo(s, appendable.ID, appendable.AppendToken, "").Code)
+}
+
+func TestHandleCreate_ExpectContinueRejectsOversized(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxContentSize = 1024
+	s, repo := newTestServer(t, cfg)
+
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// Send only the headers; the body is never written
+	_, err = fmt.Fprintf(conn, "POST / HTTP/1.1\r\n"+
+		"Host: tafcha.test\r\n"+
+		"Content-Type: text/plain\r\n"+
+		"Content-Length: 1048576\r\n"+
+		"Expect: 100-continue\r\n\r\n")
+	require.NoError(t, err)
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	assert.Empty(t, repo.snippets)
+}
+
+func TestHandleCreate_ExpectContinueAccepted(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("small enough"))
+	require.NoError(t, err)
+	req.Header.Set("Expect", "100-continue")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+}
+
+func deleteSnippet(s *Server, snippetID string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodDelete, "/"+snippetID, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return serve(s, req)
+}
+
+func TestHandleDelete(t *testing.T) {
+	for _, header := range []string{"X-Delete-Token", "Authorization"} {
+		t.Run(header, func(t *testing.T) {
+			s, repo := newTestServer(t, testConfig())
+			created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "delete me"))
+			require.NotEmpty(t, created.DeleteToken)
+
+			value := created.DeleteToken
+			if header == "Authorization" {
+				value = "Bearer " + value
+			}
+			rec := deleteSnippet(s, created.ID, map[string]string{header: value})
+
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+			assert.Empty(t, repo.snippets)
+			assert.Equal(t, http.StatusNotFound, doRequest(s, http.MethodGet, "/"+created.ID, "").Code)
+		})
+	}
+}
+
+func TestHandleCreate_DeleteURL(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "delete me"))
+
+	u, err := url.Parse(created.DeleteURL)
+	require.NoError(t, err)
+	assert.Equal(t, created.URL, u.Scheme+"://"+u.Host+u.Path)
+	assert.Equal(t, created.DeleteToken, u.Query().Get("token"))
+
+	// A wrong token in the query is rejected like any other
+	wrong := httptest.NewRequest(http.MethodDelete, u.Path+"?token=wrong", nil)
+	assert.Equal(t, http.StatusUnauthorized, serve(s, wrong).Code)
+
+	rec := serve(s, httptest.NewRequest(http.MethodDelete, u.RequestURI(), nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, repo.snippets)
+}
+
+func TestHandleDelete_WrongToken(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "keep me"))
+
+	assert.Equal(t, http.StatusUnauthorized, deleteSnippet(s, created.ID, nil).Code)
+	assert.Equal(t, http.StatusUnauthorized,
+		deleteSnippet(s, created.ID, map[string]string{"X-Delete-Token": "wrong"}).Code)
+	assert.Len(t, repo.snippets, 1)
+}
+
+func TestHandleDelete_NotFound(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+
+	rec := deleteSnippet(s, "abc123XYZ789", map[string]string{"X-Delete-Token": "anything"})
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = deleteSnippet(s, "bad!", map[string]string{"X-Delete-Token": "anything"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestHandleCreate_CustomID(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+
+	resp := decodeCreate(t, doRequest(s, http.MethodPost, "/?id=my-release-notes", "v1.2.0"))
+	assert.Equal(t, "my-release-notes", resp.ID)
+	assert.Equal(t, "http://tafcha.test/my-release-notes", resp.URL)
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("header"))
+	req.Header.Set("X-Custom-ID", "from_header")
+	assert.Equal(t, "from_header", decodeCreate(t, serve(s, req)).ID)
+
+	rec := doRequest(s, http.MethodGet, "/my-release-notes", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "v1.2.0", rec.Body.String())
+
+	rec = doRequest(s, http.MethodPost, "/?id=my-release-notes", "v1.3.0")
+	require.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+	assert.Equal(t, ErrCodeConflict, decodeError(t, rec).Code)
+	assert.Equal(t, "v1.2.0", string(repo.snippets["my-release-notes"].Content))
+}
+
+func TestHandleCreate_CustomIDIfNoneMatch(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+
+	create := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/?id=provisioned", strings.NewReader(body))
+		req.Header.Set("If-None-Match", "*")
+		return serve(s, req)
+	}
+
+	require.Equal(t, http.StatusCreated, create("first").Code)
+
+	rec := create("second")
+	require.Equal(t, http.StatusPreconditionFailed, rec.Code, rec.Body.String())
+	assert.Equal(t, ErrCodePrecondition, decodeError(t, rec).Code)
+	assert.Equal(t, "first", string(repo.snippets["provisioned"].Content))
+}
+
+func TestHandleCreate_ConfiguredIDFormat(t *testing.T) {
+	cfg := testConfig()
+	cfg.IDLength = 6
+	cfg.IDAlphabet = "abcdef"
+	s, _ := newTestServer(t, cfg)
+
+	resp := decodeCreate(t, doRequest(s, http.MethodPost, "/", "content"))
+	assert.Regexp(t, "^[a-f]{6}$", resp.ID)
+
+	rec := doRequest(s, http.MethodGet, "/"+resp.ID, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "content", rec.Body.String())
+}
+
+func TestHandleCreate_InvalidCustomID(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+
+	for _, customID := range []string{"ab", "has.dot", strings.Repeat("x", 65), "healthz", "Admin"} {
+		rec := doRequest(s, http.MethodPost, "/?id="+customID, "content")
+		require.Equal(t, http.StatusBadRequest, rec.Code, customID)
+		assert.Equal(t, ErrorDetails{"field": "id"}, decodeError(t, rec).Details, customID)
+	}
+	assert.Empty(t, repo.snippets)
+}
+
+func TestHandleCreate_Burn(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/?burn=true", "secret"))
+	require.NotNil(t, created.RemainingViews)
+	assert.Equal(t, 1, *created.RemainingViews)
+
+	rec := doRequest(s, http.MethodGet, "/"+created.ID, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "secret", rec.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, doRequest(s, http.MethodGet, "/"+created.ID, "").Code)
+	assert.Empty(t, repo.snippets)
+}
+
+func TestHandleCreate_MaxViewsConcurrentReads(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/?max_views=3", "limited"))
+	require.NotNil(t, created.RemainingViews)
+	assert.Equal(t, 3, *created.RemainingViews)
+
+	var wg sync.WaitGroup
+	codes := make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- doRequest(s, http.MethodGet, "/"+created.ID, "").Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	ok := 0
+	for code := range codes {
+		if code == http.StatusOK {
+			ok++
+		}
+	}
+	assert.Equal(t, 3, ok, "exactly max_views reads succeed")
+}
+
+func TestHandleCreate_InvalidMaxViews(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+
+	for _, query := range []string{"max_views=0", "max_views=-1", "max_views=many", "burn=true&max_views=2"} {
+		rec := doRequest(s, http.MethodPost, "/?"+query, "content")
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		assert.Equal(t, "max_views", decodeError(t, rec).Details["field"], query)
+	}
+	assert.Empty(t, repo.snippets)
+}
+
+func TestHandleCreate_UnlimitedViewsOmitsRemaining(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+
+	rec := doRequest(s, http.MethodPost, "/", "content")
+
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "remaining_views")
+}
+
+func TestHandleDelete_RejectedDoesNotBurn(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/?burn=true", "secret"))
+
+	rec := deleteSnippet(s, created.ID, map[string]string{"X-Delete-Token": "wrong"})
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	assert.Equal(t, http.StatusOK, doRequest(s, http.MethodGet, "/"+created.ID, "").Code)
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+	assert.NotContains(t, doRequest(s, http.MethodGet, "/metrics", "").Body.String(), "go_goroutines")
+
+	cfg := testConfig()
+	cfg.MetricsEnabled = true
+	s, _ = newTestServer(t, cfg)
+	rec := doRequest(s, http.MethodGet, "/metrics", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "go_goroutines")
+}
@@ -0,0 +1,118 @@
+package api
+
+import "sync"
+
+// subscriberBuffer is how many chunks a live viewer may fall behind before
+// it is disconnected.
+const subscriberBuffer = 64
+
+// hub fans out content appended to a snippet to its live viewers.
+type hub struct {
+	mu     sync.Mutex
+	subs   map[string]map[*subscriber]struct{}
+	closed chan struct{} // closed by shutdown
+}
+
+// liveChunk is content appended to a snippet. End is the snippet's size
+// after the append, which lets a viewer skip chunks already included in the
+// content it started from.
+type liveChunk struct {
+	Data []byte
+	End  int
+}
+
+// subscriber receives chunks for one snippet until done is closed.
+type subscriber struct {
+	send chan liveChunk
+	done chan struct{}
+	once sync.Once
+}
+
+func newHub() *hub {
+	return &hub{
+		subs:   make(map[string]map[*subscriber]struct{}),
+		closed: make(chan struct{}),
+	}
+}
+
+// shutdown disconnects every viewer, and those that subscribe later at
+// once. Producers watch closed.
+func (h *hub) shutdown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	select {
+	case <-h.closed:
+		return
+	default:
+	}
+	close(h.closed)
+	for key, subs := range h.subs {
+		for sub := range subs {
+			sub.close()
+		}
+		delete(h.subs, key)
+	}
+}
+
+// subscribe registers a viewer for key. The caller must unsubscribe it.
+func (h *hub) subscribe(key string) *subscriber {
+	sub := &subscriber{
+		send: make(chan liveChunk, subscriberBuffer),
+		done: make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	select {
+	case <-h.closed:
+		sub.close()
+		return sub
+	default:
+	}
+	if h.subs[key] == nil {
+		h.subs[key] = make(map[*subscriber]struct{})
+	}
+	h.subs[key][sub] = struct{}{}
+	return sub
+}
+
+// unsubscribe removes a viewer and closes its done channel.
+func (h *hub) unsubscribe(key string, sub *subscriber) {
+	h.mu.Lock()
+	delete(h.subs[key], sub)
+	if len(h.subs[key]) == 0 {
+		delete(h.subs, key)
+	}
+	h.mu.Unlock()
+
+	sub.close()
+}
+
+// publish sends chunk to every viewer of key. Viewers whose buffer is full
+// are dropped rather than slowing down the producer.
+func (h *hub) publish(key string, chunk liveChunk) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subs[key] {
+		select {
+		case sub.send <- chunk:
+		default:
+			delete(h.subs[key], sub)
+			sub.close()
+		}
+	}
+}
+
+// subscribers returns the number of viewers of key.
+func (h *hub) subscribers(key string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs[key])
+}
+
+func (s *subscriber) close() {
+	s.once.Do(func() { close(s.done) })
+}
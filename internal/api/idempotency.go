@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/storage"
+)
+
+// maxIdempotencyKeyLength bounds the Idempotency-Key header.
+const maxIdempotencyKeyLength = 255
+
+// idempotentCreate is how the first create sent with an Idempotency-Key
+// was answered, tokens included, for replaying it to a retry.
+type idempotentCreate struct {
+	ID          string `json:"-"`
+	AppendToken string `json:"append_token,omitempty"`
+	DeleteToken string `json:"delete_token,omitempty"`
+}
+
+// idempotencyRecord is what the repository keeps for a create sent with an
+// Idempotency-Key. The tokens are sealed with a key derived from the
+// Idempotency-Key, which the database never sees, so the record alone does
+// not reveal them.
+type idempotencyRecord struct {
+	ID          string `json:"id"`
+	Fingerprint string `json:"fingerprint"` // hash of the content
+	Tokens      []byte `json:"tokens"`      // nonce followed by sealed idempotentCreate
+}
+
+// errIdempotencyKeyReused reports a retry whose content differs from the
+// create first sent with its Idempotency-Key.
+var errIdempotencyKeyReused = errors.New("idempotency key reused with different content")
+
+// idempotentCall identifies a create sent with an Idempotency-Key.
+type idempotentCall struct {
+	storeKey    string   // repository key, a hash of caller and key
+	sealKey     [32]byte // seals the tokens in the record
+	fingerprint string
+}
+
+// newIdempotentCall scopes key to the caller, so only the client that sent
+// the first create gets its tokens replayed: the API key when one is sent,
+// otherwise the creator.
+func (s *Server) newIdempotentCall(r *http.Request, creator, key string, content []byte) idempotentCall {
+	caller := "creator:" + creator
+	if apiKey := r.Header.Get("X-API-Key"); s.validAPIKey(apiKey) {
+		caller = "apikey:" + apiKey
+	}
+	storeKey := sha256.Sum256([]byte("idempotency-key\x00" + caller + "\x00" + key))
+	fingerprint := sha256.Sum256(content)
+	return idempotentCall{
+		storeKey:    hex.EncodeToString(storeKey[:]),
+		sealKey:     sha256.Sum256([]byte("idempotency-seal\x00" + caller + "\x00" + key)),
+		fingerprint: hex.EncodeToString(fingerprint[:]),
+	}
+}
+
+// seal encrypts the tokens of a create for its idempotency record.
+func (c idempotentCall) seal(created idempotentCreate) ([]byte, error) {
+	aead, err := c.aead()
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := json.Marshal(created)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, []byte(c.storeKey)), nil
+}
+
+// open decrypts the tokens sealed by seal.
+func (c idempotentCall) open(sealed []byte) (idempotentCreate, error) {
+	var created idempotentCreate
+	aead, err := c.aead()
+	if err != nil {
+		return created, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return created, errors.New("sealed tokens too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(c.storeKey))
+	if err != nil {
+		return created, err
+	}
+	err = json.Unmarshal(plaintext, &created)
+	return created, err
+}
+
+func (c idempotentCall) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(c.sealKey[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// parseIdempotencyKey reads the optional Idempotency-Key header. It is
+// ignored when IdempotencyTTL is 0.
+func (s *Server) parseIdempotencyKey(r *http.Request) (string, error) {
+	key := r.Header.Get("Idempotency-Key")
+	if key == "" || s.config.IdempotencyTTL == 0 {
+		return "", nil
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return "", fmt.Errorf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLength)
+	}
+	for _, c := range key {
+		if c < 0x21 || c > 0x7e {
+			return "", fmt.Errorf("Idempotency-Key must be printable ASCII without spaces")
+		}
+	}
+	return key, nil
+}
+
+// createIdempotent stores snippet unless call's key was already used. A
+// retry gets the first create back as replay, with stored nil, or
+// errIdempotencyKeyReused if its content differs.
+func (s *Server) createIdempotent(ctx context.Context, repo storage.Repository, call idempotentCall, snippet *storage.Snippet, appendToken, deleteToken string) (stored *storage.Snippet, replay *idempotentCreate, err error) {
+	result, replayed, err := repo.GetOrSetIdempotent(ctx, call.storeKey, s.config.IdempotencyTTL, func() ([]byte, error) {
+		var err error
+		if stored, err = repo.Create(ctx, snippet); err != nil {
+			return nil, err
+		}
+		tokens, err := call.seal(idempotentCreate{AppendToken: appendToken, DeleteToken: deleteToken})
+		if err != nil {
+			return nil, fmt.Errorf("sealing idempotent create: %w", err)
+		}
+		return json.Marshal(idempotencyRecord{ID: stored.ID, Fingerprint: call.fingerprint, Tokens: tokens})
+	})
+	if err != nil || !replayed {
+		return stored, nil, err
+	}
+
+	var record idempotencyRecord
+	if err := json.Unmarshal(result, &record); err != nil {
+		return nil, nil, fmt.Errorf("decoding idempotent create: %w", err)
+	}
+	if record.Fingerprint != call.fingerprint {
+		return nil, nil, errIdempotencyKeyReused
+	}
+	created, err := call.open(record.Tokens)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening idempotent create: %w", err)
+	}
+	created.ID = record.ID
+	return nil, &created, nil
+}
+
+// replayCreate answers a retried create like the first one was answered.
+func (s *Server) replayCreate(w http.ResponseWriter, r *http.Request, replay *idempotentCreate) {
+	snippet, err := s.repoFor(r).Peek(r.Context(), replay.ID)
+	if err != nil {
+		s.logger.Error("failed to fetch replayed snippet",
+			"error", err,
+			"snippet_id", replay.ID,
+			"request_id", middleware.GetReqID(r.Context()))
+		internalError(w)
+		return
+	}
+	if snippet == nil {
+		conflict(w, "the snippet created with this Idempotency-Key no longer exists")
+		return
+	}
+
+	w.Header().Set("Idempotent-Replayed", "true")
+	s.writeCreated(w, r, snippet, replay.AppendToken, replay.DeleteToken)
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/storage"
+)
+
+func postWithKey(s *Server, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Idempotency-Key", key)
+	return serve(s, req)
+}
+
+func TestHandleCreate_IdempotencyKeyReplays(t *testing.T) {
+	cfg := testConfig()
+	cfg.IdempotencyTTL = time.Hour
+	s, repo := newTestServer(t, cfg)
+
+	first := postWithKey(s, "upload-1", "content")
+	require.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+	created := decodeCreate(t, first)
+
+	retry := postWithKey(s, "upload-1", "content")
+	require.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	replayed := decodeCreate(t, retry)
+	assert.Equal(t, created.ID, replayed.ID)
+	assert.Equal(t, created.DeleteToken, replayed.DeleteToken, "the retry gets the token it missed")
+	assert.Len(t, repo.snippets, 1)
+
+	// Another key, or none, creates a new snippet
+	assert.NotEqual(t, created.ID, decodeCreate(t, postWithKey(s, "upload-2", "content")).ID)
+	assert.NotEqual(t, created.ID, decodeCreate(t, doRequest(s, http.MethodPost, "/", "content")).ID)
+	assert.Len(t, repo.snippets, 3)
+
+	// A key whose snippet is gone cannot be replayed
+	delete(repo.snippets, created.ID)
+	rec := postWithKey(s, "upload-1", "content")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, ErrCodeConflict, decodeError(t, rec).Code)
+}
+
+func TestHandleCreate_IdempotencyKeyScopedToCaller(t *testing.T) {
+	cfg := testConfig()
+	cfg.IdempotencyTTL = time.Hour
+	s, repo := newTestServer(t, cfg)
+
+	postAs := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("content"))
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("Idempotency-Key", "upload-1")
+		return serve(s, req)
+	}
+
+	first := decodeCreate(t, postAs("192.0.2.1:1234"))
+	other := postAs("192.0.2.2:1234")
+	require.Equal(t, http.StatusCreated, other.Code)
+	assert.Empty(t, other.Header().Get("Idempotent-Replayed"))
+	second := decodeCreate(t, other)
+	assert.NotEqual(t, first.ID, second.ID, "another client's key does not replay")
+	assert.NotEqual(t, first.DeleteToken, second.DeleteToken)
+	assert.Len(t, repo.snippets, 2)
+}
+
+func TestHandleCreate_IdempotencyKeyReusedWithOtherContent(t *testing.T) {
+	cfg := testConfig()
+	cfg.IdempotencyTTL = time.Hour
+	s, repo := newTestServer(t, cfg)
+
+	require.Equal(t, http.StatusCreated, postWithKey(s, "upload-1", "content").Code)
+
+	rec := postWithKey(s, "upload-1", "other content")
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, ErrCodeKeyReused, decodeError(t, rec).Code)
+	assert.Len(t, repo.snippets, 1)
+}
+
+func TestHandleCreate_IdempotencyRecordHidesTokens(t *testing.T) {
+	cfg := testConfig()
+	cfg.IdempotencyTTL = time.Hour
+	s, repo := newTestServer(t, cfg)
+
+	req := httptest.NewRequest(http.MethodPost, "/?appendable=true", strings.NewReader("content"))
+	req.Header.Set("Idempotency-Key", "upload-1")
+	created := decodeCreate(t, serve(s, req))
+	require.NotEmpty(t, created.AppendToken)
+
+	require.Len(t, repo.idempotency, 1)
+	for key, record := range repo.idempotency {
+		assert.NotContains(t, key, "upload-1", "the raw key is not stored")
+		assert.NotContains(t, string(record), created.DeleteToken)
+		assert.NotContains(t, string(record), created.AppendToken)
+	}
+}
+
+func TestHandleCreate_IdempotencyKeyConcurrent(t *testing.T) {
+	cfg := testConfig()
+	cfg.IdempotencyTTL = time.Hour
+	repo := storage.NewMemoryRepository(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	s := NewServer(cfg, repo, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	const requests = 10
+	ids := make([]string, requests)
+	var wg sync.WaitGroup
+	for i := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := postWithKey(s, "retried", "content")
+			if assert.Equal(t, http.StatusCreated, rec.Code) {
+				ids[i] = decodeCreate(t, rec).ID
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, id := range ids {
+		assert.Equal(t, ids[0], id)
+	}
+	stats, err := repo.Stats(context.Background())
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, stats.ActiveSnippets)
+}
+
+func TestHandleCreate_IdempotencyKeyInvalid(t *testing.T) {
+	cfg := testConfig()
+	cfg.IdempotencyTTL = time.Hour
+	s, repo := newTestServer(t, cfg)
+
+	for _, key := range []string{"has space", strings.Repeat("k", 256)} {
+		rec := postWithKey(s, key, "content")
+		require.Equal(t, http.StatusBadRequest, rec.Code, key)
+		assert.Equal(t, "Idempotency-Key", decodeError(t, rec).Details["field"])
+	}
+	assert.Empty(t, repo.snippets)
+}
+
+func TestHandleCreate_IdempotencyDisabled(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+
+	first := decodeCreate(t, postWithKey(s, "upload-1", "content"))
+	second := decodeCreate(t, postWithKey(s, "upload-1", "content"))
+
+	assert.NotEqual(t, first.ID, second.ID, "the header is ignored")
+	assert.Len(t, repo.snippets, 2)
+}
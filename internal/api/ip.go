@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net"
+	"net/http"
+)
+
+type peerAddrKey struct{}
+
+// peerAddrMiddleware remembers the address of the directly connected peer
+// before middleware.RealIP rewrites RemoteAddr from forwarding headers.
+func peerAddrMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), peerAddrKey{}, r.RemoteAddr)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// clientIP returns the client address of the request without its port.
+// middleware.RealIP has already replaced RemoteAddr with the forwarded
+// address when the request came through a proxy.
+func clientIP(r *http.Request) string {
+	return hostOnly(r.RemoteAddr)
+}
+
+func hostOnly(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// isLoopbackRequest reports whether the request both resolves to a loopback
+// client and arrived over a loopback connection, so forwarding headers alone
+// cannot claim to be local.
+func isLoopbackRequest(r *http.Request) bool {
+	peer, _ := r.Context().Value(peerAddrKey{}).(string)
+	return isLoopback(hostOnly(peer)) && isLoopback(clientIP(r))
+}
+
+func isLoopback(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// creatorHash derives the stored creator identity from a client IP. It is
+// keyed so that the identity cannot be reversed by hashing every possible
+// address, which a plain hash of the small IPv4 space allows.
+func (s *Server) creatorHash(ip string) string {
+	mac := hmac.New(sha256.New, s.creatorKey)
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newCreatorKey returns the configured creator hash key, or a random one
+// for this process when none is configured.
+func newCreatorKey(configured string, logger *slog.Logger) []byte {
+	if configured != "" {
+		return []byte(configured)
+	}
+	logger.Warn("CREATOR_HASH_KEY is not set, creators are not recognised across restarts")
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/id"
+	"github.com/rayenfassatoui/tafcha-cli/internal/storage"
+)
+
+// MetaResponse is the response for GET /{id}/meta.
+type MetaResponse struct {
+	ID        string     `json:"id"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	SizeBytes int64      `json:"size_bytes"`
+	ViewCount int        `json:"view_count"`
+
+	ContentType string `json:"content_type"`
+}
+
+// fetchMeta loads the metadata of the snippet in the URL, writing an error
+// response and returning nil when there is none to show. Reading metadata
+// never counts as a view.
+func (s *Server) fetchMeta(w http.ResponseWriter, r *http.Request) *storage.SnippetMeta {
+	reqID := middleware.GetReqID(r.Context())
+	snippetID := chi.URLParam(r, "id")
+
+	if !id.IsValidCustom(snippetID) {
+		invalidID(w)
+		return nil
+	}
+
+	if !s.checkSignedURL(w, r, snippetID) {
+		return nil
+	}
+
+	meta, err := s.repoFor(r).GetMeta(r.Context(), snippetID)
+	if err != nil {
+		s.logger.Error("failed to fetch snippet metadata",
+			"error", err,
+			"snippet_id", snippetID,
+			"request_id", reqID)
+		internalError(w)
+		return nil
+	}
+	if meta == nil {
+		notFound(w)
+		return nil
+	}
+	return meta
+}
+
+// handleMeta handles GET /{id}/meta, describing a snippet without its content.
+func (s *Server) handleMeta(w http.ResponseWriter, r *http.Request) {
+	meta := s.fetchMeta(w, r)
+	if meta == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MetaResponse{
+		ID:        meta.ID,
+		ExpiresAt: meta.ExpiresAt,
+		CreatedAt: meta.CreatedAt,
+		UpdatedAt: meta.UpdatedAt,
+		SizeBytes: meta.SizeBytes,
+		ViewCount: meta.ViewCount,
+
+		ContentType: declaredContentType(meta.ContentType),
+	})
+}
+
+// handleHead handles HEAD /{id}. The headers describe the content GET would
+// return: its length, when it expires and when it last changed.
+func (s *Server) handleHead(w http.ResponseWriter, r *http.Request) {
+	meta := s.fetchMeta(w, r)
+	if meta == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", servedContentType(meta.ContentType))
+	w.Header().Set("Content-Length", strconv.FormatInt(meta.SizeBytes, 10))
+	w.Header().Set("X-Expires-At", meta.ExpiresAt.UTC().Format(time.RFC3339))
+	w.Header().Set("Last-Modified", meta.LastModified().UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusOK)
+}
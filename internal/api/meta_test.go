@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleMeta(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/?max_views=1", "hello world"))
+
+	rec := doRequest(s, http.MethodGet, "/"+created.ID+"/meta", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var meta MetaResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &meta))
+	assert.Equal(t, created.ID, meta.ID)
+	assert.Equal(t, int64(len("hello world")), meta.SizeBytes)
+	assert.True(t, created.ExpiresAt.Equal(meta.ExpiresAt))
+	assert.False(t, meta.CreatedAt.IsZero())
+	assert.NotContains(t, rec.Body.String(), "hello world")
+
+	assert.Equal(t, 0, repo.snippets[created.ID].ViewCount, "metadata reads are not views")
+}
+
+func TestHandleMeta_ViewCount(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "hello world"))
+
+	viewCount := func() int {
+		rec := doRequest(s, http.MethodGet, "/"+created.ID+"/meta", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var meta MetaResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &meta))
+		return meta.ViewCount
+	}
+
+	assert.Equal(t, 0, viewCount())
+	for i := 1; i <= 3; i++ {
+		require.Equal(t, http.StatusOK, doRequest(s, http.MethodGet, "/"+created.ID, "").Code)
+		assert.Equal(t, i, viewCount())
+	}
+}
+
+func TestHandleHead(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "hello world"))
+
+	rec := serve(s, httptest.NewRequest(http.MethodHead, "/"+created.ID, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "11", rec.Header().Get("Content-Length"))
+	assert.Empty(t, rec.Body.String())
+
+	expires, err := time.Parse(time.RFC3339, rec.Header().Get("X-Expires-At"))
+	require.NoError(t, err)
+	assert.True(t, created.ExpiresAt.Truncate(time.Second).Equal(expires))
+
+	_, err = http.ParseTime(rec.Header().Get("Last-Modified"))
+	assert.NoError(t, err)
+}
+
+func TestHandleMeta_NotFound(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+
+	assert.Equal(t, http.StatusNotFound, doRequest(s, http.MethodGet, "/AAAAAAAAAAAA/meta", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(s, httptest.NewRequest(http.MethodHead, "/AAAAAAAAAAAA", nil)).Code)
+	assert.Equal(t, http.StatusBadRequest, doRequest(s, http.MethodGet, "/bad!/meta", "").Code)
+}
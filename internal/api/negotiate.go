@@ -0,0 +1,73 @@
+package api
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// wantsPlainText reports whether the client's Accept header prefers
+// text/plain over application/json. Wildcards and missing headers keep the
+// JSON default.
+func wantsPlainText(r *http.Request) bool {
+	var textQ, jsonQ float64
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if raw, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(raw, 64); err == nil {
+				q = parsed
+			}
+		}
+
+		switch mediaType {
+		case "text/plain":
+			textQ = max(textQ, q)
+		case "application/json":
+			jsonQ = max(jsonQ, q)
+		}
+	}
+	return textQ > 0 && textQ > jsonQ
+}
+
+// acceptedEncoding picks the response encoding from the client's
+// Accept-Encoding header: "gzip", "deflate" or "" for identity. gzip wins
+// ties; "*" accepts either.
+func acceptedEncoding(r *http.Request) string {
+	var gzipQ, deflateQ float64
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if raw, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(raw, 64); err == nil {
+				q = parsed
+			}
+		}
+
+		switch coding {
+		case "gzip", "x-gzip":
+			gzipQ = max(gzipQ, q)
+		case "deflate":
+			deflateQ = max(deflateQ, q)
+		case "*":
+			gzipQ, deflateQ = max(gzipQ, q), max(deflateQ, q)
+		}
+	}
+
+	switch {
+	case gzipQ > 0 && gzipQ >= deflateQ:
+		return "gzip"
+	case deflateQ > 0:
+		return "deflate"
+	}
+	return ""
+}
@@ -0,0 +1,236 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/storage"
+)
+
+// PIN length bounds. PINs are short enough to read out loud; the lockout
+// after PinMaxAttempts wrong guesses is what keeps them from being guessed.
+const (
+	minPinLength = 4
+	maxPinLength = 8
+)
+
+// Defaults for a zero PinMaxAttempts or PinLockout.
+const (
+	defaultPinMaxAttempts = 5
+	defaultPinLockout     = 15 * time.Minute
+)
+
+// parsePin reads the optional PIN for a new snippet from X-Pin. It returns
+// "" when the snippet is not PIN protected.
+func parsePin(r *http.Request) (string, error) {
+	pin := r.Header.Get("X-Pin")
+	if pin == "" {
+		return "", nil
+	}
+	if len(pin) < minPinLength || len(pin) > maxPinLength {
+		return "", fmt.Errorf("PIN must be %d-%d digits", minPinLength, maxPinLength)
+	}
+	for _, c := range pin {
+		if c < '0' || c > '9' {
+			return "", fmt.Errorf("PIN must be %d-%d digits", minPinLength, maxPinLength)
+		}
+	}
+	return pin, nil
+}
+
+// Argon2id parameters for PIN hashes. A PIN has at most 10^8 values, so
+// only a slow hash keeps a leaked pin_hash from being reversed offline.
+const (
+	pinHashPrefix  = "argon2id:"
+	pinHashTime    = 2
+	pinHashMemory  = 19 * 1024 // KiB
+	pinHashThreads = 1
+	pinHashLength  = 32
+	pinSaltLength  = 16
+)
+
+// pinHash returns the stored form of a PIN: a random salt and the Argon2id
+// hash of the PIN under it.
+func pinHash(pin string) (string, error) {
+	salt := make([]byte, pinSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	return pinHashPrefix + hex.EncodeToString(salt) + ":" + hex.EncodeToString(pinKey(pin, salt)), nil
+}
+
+func pinKey(pin string, salt []byte) []byte {
+	return argon2.IDKey([]byte(pin), salt, pinHashTime, pinHashMemory, pinHashThreads, pinHashLength)
+}
+
+// pinMatches reports whether pin is the snippet's PIN. Snippets created
+// before PINs were hashed with Argon2id keep a SHA-256 of "id:pin".
+func pinMatches(snippet *storage.Snippet, pin string) bool {
+	encoded, ok := strings.CutPrefix(snippet.PinHash, pinHashPrefix)
+	if !ok {
+		return tokenMatches(snippet.PinHash, snippet.ID+":"+pin)
+	}
+	saltHex, keyHex, _ := strings.Cut(encoded, ":")
+	salt, err := hex.DecodeString(saltHex)
+	if err != nil {
+		return false
+	}
+	key, err := hex.DecodeString(keyHex)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(key, pinKey(pin, salt)) == 1
+}
+
+// pinGuard counts wrong PINs per snippet and locks a snippet once
+// maxAttempts wrong PINs were sent within the lockout window. The lock and
+// the count are lifted lockout after the last wrong PIN.
+type pinGuard struct {
+	maxAttempts int
+	lockout     time.Duration
+	now         func() time.Time
+
+	mu       sync.Mutex
+	failures map[string]*pinFailures
+}
+
+type pinFailures struct {
+	count int
+	last  time.Time
+}
+
+func newPinGuard(maxAttempts int, lockout time.Duration) *pinGuard {
+	if maxAttempts <= 0 {
+		maxAttempts = defaultPinMaxAttempts
+	}
+	if lockout <= 0 {
+		lockout = defaultPinLockout
+	}
+	return &pinGuard{
+		maxAttempts: maxAttempts,
+		lockout:     lockout,
+		now:         time.Now,
+		failures:    make(map[string]*pinFailures),
+	}
+}
+
+// lockedFor returns how much longer key stays locked, or 0 if it is not.
+func (g *pinGuard) lockedFor(key string) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.lockedForLocked(g.current(key))
+}
+
+// lockedForLocked is lockedFor for the failures f of a key. g.mu must be
+// held.
+func (g *pinGuard) lockedForLocked(f *pinFailures) time.Duration {
+	if f == nil || f.count < g.maxAttempts {
+		return 0
+	}
+	return f.last.Add(g.lockout).Sub(g.now())
+}
+
+// attempt reserves a PIN attempt for key before the PIN is compared, so
+// concurrent guesses cannot all pass the lock check. The attempt counts as
+// wrong until succeed is called. It returns how much longer key stays
+// locked if it already is, and otherwise whether this attempt is the last
+// one before the lock.
+func (g *pinGuard) attempt(key string) (wait time.Duration, last bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	f := g.current(key)
+	if wait := g.lockedForLocked(f); wait > 0 {
+		return wait, false
+	}
+	if f == nil {
+		g.prune()
+		f = &pinFailures{}
+		g.failures[key] = f
+	}
+	f.count++
+	f.last = g.now()
+	return 0, f.count >= g.maxAttempts
+}
+
+// succeed forgets earlier wrong PINs for key.
+func (g *pinGuard) succeed(key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.failures, key)
+}
+
+// current returns the failures recorded for key, dropping them once the
+// lockout window since the last one has passed. g.mu must be held.
+func (g *pinGuard) current(key string) *pinFailures {
+	f, ok := g.failures[key]
+	if !ok {
+		return nil
+	}
+	if !g.now().Before(f.last.Add(g.lockout)) {
+		delete(g.failures, key)
+		return nil
+	}
+	return f
+}
+
+// prune drops every entry whose lockout window has passed, so snippets
+// that stop being guessed at do not stay in memory. g.mu must be held.
+func (g *pinGuard) prune() {
+	now := g.now()
+	for key, f := range g.failures {
+		if !now.Before(f.last.Add(g.lockout)) {
+			delete(g.failures, key)
+		}
+	}
+}
+
+// checkPin enforces a PIN-protected snippet's X-Pin. It writes 401 for a
+// missing or wrong PIN and 423 Locked while too many wrong PINs lock the
+// snippet, and returns false in those cases.
+func (s *Server) checkPin(w http.ResponseWriter, r *http.Request, snippet *storage.Snippet) bool {
+	if snippet.PinHash == "" {
+		return true
+	}
+
+	key := snippet.Tenant + "/" + snippet.ID
+	pin := r.Header.Get("X-Pin")
+	if pin == "" {
+		if wait := s.pins.lockedFor(key); wait > 0 {
+			locked(w, wait)
+			return false
+		}
+		pinRequired(w)
+		return false
+	}
+
+	wait, last := s.pins.attempt(key)
+	if wait > 0 {
+		locked(w, wait)
+		return false
+	}
+	if !pinMatches(snippet, pin) {
+		if last {
+			s.logger.Warn("snippet locked after wrong PINs",
+				"snippet_id", snippet.ID,
+				"remote_ip", r.RemoteAddr)
+			locked(w, s.pins.lockout)
+			return false
+		}
+		writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "wrong PIN")
+		return false
+	}
+
+	s.pins.succeed(key)
+	return true
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/storage"
+)
+
+func createWithPin(t *testing.T, s *Server, pin string) CreateResponse {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("door code"))
+	req.Header.Set("X-Pin", pin)
+	return decodeCreate(t, serve(s, req))
+}
+
+func getWithPin(s *Server, snippetID, pin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/"+snippetID, nil)
+	if pin != "" {
+		req.Header.Set("X-Pin", pin)
+	}
+	return serve(s, req)
+}
+
+func TestPin_Correct(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+
+	created := createWithPin(t, s, "4821")
+	assert.NotEmpty(t, repo.snippets[created.ID].PinHash)
+	assert.NotContains(t, repo.snippets[created.ID].PinHash, "4821")
+
+	rec := getWithPin(s, created.ID, "")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, ErrCodePinRequired, decodeError(t, rec).Code)
+
+	rec = getWithPin(s, created.ID, "4821")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "door code", rec.Body.String())
+	assert.Equal(t, "private, no-store", rec.Header().Get("Cache-Control"))
+}
+
+func TestPin_HashIsSaltedArgon2id(t *testing.T) {
+	first, err := pinHash("4821")
+	require.NoError(t, err)
+	second, err := pinHash("4821")
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(first, pinHashPrefix))
+	assert.NotEqual(t, first, second, "each PIN gets its own salt")
+	assert.True(t, pinMatches(&storage.Snippet{PinHash: first}, "4821"))
+	assert.False(t, pinMatches(&storage.Snippet{PinHash: first}, "4822"))
+
+	// Snippets from before Argon2id keep their SHA-256 hash
+	legacy := &storage.Snippet{ID: "abc", PinHash: tokenHash("abc:4821")}
+	assert.True(t, pinMatches(legacy, "4821"))
+	assert.False(t, pinMatches(legacy, "4822"))
+}
+
+func TestPinGuard_ConcurrentAttempts(t *testing.T) {
+	g := newPinGuard(3, time.Minute)
+
+	var allowed atomic.Int64
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if wait, _ := g.attempt("snippet"); wait == 0 {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.EqualValues(t, 3, allowed.Load(), "only maxAttempts PINs are compared")
+}
+
+func TestPin_Lockout(t *testing.T) {
+	cfg := testConfig()
+	cfg.PinMaxAttempts = 3
+	cfg.PinLockout = 10 * time.Minute
+	s, _ := newTestServer(t, cfg)
+
+	now := time.Now()
+	s.pins.now = func() time.Time { return now }
+
+	created := createWithPin(t, s, "4821")
+
+	for i := 0; i < 2; i++ {
+		rec := getWithPin(s, created.ID, "0000")
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, ErrCodeUnauthorized, decodeError(t, rec).Code)
+	}
+
+	rec := getWithPin(s, created.ID, "0000")
+	require.Equal(t, http.StatusLocked, rec.Code)
+	assert.Equal(t, ErrCodeLocked, decodeError(t, rec).Code)
+	assert.Equal(t, "600", rec.Header().Get("Retry-After"))
+
+	// Even the right PIN is refused while locked
+	now = now.Add(4 * time.Minute)
+	rec = getWithPin(s, created.ID, "4821")
+	require.Equal(t, http.StatusLocked, rec.Code)
+	assert.Equal(t, "360", rec.Header().Get("Retry-After"))
+
+	// The lock lifts once the lockout has passed since the last wrong PIN
+	now = now.Add(6 * time.Minute)
+	rec = getWithPin(s, created.ID, "4821")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, s.pins.failures)
+}
+
+func TestPin_SuccessResetsCount(t *testing.T) {
+	cfg := testConfig()
+	cfg.PinMaxAttempts = 2
+	s, _ := newTestServer(t, cfg)
+
+	created := createWithPin(t, s, "4821")
+
+	assert.Equal(t, http.StatusUnauthorized, getWithPin(s, created.ID, "0000").Code)
+	assert.Equal(t, http.StatusOK, getWithPin(s, created.ID, "4821").Code)
+	assert.Equal(t, http.StatusUnauthorized, getWithPin(s, created.ID, "0000").Code)
+}
+
+func TestPin_Invalid(t *testing.T) {
+	s, repo := newTestServer(t, testConfig())
+
+	for _, tt := range []struct{ pin, target string }{
+		{"123", "/"},
+		{"123456789", "/"},
+		{"12a4", "/"},
+		{"4821", "/?burn=true"},
+	} {
+		req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader("content"))
+		req.Header.Set("X-Pin", tt.pin)
+		rec := serve(s, req)
+		require.Equal(t, http.StatusBadRequest, rec.Code, tt.pin)
+		assert.Equal(t, ErrorDetails{"field": "pin"}, decodeError(t, rec).Details)
+	}
+	assert.Empty(t, repo.snippets)
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/config"
+)
+
+// quotaTracker counts what each creator uploaded in its current window.
+// Counts live in memory, so enforcing the quota and reporting it in
+// X-Quota-* headers costs no queries. A window starts with a creator's
+// first upload and its count is dropped once the window has passed.
+type quotaTracker struct {
+	limit  int64
+	bytes  bool // count content bytes instead of snippets
+	window time.Duration
+	now    func() time.Time
+
+	mu    sync.Mutex
+	usage map[string]*quotaUsage
+}
+
+type quotaUsage struct {
+	used  int64
+	reset time.Time
+}
+
+// newQuotaTracker returns nil when cfg has no quota.
+func newQuotaTracker(cfg *config.Config) *quotaTracker {
+	if cfg.QuotaLimit <= 0 {
+		return nil
+	}
+	return &quotaTracker{
+		limit:  cfg.QuotaLimit,
+		bytes:  cfg.QuotaUnit == config.QuotaUnitBytes,
+		window: cfg.QuotaWindow,
+		now:    time.Now,
+		usage:  make(map[string]*quotaUsage),
+	}
+}
+
+// cost is what an upload of size bytes counts against the quota.
+func (q *quotaTracker) cost(size int) int64 {
+	if q.bytes {
+		return int64(size)
+	}
+	return 1
+}
+
+// reserve adds cost to key's usage if that stays within the limit. It
+// returns the usage after the call and when the window resets, and false
+// when the upload does not fit.
+func (q *quotaTracker) reserve(key string, cost int64) (used int64, reset time.Time, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	u := q.current(key)
+	if u == nil {
+		q.prune()
+		u = &quotaUsage{reset: q.now().Add(q.window)}
+		q.usage[key] = u
+	}
+	if u.used+cost > q.limit {
+		return u.used, u.reset, false
+	}
+	u.used += cost
+	return u.used, u.reset, true
+}
+
+// release gives back cost reserved for an upload that was not stored and
+// returns the usage after the call.
+func (q *quotaTracker) release(key string, cost int64) int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	u := q.current(key)
+	if u == nil {
+		return 0
+	}
+	u.used = max(u.used-cost, 0)
+	return u.used
+}
+
+// current returns key's usage in its current window, dropping it once the
+// window has passed. q.mu must be held.
+func (q *quotaTracker) current(key string) *quotaUsage {
+	u, ok := q.usage[key]
+	if !ok {
+		return nil
+	}
+	if !q.now().Before(u.reset) {
+		delete(q.usage, key)
+		return nil
+	}
+	return u
+}
+
+// prune drops every usage whose window has passed. q.mu must be held.
+func (q *quotaTracker) prune() {
+	now := q.now()
+	for key, u := range q.usage {
+		if !now.Before(u.reset) {
+			delete(q.usage, key)
+		}
+	}
+}
+
+// setHeaders reports the quota and used of it to the client.
+func (q *quotaTracker) setHeaders(w http.ResponseWriter, used int64) {
+	w.Header().Set("X-Quota-Limit", strconv.FormatInt(q.limit, 10))
+	w.Header().Set("X-Quota-Used", strconv.FormatInt(used, 10))
+	w.Header().Set("X-Quota-Remaining", strconv.FormatInt(max(q.limit-used, 0), 10))
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/config"
+)
+
+func uploadFrom(s *Server, remoteAddr, target, content string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(content))
+	req.RemoteAddr = remoteAddr
+	return serve(s, req)
+}
+
+func assertQuota(t *testing.T, rec *httptest.ResponseRecorder, limit, used, remaining string) {
+	t.Helper()
+
+	assert.Equal(t, limit, rec.Header().Get("X-Quota-Limit"))
+	assert.Equal(t, used, rec.Header().Get("X-Quota-Used"))
+	assert.Equal(t, remaining, rec.Header().Get("X-Quota-Remaining"))
+}
+
+func TestQuota_Snippets(t *testing.T) {
+	cfg := testConfig()
+	cfg.QuotaLimit = 3
+	cfg.QuotaUnit = config.QuotaUnitSnippets
+	cfg.QuotaWindow = time.Hour
+	s, _ := newTestServer(t, cfg)
+
+	now := time.Now()
+	s.quota.now = func() time.Time { return now }
+
+	for i, want := range []string{"2", "1", "0"} {
+		rec := uploadFrom(s, "198.51.100.7:1234", "/", "note")
+		require.Equal(t, http.StatusCreated, rec.Code)
+		assertQuota(t, rec, "3", strconv.Itoa(i+1), want)
+	}
+
+	rec := uploadFrom(s, "198.51.100.7:1234", "/", "note")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, ErrCodeQuotaExceeded, decodeError(t, rec).Code)
+	assert.Equal(t, "3600", rec.Header().Get("Retry-After"))
+	assertQuota(t, rec, "3", "3", "0")
+
+	// Another IP has its own quota
+	rec = uploadFrom(s, "198.51.100.8:1234", "/", "note")
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assertQuota(t, rec, "3", "1", "2")
+
+	// A new window starts once the old one has passed
+	now = now.Add(time.Hour)
+	rec = uploadFrom(s, "198.51.100.7:1234", "/", "note")
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assertQuota(t, rec, "3", "1", "2")
+}
+
+func TestQuota_Bytes(t *testing.T) {
+	cfg := testConfig()
+	cfg.QuotaLimit = 10
+	cfg.QuotaUnit = config.QuotaUnitBytes
+	cfg.QuotaWindow = time.Hour
+	s, _ := newTestServer(t, cfg)
+
+	rec := uploadFrom(s, "198.51.100.7:1234", "/", "123456")
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assertQuota(t, rec, "10", "6", "4")
+
+	// An upload that does not fit is refused without using up the rest
+	rec = uploadFrom(s, "198.51.100.7:1234", "/", "12345")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assertQuota(t, rec, "10", "6", "4")
+
+	rec = uploadFrom(s, "198.51.100.7:1234", "/", "1234")
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assertQuota(t, rec, "10", "10", "0")
+}
+
+func TestQuota_FailedCreateIsGivenBack(t *testing.T) {
+	cfg := testConfig()
+	cfg.QuotaLimit = 5
+	cfg.QuotaWindow = time.Hour
+	s, _ := newTestServer(t, cfg)
+
+	rec := uploadFrom(s, "198.51.100.7:1234", "/?id=taken-id", "note")
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assertQuota(t, rec, "5", "1", "4")
+
+	rec = uploadFrom(s, "198.51.100.7:1234", "/?id=taken-id", "note")
+	require.Equal(t, http.StatusConflict, rec.Code)
+	assertQuota(t, rec, "5", "1", "4")
+}
+
+func TestQuota_DisabledByDefault(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+
+	rec := doRequest(s, http.MethodPost, "/", "note")
+
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.Empty(t, rec.Header().Get("X-Quota-Limit"))
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/httprate"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/config"
+)
+
+// rateLimitWindow is the window the rate limits are counted over.
+var rateLimitWindow = time.Minute
+
+// rateLimitPoll is how often a waiting request checks the limit again.
+const rateLimitPoll = 100 * time.Millisecond
+
+// maxRateLimitWaiters is how many requests of one client may wait at once.
+// Further ones are answered straight away, as without ?wait=true.
+const maxRateLimitWaiters = 4
+
+type rateLimitHitKey struct{}
+
+// rateLimitKey identifies who a request is counted against: the API key it
+// carries when that is one of API_KEYS, so clients sharing a NAT get their
+// own budgets, and otherwise its IP. Only a digest of the key is used, as
+// counters may be kept in Redis.
+func (s *Server) rateLimitKey(r *http.Request) (string, error) {
+	if key := r.Header.Get("X-API-Key"); s.validAPIKey(key) {
+		sum := sha256.Sum256([]byte(key))
+		return "apikey:" + hex.EncodeToString(sum[:16]), nil
+	}
+	return httprate.KeyByIP(r)
+}
+
+// onRateLimited answers a request over the limit with a JSON 429, or, for
+// a request held by waitForRateLimit, only records that it was limited.
+// httprate has already set X-RateLimit-Limit, X-RateLimit-Remaining,
+// X-RateLimit-Reset and Retry-After.
+func onRateLimited(w http.ResponseWriter, r *http.Request) {
+	if hit, ok := r.Context().Value(rateLimitHitKey{}).(*bool); ok {
+		*hit = true
+		return
+	}
+	rateLimited(w)
+}
+
+// waitForRateLimit lets a request with ?wait=true wait up to
+// RateLimitMaxWait for the limiter to admit it, instead of failing
+// immediately. A limited attempt is not counted, so polling is free; the
+// request gets a 429 only once the wait runs out.
+func (s *Server) waitForRateLimit(limited http.Handler) http.Handler {
+	var (
+		mu      sync.Mutex
+		waiters = make(map[string]int)
+	)
+	// join takes one of key's waiting slots, reporting false if none is left
+	join := func(key string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if waiters[key] >= maxRateLimitWaiters {
+			return false
+		}
+		waiters[key]++
+		return true
+	}
+	leave := func(key string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if waiters[key]--; waiters[key] <= 0 {
+			delete(waiters, key)
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("wait") != "true" {
+			limited.ServeHTTP(w, r)
+			return
+		}
+		client, err := s.rateLimitKey(r)
+		key := client + "/" + tenantFromContext(r.Context())
+		if err != nil || !join(key) {
+			limited.ServeHTTP(w, r)
+			return
+		}
+		defer leave(key)
+
+		deadline := time.Now().Add(s.config.RateLimitMaxWait)
+		hit := new(bool)
+		r = r.WithContext(context.WithValue(r.Context(), rateLimitHitKey{}, hit))
+		for {
+			*hit = false
+			// Retry-After of an earlier, limited attempt must not reach an
+			// admitted response
+			w.Header().Del("Retry-After")
+			limited.ServeHTTP(w, r)
+			if !*hit {
+				return
+			}
+
+			wait := min(rateLimitPoll, time.Until(deadline))
+			if wait <= 0 {
+				rateLimited(w)
+				return
+			}
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(wait):
+			}
+		}
+	})
+}
+
+// failModeCounter applies RATE_LIMIT_FAIL_MODE to a shared counter. It
+// never returns an error to the limiter: while the store fails, reads
+// report no requests (fail open) or a full window (fail closed), and
+// failed increments are dropped.
+type failModeCounter struct {
+	next   httprate.LimitCounter
+	closed bool
+	warn   func(error)
+
+	mu    sync.Mutex
+	limit int
+}
+
+func (s *Server) failMode(next httprate.LimitCounter) *failModeCounter {
+	var (
+		mu       sync.Mutex
+		lastWarn time.Time
+	)
+	return &failModeCounter{
+		next:   next,
+		closed: s.config.RateLimitFailMode == config.RateLimitFailClosed,
+		// One warning per window is enough to notice an outage
+		warn: func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if time.Since(lastWarn) < rateLimitWindow {
+				return
+			}
+			lastWarn = time.Now()
+			s.logger.Warn("rate limit store unavailable",
+				"error", err,
+				"fail_mode", s.config.RateLimitFailMode)
+		},
+	}
+}
+
+func (c *failModeCounter) Config(requestLimit int, windowLength time.Duration) {
+	c.mu.Lock()
+	c.limit = requestLimit
+	c.mu.Unlock()
+	c.next.Config(requestLimit, windowLength)
+}
+
+func (c *failModeCounter) Increment(key string, currentWindow time.Time) error {
+	return c.IncrementBy(key, currentWindow, 1)
+}
+
+func (c *failModeCounter) IncrementBy(key string, currentWindow time.Time, amount int) error {
+	if err := c.next.IncrementBy(key, currentWindow, amount); err != nil {
+		c.warn(err)
+	}
+	return nil
+}
+
+func (c *failModeCounter) Get(key string, currentWindow, previousWindow time.Time) (int, int, error) {
+	curr, prev, err := c.next.Get(key, currentWindow, previousWindow)
+	if err == nil {
+		return curr, prev, nil
+	}
+	c.warn(err)
+	if !c.closed {
+		return 0, 0, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.limit, 0, nil
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// VerifyReceiptRequest is the body of POST /verify-receipt.
+type VerifyReceiptRequest struct {
+	Receipt string `json:"receipt"`
+}
+
+// VerifyReceiptResponse describes the snippet a valid receipt was issued for.
+type VerifyReceiptResponse struct {
+	Valid     bool      `json:"valid"`
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	SizeBytes int64     `json:"size_bytes"`
+}
+
+// handleVerifyReceipt handles POST /verify-receipt. Only the signature is
+// checked; the snippet may since have expired or been deleted.
+func (s *Server) handleVerifyReceipt(w http.ResponseWriter, r *http.Request) {
+	var req VerifyReceiptRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		badRequest(w, "invalid request body: "+err.Error())
+		return
+	}
+	if req.Receipt == "" {
+		badRequestField(w, "receipt", "receipt is required")
+		return
+	}
+
+	rc, err := verifyReceipt([]byte(s.config.ReceiptSigningKey), req.Receipt)
+	if err != nil {
+		forbidden(w, "invalid creation receipt")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(VerifyReceiptResponse{
+		Valid:     true,
+		ID:        rc.ID,
+		CreatedAt: rc.CreatedAt,
+		SizeBytes: rc.SizeBytes,
+	})
+}
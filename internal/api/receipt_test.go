@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/config"
+)
+
+func receiptConfig() *config.Config {
+	cfg := testConfig()
+	cfg.ReceiptSigningKey = "receipt-secret"
+	return cfg
+}
+
+func verifyBody(receipt string) string {
+	body, _ := json.Marshal(VerifyReceiptRequest{Receipt: receipt})
+	return string(body)
+}
+
+func TestVerifyReceipt_Valid(t *testing.T) {
+	s, repo := newTestServer(t, receiptConfig())
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "hello world"))
+	require.NotEmpty(t, created.CreationReceipt)
+
+	rec := doRequest(s, http.MethodPost, "/verify-receipt", verifyBody(created.CreationReceipt))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp VerifyReceiptResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.True(t, resp.Valid)
+	assert.Equal(t, created.ID, resp.ID)
+	assert.Equal(t, int64(len("hello world")), resp.SizeBytes)
+	assert.True(t, repo.snippets[created.ID].CreatedAt.Equal(resp.CreatedAt))
+}
+
+func TestVerifyReceipt_Tampered(t *testing.T) {
+	s, _ := newTestServer(t, receiptConfig())
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "hello world"))
+	payload, sig, _ := strings.Cut(created.CreationReceipt, ".")
+
+	// Claim a different snippet with the original signature.
+	forged, err := signReceipt([]byte("other-secret"), receipt{ID: "someoneElses", SizeBytes: 11})
+	require.NoError(t, err)
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+
+	for name, value := range map[string]string{
+		"swapped payload": forgedPayload + "." + sig,
+		"wrong key":       forged,
+		"truncated sig":   payload + "." + sig[:len(sig)-2],
+		"no signature":    payload,
+	} {
+		t.Run(name, func(t *testing.T) {
+			rec := doRequest(s, http.MethodPost, "/verify-receipt", verifyBody(value))
+			require.Equal(t, http.StatusForbidden, rec.Code)
+			assert.Equal(t, ErrCodeForbidden, decodeError(t, rec).Code)
+		})
+	}
+
+	rec := doRequest(s, http.MethodPost, "/verify-receipt", verifyBody(""))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestCreate_NoReceiptWithoutKey(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "hello world"))
+	assert.Empty(t, created.CreationReceipt)
+
+	rec := doRequest(s, http.MethodPost, "/verify-receipt", verifyBody("x.y"))
+	assert.NotEqual(t, http.StatusOK, rec.Code)
+}
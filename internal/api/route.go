@@ -0,0 +1,88 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/config"
+)
+
+// reservedSegments are the fixed path segments of the routes. With
+// CaseInsensitiveRoutes they match in any case.
+var reservedSegments = map[string]bool{
+	"healthz":        true,
+	"readyz":         true,
+	"metrics":        true,
+	"stats":          true,
+	"admin":          true,
+	"snippets":       true,
+	"import":         true,
+	"verify-receipt": true,
+	"append":         true,
+	"presign":        true,
+	"complete":       true,
+	"meta":           true,
+	"thumb":          true,
+	"ws":             true,
+	"docs":           true,
+	"openapi.yaml":   true,
+}
+
+// normalizePathMiddleware drops trailing slashes and lowercases reserved
+// route segments, as configured. It runs before tenant resolution, so a
+// path-mode /t/{tenant} prefix is kept as is.
+func (s *Server) normalizePathMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := s.normalizePath(r.URL.Path)
+		if path == r.URL.Path {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if s.config.TrailingSlash == config.TrailingSlashRedirect {
+			target := *r.URL
+			target.Path, target.RawPath = path, ""
+			status := http.StatusPermanentRedirect
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				status = http.StatusMovedPermanently
+			}
+			http.Redirect(w, r, target.RequestURI(), status)
+			return
+		}
+
+		r.URL.Path = path
+		r.URL.RawPath = ""
+		next.ServeHTTP(w, r)
+	})
+}
+
+// normalizePath returns the canonical form of path. Segments that are valid
+// snippet IDs are never touched, since IDs are case-sensitive.
+func (s *Server) normalizePath(path string) string {
+	var prefix string
+	if s.config.TenancyMode == config.TenancyPath {
+		if rest, ok := strings.CutPrefix(path, tenantPathPrefix); ok {
+			tenant, rest, _ := strings.Cut(rest, "/")
+			prefix, path = tenantPathPrefix+tenant, "/"+rest
+		}
+	}
+
+	if s.config.TrailingSlash != config.TrailingSlashOff {
+		for len(path) > 1 && strings.HasSuffix(path, "/") {
+			path = strings.TrimSuffix(path, "/")
+		}
+	}
+
+	if s.config.CaseInsensitiveRoutes {
+		segments := strings.Split(path, "/")
+		for i, segment := range segments {
+			lower := strings.ToLower(segment)
+			if lower != segment && reservedSegments[lower] && !s.idGenerator.IsValid(segment) {
+				segments[i] = lower
+			}
+		}
+		path = strings.Join(segments, "/")
+	}
+
+	return prefix + path
+}
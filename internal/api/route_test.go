@@ -0,0 +1,88 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/config"
+)
+
+func TestTrailingSlash_Strip(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "hello world"))
+
+	rec := doRequest(s, http.MethodGet, "/"+created.ID+"/", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "hello world", rec.Body.String())
+
+	rec = doRequest(s, http.MethodGet, "/"+created.ID+"/meta/", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestTrailingSlash_Redirect(t *testing.T) {
+	cfg := testConfig()
+	cfg.TrailingSlash = config.TrailingSlashRedirect
+	s, _ := newTestServer(t, cfg)
+
+	rec := doRequest(s, http.MethodGet, "/AlNqaGNP4POi/?raw", "")
+	assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+	assert.Equal(t, "/AlNqaGNP4POi?raw", rec.Header().Get("Location"))
+
+	rec = doRequest(s, http.MethodPost, "/AlNqaGNP4POi/append/", "more")
+	assert.Equal(t, http.StatusPermanentRedirect, rec.Code)
+}
+
+func TestTrailingSlash_Off(t *testing.T) {
+	cfg := testConfig()
+	cfg.TrailingSlash = config.TrailingSlashOff
+	s, _ := newTestServer(t, cfg)
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "hello world"))
+
+	assert.NotEqual(t, http.StatusOK, doRequest(s, http.MethodGet, "/"+created.ID+"/", "").Code)
+}
+
+func TestCaseInsensitiveRoutes(t *testing.T) {
+	cfg := testConfig()
+	cfg.CaseInsensitiveRoutes = true
+	s, _ := newTestServer(t, cfg)
+
+	rec := doRequest(s, http.MethodGet, "/Healthz", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+	assert.Equal(t, http.StatusOK, doRequest(s, http.MethodGet, "/READYZ/", "").Code)
+
+	// IDs keep their case: the lowercased ID does not exist.
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "hello world"))
+	rec = doRequest(s, http.MethodGet, "/"+created.ID+"/Meta", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = doRequest(s, http.MethodGet, "/"+created.ID, "")
+	assert.Equal(t, "hello world", rec.Body.String())
+}
+
+func TestCaseInsensitiveRoutes_Disabled(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+
+	assert.NotEqual(t, http.StatusOK, doRequest(s, http.MethodGet, "/Healthz", "").Code)
+}
+
+func TestNormalizePath(t *testing.T) {
+	cfg := testConfig()
+	cfg.CaseInsensitiveRoutes = true
+	cfg.TenancyMode = config.TenancyPath
+	s, _ := newTestServer(t, cfg)
+
+	tests := map[string]string{
+		"/":                            "/",
+		"/AlNqaGNP4POi//":              "/AlNqaGNP4POi",
+		"/Admin/Snippets/AlNqaGNP4POi": "/admin/snippets/AlNqaGNP4POi",
+		"/t/acme/":                     "/t/acme/",
+		"/t/acme/Healthz/":             "/t/acme/healthz",
+		"/ABCDEFGHIJKL":                "/ABCDEFGHIJKL",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, s.normalizePath(in), in)
+	}
+}
@@ -2,35 +2,75 @@ package api
 
 import (
 	"log/slog"
+	"math/rand/v2"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/httprate"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/rayenfassatoui/tafcha-cli/internal/config"
+	"github.com/rayenfassatoui/tafcha-cli/internal/hash"
 	"github.com/rayenfassatoui/tafcha-cli/internal/id"
+	"github.com/rayenfassatoui/tafcha-cli/internal/ratelimit"
 	"github.com/rayenfassatoui/tafcha-cli/internal/storage"
 )
 
 // Server represents the HTTP API server.
 type Server struct {
-	router      *chi.Mux
-	config      *config.Config
-	repo        storage.Repository
-	idGenerator *id.Generator
-	logger      *slog.Logger
+	router       *chi.Mux
+	config       *config.Config
+	repo         storage.Repository
+	idGenerator  *id.Generator
+	hasher       hash.Hasher
+	thumbs       *thumbCache
+	thumbDecodes chan struct{} // one slot per thumbnail being generated
+	creatorKey   []byte
+	live         *hub
+	pins         *pinGuard
+	quota        *quotaTracker          // nil without QUOTA_LIMIT
+	redis        *ratelimit.RedisClient // nil unless RATE_LIMIT_STORE=redis
+	inFlight     atomic.Int64
+	cleanup      *CleanupWorker   // nil unless SetCleanupWorker was called
+	uploads      storage.Uploader // nil unless SetUploader was called
+	logger       *slog.Logger
 }
 
 // NewServer creates a new API server.
 func NewServer(cfg *config.Config, repo storage.Repository, logger *slog.Logger) *Server {
+	hasher, err := hash.New(cfg.ContentHashAlgo)
+	if err != nil {
+		logger.Warn("falling back to default content hash", "error", err, "algorithm", hash.Default)
+		hasher, _ = hash.New(string(hash.Default))
+	}
+	idGenerator, err := id.New(cfg.IDLength, cfg.IDAlphabet)
+	if err != nil {
+		logger.Warn("falling back to default snippet IDs", "error", err, "length", id.Length)
+		idGenerator, _ = id.New(0, "")
+	}
+
 	s := &Server{
-		router:      chi.NewRouter(),
-		config:      cfg,
-		repo:        repo,
-		idGenerator: id.New(),
-		logger:      logger,
+		router:       chi.NewRouter(),
+		config:       cfg,
+		repo:         repo,
+		idGenerator:  idGenerator,
+		hasher:       hasher,
+		thumbs:       newThumbCache(thumbCacheEntries),
+		thumbDecodes: make(chan struct{}, maxThumbDecodes),
+		creatorKey:   newCreatorKey(cfg.CreatorHashKey, logger),
+		live:         newHub(),
+		pins:         newPinGuard(cfg.PinMaxAttempts, cfg.PinLockout),
+		quota:        newQuotaTracker(cfg),
+		logger:       logger,
+	}
+
+	if cfg.RateLimitStore == config.RateLimitStoreRedis {
+		if s.redis, err = ratelimit.NewRedisClient(cfg.RedisURL); err != nil {
+			logger.Warn("falling back to in-memory rate limits", "error", err)
+		}
 	}
 
 	s.setupMiddleware()
@@ -40,18 +80,37 @@ func NewServer(cfg *config.Config, repo storage.Repository, logger *slog.Logger)
 }
 
 func (s *Server) setupMiddleware() {
+	// In-flight request count, reported while draining on shutdown
+	s.router.Use(s.trackInFlight)
+
 	// Request ID for tracing
 	s.router.Use(middleware.RequestID)
 
+	// OpenTelemetry server spans (no-op unless tracing is configured)
+	s.router.Use(tracingMiddleware)
+
 	// Real IP extraction (for rate limiting behind proxies)
+	s.router.Use(peerAddrMiddleware)
 	s.router.Use(middleware.RealIP)
 
+	// Trailing slashes and reserved route casing
+	s.router.Use(s.normalizePathMiddleware)
+
+	// Tenant resolution (may rewrite the path in path mode)
+	s.router.Use(s.tenantMiddleware)
+
 	// Structured logging
 	s.router.Use(s.loggingMiddleware)
 
 	// Panic recovery
 	s.router.Use(middleware.Recoverer)
 
+	// Browser access from other origins, answering preflights before the
+	// Content-Type check and routing
+	if len(s.config.CORSAllowedOrigins) > 0 {
+		s.router.Use(s.corsMiddleware)
+	}
+
 	// Content-Type enforcement for POST
 	s.router.Use(s.contentTypeMiddleware)
 }
@@ -60,32 +119,118 @@ func (s *Server) setupRoutes() {
 	// Health checks (no rate limiting)
 	s.router.Get("/healthz", s.handleHealthz)
 	s.router.Get("/readyz", s.handleReadyz)
+	s.router.Get("/openapi.yaml", s.handleOpenAPI)
+	s.router.Get("/docs", s.handleDocs)
+	if s.config.MetricsEnabled {
+		s.router.Handle("/metrics", promhttp.Handler())
+	}
+	if s.config.StatsToken != "" {
+		s.router.Get("/stats", s.handleStats)
+	}
 
-	// POST endpoint with rate limiting
+	// Write endpoints share the POST rate limit and, with API_KEYS, need a key
 	s.router.Group(func(r chi.Router) {
-		r.Use(httprate.LimitByIP(s.config.PostRateLimit, time.Minute))
+		if len(s.config.APIKeys) > 0 {
+			r.Use(s.apiKeyAuth)
+		}
+		r.Use(s.rateLimit("post", s.config.PostRateLimit, true))
 		r.Post("/", s.handleCreate)
+		r.Post("/{id}/append", s.handleAppend)
+		r.Post("/presign", s.handlePresign)
+		r.Post("/{id}/complete", s.handleComplete)
+		r.Delete("/{id}", s.handleDelete)
+		r.Patch("/{id}", s.handleExtend)
+		if s.config.ReceiptSigningKey != "" {
+			r.Post("/verify-receipt", s.handleVerifyReceipt)
+		}
+	})
+
+	// Admin endpoints
+	s.router.Route("/admin", func(r chi.Router) {
+		r.Use(s.adminAuth)
+		r.Delete("/snippets", s.handleDeleteByCreator)
+		r.Put("/snippets/{id}", s.handlePutSnippet)
+		r.Post("/import", s.handleImport)
 	})
 
 	// GET endpoint with rate limiting
 	s.router.Group(func(r chi.Router) {
-		r.Use(httprate.LimitByIP(s.config.GetRateLimit, time.Minute))
-		r.Get("/{id}", s.handleGet)
+		r.Use(s.rateLimit("get", s.config.GetRateLimit, false))
+		r.With(s.compressMiddleware).Get("/{id}", s.handleGet)
+		r.Head("/{id}", s.handleHead)
+		r.Get("/{id}/meta", s.handleMeta)
+		if s.config.ThumbnailSize > 0 {
+			r.Get("/{id}/thumb", s.handleThumb)
+		}
+		if s.config.LiveStreaming {
+			r.Get("/ws/{id}", s.handleStream)
+		}
 	})
 }
 
-// loggingMiddleware logs HTTP requests.
+// trackInFlight counts requests while their handlers run.
+func (s *Server) trackInFlight(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimit returns a per-client, per-tenant limiter allowing limit
+// requests per minute, telling clients apart with rateLimitKey. name keeps
+// its counters apart from other limiters' in a shared store. Loopback
+// clients bypass it when ExemptLocalhost is enabled.
+// With waitable, requests with ?wait=true may be held until the limit frees
+// up, see waitForRateLimit.
+func (s *Server) rateLimit(name string, limit int, waitable bool) func(http.Handler) http.Handler {
+	opts := []httprate.Option{
+		httprate.WithKeyFuncs(s.rateLimitKey, keyByTenant),
+		httprate.WithLimitHandler(onRateLimited),
+	}
+	if s.redis != nil {
+		counter := ratelimit.NewRedisCounter(s.redis, "tafcha:ratelimit:"+name)
+		opts = append(opts, httprate.WithLimitCounter(s.failMode(counter)))
+	}
+	limiter := httprate.Limit(limit, rateLimitWindow, opts...)
+
+	return func(next http.Handler) http.Handler {
+		limited := limiter(next)
+		if waitable && s.config.RateLimitMaxWait > 0 {
+			limited = s.waitForRateLimit(limited)
+		}
+		if !s.config.ExemptLocalhost {
+			return limited
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isLoopbackRequest(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			limited.ServeHTTP(w, r)
+		})
+	}
+}
+
+// loggingMiddleware logs HTTP requests. Successful GETs are logged at
+// LogSampleRate; everything else, including every write, always is.
 func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 
 		defer func() {
+			if !s.shouldLog(r.Method, ww.Status()) {
+				return
+			}
 			s.logger.Info("http request",
 				"method", r.Method,
 				"path", r.URL.Path,
+				"route", routePattern(r),
 				"status", ww.Status(),
 				"bytes", ww.BytesWritten(),
+				"size_bucket", sizeBucket(ww.BytesWritten()),
 				"duration_ms", time.Since(start).Milliseconds(),
 				"request_id", middleware.GetReqID(r.Context()),
 				"remote_ip", r.RemoteAddr,
@@ -96,6 +241,43 @@ func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// shouldLog reports whether a finished request is logged. Only 2xx GETs
+// and HEADs are sampled.
+func (s *Server) shouldLog(method string, status int) bool {
+	if method != http.MethodGet && method != http.MethodHead {
+		return true
+	}
+	if status < 200 || status > 299 {
+		return true
+	}
+	return rand.Float64() < s.config.LogSampleRate
+}
+
+// routePattern returns the chi route that matched r, e.g. "/{id}/meta", so
+// logs group by endpoint rather than by snippet ID.
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		return rctx.RoutePattern()
+	}
+	return ""
+}
+
+// sizeBucket coarsens a body size for logging.
+func sizeBucket(n int) string {
+	switch {
+	case n == 0:
+		return "0"
+	case n < 1<<10:
+		return "<1KiB"
+	case n < 64<<10:
+		return "1KiB-64KiB"
+	case n < 1<<20:
+		return "64KiB-1MiB"
+	default:
+		return ">=1MiB"
+	}
+}
+
 // contentTypeMiddleware ensures POST requests have appropriate content type.
 func (s *Server) contentTypeMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -119,3 +301,20 @@ func (s *Server) contentTypeMiddleware(next http.Handler) http.Handler {
 func (s *Server) Handler() http.Handler {
 	return s.router
 }
+
+// ActiveRequests returns how many requests are being handled right now.
+func (s *Server) ActiveRequests() int64 {
+	return s.inFlight.Load()
+}
+
+// CloseStreams ends every live WebSocket with a going-away close frame, and
+// new ones right after they open. http.Server.Shutdown does not wait for or close
+// hijacked connections, so register it with RegisterOnShutdown.
+func (s *Server) CloseStreams() {
+	s.live.shutdown()
+}
+
+// SetCleanupWorker reports the status of w in GET /readyz.
+func (s *Server) SetCleanupWorker(w *CleanupWorker) {
+	s.cleanup = w
+}
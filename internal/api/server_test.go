@@ -0,0 +1,317 @@
+package api
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/config"
+)
+
+func postFrom(s *Server, remoteAddr string, headers map[string]string) int {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("content"))
+	req.RemoteAddr = remoteAddr
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return serve(s, req).Code
+}
+
+func TestRateLimit_ExemptLocalhost(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1:5000", "[::1]:5000"} {
+		t.Run(addr, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.PostRateLimit = 2
+			cfg.ExemptLocalhost = true
+			s, _ := newTestServer(t, cfg)
+
+			for i := 0; i < 5; i++ {
+				assert.Equal(t, http.StatusCreated, postFrom(s, addr, nil), "request %d", i+1)
+			}
+		})
+	}
+}
+
+func TestRateLimit_LocalhostThrottledWhenDisabled(t *testing.T) {
+	cfg := testConfig()
+	cfg.PostRateLimit = 2
+	s, _ := newTestServer(t, cfg)
+
+	assert.Equal(t, http.StatusCreated, postFrom(s, "127.0.0.1:5000", nil))
+	assert.Equal(t, http.StatusCreated, postFrom(s, "127.0.0.1:5000", nil))
+	assert.Equal(t, http.StatusTooManyRequests, postFrom(s, "127.0.0.1:5000", nil))
+}
+
+func TestRateLimit_ForwardedLoopbackNotExempt(t *testing.T) {
+	cfg := testConfig()
+	cfg.PostRateLimit = 2
+	cfg.ExemptLocalhost = true
+	s, _ := newTestServer(t, cfg)
+
+	spoofed := map[string]string{"X-Forwarded-For": "127.0.0.1"}
+	assert.Equal(t, http.StatusCreated, postFrom(s, "203.0.113.9:5000", spoofed))
+	assert.Equal(t, http.StatusCreated, postFrom(s, "203.0.113.9:5000", spoofed))
+	assert.Equal(t, http.StatusTooManyRequests, postFrom(s, "203.0.113.9:5000", spoofed))
+}
+
+// unreachableRedisURL returns the URL of a port nothing listens on.
+func unreachableRedisURL(t *testing.T) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+	return "redis://" + addr
+}
+
+func TestRateLimit_StoreSelection(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+	assert.Nil(t, s.redis, "memory by default")
+
+	cfg := testConfig()
+	cfg.RateLimitStore = config.RateLimitStoreRedis
+	cfg.RedisURL = "redis://localhost:6379"
+	s, _ = newTestServer(t, cfg)
+	assert.NotNil(t, s.redis)
+}
+
+func TestRateLimit_RedisFailOpen(t *testing.T) {
+	cfg := testConfig()
+	cfg.PostRateLimit = 1
+	cfg.RateLimitStore = config.RateLimitStoreRedis
+	cfg.RateLimitFailMode = config.RateLimitFailOpen
+	cfg.RedisURL = unreachableRedisURL(t)
+	s, _ := newTestServer(t, cfg)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusCreated, postFrom(s, "203.0.113.9:5000", nil), "request %d", i+1)
+	}
+}
+
+func TestRateLimit_RedisFailClosed(t *testing.T) {
+	cfg := testConfig()
+	cfg.PostRateLimit = 5
+	cfg.RateLimitStore = config.RateLimitStoreRedis
+	cfg.RateLimitFailMode = config.RateLimitFailClosed
+	cfg.RedisURL = unreachableRedisURL(t)
+	s, _ := newTestServer(t, cfg)
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("content"))
+	req.RemoteAddr = "203.0.113.9:5000"
+	rec := serve(s, req)
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, ErrCodeRateLimited, decodeError(t, rec).Code)
+}
+
+func TestRateLimit_HeadersAndJSON(t *testing.T) {
+	cfg := testConfig()
+	cfg.PostRateLimit = 30
+	s, _ := newTestServer(t, cfg)
+
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("content"))
+		req.RemoteAddr = "203.0.113.9:5000"
+		return serve(s, req)
+	}
+
+	first := post()
+	require.Equal(t, http.StatusCreated, first.Code)
+	assert.Equal(t, "30", first.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "29", first.Header().Get("X-RateLimit-Remaining"))
+	assert.Empty(t, first.Header().Get("Retry-After"))
+
+	for i := 2; i <= 30; i++ {
+		require.Equal(t, http.StatusCreated, post().Code, "request %d", i)
+	}
+
+	rec := post()
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "30", rec.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", rec.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, ErrCodeRateLimited, decodeError(t, rec).Code)
+}
+
+// withRateLimitWindow shortens the rate limit window for the test.
+func withRateLimitWindow(t *testing.T, window time.Duration) {
+	t.Helper()
+
+	prev := rateLimitWindow
+	rateLimitWindow = window
+	t.Cleanup(func() { rateLimitWindow = prev })
+}
+
+func postWait(s *Server) int {
+	req := httptest.NewRequest(http.MethodPost, "/?wait=true", strings.NewReader("content"))
+	req.RemoteAddr = "203.0.113.9:5000"
+	return serve(s, req).Code
+}
+
+func TestRateLimit_WaitThenSucceed(t *testing.T) {
+	withRateLimitWindow(t, 300*time.Millisecond)
+	cfg := testConfig()
+	cfg.PostRateLimit = 1
+	cfg.RateLimitMaxWait = 2 * time.Second
+	s, _ := newTestServer(t, cfg)
+
+	assert.Equal(t, http.StatusCreated, postWait(s))
+
+	start := time.Now()
+	req := httptest.NewRequest(http.MethodPost, "/?wait=true", strings.NewReader("content"))
+	req.RemoteAddr = "203.0.113.9:5000"
+	rec := serve(s, req)
+	assert.Equal(t, http.StatusCreated, rec.Code, "held until the limit frees up")
+	assert.Greater(t, time.Since(start), rateLimitPoll/2)
+	assert.Empty(t, rec.Header().Get("Retry-After"), "no Retry-After left over from the limited attempts")
+
+	// Without ?wait the limit still applies immediately
+	assert.Equal(t, http.StatusTooManyRequests, postFrom(s, "203.0.113.9:5000", nil))
+}
+
+func TestRateLimit_WaitTimesOut(t *testing.T) {
+	withRateLimitWindow(t, time.Hour)
+	cfg := testConfig()
+	cfg.PostRateLimit = 1
+	cfg.RateLimitMaxWait = 250 * time.Millisecond
+	s, _ := newTestServer(t, cfg)
+
+	assert.Equal(t, http.StatusCreated, postWait(s))
+
+	start := time.Now()
+	assert.Equal(t, http.StatusTooManyRequests, postWait(s))
+	assert.GreaterOrEqual(t, time.Since(start), cfg.RateLimitMaxWait)
+}
+
+func TestRateLimit_WaitersCapped(t *testing.T) {
+	withRateLimitWindow(t, time.Hour)
+	cfg := testConfig()
+	cfg.PostRateLimit = 1
+	cfg.RateLimitMaxWait = time.Second
+	s, _ := newTestServer(t, cfg)
+
+	assert.Equal(t, http.StatusCreated, postWait(s))
+
+	var wg sync.WaitGroup
+	for i := 0; i < maxRateLimitWaiters; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			postWait(s)
+		}()
+	}
+	time.Sleep(rateLimitPoll)
+
+	start := time.Now()
+	assert.Equal(t, http.StatusTooManyRequests, postWait(s))
+	assert.Less(t, time.Since(start), rateLimitPoll, "answered without waiting")
+	wg.Wait()
+}
+
+func TestRateLimit_WaitDisabled(t *testing.T) {
+	withRateLimitWindow(t, time.Hour)
+	cfg := testConfig()
+	cfg.PostRateLimit = 1
+	s, _ := newTestServer(t, cfg)
+
+	assert.Equal(t, http.StatusCreated, postWait(s))
+	start := time.Now()
+	assert.Equal(t, http.StatusTooManyRequests, postWait(s))
+	assert.Less(t, time.Since(start), rateLimitPoll)
+}
+
+// accessLogs returns the "http request" records logs has seen.
+func accessLogs(logs *recordingHandler) []map[string]any {
+	logs.mu.Lock()
+	defer logs.mu.Unlock()
+
+	var out []map[string]any
+	for _, rec := range logs.records {
+		if rec["msg"] == "http request" {
+			out = append(out, rec)
+		}
+	}
+	return out
+}
+
+func TestLogging_Sampling(t *testing.T) {
+	cfg := testConfig()
+	cfg.LogSampleRate = 0
+	logs := &recordingHandler{}
+	s := NewServer(cfg, newStubRepo(), slog.New(logs))
+
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "always logged"))
+	require.Len(t, accessLogs(logs), 1, "POSTs are logged regardless of the sample rate")
+	post := accessLogs(logs)[0]
+	assert.Equal(t, "POST", post["method"])
+	assert.Equal(t, "/", post["route"])
+	assert.Equal(t, "<1KiB", post["size_bucket"])
+
+	// A successful GET is sampled away, a failed one is not
+	require.Equal(t, http.StatusOK, doRequest(s, http.MethodGet, "/"+created.ID, "").Code)
+	require.Len(t, accessLogs(logs), 1)
+
+	require.Equal(t, http.StatusNotFound, doRequest(s, http.MethodGet, "/missingSnippet1", "").Code)
+	require.Len(t, accessLogs(logs), 2)
+	assert.Equal(t, "/{id}", accessLogs(logs)[1]["route"])
+}
+
+func TestLogging_FullSampleRate(t *testing.T) {
+	cfg := testConfig()
+	cfg.LogSampleRate = 1
+	logs := &recordingHandler{}
+	s := NewServer(cfg, newStubRepo(), slog.New(logs))
+
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "content"))
+	doRequest(s, http.MethodGet, "/"+created.ID+"/meta", "")
+
+	require.Len(t, accessLogs(logs), 2)
+	assert.Equal(t, "/{id}/meta", accessLogs(logs)[1]["route"])
+}
+
+func TestSizeBucket(t *testing.T) {
+	assert.Equal(t, "0", sizeBucket(0))
+	assert.Equal(t, "<1KiB", sizeBucket(1023))
+	assert.Equal(t, "1KiB-64KiB", sizeBucket(1024))
+	assert.Equal(t, "64KiB-1MiB", sizeBucket(64<<10))
+	assert.Equal(t, ">=1MiB", sizeBucket(1<<20))
+}
+
+func TestActiveRequests(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+
+	started, release := make(chan struct{}), make(chan struct{})
+	slow := s.trackInFlight(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slow.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}()
+	}
+	<-started
+	<-started
+	assert.EqualValues(t, 2, s.ActiveRequests())
+
+	close(release)
+	wg.Wait()
+	assert.Zero(t, s.ActiveRequests())
+
+	// Requests through the router are counted too, and released when done
+	doRequest(s, http.MethodPost, "/", "content")
+	assert.Zero(t, s.ActiveRequests())
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/urlsign"
+)
+
+// checkSignedURL enforces signed links for read endpoints. A request with
+// sig or exp is always verified; an unsigned one is refused only when
+// RequireSignedURLs is set. It writes a 403 and returns false on failure.
+func (s *Server) checkSignedURL(w http.ResponseWriter, r *http.Request, snippetID string) bool {
+	query := r.URL.Query()
+	signed := query.Has("sig") || query.Has("exp")
+	if !signed && !s.config.RequireSignedURLs {
+		return true
+	}
+
+	if s.config.URLSigningKey == "" {
+		forbidden(w, "signed links are not enabled")
+		return false
+	}
+
+	err := urlsign.Verify([]byte(s.config.URLSigningKey), snippetID, query, time.Now())
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, urlsign.ErrExpired):
+		forbidden(w, "signed link has expired")
+	case errors.Is(err, urlsign.ErrMissing):
+		forbidden(w, "a signed link is required")
+	default:
+		forbidden(w, "invalid link signature")
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/urlsign"
+)
+
+func TestHandleGet_SignedURL(t *testing.T) {
+	cfg := testConfig()
+	cfg.URLSigningKey = "shared"
+	cfg.RequireSignedURLs = true
+	s, _ := newTestServer(t, cfg)
+
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "signed content"))
+	signed := func(exp time.Time) string {
+		return "/" + created.ID + "?" + urlsign.Query([]byte("shared"), created.ID, exp).Encode()
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		rec := doRequest(s, http.MethodGet, signed(time.Now().Add(5*time.Minute)), "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "signed content", rec.Body.String())
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		rec := doRequest(s, http.MethodGet, signed(time.Now().Add(-time.Second)), "")
+		require.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, decodeError(t, rec).Message, "expired")
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		target := signed(time.Now().Add(5*time.Minute)) + "0"
+		rec := doRequest(s, http.MethodGet, target, "")
+		require.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, ErrCodeForbidden, decodeError(t, rec).Code)
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, doRequest(s, http.MethodGet, "/"+created.ID, "").Code)
+	})
+}
+
+func TestHandleGet_SignedURLOptional(t *testing.T) {
+	cfg := testConfig()
+	cfg.URLSigningKey = "shared"
+	s, _ := newTestServer(t, cfg)
+
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "content"))
+
+	assert.Equal(t, http.StatusOK, doRequest(s, http.MethodGet, "/"+created.ID, "").Code)
+
+	// A link carrying a bad signature is still rejected
+	rec := doRequest(s, http.MethodGet, "/"+created.ID+"?exp=1&sig=nope", "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
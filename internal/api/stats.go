@@ -0,0 +1,44 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// StatsResponse is the response for GET /stats.
+type StatsResponse struct {
+	ActiveSnippets   int64 `json:"active_snippets"`
+	TotalBytes       int64 `json:"total_bytes"`
+	ExpiringNextHour int64 `json:"expiring_next_hour"`
+}
+
+// handleStats handles GET /stats, reporting aggregate counts over active
+// snippets in all tenants. It needs STATS_TOKEN as a bearer token.
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.config.StatsToken)) != 1 {
+		unauthorized(w)
+		return
+	}
+
+	stats, err := s.repo.Stats(r.Context())
+	if err != nil {
+		s.logger.Error("failed to compute stats",
+			"error", err,
+			"request_id", middleware.GetReqID(r.Context()))
+		internalError(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(StatsResponse{
+		ActiveSnippets:   stats.ActiveSnippets,
+		TotalBytes:       stats.TotalBytes,
+		ExpiringNextHour: stats.ExpiringNextHour,
+	})
+}
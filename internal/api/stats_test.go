@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/storage"
+)
+
+func TestHandleStats(t *testing.T) {
+	cfg := testConfig()
+	cfg.StatsToken = "stats-secret"
+	s, repo := newTestServer(t, cfg)
+
+	now := time.Now()
+	repo.snippets["soon"] = &storage.Snippet{ID: "soon", Content: []byte("hello"), ExpiresAt: now.Add(10 * time.Minute)}
+	repo.snippets["later"] = &storage.Snippet{ID: "later", Content: []byte("world!"), ExpiresAt: now.Add(time.Hour * 24)}
+	repo.snippets["expired"] = &storage.Snippet{ID: "expired", Content: []byte("gone"), ExpiresAt: now.Add(-time.Minute)}
+
+	req := httptest.NewRequest(http.MethodGet, "/stats", nil)
+	req.Header.Set("Authorization", "Bearer stats-secret")
+	rec := serve(s, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp StatsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, StatsResponse{ActiveSnippets: 2, TotalBytes: 11, ExpiringNextHour: 1}, resp)
+}
+
+func TestHandleStats_Auth(t *testing.T) {
+	cfg := testConfig()
+	cfg.StatsToken = "stats-secret"
+	s, _ := newTestServer(t, cfg)
+
+	for name, auth := range map[string]string{"missing": "", "wrong": "Bearer nope", "not bearer": "stats-secret"} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/stats", nil)
+			if auth != "" {
+				req.Header.Set("Authorization", auth)
+			}
+			assert.Equal(t, http.StatusUnauthorized, serve(s, req).Code)
+		})
+	}
+}
+
+func TestHandleStats_DisabledByDefault(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+
+	req := httptest.NewRequest(http.MethodGet, "/stats", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rec := serve(s, req)
+	assert.NotEqual(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "active_snippets")
+}
@@ -0,0 +1,289 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/gorilla/websocket"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/id"
+	"github.com/rayenfassatoui/tafcha-cli/internal/storage"
+)
+
+const (
+	streamWriteWait  = 10 * time.Second
+	streamPongWait   = 60 * time.Second
+	streamPingPeriod = streamPongWait * 9 / 10
+)
+
+// A producer that cannot set headers, such as a browser, passes its append
+// token as WebSocket subprotocols: streamAppendProtocol and
+// streamTokenPrefix followed by the token. Only streamAppendProtocol is
+// echoed back.
+const (
+	streamAppendProtocol = "tafcha.append"
+	streamTokenPrefix    = "tafcha.token."
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+}
+
+var producerUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	Subprotocols:    []string{streamAppendProtocol},
+}
+
+// liveKey identifies a snippet in the live hub.
+func liveKey(tenant, snippetID string) string {
+	return tenant + "/" + snippetID
+}
+
+// handleStream handles GET /ws/{id}, a WebSocket for live snippets.
+//
+// A producer connects with the snippet's append token (X-Append-Token header
+// or, for browsers, the Sec-WebSocket-Protocol header) and sends text
+// messages, each appended to the snippet as with POST /{id}/append. The
+// token is not accepted in the URL, which ends up in access logs. Any other
+// connection is a viewer: it receives the current content, then every
+// chunk appended after it.
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	snippetID := chi.URLParam(r, "id")
+	if !id.IsValidCustom(snippetID) {
+		invalidID(w)
+		return
+	}
+	if r.URL.Query().Has("token") {
+		badRequestField(w, "token", "pass the append token in X-Append-Token or Sec-WebSocket-Protocol, not the URL")
+		return
+	}
+
+	token := r.Header.Get("X-Append-Token")
+	if token == "" {
+		token = streamProtocolToken(r)
+	}
+	if token != "" {
+		s.streamProducer(w, r, snippetID, token)
+		return
+	}
+	s.streamViewer(w, r, snippetID)
+}
+
+// streamProtocolToken returns the append token offered as a subprotocol
+// next to streamAppendProtocol, or "".
+func streamProtocolToken(r *http.Request) string {
+	protocols := websocket.Subprotocols(r)
+	if !slices.Contains(protocols, streamAppendProtocol) {
+		return ""
+	}
+	for _, p := range protocols {
+		if token, ok := strings.CutPrefix(p, streamTokenPrefix); ok {
+			return token
+		}
+	}
+	return ""
+}
+
+// streamProducer appends each text message to the snippet and broadcasts it.
+func (s *Server) streamProducer(w http.ResponseWriter, r *http.Request, snippetID, token string) {
+	reqID := middleware.GetReqID(r.Context())
+	repo := s.repoFor(r)
+
+	snippet, err := repo.Peek(r.Context(), snippetID)
+	if err != nil {
+		s.logger.Error("failed to fetch snippet",
+			"error", err,
+			"snippet_id", snippetID,
+			"request_id", reqID)
+		internalError(w)
+		return
+	}
+	if snippet == nil {
+		notFound(w)
+		return
+	}
+	if !tokenMatches(snippet.AppendTokenHash, token) {
+		unauthorized(w)
+		return
+	}
+
+	conn, err := producerUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // the upgrader already replied
+	}
+	defer conn.Close()
+
+	conn.SetReadLimit(s.config.MaxContentSize)
+	key := liveKey(tenantFromContext(r.Context()), snippetID)
+
+	// On shutdown, close the connection so the read below returns
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-s.live.closed:
+			closeStream(conn, websocket.CloseGoingAway, "")
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	for {
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			if errors.Is(err, websocket.ErrReadLimit) {
+				closeStream(conn, websocket.CloseMessageTooBig, "content exceeds maximum size")
+			}
+			return
+		}
+		if msgType != websocket.TextMessage || !utf8.Valid(data) {
+			closeStream(conn, websocket.CloseUnsupportedData, "only UTF-8 text is accepted")
+			return
+		}
+		if len(data) == 0 {
+			continue
+		}
+
+		updated, err := repo.Append(r.Context(), snippetID, storage.AppendRequest{
+			TokenHash:   tokenHash(token),
+			Content:     data,
+			MaxSize:     s.config.MaxContentSize,
+			ResetExpiry: s.config.AppendResetsExpiry,
+		})
+		switch {
+		case errors.Is(err, storage.ErrTooLarge):
+			closeStream(conn, websocket.CloseMessageTooBig, "content exceeds maximum size")
+			return
+		case errors.Is(err, storage.ErrNotFound):
+			closeStream(conn, websocket.CloseNormalClosure, "snippet not found or expired")
+			return
+		case errors.Is(err, storage.ErrTokenMismatch):
+			closeStream(conn, websocket.ClosePolicyViolation, "invalid append token")
+			return
+		case err != nil:
+			s.logger.Error("failed to append streamed content",
+				"error", err,
+				"snippet_id", snippetID,
+				"request_id", reqID)
+			closeStream(conn, websocket.CloseInternalServerErr, "an internal error occurred")
+			return
+		}
+
+		s.live.publish(key, liveChunk{Data: data, End: len(updated.Content)})
+	}
+}
+
+// streamViewer sends the snippet's content followed by live appends.
+func (s *Server) streamViewer(w http.ResponseWriter, r *http.Request, snippetID string) {
+	if !s.checkSignedURL(w, r, snippetID) {
+		return
+	}
+
+	reqID := middleware.GetReqID(r.Context())
+	key := liveKey(tenantFromContext(r.Context()), snippetID)
+
+	// Subscribe before reading the snapshot so no append is missed; chunks
+	// the snapshot already contains are skipped below.
+	sub := s.live.subscribe(key)
+	defer s.live.unsubscribe(key, sub)
+
+	// Peek, since a viewer that reconnects must not count as another view
+	snippet, err := s.repoFor(r).Peek(r.Context(), snippetID)
+	if err != nil {
+		s.logger.Error("failed to fetch snippet",
+			"error", err,
+			"snippet_id", snippetID,
+			"request_id", reqID)
+		internalError(w)
+		return
+	}
+	if snippet == nil {
+		notFound(w)
+		return
+	}
+	if !s.checkPin(w, r, snippet) {
+		return
+	}
+	if snippet.MaxViews > 0 {
+		forbidden(w, "live streaming is not available for snippets with a view limit")
+		return
+	}
+	if !isText(snippet.Content) {
+		unsupportedMediaType(w, "live streaming is only available for text snippets")
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// Viewers only send control frames; reading processes pongs and
+	// notices when the viewer goes away.
+	conn.SetReadLimit(512)
+	conn.SetReadDeadline(time.Now().Add(streamPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(streamPongWait))
+	})
+	go func() {
+		defer sub.close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	sent := len(snippet.Content)
+	if sent > 0 && !writeStream(conn, snippet.Content) {
+		return
+	}
+
+	ping := time.NewTicker(streamPingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case chunk := <-sub.send:
+			if chunk.End <= sent {
+				continue
+			}
+			data := chunk.Data
+			if skip := sent - (chunk.End - len(data)); skip > 0 {
+				data = data[skip:]
+			}
+			if !writeStream(conn, data) {
+				return
+			}
+			sent = chunk.End
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-sub.done:
+			closeStream(conn, websocket.CloseGoingAway, "")
+			return
+		}
+	}
+}
+
+func writeStream(conn *websocket.Conn, data []byte) bool {
+	conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+	return conn.WriteMessage(websocket.TextMessage, data) == nil
+}
+
+func closeStream(conn *websocket.Conn, code int, reason string) {
+	conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, reason),
+		time.Now().Add(streamWriteWait))
+}
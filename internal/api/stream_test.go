@@ -0,0 +1,196 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStreamServer(t *testing.T) (*Server, *stubRepo, *httptest.Server) {
+	t.Helper()
+
+	cfg := testConfig()
+	cfg.LiveStreaming = true
+	s, repo := newTestServer(t, cfg)
+	srv := httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+	return s, repo, srv
+}
+
+func dialStream(t *testing.T, srv *httptest.Server, snippetID, token string) *websocket.Conn {
+	t.Helper()
+
+	header := http.Header{}
+	if token != "" {
+		header.Set("X-Append-Token", token)
+	}
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws/"+snippetID, header)
+	require.NoError(t, err)
+	resp.Body.Close()
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readText(t *testing.T, conn *websocket.Conn) string {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	msgType, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	require.Equal(t, websocket.TextMessage, msgType)
+	return string(data)
+}
+
+func TestStream_ProducerToViewer(t *testing.T) {
+	s, repo, srv := newStreamServer(t)
+	created := createAppendable(t, s, "start\n")
+
+	viewer := dialStream(t, srv, created.ID, "")
+	assert.Equal(t, "start\n", readText(t, viewer), "viewer gets the current content first")
+
+	producer := dialStream(t, srv, created.ID, created.AppendToken)
+	for _, line := range []string{"line 1\n", "line 2\n"} {
+		require.NoError(t, producer.WriteMessage(websocket.TextMessage, []byte(line)))
+		assert.Equal(t, line, readText(t, viewer))
+	}
+
+	// HTTP appends reach live viewers too
+	require.Equal(t, http.StatusOK, appendTo(s, created.ID, created.AppendToken, "line 3\n").Code)
+	assert.Equal(t, "line 3\n", readText(t, viewer))
+
+	assert.Equal(t, "start\nline 1\nline 2\nline 3\n", string(repo.snippets[created.ID].Content))
+}
+
+func TestStream_SizeLimitAcrossStream(t *testing.T) {
+	s, repo, srv := newStreamServer(t)
+	s.config.MaxContentSize = 16
+	created := createAppendable(t, s, "0123456789")
+
+	producer := dialStream(t, srv, created.ID, created.AppendToken)
+	require.NoError(t, producer.WriteMessage(websocket.TextMessage, []byte("abc")))
+	require.NoError(t, producer.WriteMessage(websocket.TextMessage, []byte("defg")))
+
+	producer.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err := producer.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseMessageTooBig), "got %v", err)
+	assert.Equal(t, "0123456789abc", string(repo.snippets[created.ID].Content))
+}
+
+func TestStream_RejectsBinaryMessages(t *testing.T) {
+	s, _, srv := newStreamServer(t)
+	created := createAppendable(t, s, "text")
+
+	producer := dialStream(t, srv, created.ID, created.AppendToken)
+	require.NoError(t, producer.WriteMessage(websocket.BinaryMessage, []byte{0x00, 0x01}))
+
+	producer.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err := producer.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseUnsupportedData), "got %v", err)
+}
+
+func TestStream_InvalidToken(t *testing.T) {
+	s, _, srv := newStreamServer(t)
+	created := createAppendable(t, s, "text")
+
+	header := http.Header{"X-Append-Token": []string{"wrong"}}
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws/"+created.ID, header)
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestStream_TokenAsSubprotocol(t *testing.T) {
+	s, repo, srv := newStreamServer(t)
+	created := createAppendable(t, s, "start\n")
+
+	dialer := websocket.Dialer{Subprotocols: []string{streamAppendProtocol, streamTokenPrefix + created.AppendToken}}
+	producer, resp, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws/"+created.ID, nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	defer producer.Close()
+	assert.Equal(t, streamAppendProtocol, producer.Subprotocol(), "the token is not echoed")
+
+	require.NoError(t, producer.WriteMessage(websocket.TextMessage, []byte("line 1\n")))
+	require.Eventually(t, func() bool {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+		return string(repo.snippets[created.ID].Content) == "start\nline 1\n"
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestStream_TokenInURLRejected(t *testing.T) {
+	s, _, srv := newStreamServer(t)
+	created := createAppendable(t, s, "text")
+
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws/"+created.ID+"?token="+created.AppendToken, nil)
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestStream_ViewerDoesNotCountViews(t *testing.T) {
+	s, repo, srv := newStreamServer(t)
+	created := createAppendable(t, s, "text")
+
+	for i := 0; i < 2; i++ {
+		viewer := dialStream(t, srv, created.ID, "")
+		assert.Equal(t, "text", readText(t, viewer))
+	}
+	repo.mu.Lock()
+	assert.Zero(t, repo.snippets[created.ID].ViewCount)
+	repo.snippets[created.ID].MaxViews = 1
+	repo.mu.Unlock()
+
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws/"+created.ID, nil)
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "a view limit cannot be bypassed by streaming")
+}
+
+func TestStream_CloseStreams(t *testing.T) {
+	s, _, srv := newStreamServer(t)
+	created := createAppendable(t, s, "text")
+
+	viewer := dialStream(t, srv, created.ID, "")
+	assert.Equal(t, "text", readText(t, viewer))
+	producer := dialStream(t, srv, created.ID, created.AppendToken)
+
+	s.CloseStreams()
+
+	for _, conn := range []*websocket.Conn{viewer, producer} {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, _, err := conn.ReadMessage()
+		assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "got %v", err)
+	}
+	require.Eventually(t, func() bool { return s.ActiveRequests() == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestStream_Disabled(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+	created := createAppendable(t, s, "text")
+
+	rec := doRequest(s, http.MethodGet, "/ws/"+created.ID, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHub_DropsSlowSubscribers(t *testing.T) {
+	h := newHub()
+	sub := h.subscribe("k")
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		h.publish("k", liveChunk{Data: []byte("x"), End: i + 1})
+	}
+
+	select {
+	case <-sub.done:
+	default:
+		t.Fatal("slow subscriber was not dropped")
+	}
+	assert.Equal(t, 0, h.subscribers("k"))
+}
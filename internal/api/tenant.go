@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/config"
+	"github.com/rayenfassatoui/tafcha-cli/internal/storage"
+)
+
+// tenantPathPrefix introduces the tenant segment in path mode: /t/{tenant}/...
+const tenantPathPrefix = "/t/"
+
+var tenantPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9.-]{0,253}[a-z0-9])?$`)
+
+type tenantKey struct{}
+
+// tenantFromContext returns the request's tenant, empty for the default.
+func tenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// tenantMiddleware resolves the tenant for the request according to the
+// tenancy mode. In path mode the /t/{tenant} prefix is stripped so the
+// regular routes match; requests without a prefix use the default tenant.
+// In host mode the operational routes are also served to hosts that are
+// not tenants, such as probes and scrapers addressing the pod directly,
+// with the default tenant.
+func (s *Server) tenantMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var tenant string
+
+		switch s.config.TenancyMode {
+		case config.TenancyHost:
+			tenant = strings.ToLower(hostOnly(r.Host))
+			if isOperationalPath(r.URL.Path) && !s.knownTenant(tenant) {
+				tenant = ""
+			}
+		case config.TenancyPath:
+			if rest, ok := strings.CutPrefix(r.URL.Path, tenantPathPrefix); ok {
+				tenant, rest, _ = strings.Cut(rest, "/")
+				r.URL.Path = "/" + rest
+				r.URL.RawPath = ""
+			}
+		}
+
+		if tenant != "" && (!tenantPattern.MatchString(tenant) || !s.knownTenant(tenant)) {
+			notFound(w)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), tenantKey{}, tenant)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// isOperationalPath reports whether path belongs to the health, metrics or
+// admin routes rather than to a tenant's snippets.
+func isOperationalPath(path string) bool {
+	switch path {
+	case "/healthz", "/readyz", "/metrics":
+		return true
+	}
+	return path == "/admin" || strings.HasPrefix(path, "/admin/")
+}
+
+// knownTenant reports whether tenant is one of TENANTS.
+func (s *Server) knownTenant(tenant string) bool {
+	for _, t := range s.config.Tenants {
+		if strings.EqualFold(t, tenant) {
+			return true
+		}
+	}
+	return false
+}
+
+// repoFor returns the repository scoped to the request's tenant.
+func (s *Server) repoFor(r *http.Request) storage.Repository {
+	return s.repo.WithTenant(tenantFromContext(r.Context()))
+}
+
+// keyByTenant partitions rate limits per tenant. Only configured tenants
+// get this far, so a client cannot mint new budgets by naming new ones.
+func keyByTenant(r *http.Request) (string, error) {
+	return tenantFromContext(r.Context()), nil
+}
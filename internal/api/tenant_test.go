@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/config"
+	"github.com/rayenfassatoui/tafcha-cli/internal/storage"
+)
+
+func seedTenant(t *testing.T, repo *stubRepo, tenant, id, content string) {
+	t.Helper()
+
+	_, err := repo.WithTenant(tenant).Create(context.Background(), &storage.Snippet{
+		ID:        id,
+		Content:   []byte(content),
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+}
+
+func TestTenancy_HostIsolatesSameID(t *testing.T) {
+	cfg := testConfig()
+	cfg.TenancyMode = config.TenancyHost
+	cfg.Tenants = []string{"alpha.example", "beta.example"}
+	s, repo := newTestServer(t, cfg)
+
+	seedTenant(t, repo, "alpha.example", "abc123XYZ789", "from alpha")
+	seedTenant(t, repo, "beta.example", "abc123XYZ789", "from beta")
+
+	get := func(host string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/abc123XYZ789", nil)
+		req.Host = host
+		return serve(s, req)
+	}
+
+	assert.Equal(t, "from alpha", get("alpha.example").Body.String())
+	assert.Equal(t, "from beta", get("Beta.Example:8080").Body.String())
+	assert.Equal(t, http.StatusNotFound, get("gamma.example").Code)
+}
+
+func TestTenancy_PathIsolatesSameID(t *testing.T) {
+	cfg := testConfig()
+	cfg.TenancyMode = config.TenancyPath
+	cfg.Tenants = []string{"alpha", "beta"}
+	s, repo := newTestServer(t, cfg)
+
+	seedTenant(t, repo, "alpha", "abc123XYZ789", "from alpha")
+	seedTenant(t, repo, "beta", "abc123XYZ789", "from beta")
+
+	assert.Equal(t, "from alpha", doRequest(s, http.MethodGet, "/t/alpha/abc123XYZ789", "").Body.String())
+	assert.Equal(t, "from beta", doRequest(s, http.MethodGet, "/t/beta/abc123XYZ789", "").Body.String())
+	assert.Equal(t, http.StatusNotFound, doRequest(s, http.MethodGet, "/abc123XYZ789", "").Code)
+}
+
+func TestTenancy_PathCreateURL(t *testing.T) {
+	cfg := testConfig()
+	cfg.TenancyMode = config.TenancyPath
+	cfg.Tenants = []string{"alpha", "beta"}
+	s, repo := newTestServer(t, cfg)
+
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/t/alpha/", "hello"))
+
+	assert.Equal(t, "http://tafcha.test/t/alpha/"+created.ID, created.URL)
+	assert.Contains(t, repo.snippets, "alpha/"+created.ID)
+
+	rec := doRequest(s, http.MethodGet, strings.TrimPrefix(created.URL, "http://tafcha.test"), "")
+	assert.Equal(t, "hello", rec.Body.String())
+}
+
+func TestTenancy_HostCreateURL(t *testing.T) {
+	cfg := testConfig()
+	cfg.TenancyMode = config.TenancyHost
+	cfg.Tenants = []string{"alpha.example", "beta.example"}
+	s, _ := newTestServer(t, cfg)
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello"))
+	req.Host = "alpha.example"
+	created := decodeCreate(t, serve(s, req))
+
+	assert.Equal(t, "http://alpha.example/"+created.ID, created.URL)
+}
+
+func TestTenancy_RateLimitPerTenant(t *testing.T) {
+	cfg := testConfig()
+	cfg.TenancyMode = config.TenancyPath
+	cfg.Tenants = []string{"alpha", "beta"}
+	cfg.PostRateLimit = 1
+	s, _ := newTestServer(t, cfg)
+
+	assert.Equal(t, http.StatusCreated, doRequest(s, http.MethodPost, "/t/alpha/", "a").Code)
+	assert.Equal(t, http.StatusTooManyRequests, doRequest(s, http.MethodPost, "/t/alpha/", "a").Code)
+	assert.Equal(t, http.StatusCreated, doRequest(s, http.MethodPost, "/t/beta/", "b").Code)
+}
+
+func TestTenancy_UnknownTenant(t *testing.T) {
+	cfg := testConfig()
+	cfg.TenancyMode = config.TenancyPath
+	cfg.Tenants = []string{"alpha"}
+	cfg.PostRateLimit = 1
+	s, repo := newTestServer(t, cfg)
+
+	assert.Equal(t, http.StatusCreated, doRequest(s, http.MethodPost, "/t/alpha/", "a").Code)
+	for _, tenant := range []string{"beta", "gamma", "alpha2"} {
+		assert.Equal(t, http.StatusNotFound, doRequest(s, http.MethodPost, "/t/"+tenant+"/", "a").Code,
+			"an unlisted tenant does not get its own budget")
+	}
+	assert.Len(t, repo.snippets, 1)
+}
+
+func TestTenancy_HostOperationalRoutes(t *testing.T) {
+	cfg := testConfig()
+	cfg.TenancyMode = config.TenancyHost
+	cfg.Tenants = []string{"alpha.example"}
+	s, _ := newTestServer(t, cfg)
+
+	get := func(host, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Host = host
+		return serve(s, req)
+	}
+
+	for _, host := range []string{"10.0.0.5:8080", "localhost:8080", "alpha.example"} {
+		assert.Equal(t, http.StatusOK, get(host, "/healthz").Code, host)
+	}
+	assert.Equal(t, http.StatusNotFound, get("10.0.0.5:8080", "/abc123XYZ789").Code,
+		"snippets still need a tenant host")
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/id"
+	"github.com/rayenfassatoui/tafcha-cli/internal/storage"
+)
+
+const (
+	// maxThumbSourcePixels bounds the images we are willing to decode. A
+	// decoded image takes 4 to 8 bytes per pixel.
+	maxThumbSourcePixels = 4_000_000
+
+	// maxThumbDecodes is how many thumbnails are generated at once; further
+	// requests wait for a slot.
+	maxThumbDecodes = 4
+
+	// thumbCacheEntries is how many encoded thumbnails are kept in memory.
+	thumbCacheEntries = 256
+)
+
+// handleThumb handles GET /{id}/thumb, serving a PNG thumbnail of an image
+// snippet that fits within ThumbnailSize on both sides.
+func (s *Server) handleThumb(w http.ResponseWriter, r *http.Request) {
+	reqID := middleware.GetReqID(r.Context())
+	snippetID := chi.URLParam(r, "id")
+
+	if !id.IsValidCustom(snippetID) {
+		invalidID(w)
+		return
+	}
+
+	if !s.checkSignedURL(w, r, snippetID) {
+		return
+	}
+
+	snippet, err := s.repoFor(r).Get(r.Context(), snippetID)
+	if err != nil {
+		s.logger.Error("failed to fetch snippet",
+			"error", err,
+			"snippet_id", snippetID,
+			"request_id", reqID)
+		internalError(w)
+		return
+	}
+	if snippet == nil {
+		notFound(w)
+		return
+	}
+	if !s.checkPin(w, r, snippet) {
+		return
+	}
+
+	key := thumbCacheKey(snippet)
+	thumb, ok := s.thumbs.get(key)
+	if !ok {
+		select {
+		case s.thumbDecodes <- struct{}{}:
+		case <-r.Context().Done():
+			return
+		}
+		thumb, err = makeThumbnail(snippet.Content, s.config.ThumbnailSize)
+		<-s.thumbDecodes
+		var notImage errNotImage
+		if errors.As(err, &notImage) {
+			unsupportedMediaType(w, notImage.Error())
+			return
+		}
+		if err != nil {
+			s.logger.Error("failed to encode thumbnail",
+				"error", err,
+				"snippet_id", snippetID,
+				"request_id", reqID)
+			internalError(w)
+			return
+		}
+		s.thumbs.add(key, thumb)
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(thumb)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	w.Write(thumb)
+}
+
+// thumbCacheKey identifies a snippet's content by its hash, so a snippet
+// that was appended to, or deleted and created again under the same ID,
+// never gets the thumbnail of its earlier content.
+func thumbCacheKey(snippet *storage.Snippet) string {
+	sum := sha256.Sum256(snippet.Content)
+	return hex.EncodeToString(sum[:])
+}
+
+// errNotImage is returned by makeThumbnail for content it cannot thumbnail.
+type errNotImage string
+
+func (e errNotImage) Error() string { return string(e) }
+
+// makeThumbnail decodes a PNG or JPEG image and returns it downscaled to fit
+// within maxSide, encoded as PNG. Smaller images are re-encoded as is.
+func makeThumbnail(content []byte, maxSide int) ([]byte, error) {
+	switch http.DetectContentType(content) {
+	case "image/png", "image/jpeg":
+	default:
+		return nil, errNotImage("thumbnails are only available for PNG and JPEG images")
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, errNotImage("image could not be decoded")
+	}
+	if cfg.Width*cfg.Height > maxThumbSourcePixels {
+		return nil, errNotImage("image is too large to thumbnail")
+	}
+
+	var src image.Image
+	if format == "png" {
+		src, err = png.Decode(bytes.NewReader(content))
+	} else {
+		src, err = jpeg.Decode(bytes.NewReader(content))
+	}
+	if err != nil {
+		return nil, errNotImage("image could not be decoded")
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, downscale(src, maxSide)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// downscale shrinks src to fit within maxSide x maxSide, keeping the aspect
+// ratio, by averaging each destination pixel's source area.
+func downscale(src image.Image, maxSide int) image.Image {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	if sw <= maxSide && sh <= maxSide {
+		return src
+	}
+
+	dw, dh := maxSide, maxSide
+	if sw > sh {
+		dh = max(1, sh*maxSide/sw)
+	} else {
+		dw = max(1, sw*maxSide/sh)
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := b.Min.Y+y*sh/dh, b.Min.Y+(y+1)*sh/dh
+		for x := 0; x < dw; x++ {
+			x0, x1 := b.Min.X+x*sw/dw, b.Min.X+(x+1)*sw/dw
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := color.NRGBA64Model.Convert(src.At(sx, sy)).(color.NRGBA64)
+					r += uint64(c.R)
+					g += uint64(c.G)
+					bl += uint64(c.B)
+					a += uint64(c.A)
+					n++
+				}
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(bl / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
+
+// thumbCache is a small LRU of encoded thumbnails.
+type thumbCache struct {
+	mu      sync.Mutex
+	max     int
+	order   *list.List // front is most recently used
+	entries map[string]*list.Element
+}
+
+type thumbEntry struct {
+	key  string
+	data []byte
+}
+
+func newThumbCache(max int) *thumbCache {
+	return &thumbCache{max: max, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (c *thumbCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*thumbEntry).data, true
+}
+
+func (c *thumbCache) add(key string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*thumbEntry).data = data
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&thumbEntry{key: key, data: data})
+	if c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*thumbEntry).key)
+	}
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodePNG(t *testing.T, w, h int) string {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.String()
+}
+
+func TestHandleThumb_PNG(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxContentSize = 1 << 20
+	cfg.ThumbnailSize = 32
+	s, _ := newTestServer(t, cfg)
+
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", encodePNG(t, 200, 100)))
+	rec := doRequest(s, http.MethodGet, "/"+created.ID+"/thumb", "")
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+
+	thumb, err := png.Decode(rec.Body)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 32, 16), thumb.Bounds(), "fits the bound and keeps the aspect ratio")
+
+	// The second request is served from the cache
+	again := doRequest(s, http.MethodGet, "/"+created.ID+"/thumb", "")
+	require.Equal(t, http.StatusOK, again.Code)
+	assert.Equal(t, 1, s.thumbs.order.Len())
+}
+
+func TestHandleThumb_SmallImageNotUpscaled(t *testing.T) {
+	cfg := testConfig()
+	cfg.ThumbnailSize = 32
+	s, _ := newTestServer(t, cfg)
+
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", encodePNG(t, 8, 4)))
+	rec := doRequest(s, http.MethodGet, "/"+created.ID+"/thumb", "")
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	thumb, err := png.Decode(rec.Body)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 8, 4), thumb.Bounds())
+}
+
+func TestHandleThumb_TextUnsupported(t *testing.T) {
+	cfg := testConfig()
+	cfg.ThumbnailSize = 32
+	s, _ := newTestServer(t, cfg)
+
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "just text"))
+	rec := doRequest(s, http.MethodGet, "/"+created.ID+"/thumb", "")
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	assert.Equal(t, ErrCodeUnsupported, decodeError(t, rec).Code)
+}
+
+func TestHandleThumb_ContentReplacedUnderSameID(t *testing.T) {
+	cfg := testConfig()
+	cfg.ThumbnailSize = 32
+	s, repo := newTestServer(t, cfg)
+
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", encodePNG(t, 8, 4)))
+	first := doRequest(s, http.MethodGet, "/"+created.ID+"/thumb", "")
+	require.Equal(t, http.StatusOK, first.Code)
+
+	// Deleted and created again with other content; UpdatedAt stays unset
+	replaced := *repo.snippets[created.ID]
+	replaced.Content = []byte(encodePNG(t, 4, 8))
+	replaced.UpdatedAt = nil
+	repo.snippets[created.ID] = &replaced
+
+	rec := doRequest(s, http.MethodGet, "/"+created.ID+"/thumb", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	thumb, err := png.Decode(rec.Body)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 4, 8), thumb.Bounds())
+}
+
+func TestMakeThumbnail_TooLarge(t *testing.T) {
+	_, err := makeThumbnail([]byte(encodePNG(t, 2001, 2000)), 32)
+
+	assert.EqualError(t, err, "image is too large to thumbnail")
+}
+
+func TestHandleThumb_Disabled(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+
+	created := decodeCreate(t, doRequest(s, http.MethodPost, "/", "just text"))
+	rec := doRequest(s, http.MethodGet, "/"+created.ID+"/thumb", "")
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestThumbCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := newThumbCache(2)
+	c.add("a", []byte("a"))
+	c.add("b", []byte("b"))
+	c.get("a")
+	c.add("c", []byte("c"))
+
+	_, ok := c.get("b")
+	assert.False(t, ok)
+	_, ok = c.get("a")
+	assert.True(t, ok)
+	_, ok = c.get("c")
+	assert.True(t, ok)
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// newToken returns a random URL-safe secret handed to a snippet's creator.
+func newToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// tokenHash returns the form of a token that is stored in the database.
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// tokenMatches reports whether token hashes to the stored hash. An empty
+// stored hash never matches.
+func tokenMatches(storedHash, token string) bool {
+	if storedHash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(storedHash), []byte(tokenHash(token))) == 1
+}
+
+// errInvalidReceipt is returned for a malformed or tampered receipt.
+var errInvalidReceipt = errors.New("invalid receipt")
+
+// receipt is the signed proof that a snippet was created by whoever holds
+// it. It is self-contained: nothing is stored, and it stays verifiable after
+// the snippet itself is gone.
+type receipt struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	SizeBytes int64     `json:"size_bytes"`
+}
+
+// signReceipt encodes rc as "<payload>.<sig>", where payload is the
+// base64url JSON of rc and sig the base64url HMAC-SHA256 of payload.
+func signReceipt(key []byte, rc receipt) (string, error) {
+	raw, err := json.Marshal(rc)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(raw)
+	return payload + "." + receiptSig(key, payload), nil
+}
+
+// verifyReceipt checks the signature of a receipt made by signReceipt and
+// returns its contents.
+func verifyReceipt(key []byte, signed string) (receipt, error) {
+	payload, sig, ok := strings.Cut(signed, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(receiptSig(key, payload))) {
+		return receipt{}, errInvalidReceipt
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return receipt{}, errInvalidReceipt
+	}
+	var rc receipt
+	if err := json.Unmarshal(raw, &rc); err != nil || rc.ID == "" {
+		return receipt{}, errInvalidReceipt
+	}
+	return rc, nil
+}
+
+func receiptSig(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/tracing"
+)
+
+func TestTracingMiddleware_KeepsFailedRequests(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(tracing.NewSampler(0)),
+		sdktrace.WithSpanProcessor(tracing.KeepErrors(sdktrace.NewSimpleSpanProcessor(exporter))),
+	)
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	r := chi.NewRouter()
+	r.Use(tracingMiddleware)
+	r.Get("/ok/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	r.Get("/fail/{id}", func(w http.ResponseWriter, r *http.Request) {
+		internalError(w)
+	})
+
+	for _, path := range []string{"/ok/abc", "/fail/abc"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// At sample rate 0 only the failed request is exported
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "GET /fail/{id}", spans[0].Name)
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+}
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/id"
+	"github.com/rayenfassatoui/tafcha-cli/internal/storage"
+)
+
+// uploadWindow is how long a presigned upload URL is valid, and so how long
+// a reserved snippet waits for POST /{id}/complete.
+const uploadWindow = 15 * time.Minute
+
+// PresignResponse is the response for POST /presign.
+type PresignResponse struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+
+	// UploadURL takes a PUT of exactly the announced number of bytes
+	// until UploadExpiresAt.
+	UploadURL       string    `json:"upload_url"`
+	UploadExpiresAt time.Time `json:"upload_expires_at"`
+
+	// DeleteToken authorizes POST /{id}/complete, and later DELETE /{id}
+	// like the token returned by POST /.
+	DeleteToken string `json:"delete_token"`
+}
+
+// CompleteResponse is the response for POST /{id}/complete.
+type CompleteResponse struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	SizeBytes int64     `json:"size_bytes"`
+	ExpiresAt time.Time `json:"expires_at"`
+	ExpiresIn int64     `json:"expires_in"`
+}
+
+// SetUploader enables POST /presign and POST /{id}/complete, which let
+// clients upload content straight to object storage through u. Without it
+// both answer 404.
+func (s *Server) SetUploader(u storage.Uploader) {
+	s.uploads = u
+}
+
+// handlePresign handles POST /presign?size=N. It reserves a snippet ID and
+// returns a URL the client uploads its N bytes of content to, after which
+// POST /{id}/complete makes the snippet permanent.
+func (s *Server) handlePresign(w http.ResponseWriter, r *http.Request) {
+	reqID := middleware.GetReqID(r.Context())
+
+	if s.uploads == nil {
+		notFound(w)
+		return
+	}
+
+	size, err := strconv.ParseInt(r.URL.Query().Get("size"), 10, 64)
+	if err != nil || size <= 0 {
+		badRequestField(w, "size", "size must be the content length in bytes")
+		return
+	}
+	if size > s.config.MaxContentSize {
+		payloadTooLarge(w, s.config.MaxContentSize)
+		return
+	}
+
+	snippetID, err := s.idGenerator.Generate()
+	if err != nil {
+		s.logger.Error("failed to generate ID",
+			"error", err,
+			"request_id", reqID)
+		internalError(w)
+		return
+	}
+	deleteToken, err := newToken()
+	if err != nil {
+		s.logger.Error("failed to generate delete token",
+			"error", err,
+			"request_id", reqID)
+		internalError(w)
+		return
+	}
+
+	// The announced size counts against the quota, as it would on POST /
+	creator := s.creatorHash(clientIP(r))
+	var quotaCost int64
+	if s.quota != nil {
+		quotaCost = s.quota.cost(int(size))
+		used, reset, ok := s.quota.reserve(creator, quotaCost)
+		s.quota.setHeaders(w, used)
+		if !ok {
+			quotaExceeded(w, time.Until(reset))
+			return
+		}
+	}
+
+	uploadURL, err := s.uploads.ReserveUpload(r.Context(), tenantFromContext(r.Context()), &storage.Snippet{
+		ID:              snippetID,
+		Creator:         creator,
+		DeleteTokenHash: tokenHash(deleteToken),
+	}, size, uploadWindow)
+	if err != nil && s.quota != nil {
+		s.quota.setHeaders(w, s.quota.release(creator, quotaCost))
+	}
+	if errors.Is(err, storage.ErrConflict) {
+		conflict(w, "a snippet with this ID already exists")
+		return
+	}
+	if err != nil {
+		s.logger.Error("failed to reserve upload",
+			"error", err,
+			"request_id", reqID)
+		internalError(w)
+		return
+	}
+
+	s.logger.Info("upload reserved",
+		"snippet_id", snippetID,
+		"size_bytes", size,
+		"request_id", reqID,
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(PresignResponse{
+		ID:              snippetID,
+		URL:             s.snippetURL(r, snippetID),
+		UploadURL:       uploadURL,
+		UploadExpiresAt: time.Now().Add(uploadWindow),
+		DeleteToken:     deleteToken,
+	})
+}
+
+// handleComplete handles POST /{id}/complete, which finishes an upload
+// started with POST /presign once the content is in the bucket. It needs
+// the delete token from that response and accepts ?expiry= like POST /.
+func (s *Server) handleComplete(w http.ResponseWriter, r *http.Request) {
+	reqID := middleware.GetReqID(r.Context())
+	snippetID := chi.URLParam(r, "id")
+
+	if s.uploads == nil {
+		notFound(w)
+		return
+	}
+	if !id.IsValidCustom(snippetID) {
+		invalidID(w)
+		return
+	}
+
+	token := deleteToken(r)
+	if token == "" {
+		unauthorized(w)
+		return
+	}
+
+	expiryDuration, ok := s.parseExpiry(w, r)
+	if !ok {
+		return
+	}
+	expiresAt := func(size int64) time.Time {
+		if expiryDuration == 0 {
+			return expiresAtFor(s.config.DefaultExpiryFor(size))
+		}
+		return expiresAtFor(expiryDuration)
+	}
+
+	meta, err := s.uploads.CompleteUpload(r.Context(), tenantFromContext(r.Context()), snippetID,
+		tokenHash(token), s.config.MaxContentSize, expiresAt)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		notFound(w)
+		return
+	case errors.Is(err, storage.ErrTokenMismatch):
+		unauthorized(w)
+		return
+	case errors.Is(err, storage.ErrNotUploaded):
+		conflict(w, "the content has not been uploaded yet")
+		return
+	case errors.Is(err, storage.ErrTooLarge):
+		payloadTooLarge(w, s.config.MaxContentSize)
+		return
+	case err != nil:
+		s.logger.Error("failed to complete upload",
+			"error", err,
+			"snippet_id", snippetID,
+			"request_id", reqID)
+		internalError(w)
+		return
+	}
+
+	s.logger.Info("snippet created",
+		"snippet_id", snippetID,
+		"size_bytes", meta.SizeBytes,
+		"expires_at", meta.ExpiresAt,
+		"request_id", reqID,
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(CompleteResponse{
+		ID:        snippetID,
+		URL:       s.snippetURL(r, snippetID),
+		SizeBytes: meta.SizeBytes,
+		ExpiresAt: meta.ExpiresAt,
+		ExpiresIn: max(int64(time.Until(meta.ExpiresAt)/time.Second), 0),
+	})
+}
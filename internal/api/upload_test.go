@@ -0,0 +1,157 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/storage"
+)
+
+// mockObjects is an in-memory storage.ObjectStore whose presigned URLs
+// point at a fake bucket host.
+type mockObjects struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (m *mockObjects) PutObject(ctx context.Context, key string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = bytes.Clone(data)
+	return nil
+}
+
+func (m *mockObjects) GetObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, storage.ErrObjectNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *mockObjects) HeadObject(ctx context.Context, key string) (storage.ObjectInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[key]
+	if !ok {
+		return storage.ObjectInfo{}, storage.ErrObjectNotFound
+	}
+	return storage.ObjectInfo{Key: key, Size: int64(len(data)), LastModified: time.Now()}, nil
+}
+
+func (m *mockObjects) DeleteObject(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *mockObjects) ListObjects(ctx context.Context, prefix, startAfter string) ([]storage.ObjectInfo, bool, error) {
+	return nil, false, nil
+}
+
+func (m *mockObjects) PresignPut(key string, size int64, expires time.Duration) (string, error) {
+	return "https://bucket.test/" + key, nil
+}
+
+// upload does what a client does with a presigned URL.
+func (m *mockObjects) upload(t *testing.T, uploadURL, content string) {
+	t.Helper()
+	u, err := url.Parse(uploadURL)
+	require.NoError(t, err)
+	require.NoError(t, m.PutObject(context.Background(), strings.TrimPrefix(u.Path, "/"), []byte(content)))
+}
+
+func newUploadServer(t *testing.T) (*Server, *mockObjects) {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	objects := &mockObjects{objects: make(map[string][]byte)}
+	repo := storage.NewS3Repository(storage.NewMemoryRepository(logger), objects, logger)
+	s := NewServer(testConfig(), repo, logger)
+	s.SetUploader(repo)
+	return s, objects
+}
+
+func presign(t *testing.T, s *Server, size string) PresignResponse {
+	t.Helper()
+	rec := doRequest(s, http.MethodPost, "/presign?size="+size, "")
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var resp PresignResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp
+}
+
+func complete(s *Server, snippetID, token, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/"+snippetID+"/complete"+query, nil)
+	if token != "" {
+		req.Header.Set("X-Delete-Token", token)
+	}
+	return serve(s, req)
+}
+
+func TestUpload_PresignAndComplete(t *testing.T) {
+	s, objects := newUploadServer(t)
+
+	presigned := presign(t, s, "11")
+	assert.Equal(t, "http://tafcha.test/"+presigned.ID, presigned.URL)
+	assert.Equal(t, "https://bucket.test/snippets/"+presigned.ID, presigned.UploadURL)
+	assert.NotEmpty(t, presigned.DeleteToken)
+
+	rec := complete(s, presigned.ID, presigned.DeleteToken, "")
+	assert.Equal(t, http.StatusConflict, rec.Code, "nothing was uploaded yet")
+
+	objects.upload(t, presigned.UploadURL, "hello world")
+
+	assert.Equal(t, http.StatusUnauthorized, complete(s, presigned.ID, "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, complete(s, presigned.ID, "wrong", "").Code)
+
+	rec = complete(s, presigned.ID, presigned.DeleteToken, "?expiry=2h")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var completed CompleteResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &completed))
+	assert.Equal(t, int64(11), completed.SizeBytes)
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour), completed.ExpiresAt, time.Second)
+
+	rec = doRequest(s, http.MethodGet, "/"+presigned.ID, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "hello world", rec.Body.String())
+}
+
+func TestUpload_Invalid(t *testing.T) {
+	s, objects := newUploadServer(t)
+
+	for _, size := range []string{"", "0", "-1", "lots"} {
+		assert.Equal(t, http.StatusBadRequest, doRequest(s, http.MethodPost, "/presign?size="+size, "").Code, size)
+	}
+	assert.Equal(t, http.StatusRequestEntityTooLarge, doRequest(s, http.MethodPost, "/presign?size=1025", "").Code)
+	assert.Equal(t, http.StatusNotFound, complete(s, "missingSnippet", "token", "").Code)
+
+	// Content over MAX_CONTENT_SIZE is refused and the snippet removed
+	presigned := presign(t, s, "4")
+	objects.upload(t, presigned.UploadURL, strings.Repeat("x", 1025))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, complete(s, presigned.ID, presigned.DeleteToken, "").Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(s, http.MethodGet, "/"+presigned.ID, "").Code)
+}
+
+func TestUpload_NeedsObjectStorage(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+
+	assert.Equal(t, http.StatusNotFound, doRequest(s, http.MethodPost, "/presign?size=5", "").Code)
+	assert.Equal(t, http.StatusNotFound, complete(s, "abc123XYZ789", "token", "").Code)
+}
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+)
+
+// Browser opens URLs with the platform's default browser.
+type Browser struct {
+	GOOS     string
+	LookPath func(file string) (string, error)
+	Run      func(name string, args ...string) error
+}
+
+// NewBrowser returns a Browser for the current platform that starts the
+// opener without waiting for it to exit.
+func NewBrowser() *Browser {
+	return &Browser{
+		GOOS:     runtime.GOOS,
+		LookPath: exec.LookPath,
+		Run: func(name string, args ...string) error {
+			cmd := exec.Command(name, args...)
+			if err := cmd.Start(); err != nil {
+				return err
+			}
+			return cmd.Process.Release()
+		},
+	}
+}
+
+// ErrNoBrowser is returned by Open when the platform has no known opener.
+var ErrNoBrowser = errors.New("no browser opener found")
+
+// Open launches url in the default browser: open on macOS, rundll32 on
+// Windows and xdg-open elsewhere.
+func (b *Browser) Open(url string) error {
+	name, args := b.opener(url)
+	if _, err := b.LookPath(name); err != nil {
+		return ErrNoBrowser
+	}
+	return b.Run(name, args...)
+}
+
+func (b *Browser) opener(url string) (string, []string) {
+	switch b.GOOS {
+	case "darwin":
+		return "open", []string{url}
+	case "windows":
+		return "rundll32", []string{"url.dll,FileProtocolHandler", url}
+	default:
+		return "xdg-open", []string{url}
+	}
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBrowser records the command it would run. Only commands in found
+// are reported as installed.
+func fakeBrowser(goos string, found ...string) (*Browser, *[]string) {
+	var ran []string
+	return &Browser{
+		GOOS: goos,
+		LookPath: func(file string) (string, error) {
+			for _, f := range found {
+				if f == file {
+					return "/usr/bin/" + file, nil
+				}
+			}
+			return "", errors.New("not found")
+		},
+		Run: func(name string, args ...string) error {
+			ran = append(append(ran, name), args...)
+			return nil
+		},
+	}, &ran
+}
+
+func TestBrowser_Open(t *testing.T) {
+	const url = "https://tafcha.dev/abc123XYZ789"
+
+	tests := []struct {
+		goos string
+		want []string
+	}{
+		{goos: "linux", want: []string{"xdg-open", url}},
+		{goos: "freebsd", want: []string{"xdg-open", url}},
+		{goos: "darwin", want: []string{"open", url}},
+		{goos: "windows", want: []string{"rundll32", "url.dll,FileProtocolHandler", url}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.goos, func(t *testing.T) {
+			b, ran := fakeBrowser(tt.goos, tt.want[0])
+			require.NoError(t, b.Open(url))
+			assert.Equal(t, tt.want, *ran)
+		})
+	}
+}
+
+func TestBrowser_Open_NoOpener(t *testing.T) {
+	b, ran := fakeBrowser("linux")
+
+	assert.ErrorIs(t, b.Open("https://tafcha.dev/abc123XYZ789"), ErrNoBrowser)
+	assert.Empty(t, *ran)
+}
@@ -4,24 +4,76 @@ package cli
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/urlsign"
 )
 
+// expectContinueThreshold is the upload size above which the client asks the
+// server to approve the request headers before sending the body.
+const expectContinueThreshold = 64 << 10
+
+// ErrRateLimited is returned when the server answers 429 Too Many Requests.
+var ErrRateLimited = errors.New("rate limited by the server")
+
+// rateLimitError returns ErrRateLimited, saying when to retry if the server
+// sent a Retry-After in seconds.
+func rateLimitError(resp *http.Response) error {
+	secs, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || secs < 0 {
+		return ErrRateLimited
+	}
+	return fmt.Errorf("%w, retry in %s", ErrRateLimited, time.Duration(secs)*time.Second)
+}
+
 // Client is the HTTP client for interacting with the Tafcha API.
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+	apiKey     string
 }
 
 // CreateResponse matches the API response for snippet creation.
 type CreateResponse struct {
 	ID        string    `json:"id"`
 	URL       string    `json:"url"`
+	ShortCode string    `json:"short_code"`
+	RawURL    string    `json:"raw_url"`
 	ExpiresAt time.Time `json:"expires_at"`
+
+	// ExpiresIn is the seconds left when the server answered. Older
+	// servers do not send it.
+	ExpiresIn int64 `json:"expires_in,omitempty"`
+
+	// DeleteToken is needed to delete the snippet later.
+	DeleteToken string `json:"delete_token,omitempty"`
+	DeleteURL   string `json:"delete_url,omitempty"`
+
+	// RemainingViews is set for burn-after-reading snippets.
+	RemainingViews *int `json:"remaining_views,omitempty"`
+
+	// AppendToken is set for snippets created with Appendable.
+	AppendToken string `json:"append_token,omitempty"`
+
+	receivedAt time.Time // when Create got the response
+}
+
+// LocalExpiry returns when the snippet expires by the local clock. With
+// ExpiresIn it is counted from when the response arrived, so a local clock
+// that disagrees with the server's does not shift it; otherwise it is
+// ExpiresAt.
+func (r *CreateResponse) LocalExpiry() time.Time {
+	if r.ExpiresIn > 0 && !r.receivedAt.IsZero() {
+		return r.receivedAt.Add(time.Duration(r.ExpiresIn) * time.Second)
+	}
+	return r.ExpiresAt
 }
 
 // APIError represents an error from the API.
@@ -45,20 +97,137 @@ func NewClient(baseURL string, timeout time.Duration) *Client {
 	}
 }
 
+// SetAPIKey sends key in the X-API-Key header of every write, for servers
+// that require one with API_KEYS.
+func (c *Client) SetAPIKey(key string) {
+	c.apiKey = key
+}
+
+// newWriteRequest builds a request that changes a snippet, carrying the API
+// key when one is set.
+func (c *Client) newWriteRequest(method, target string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	return req, nil
+}
+
+// CreateOptions are the optional settings for a new snippet.
+type CreateOptions struct {
+	Expiry string // e.g. 10m, 3d; empty for the server default
+	Burn   bool   // delete the snippet after its first view
+	ID     string // custom snippet ID; empty for a generated one
+
+	// ExpireAt asks for the snippet to expire at this time instead of
+	// after Expiry. The server rejects requests that set both.
+	ExpireAt time.Time
+
+	// Appendable asks for an append token so content can be added later
+	// with Append.
+	Appendable bool
+
+	// ContentType is sent instead of text/plain when set. It is ignored
+	// with Encrypt, since the server then only sees ciphertext.
+	ContentType string
+
+	// Encrypt seals the content with a fresh key before upload. The key is
+	// appended to the returned URL as a fragment and never sent.
+	Encrypt bool
+
+	// Passphrase encrypts with a key derived from it instead. Only the salt
+	// and KDF parameters (KDF, or DefaultKDFParams when nil) go in the
+	// fragment; the passphrase is shared separately.
+	Passphrase string
+	KDF        *KDFParams
+}
+
+// sealContent encrypts content as requested by opts and returns the
+// ciphertext with the URL fragment the reader needs.
+func sealContent(content []byte, opts CreateOptions) ([]byte, string, error) {
+	var (
+		key      []byte
+		fragment string
+		err      error
+	)
+	if opts.Passphrase != "" {
+		params := DefaultKDFParams
+		if opts.KDF != nil {
+			params = *opts.KDF
+		}
+		salt, err := NewSalt()
+		if err != nil {
+			return nil, "", err
+		}
+		if key, err = DeriveKey(opts.Passphrase, salt, params); err != nil {
+			return nil, "", err
+		}
+		fragment = EncodePassphraseFragment(salt, params)
+	} else {
+		if key, err = NewKey(); err != nil {
+			return nil, "", err
+		}
+		fragment = EncodeKey(key)
+	}
+
+	sealed, err := Encrypt(key, content)
+	if err != nil {
+		return nil, "", fmt.Errorf("encrypting content: %w", err)
+	}
+	return sealed, fragment, nil
+}
+
 // Create uploads content and returns the snippet URL.
-func (c *Client) Create(content []byte, expiry string) (*CreateResponse, error) {
-	// Build URL with optional expiry query parameter
+func (c *Client) Create(content []byte, opts CreateOptions) (*CreateResponse, error) {
+	encrypted := opts.Encrypt || opts.Passphrase != ""
+	var fragment string
+	if encrypted {
+		var err error
+		if content, fragment, err = sealContent(content, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	// Build URL with optional query parameters
+	query := url.Values{}
+	if opts.Expiry != "" {
+		query.Set("expiry", opts.Expiry)
+	}
+	if !opts.ExpireAt.IsZero() {
+		query.Set("expire_at", opts.ExpireAt.Format(time.RFC3339))
+	}
+	if opts.Burn {
+		query.Set("burn", "true")
+	}
+	if opts.ID != "" {
+		query.Set("id", opts.ID)
+	}
+	if opts.Appendable {
+		query.Set("appendable", "true")
+	}
 	apiURL := c.baseURL
-	if expiry != "" {
-		apiURL = fmt.Sprintf("%s?expiry=%s", c.baseURL, url.QueryEscape(expiry))
+	if len(query) > 0 {
+		apiURL += "?" + query.Encode()
 	}
 
-	req, err := http.NewRequest(http.MethodPost, apiURL, bytes.NewReader(content))
+	req, err := c.newWriteRequest(http.MethodPost, apiURL, bytes.NewReader(content))
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "text/plain")
+	contentType := "text/plain"
+	if opts.ContentType != "" && !encrypted {
+		contentType = opts.ContentType
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	// Let the server reject oversized uploads before we stream them
+	if len(content) > expectContinueThreshold {
+		req.Header.Set("Expect", "100-continue")
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -71,6 +240,9 @@ func (c *Client) Create(content []byte, expiry string) (*CreateResponse, error)
 		return nil, fmt.Errorf("reading response: %w", err)
 	}
 
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, rateLimitError(resp)
+	}
 	if resp.StatusCode != http.StatusCreated {
 		var errResp ErrorResponse
 		if json.Unmarshal(body, &errResp) == nil && errResp.Error.Message != "" {
@@ -83,6 +255,11 @@ func (c *Client) Create(content []byte, expiry string) (*CreateResponse, error)
 	if err := json.Unmarshal(body, &result); err != nil {
 		return nil, fmt.Errorf("parsing response: %w", err)
 	}
+	result.receivedAt = time.Now()
+
+	if fragment != "" {
+		result.URL += "#" + fragment
+	}
 
 	return &result, nil
 }
@@ -105,6 +282,9 @@ func (c *Client) Get(id string) ([]byte, error) {
 	if resp.StatusCode == http.StatusNotFound {
 		return nil, fmt.Errorf("snippet not found or expired")
 	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, rateLimitError(resp)
+	}
 
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
@@ -112,3 +292,123 @@ func (c *Client) Get(id string) ([]byte, error) {
 
 	return body, nil
 }
+
+// Append adds content to an appendable snippet using the append token
+// returned at creation.
+func (c *Client) Append(id, token string, content []byte) error {
+	req, err := c.newWriteRequest(http.MethodPost, fmt.Sprintf("%s/%s/append", c.baseURL, id), bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("X-Append-Token", token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusTooManyRequests:
+		return rateLimitError(resp)
+	case http.StatusNotFound:
+		return fmt.Errorf("snippet not found or expired")
+	case http.StatusUnauthorized:
+		return fmt.Errorf("append token rejected")
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	var errResp ErrorResponse
+	if json.Unmarshal(body, &errResp) == nil && errResp.Error.Message != "" {
+		return fmt.Errorf("API error (%s): %s", errResp.Error.Code, errResp.Error.Message)
+	}
+	return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+}
+
+// Delete removes a snippet using the delete token returned at creation.
+func (c *Client) Delete(id, token string) error {
+	req, err := c.newWriteRequest(http.MethodDelete, fmt.Sprintf("%s/%s", c.baseURL, id), nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("X-Delete-Token", token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("snippet not found or expired")
+	case http.StatusUnauthorized:
+		return fmt.Errorf("delete token rejected")
+	case http.StatusTooManyRequests:
+		return rateLimitError(resp)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	var errResp ErrorResponse
+	if json.Unmarshal(body, &errResp) == nil && errResp.Error.Message != "" {
+		return fmt.Errorf("API error (%s): %s", errResp.Error.Code, errResp.Error.Message)
+	}
+	return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+}
+
+// ExtendResponse is the response to Extend.
+type ExtendResponse struct {
+	ID        string    `json:"id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Extend gives a snippet a new expiry, counted from now, using the delete
+// token returned at creation.
+func (c *Client) Extend(id, token, expiry string) (*ExtendResponse, error) {
+	query := url.Values{"expiry": {expiry}}
+	req, err := c.newWriteRequest(http.MethodPatch, fmt.Sprintf("%s/%s?%s", c.baseURL, id, query.Encode()), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("X-Delete-Token", token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var result ExtendResponse
+		if err := json.Unmarshal(body, &result); err != nil {
+			return nil, fmt.Errorf("parsing response: %w", err)
+		}
+		return &result, nil
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("snippet not found or expired")
+	case http.StatusUnauthorized:
+		return nil, fmt.Errorf("delete token rejected")
+	case http.StatusTooManyRequests:
+		return nil, rateLimitError(resp)
+	}
+
+	var errResp ErrorResponse
+	if json.Unmarshal(body, &errResp) == nil && errResp.Error.Message != "" {
+		return nil, fmt.Errorf("API error (%s): %s", errResp.Error.Code, errResp.Error.Message)
+	}
+	return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+}
+
+// SignURL returns a link to snippetID that the server accepts until exp.
+// secret must match the server's URL_SIGNING_KEY.
+func SignURL(baseURL, snippetID, secret string, exp time.Time) string {
+	query := urlsign.Query([]byte(secret), snippetID, exp)
+	return fmt.Sprintf("%s/%s?%s", strings.TrimSuffix(baseURL, "/"), snippetID, query.Encode())
+}
@@ -0,0 +1,374 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/api"
+	"github.com/rayenfassatoui/tafcha-cli/internal/config"
+	"github.com/rayenfassatoui/tafcha-cli/internal/storage"
+	"github.com/rayenfassatoui/tafcha-cli/internal/urlsign"
+)
+
+func TestClient_Create(t *testing.T) {
+	var gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{
+			"id": "abc123XYZ789",
+			"url": "https://tafcha.dev/abc123XYZ789",
+			"short_code": "abc123XYZ789",
+			"raw_url": "https://tafcha.dev/abc123XYZ789?raw",
+			"expires_at": "2026-01-31T22:39:46Z"
+		}`))
+	}))
+	defer srv.Close()
+
+	resp, err := NewClient(srv.URL, 5*time.Second).Create([]byte("hello"), CreateOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, "hello", gotBody)
+	assert.Equal(t, "abc123XYZ789", resp.ShortCode)
+	assert.Equal(t, resp.ID, resp.ShortCode)
+	assert.Equal(t, "https://tafcha.dev/"+resp.ShortCode, resp.URL)
+	assert.Equal(t, resp.URL+"?raw", resp.RawURL)
+}
+
+func TestClient_Create_ExpiresIn(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		// The server's clock is far behind ours
+		w.Write([]byte(`{"id":"abc123XYZ789","expires_at":"2001-01-01T00:00:00Z","expires_in":3600}`))
+	}))
+	defer srv.Close()
+
+	resp, err := NewClient(srv.URL, 5*time.Second).Create([]byte("hello"), CreateOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(3600), resp.ExpiresIn)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), resp.LocalExpiry(), 5*time.Second)
+
+	// Without expires_in, as from older servers, expires_at is used
+	resp.ExpiresIn = 0
+	assert.Equal(t, time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC), resp.LocalExpiry())
+}
+
+func TestClient_Create_APIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"code":"INVALID_EXPIRY","message":"bad expiry"}}`))
+	}))
+	defer srv.Close()
+
+	_, err := NewClient(srv.URL, 5*time.Second).Create([]byte("hello"), CreateOptions{Expiry: "nope"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "INVALID_EXPIRY")
+	assert.Contains(t, err.Error(), "bad expiry")
+}
+
+func TestClient_Create_RateLimited(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "42")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":{"code":"RATE_LIMITED","message":"rate limit exceeded, please try again later"}}`))
+	}))
+	defer srv.Close()
+
+	_, err := NewClient(srv.URL, 5*time.Second).Create([]byte("hello"), CreateOptions{})
+	require.ErrorIs(t, err, ErrRateLimited)
+	assert.Contains(t, err.Error(), "retry in 42s")
+}
+
+// The real server's rate limiter answers in the shape the client expects,
+// so users see when to retry rather than a raw status.
+func TestClient_Create_RateLimitedByServer(t *testing.T) {
+	cfg := &config.Config{
+		BaseURL:         "http://tafcha.test",
+		MaxContentSize:  1024,
+		DefaultExpiry:   time.Hour,
+		MinExpiry:       time.Minute,
+		MaxExpiry:       24 * time.Hour,
+		PostRateLimit:   3,
+		GetRateLimit:    100,
+		ContentHashAlgo: "sha256",
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	srv := httptest.NewServer(api.NewServer(cfg, storage.NewMemoryRepository(logger), logger).Handler())
+	defer srv.Close()
+
+	client := NewClient(srv.URL, 5*time.Second)
+	for i := 0; i < cfg.PostRateLimit; i++ {
+		_, err := client.Create([]byte("hello"), CreateOptions{})
+		require.NoError(t, err, "request %d", i+1)
+	}
+
+	_, err := client.Create([]byte("hello"), CreateOptions{})
+	require.ErrorIs(t, err, ErrRateLimited)
+	assert.Contains(t, err.Error(), "retry in")
+	assert.NotContains(t, err.Error(), "unexpected status")
+}
+
+func TestClient_Create_APIKey(t *testing.T) {
+	cfg := &config.Config{
+		BaseURL:         "http://tafcha.test",
+		MaxContentSize:  1024,
+		DefaultExpiry:   time.Hour,
+		MinExpiry:       time.Minute,
+		MaxExpiry:       24 * time.Hour,
+		PostRateLimit:   100,
+		GetRateLimit:    100,
+		ContentHashAlgo: "sha256",
+		APIKeys:         []string{"secret-key"},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	srv := httptest.NewServer(api.NewServer(cfg, storage.NewMemoryRepository(logger), logger).Handler())
+	defer srv.Close()
+
+	client := NewClient(srv.URL, 5*time.Second)
+	_, err := client.Create([]byte("hello"), CreateOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "API key")
+
+	client.SetAPIKey("secret-key")
+	resp, err := client.Create([]byte("hello"), CreateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, client.Delete(resp.ID, resp.DeleteToken))
+}
+
+func TestClient_Create_ExpectContinue(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		expect string
+	}{
+		{"small upload", 1024, ""},
+		{"large upload", expectContinueThreshold + 1, "100-continue"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotExpect string
+			var gotSize int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotExpect = r.Header.Get("Expect")
+				body, _ := io.ReadAll(r.Body)
+				gotSize = len(body)
+
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"id":"abc123XYZ789","url":"https://tafcha.dev/abc123XYZ789"}`))
+			}))
+			defer srv.Close()
+
+			_, err := NewClient(srv.URL, 5*time.Second).Create(bytes.Repeat([]byte("a"), tt.size), CreateOptions{})
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expect, gotExpect)
+			assert.Equal(t, tt.size, gotSize)
+		})
+	}
+}
+
+func TestClient_Create_ExpectContinueRejected(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Reject from the headers alone, without reading the body
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		w.Write([]byte(`{"error":{"code":"PAYLOAD_TOO_LARGE","message":"content exceeds maximum size"}}`))
+	}))
+	defer srv.Close()
+
+	_, err := NewClient(srv.URL, 5*time.Second).Create(bytes.Repeat([]byte("a"), 2<<20), CreateOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "PAYLOAD_TOO_LARGE")
+}
+
+func TestClient_Delete(t *testing.T) {
+	var gotMethod, gotPath, gotToken string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath, gotToken = r.Method, r.URL.Path, r.Header.Get("X-Delete-Token")
+		switch r.Header.Get("X-Delete-Token") {
+		case "good":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, 5*time.Second)
+
+	require.NoError(t, client.Delete("abc123XYZ789", "good"))
+	assert.Equal(t, http.MethodDelete, gotMethod)
+	assert.Equal(t, "/abc123XYZ789", gotPath)
+	assert.Equal(t, "good", gotToken)
+
+	err := client.Delete("abc123XYZ789", "bad")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "token rejected")
+}
+
+func TestClient_Extend(t *testing.T) {
+	var gotMethod, gotPath, gotExpiry string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath, gotExpiry = r.Method, r.URL.Path, r.URL.Query().Get("expiry")
+		if r.Header.Get("X-Delete-Token") != "good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"id":"abc123XYZ789","expires_at":"2025-06-01T00:00:00Z","expires_in":604800}`))
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, 5*time.Second)
+
+	resp, err := client.Extend("abc123XYZ789", "good", "7d")
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodPatch, gotMethod)
+	assert.Equal(t, "/abc123XYZ789", gotPath)
+	assert.Equal(t, "7d", gotExpiry)
+	assert.Equal(t, time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), resp.ExpiresAt)
+
+	_, err = client.Extend("abc123XYZ789", "bad", "7d")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "token rejected")
+}
+
+func TestClient_Create_Options(t *testing.T) {
+	var gotQuery url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query()
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"abc123XYZ789","url":"https://tafcha.dev/abc123XYZ789","remaining_views":1}`))
+	}))
+	defer srv.Close()
+
+	resp, err := NewClient(srv.URL, 5*time.Second).Create([]byte("secret"), CreateOptions{Expiry: "1h", Burn: true, ID: "release-notes"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "1h", gotQuery.Get("expiry"))
+	assert.Equal(t, "true", gotQuery.Get("burn"))
+	assert.Equal(t, "release-notes", gotQuery.Get("id"))
+	require.NotNil(t, resp.RemainingViews)
+	assert.Equal(t, 1, *resp.RemainingViews)
+}
+
+func TestClient_Create_ExpireAt(t *testing.T) {
+	var gotQuery url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query()
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"abc123XYZ789","url":"https://tafcha.dev/abc123XYZ789"}`))
+	}))
+	defer srv.Close()
+
+	at := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	_, err := NewClient(srv.URL, 5*time.Second).Create([]byte("x"), CreateOptions{ExpireAt: at})
+	require.NoError(t, err)
+
+	assert.Equal(t, "2025-06-01T00:00:00Z", gotQuery.Get("expire_at"))
+	assert.False(t, gotQuery.Has("expiry"))
+}
+
+func TestClient_Create_Encrypt(t *testing.T) {
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"abc123XYZ789","url":"https://tafcha.dev/abc123XYZ789"}`))
+	}))
+	defer srv.Close()
+
+	resp, err := NewClient(srv.URL, 5*time.Second).Create([]byte("plaintext"), CreateOptions{Encrypt: true})
+	require.NoError(t, err)
+
+	assert.NotContains(t, string(gotBody), "plaintext")
+
+	base, fragment, found := strings.Cut(resp.URL, "#")
+	require.True(t, found)
+	assert.Equal(t, "https://tafcha.dev/abc123XYZ789", base)
+
+	key, err := DecodeKey(fragment)
+	require.NoError(t, err)
+	got, err := Decrypt(key, gotBody)
+	require.NoError(t, err)
+	assert.Equal(t, "plaintext", string(got))
+}
+
+func TestClient_Create_Passphrase(t *testing.T) {
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"abc123XYZ789","url":"https://tafcha.dev/abc123XYZ789"}`))
+	}))
+	defer srv.Close()
+
+	resp, err := NewClient(srv.URL, 5*time.Second).Create([]byte("plaintext"),
+		CreateOptions{Passphrase: "hunter2", KDF: &testKDF})
+	require.NoError(t, err)
+
+	_, fragment, _ := strings.Cut(resp.URL, "#")
+	assert.NotContains(t, fragment, "hunter2")
+
+	frag, err := ParseKeyFragment(fragment)
+	require.NoError(t, err)
+	key, err := frag.ResolveKey("hunter2")
+	require.NoError(t, err)
+	got, err := Decrypt(key, gotBody)
+	require.NoError(t, err)
+	assert.Equal(t, "plaintext", string(got))
+}
+
+func TestClient_Create_ContentType(t *testing.T) {
+	var gotType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotType = r.Header.Get("Content-Type")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"abc123XYZ789","url":"https://tafcha.dev/abc123XYZ789"}`))
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, 5*time.Second)
+	content := []byte(`{"ok":true}`)
+
+	_, err := client.Create(content, CreateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", gotType)
+
+	_, err = client.Create(content, CreateOptions{ContentType: DetectContentType(content)})
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", gotType)
+
+	_, err = client.Create(content, CreateOptions{ContentType: "application/json", Encrypt: true})
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", gotType, "ciphertext is always text/plain")
+}
+
+func TestSignURL(t *testing.T) {
+	exp := time.Now().Add(5 * time.Minute)
+
+	signed := SignURL("https://tafcha.dev/", "abc123XYZ789", "shared", exp)
+
+	u, err := url.Parse(signed)
+	require.NoError(t, err)
+	assert.Equal(t, "/abc123XYZ789", u.Path)
+	assert.Equal(t, strconv.FormatInt(exp.Unix(), 10), u.Query().Get("exp"))
+	assert.NoError(t, urlsign.Verify([]byte("shared"), "abc123XYZ789", u.Query(), time.Now()))
+}
@@ -0,0 +1,208 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// KeySize is the length of an encryption key in bytes (AES-256).
+const KeySize = 32
+
+// ErrDecrypt is returned when a payload cannot be decrypted with the key.
+var ErrDecrypt = errors.New("decryption failed: wrong key or corrupted snippet")
+
+// NewKey returns a random AES-256 key.
+func NewKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return key, nil
+}
+
+// EncodeKey formats a key for use as a URL fragment.
+func EncodeKey(key []byte) string {
+	return base64.RawURLEncoding.EncodeToString(key)
+}
+
+// DecodeKey parses a key produced by EncodeKey.
+func DecodeKey(s string) ([]byte, error) {
+	key, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(key) != KeySize {
+		return nil, errors.New("invalid encryption key in URL fragment")
+	}
+	return key, nil
+}
+
+// Encrypt seals plaintext with AES-256-GCM. The result is the random nonce
+// followed by the ciphertext, base64-encoded so it can be stored as text.
+func Encrypt(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := gcm.Seal(nonce, nonce, plaintext, nil)
+	out := make([]byte, base64.StdEncoding.EncodedLen(len(sealed)))
+	base64.StdEncoding.Encode(out, sealed)
+	return out, nil
+}
+
+// Decrypt reverses Encrypt.
+func Decrypt(key, payload []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := make([]byte, base64.StdEncoding.DecodedLen(len(payload)))
+	n, err := base64.StdEncoding.Decode(sealed, bytes.TrimSpace(payload))
+	if err != nil || n < gcm.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	sealed = sealed[:n]
+
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// KDFParams are the Argon2id cost parameters used to derive a key from a
+// passphrase. They travel in the URL fragment with the salt so the reader
+// derives the same key.
+type KDFParams struct {
+	Time    uint32 // passes over memory
+	Memory  uint32 // KiB
+	Threads uint8
+}
+
+// DefaultKDFParams follow the RFC 9106 second recommended option.
+var DefaultKDFParams = KDFParams{Time: 3, Memory: 64 * 1024, Threads: 4}
+
+// SaltSize is the length of a passphrase salt in bytes.
+const SaltSize = 16
+
+// passphrasePrefix marks a fragment holding KDF parameters and a salt
+// rather than a raw key.
+const passphrasePrefix = "pw"
+
+// Upper bounds for KDF parameters read from a fragment, so a crafted link
+// cannot make the reader burn unbounded CPU or memory.
+const (
+	maxKDFTime    = 16
+	maxKDFMemory  = 1 << 20 // 1 GiB in KiB
+	maxKDFThreads = 16
+)
+
+func (p KDFParams) validate() error {
+	if p.Time < 1 || p.Time > maxKDFTime ||
+		p.Memory < 8*uint32(p.Threads) || p.Memory > maxKDFMemory ||
+		p.Threads < 1 || p.Threads > maxKDFThreads {
+		return fmt.Errorf("KDF parameters out of range: time=%d memory=%dKiB threads=%d", p.Time, p.Memory, p.Threads)
+	}
+	return nil
+}
+
+// NewSalt returns a random salt for DeriveKey.
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return salt, nil
+}
+
+// DeriveKey derives an AES-256 key from passphrase with Argon2id.
+func DeriveKey(passphrase string, salt []byte, p KDFParams) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errors.New("passphrase must not be empty")
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return argon2.IDKey([]byte(passphrase), salt, p.Time, p.Memory, p.Threads, KeySize), nil
+}
+
+// EncodePassphraseFragment formats the salt and KDF parameters for the URL
+// fragment as pw.<time>.<memory>.<threads>.<salt>.
+func EncodePassphraseFragment(salt []byte, p KDFParams) string {
+	return fmt.Sprintf("%s.%d.%d.%d.%s", passphrasePrefix, p.Time, p.Memory, p.Threads,
+		base64.RawURLEncoding.EncodeToString(salt))
+}
+
+// KeyFragment is a parsed URL fragment: either a raw key or the salt and
+// parameters to derive one from a passphrase.
+type KeyFragment struct {
+	Key  []byte
+	Salt []byte
+	KDF  KDFParams
+}
+
+// NeedsPassphrase reports whether the key must be derived from a passphrase.
+func (f KeyFragment) NeedsPassphrase() bool {
+	return f.Key == nil
+}
+
+// ResolveKey returns the fragment's key, deriving it from passphrase when
+// the fragment holds a salt.
+func (f KeyFragment) ResolveKey(passphrase string) ([]byte, error) {
+	if !f.NeedsPassphrase() {
+		return f.Key, nil
+	}
+	return DeriveKey(passphrase, f.Salt, f.KDF)
+}
+
+// ParseKeyFragment parses a fragment written by EncodeKey or
+// EncodePassphraseFragment.
+func ParseKeyFragment(fragment string) (KeyFragment, error) {
+	if !strings.HasPrefix(fragment, passphrasePrefix+".") {
+		key, err := DecodeKey(fragment)
+		return KeyFragment{Key: key}, err
+	}
+
+	invalid := errors.New("invalid passphrase parameters in URL fragment")
+	parts := strings.Split(fragment, ".")
+	if len(parts) != 5 {
+		return KeyFragment{}, invalid
+	}
+	t, errT := strconv.ParseUint(parts[1], 10, 32)
+	m, errM := strconv.ParseUint(parts[2], 10, 32)
+	th, errTh := strconv.ParseUint(parts[3], 10, 8)
+	salt, errS := base64.RawURLEncoding.DecodeString(parts[4])
+	if errT != nil || errM != nil || errTh != nil || errS != nil || len(salt) < 8 {
+		return KeyFragment{}, invalid
+	}
+
+	params := KDFParams{Time: uint32(t), Memory: uint32(m), Threads: uint8(th)}
+	if err := params.validate(); err != nil {
+		return KeyFragment{}, err
+	}
+	return KeyFragment{Salt: salt, KDF: params}, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes", KeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptDecrypt_RoundTrip(t *testing.T) {
+	key, err := NewKey()
+	require.NoError(t, err)
+
+	for _, plaintext := range []string{"", "hello world\n", "multibyte: ééé 日本語"} {
+		payload, err := Encrypt(key, []byte(plaintext))
+		require.NoError(t, err)
+
+		got, err := Decrypt(key, payload)
+		require.NoError(t, err)
+		assert.Equal(t, plaintext, string(got))
+	}
+}
+
+func TestEncrypt_FreshNonce(t *testing.T) {
+	key, err := NewKey()
+	require.NoError(t, err)
+
+	a, err := Encrypt(key, []byte("same"))
+	require.NoError(t, err)
+	b, err := Encrypt(key, []byte("same"))
+	require.NoError(t, err)
+
+	assert.NotEqual(t, a, b)
+}
+
+func TestDecrypt_Failures(t *testing.T) {
+	key, err := NewKey()
+	require.NoError(t, err)
+	other, err := NewKey()
+	require.NoError(t, err)
+
+	payload, err := Encrypt(key, []byte("secret"))
+	require.NoError(t, err)
+
+	_, err = Decrypt(other, payload)
+	assert.ErrorIs(t, err, ErrDecrypt)
+
+	tampered := append([]byte{}, payload...)
+	tampered[len(tampered)/2] ^= 'A' ^ 'B'
+	_, err = Decrypt(key, tampered)
+	assert.ErrorIs(t, err, ErrDecrypt)
+
+	_, err = Decrypt(key, []byte("not base64!"))
+	assert.ErrorIs(t, err, ErrDecrypt)
+}
+
+func TestEncodeDecodeKey(t *testing.T) {
+	key, err := NewKey()
+	require.NoError(t, err)
+
+	decoded, err := DecodeKey(EncodeKey(key))
+	require.NoError(t, err)
+	assert.Equal(t, key, decoded)
+
+	_, err = DecodeKey("short")
+	assert.Error(t, err)
+}
+
+// testKDF keeps the Argon2id cost low so tests stay fast.
+var testKDF = KDFParams{Time: 1, Memory: 64, Threads: 1}
+
+func TestDeriveKey(t *testing.T) {
+	salt, err := NewSalt()
+	require.NoError(t, err)
+
+	a, err := DeriveKey("correct horse battery staple", salt, testKDF)
+	require.NoError(t, err)
+	b, err := DeriveKey("correct horse battery staple", salt, testKDF)
+	require.NoError(t, err)
+	assert.Equal(t, a, b, "same passphrase and salt give the same key")
+	assert.Len(t, a, KeySize)
+
+	otherSalt, err := NewSalt()
+	require.NoError(t, err)
+	c, err := DeriveKey("correct horse battery staple", otherSalt, testKDF)
+	require.NoError(t, err)
+	assert.NotEqual(t, a, c)
+
+	_, err = DeriveKey("", salt, testKDF)
+	assert.Error(t, err)
+	_, err = DeriveKey("x", salt, KDFParams{Time: 100, Memory: 64, Threads: 1})
+	assert.Error(t, err)
+}
+
+func TestPassphraseFragment_RoundTrip(t *testing.T) {
+	salt, err := NewSalt()
+	require.NoError(t, err)
+	key, err := DeriveKey("hunter2", salt, testKDF)
+	require.NoError(t, err)
+	payload, err := Encrypt(key, []byte("shared secret"))
+	require.NoError(t, err)
+
+	frag, err := ParseKeyFragment(EncodePassphraseFragment(salt, testKDF))
+	require.NoError(t, err)
+	require.True(t, frag.NeedsPassphrase())
+	assert.Equal(t, testKDF, frag.KDF)
+
+	resolved, err := frag.ResolveKey("hunter2")
+	require.NoError(t, err)
+	got, err := Decrypt(resolved, payload)
+	require.NoError(t, err)
+	assert.Equal(t, "shared secret", string(got))
+
+	wrong, err := frag.ResolveKey("hunter3")
+	require.NoError(t, err)
+	_, err = Decrypt(wrong, payload)
+	assert.ErrorIs(t, err, ErrDecrypt)
+}
+
+func TestParseKeyFragment(t *testing.T) {
+	key, err := NewKey()
+	require.NoError(t, err)
+
+	frag, err := ParseKeyFragment(EncodeKey(key))
+	require.NoError(t, err)
+	assert.False(t, frag.NeedsPassphrase())
+	assert.Equal(t, key, frag.Key)
+
+	for _, bad := range []string{
+		"pw.1.64.1",
+		"pw.x.64.1.AAAAAAAAAAAAAAAAAAAAAA",
+		"pw.1.64.1.!!",
+		"pw.99.64.1.AAAAAAAAAAAAAAAAAAAAAA",
+		"pw.1.99999999.1.AAAAAAAAAAAAAAAAAAAAAA",
+	} {
+		_, err := ParseKeyFragment(bad)
+		assert.Error(t, err, bad)
+	}
+}
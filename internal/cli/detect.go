@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// DetectContentType guesses the media type of content for upload. Valid
+// JSON objects and arrays are application/json and text that looks like
+// Markdown is text/markdown; anything else falls back to
+// http.DetectContentType.
+func DetectContentType(content []byte) string {
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		return "application/json"
+	}
+
+	detected := http.DetectContentType(content)
+	if strings.HasPrefix(detected, "text/plain") && looksLikeMarkdown(content) {
+		return "text/markdown; charset=utf-8"
+	}
+	return detected
+}
+
+// looksLikeMarkdown reports whether any line starts with an ATX heading or
+// a fenced code block, which are rare in other plain text.
+func looksLikeMarkdown(content []byte) bool {
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		line = bytes.TrimRight(line, "\r")
+		if bytes.HasPrefix(line, []byte("```")) {
+			return true
+		}
+		if level := len(line) - len(bytes.TrimLeft(line, "#")); level >= 1 && level <= 6 &&
+			len(line) > level && line[level] == ' ' {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectContentType(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "json object", content: `{"level":"error","msg":"db down"}` + "\n", want: "application/json"},
+		{name: "json array", content: "  [1, 2, 3]", want: "application/json"},
+		{name: "invalid json", content: `{"unterminated": `, want: "text/plain; charset=utf-8"},
+		{name: "markdown heading", content: "# Notes\n\nSome text\n", want: "text/markdown; charset=utf-8"},
+		{name: "markdown fence", content: "run this:\r\n```sh\r\nmake\r\n```\r\n", want: "text/markdown; charset=utf-8"},
+		{name: "shell comment is not a heading", content: "#!/bin/sh\n#comment\necho hi\n", want: "text/plain; charset=utf-8"},
+		{name: "plain text", content: "hello world\n", want: "text/plain; charset=utf-8"},
+		{name: "png", content: "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", want: "image/png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, DetectContentType([]byte(tt.content)))
+		})
+	}
+}
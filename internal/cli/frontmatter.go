@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/expiry"
+)
+
+// Frontmatter holds the directives read from the top of a file.
+type Frontmatter struct {
+	Expiry string // e.g. 1d; checked with the expiry parser
+	Title  string // kept locally in the upload history
+}
+
+// frontmatterPrefix starts every directive line, e.g. "# tafcha-expiry: 1d".
+const frontmatterPrefix = "tafcha-"
+
+// ParseFrontmatter reads directives from the start of content and returns
+// them with the remaining content.
+//
+// The rules are:
+//   - Directives are lines "# tafcha-<key>: <value>" at the very top of the
+//     content. The block ends at the first line that is not a directive, so
+//     a directive further down is left alone.
+//   - Recognized keys are expiry and title. An unknown tafcha- key, a
+//     repeated key, an empty value or an invalid expiry is an error.
+//   - Directive lines are stripped, together with one blank line directly
+//     after the block. Everything else is kept byte for byte.
+//
+// Content without directives is returned unchanged with an empty
+// Frontmatter.
+func ParseFrontmatter(content []byte) (Frontmatter, []byte, error) {
+	var fm Frontmatter
+	seen := make(map[string]bool)
+
+	rest := content
+	for len(rest) > 0 {
+		line, next := cutLine(rest)
+		key, value, ok := parseDirective(line)
+		if !ok {
+			break
+		}
+
+		if seen[key] {
+			return Frontmatter{}, nil, fmt.Errorf("frontmatter: %s%s is set twice", frontmatterPrefix, key)
+		}
+		seen[key] = true
+		if value == "" {
+			return Frontmatter{}, nil, fmt.Errorf("frontmatter: %s%s has no value", frontmatterPrefix, key)
+		}
+
+		switch key {
+		case "expiry":
+			if _, err := expiry.Parse(value); err != nil {
+				return Frontmatter{}, nil, fmt.Errorf("frontmatter: %s%s: %w", frontmatterPrefix, key, err)
+			}
+			fm.Expiry = value
+		case "title":
+			fm.Title = value
+		default:
+			return Frontmatter{}, nil, fmt.Errorf("frontmatter: unknown directive %s%s (expected expiry or title)", frontmatterPrefix, key)
+		}
+		rest = next
+	}
+
+	if len(seen) == 0 {
+		return fm, content, nil
+	}
+	if line, next := cutLine(rest); len(rest) > 0 && len(bytes.TrimSpace(line)) == 0 {
+		rest = next
+	}
+	return fm, rest, nil
+}
+
+// parseDirective splits a "# tafcha-<key>: <value>" line. ok is false for
+// any other line.
+func parseDirective(line []byte) (key, value string, ok bool) {
+	text := strings.TrimSuffix(string(line), "\r")
+	text, ok = strings.CutPrefix(text, "#")
+	if !ok {
+		return "", "", false
+	}
+	text, ok = strings.CutPrefix(strings.TrimLeft(text, " \t"), frontmatterPrefix)
+	if !ok {
+		return "", "", false
+	}
+	key, value, ok = strings.Cut(text, ":")
+	if !ok || key == "" || strings.ContainsAny(key, " \t") {
+		return "", "", false
+	}
+	return strings.ToLower(key), strings.TrimSpace(value), true
+}
+
+// cutLine splits off the first line of b, without its "\n".
+func cutLine(b []byte) (line, rest []byte) {
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		return b[:i], b[i+1:]
+	}
+	return b, nil
+}
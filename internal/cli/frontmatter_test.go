@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFrontmatter(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    Frontmatter
+		rest    string
+	}{
+		{
+			name:    "expiry and title",
+			content: "# tafcha-expiry: 1d\n# tafcha-title: Deploy notes\n\nstep one\n",
+			want:    Frontmatter{Expiry: "1d", Title: "Deploy notes"},
+			rest:    "step one\n",
+		},
+		{
+			name:    "only one blank line is stripped",
+			content: "# tafcha-expiry: 1h\n\n\nbody",
+			want:    Frontmatter{Expiry: "1h"},
+			rest:    "\nbody",
+		},
+		{
+			name:    "CRLF and loose spacing",
+			content: "#tafcha-Title:   Report  \r\nbody\r\n",
+			want:    Frontmatter{Title: "Report"},
+			rest:    "body\r\n",
+		},
+		{
+			name:    "content is only frontmatter",
+			content: "# tafcha-expiry: 10m",
+			want:    Frontmatter{Expiry: "10m"},
+			rest:    "",
+		},
+		{
+			name:    "no frontmatter",
+			content: "# Heading\n# tafcha-expiry: 1d\n",
+			rest:    "# Heading\n# tafcha-expiry: 1d\n",
+		},
+		{
+			name:    "shebang stops the block",
+			content: "#!/bin/sh\necho hi\n",
+			rest:    "#!/bin/sh\necho hi\n",
+		},
+		{
+			name:    "empty content",
+			content: "",
+			rest:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fm, rest, err := ParseFrontmatter([]byte(tt.content))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, fm)
+			assert.Equal(t, tt.rest, string(rest))
+		})
+	}
+}
+
+func TestParseFrontmatter_Malformed(t *testing.T) {
+	tests := map[string]string{
+		"unknown key":    "# tafcha-expires: 1d\nbody",
+		"invalid expiry": "# tafcha-expiry: tomorrow\nbody",
+		"empty value":    "# tafcha-title:\nbody",
+		"repeated key":   "# tafcha-expiry: 1d\n# tafcha-expiry: 2d\nbody",
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := ParseFrontmatter([]byte(content))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "frontmatter")
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// HistoryEntry records one snippet created from this machine.
+type HistoryEntry struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Title     string    `json:"title,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+	SizeBytes int       `json:"size_bytes"`
+}
+
+// HistoryPath returns the history file location,
+// $XDG_DATA_HOME/tafcha/history.jsonl or ~/.local/share/tafcha/history.jsonl.
+func HistoryPath(getenv func(string) string) (string, error) {
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+
+	dataHome := getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home := getenv("HOME")
+		if home == "" {
+			return "", errors.New("cannot locate history file: neither XDG_DATA_HOME nor HOME is set")
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dataHome, "tafcha", "history.jsonl"), nil
+}
+
+// AppendHistory adds entry as one JSON line at the end of the file at path,
+// creating it and its directory if needed. The file is private to the user
+// since URLs of encrypted snippets carry their key.
+func AppendHistory(path string, entry HistoryEntry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadHistory returns the entries in the file at path, oldest first. A
+// missing file is an empty history. Lines that cannot be parsed are
+// skipped and counted in skipped so callers can warn about them.
+func ReadHistory(path string) (entries []HistoryEntry, skipped int, err error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var entry HistoryEntry
+		if err := json.Unmarshal(line, &entry); err != nil || entry.URL == "" {
+			skipped++
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return entries, skipped, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return entries, skipped, nil
+}
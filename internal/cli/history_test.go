@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryPath(t *testing.T) {
+	path, err := HistoryPath(envMap(map[string]string{"XDG_DATA_HOME": "/data", "HOME": "/home/me"}))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("/data", "tafcha", "history.jsonl"), path)
+
+	path, err = HistoryPath(envMap(map[string]string{"HOME": "/home/me"}))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("/home/me", ".local", "share", "tafcha", "history.jsonl"), path)
+
+	_, err = HistoryPath(envMap(nil))
+	assert.Error(t, err)
+}
+
+func TestHistory_AppendAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "history.jsonl")
+
+	entries, skipped, err := ReadHistory(path)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	assert.Zero(t, skipped)
+
+	created := time.Date(2026, 1, 28, 22, 39, 46, 0, time.UTC)
+	first := HistoryEntry{ID: "abc123XYZ789", URL: "https://tafcha.dev/abc123XYZ789", CreatedAt: created, ExpiresAt: created.Add(72 * time.Hour), SizeBytes: 6}
+	second := HistoryEntry{ID: "def456UVW012", URL: "https://tafcha.dev/def456UVW012", CreatedAt: created.Add(time.Minute), ExpiresAt: created.Add(time.Hour), SizeBytes: 12}
+	require.NoError(t, AppendHistory(path, first))
+	require.NoError(t, AppendHistory(path, second))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	entries, skipped, err = ReadHistory(path)
+	require.NoError(t, err)
+	assert.Zero(t, skipped)
+	assert.Equal(t, []HistoryEntry{first, second}, entries)
+}
+
+func TestReadHistory_CorruptLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte(
+		`{"id":"abc123XYZ789","url":"https://tafcha.dev/abc123XYZ789"}`+"\n"+
+			"not json\n"+
+			"\n"+
+			`{"id":"truncated`,
+	), 0o600))
+
+	entries, skipped, err := ReadHistory(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, skipped)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "abc123XYZ789", entries[0].ID)
+}
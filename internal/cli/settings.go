@@ -0,0 +1,232 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/expiry"
+)
+
+// Default values used when neither a profile nor the environment sets them.
+const (
+	DefaultAPIURL  = "https://tafcha.dev"
+	DefaultTimeout = 30 * time.Second
+)
+
+// Settings holds the resolved CLI defaults.
+type Settings struct {
+	Profile string
+	APIURL  string
+	Expiry  string
+	Timeout time.Duration
+	Quiet   bool
+
+	// SigningKey signs time-limited links; it must match the server's
+	// URL_SIGNING_KEY.
+	SigningKey string
+
+	// APIKey is sent with writes to servers that require one.
+	APIKey string
+
+	// ConfigFile is the config file that was read, empty if there was none.
+	// Sources maps each setting (api, expiry, timeout, quiet) to where its
+	// value came from.
+	ConfigFile string
+	Sources    map[string]string
+
+	// timeoutRaw keeps the unparsed TIMEOUT value so Validate can report it.
+	timeoutRaw string
+
+	// configErr is set when the config file exists but cannot be used.
+	configErr error
+}
+
+// Setting sources reported in Settings.Sources besides environment
+// variable names.
+const (
+	SourceDefault = "default"
+	SourceFile    = "config file"
+)
+
+// fileSettings is the layout of the config file.
+type fileSettings struct {
+	API     string `yaml:"api"`
+	Expiry  string `yaml:"expiry"`
+	Timeout string `yaml:"timeout"`
+	Quiet   *bool  `yaml:"quiet"`
+}
+
+// ConfigPath returns the config file location: TAFCHA_CONFIG when set,
+// otherwise $XDG_CONFIG_HOME/tafcha/config.yaml or
+// ~/.config/tafcha/config.yaml. It returns "" when none can be determined.
+func ConfigPath(getenv func(string) string) string {
+	if path := getenv("TAFCHA_CONFIG"); path != "" {
+		return path
+	}
+	if dir := getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "tafcha", "config.yaml")
+	}
+	if home := getenv("HOME"); home != "" {
+		return filepath.Join(home, ".config", "tafcha", "config.yaml")
+	}
+	return ""
+}
+
+// readConfigFile parses the config file at path. A missing file yields
+// nil settings and no error.
+func readConfigFile(path string) (*fileSettings, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	var fs fileSettings
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&fs); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("config file %s is not valid (expected keys: api, expiry, timeout, quiet): %w", path, err)
+	}
+	return &fs, nil
+}
+
+// ConfigError reports a config file that exists but could not be read or
+// parsed. Its settings are then ignored.
+func (s *Settings) ConfigError() error {
+	return s.configErr
+}
+
+// LoadSettings resolves CLI defaults from environment variables and the
+// config file (see ConfigPath).
+//
+// Each setting is looked up as TAFCHA_<PROFILE>_<KEY> when TAFCHA_PROFILE is
+// set, then as TAFCHA_<KEY>, then in the config file, then falls back to the
+// built-in default. Supported keys are API, EXPIRY, TIMEOUT, QUIET,
+// SIGNING_KEY and API_KEY; the config file holds api, expiry, timeout and
+// quiet.
+func LoadSettings(getenv func(string) string) *Settings {
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+
+	s := &Settings{
+		Profile: strings.TrimSpace(getenv("TAFCHA_PROFILE")),
+		Timeout: DefaultTimeout,
+		Sources: map[string]string{
+			"api":     SourceDefault,
+			"expiry":  SourceDefault,
+			"timeout": SourceDefault,
+			"quiet":   SourceDefault,
+		},
+	}
+
+	file := &fileSettings{}
+	if path := ConfigPath(getenv); path != "" {
+		fs, err := readConfigFile(path)
+		switch {
+		case err != nil:
+			s.configErr = err
+		case fs != nil:
+			s.ConfigFile = path
+			file = fs
+		}
+	}
+
+	// lookup returns the value for key and where it came from, or "" when
+	// neither the environment nor the config file sets it.
+	lookup := func(key, fileVal string) (string, string) {
+		if s.Profile != "" {
+			name := profileEnvKey(s.Profile, key)
+			if val := getenv(name); val != "" {
+				return val, name
+			}
+		}
+		if val := getenv("TAFCHA_" + key); val != "" {
+			return val, "TAFCHA_" + key
+		}
+		if fileVal != "" {
+			return fileVal, SourceFile
+		}
+		return "", ""
+	}
+
+	s.APIURL = DefaultAPIURL
+	if val, src := lookup("API", file.API); val != "" {
+		s.APIURL, s.Sources["api"] = val, src
+	}
+
+	if val, src := lookup("EXPIRY", file.Expiry); val != "" {
+		s.Expiry, s.Sources["expiry"] = val, src
+	}
+
+	// An unusable timeout keeps the default; Validate reports it
+	if raw, src := lookup("TIMEOUT", file.Timeout); raw != "" {
+		s.timeoutRaw, s.Sources["timeout"] = raw, src
+		if d, err := time.ParseDuration(raw); err == nil && d > 0 {
+			s.Timeout = d
+		}
+	}
+
+	var fileQuiet string
+	if file.Quiet != nil {
+		fileQuiet = fmt.Sprint(*file.Quiet)
+	}
+	if raw, src := lookup("QUIET", fileQuiet); raw != "" {
+		if quiet, err := strconv.ParseBool(raw); err == nil {
+			s.Quiet, s.Sources["quiet"] = quiet, src
+		}
+	}
+
+	s.SigningKey, _ = lookup("SIGNING_KEY", "")
+	s.APIKey, _ = lookup("API_KEY", "")
+
+	return s
+}
+
+// Validate reports every problem found in the resolved settings.
+// It never makes network calls.
+func (s *Settings) Validate() []error {
+	var problems []error
+
+	if s.configErr != nil {
+		problems = append(problems, s.configErr)
+	}
+
+	u, err := url.Parse(s.APIURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		problems = append(problems, fmt.Errorf("api url %q must be an absolute http(s) URL", s.APIURL))
+	}
+
+	if s.Expiry != "" {
+		if _, err := expiry.Parse(s.Expiry); err != nil {
+			problems = append(problems, fmt.Errorf("default expiry: %w", err))
+		}
+	}
+
+	if s.timeoutRaw != "" {
+		if d, err := time.ParseDuration(s.timeoutRaw); err != nil {
+			problems = append(problems, fmt.Errorf("timeout %q is not a valid duration", s.timeoutRaw))
+		} else if d <= 0 {
+			problems = append(problems, fmt.Errorf("timeout must be positive, got %v", d))
+		}
+	}
+
+	return problems
+}
+
+func profileEnvKey(profile, key string) string {
+	profile = strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(profile))
+	return "TAFCHA_" + profile + "_" + key
+}
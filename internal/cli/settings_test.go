@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func envMap(vars map[string]string) func(string) string {
+	return func(key string) string {
+		return vars[key]
+	}
+}
+
+func TestLoadSettings_Defaults(t *testing.T) {
+	s := LoadSettings(envMap(nil))
+
+	assert.Equal(t, "", s.Profile)
+	assert.Equal(t, DefaultAPIURL, s.APIURL)
+	assert.Equal(t, "", s.Expiry)
+	assert.Equal(t, DefaultTimeout, s.Timeout)
+	assert.False(t, s.Quiet)
+	assert.Equal(t, "", s.ConfigFile)
+	assert.Equal(t, SourceDefault, s.Sources["api"])
+	assert.Empty(t, s.Validate())
+}
+
+func TestLoadSettings_Profile(t *testing.T) {
+	s := LoadSettings(envMap(map[string]string{
+		"TAFCHA_PROFILE":            "work-local",
+		"TAFCHA_WORK_LOCAL_API":     "http://localhost:8080",
+		"TAFCHA_API":                "https://ignored.example",
+		"TAFCHA_EXPIRY":             "1d",
+		"TAFCHA_WORK_LOCAL_TIMEOUT": "5s",
+	}))
+
+	assert.Equal(t, "work-local", s.Profile)
+	assert.Equal(t, "http://localhost:8080", s.APIURL)
+	assert.Equal(t, "1d", s.Expiry, "falls back to the unprofiled value")
+	assert.Equal(t, 5*time.Second, s.Timeout)
+	assert.Empty(t, s.Validate())
+}
+
+func TestSettings_Validate_Invalid(t *testing.T) {
+	s := LoadSettings(envMap(map[string]string{
+		"TAFCHA_API":     "tafcha.dev",
+		"TAFCHA_EXPIRY":  "3 days",
+		"TAFCHA_TIMEOUT": "soon",
+	}))
+
+	problems := s.Validate()
+	require.Len(t, problems, 3)
+	assert.Contains(t, problems[0].Error(), "api url")
+	assert.Contains(t, problems[1].Error(), "default expiry")
+	assert.Contains(t, problems[2].Error(), "timeout")
+	assert.Equal(t, DefaultTimeout, s.Timeout)
+}
+
+func TestSettings_Validate_NonPositiveTimeout(t *testing.T) {
+	s := LoadSettings(envMap(map[string]string{"TAFCHA_TIMEOUT": "0s"}))
+
+	problems := s.Validate()
+	require.Len(t, problems, 1)
+	assert.Contains(t, problems[0].Error(), "timeout must be positive")
+	assert.Equal(t, DefaultTimeout, s.Timeout, "a zero timeout would never time out")
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tafcha", "config.yaml")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return dir
+}
+
+func TestLoadSettings_ConfigFile(t *testing.T) {
+	dir := writeConfig(t, "api: https://my-host.example\nexpiry: 1d\ntimeout: 10s\nquiet: true\n")
+
+	s := LoadSettings(envMap(map[string]string{
+		"XDG_CONFIG_HOME": dir,
+		"TAFCHA_EXPIRY":   "1h",
+	}))
+
+	assert.Equal(t, filepath.Join(dir, "tafcha", "config.yaml"), s.ConfigFile)
+	assert.Equal(t, "https://my-host.example", s.APIURL)
+	assert.Equal(t, "1h", s.Expiry, "environment wins over the file")
+	assert.Equal(t, 10*time.Second, s.Timeout)
+	assert.True(t, s.Quiet)
+	assert.Equal(t, map[string]string{
+		"api":     SourceFile,
+		"expiry":  "TAFCHA_EXPIRY",
+		"timeout": SourceFile,
+		"quiet":   SourceFile,
+	}, s.Sources)
+	assert.Empty(t, s.Validate())
+}
+
+func TestLoadSettings_MissingConfigFile(t *testing.T) {
+	s := LoadSettings(envMap(map[string]string{"HOME": t.TempDir()}))
+
+	assert.NoError(t, s.ConfigError())
+	assert.Equal(t, "", s.ConfigFile)
+	assert.Equal(t, DefaultAPIURL, s.APIURL)
+}
+
+func TestLoadSettings_MalformedConfigFile(t *testing.T) {
+	for name, content := range map[string]string{
+		"syntax":      "api: [unclosed\n",
+		"unknown key": "api_url: https://typo.example\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := writeConfig(t, content)
+			s := LoadSettings(envMap(map[string]string{"XDG_CONFIG_HOME": dir}))
+
+			require.Error(t, s.ConfigError())
+			assert.Contains(t, s.ConfigError().Error(), "config.yaml")
+			assert.Equal(t, DefaultAPIURL, s.APIURL, "a broken file is ignored")
+			assert.Len(t, s.Validate(), 1)
+		})
+	}
+}
+
+func TestConfigPath(t *testing.T) {
+	assert.Equal(t, "/etc/tafcha.yaml", ConfigPath(envMap(map[string]string{"TAFCHA_CONFIG": "/etc/tafcha.yaml", "HOME": "/home/me"})))
+	assert.Equal(t, filepath.Join("/home/me", ".config", "tafcha", "config.yaml"), ConfigPath(envMap(map[string]string{"HOME": "/home/me"})))
+	assert.Equal(t, "", ConfigPath(envMap(nil)))
+}
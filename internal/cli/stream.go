@@ -0,0 +1,186 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Stream defaults, used for zero StreamOptions fields. The interval keeps a
+// stream within the server's default POST rate limit of 30 a minute.
+const (
+	DefaultStreamInterval   = 3 * time.Second
+	DefaultStreamFlushBytes = 16 << 10
+)
+
+// streamReadSize is how much is read from the input at a time.
+const streamReadSize = 32 << 10
+
+// StreamOptions are the settings for Stream.
+type StreamOptions struct {
+	Expiry string // e.g. 10m, 3d; empty for the server default
+
+	// Interval is how often buffered lines are sent.
+	Interval time.Duration
+
+	// FlushBytes sends buffered lines as soon as this much is waiting,
+	// without waiting for Interval. A single line this long is sent even
+	// before its newline arrives.
+	FlushBytes int
+}
+
+// Stream sends everything read from in to a new appendable snippet while
+// it is being read. The snippet is created with the first complete lines,
+// and created is called with it before anything else is sent; later lines
+// are appended every Interval, or sooner once FlushBytes are waiting.
+//
+// A trailing partial line is held back until its newline, FlushBytes or
+// the end of in. While a request is in flight nothing more is read, so a
+// slow server slows the writer down instead of filling memory; a rate
+// limited request is retried at the next interval with whatever has been
+// read since. Stream returns when in is exhausted and everything was sent.
+func (c *Client) Stream(in io.Reader, opts StreamOptions, created func(*CreateResponse)) error {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultStreamInterval
+	}
+	if opts.FlushBytes <= 0 {
+		opts.FlushBytes = DefaultStreamFlushBytes
+	}
+
+	chunks := make(chan []byte)
+	readErr := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(chunks)
+		buf := make([]byte, streamReadSize)
+		for {
+			n, err := in.Read(buf)
+			if n > 0 {
+				select {
+				case chunks <- bytes.Clone(buf[:n]):
+				case <-done:
+					return
+				}
+			}
+			if err != nil {
+				if err != io.EOF {
+					readErr <- err
+				}
+				return
+			}
+		}
+	}()
+
+	s := &stream{client: c, opts: opts, created: created}
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				select {
+				case err := <-readErr:
+					return fmt.Errorf("reading input: %w", err)
+				default:
+				}
+				return s.finish()
+			}
+			s.pending = append(s.pending, chunk...)
+			if len(s.pending) >= opts.FlushBytes {
+				if err := s.flush(); err != nil {
+					return err
+				}
+			}
+		case <-ticker.C:
+			if err := s.flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// stream is the state of one Stream call.
+type stream struct {
+	client  *Client
+	opts    StreamOptions
+	created func(*CreateResponse)
+
+	snippet *CreateResponse
+	pending []byte
+	retryAt time.Time // no sends before this after a rate limited one
+}
+
+// flush sends the complete lines in pending, or all of it when a single
+// partial line has reached FlushBytes. A rate limited send keeps pending
+// and holds off further sends for an interval; if FlushBytes pile up in
+// the meantime, flush waits out the interval so reading stops too.
+func (s *stream) flush() error {
+	n := bytes.LastIndexByte(s.pending, '\n') + 1
+	if n == 0 && len(s.pending) >= s.opts.FlushBytes {
+		n = len(s.pending)
+	}
+	if n == 0 {
+		return nil
+	}
+	if wait := time.Until(s.retryAt); wait > 0 {
+		if len(s.pending) < s.opts.FlushBytes {
+			return nil
+		}
+		time.Sleep(wait)
+	}
+
+	err := s.send(s.pending[:n])
+	if errors.Is(err, ErrRateLimited) {
+		s.retryAt = time.Now().Add(s.opts.Interval)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.pending = append(s.pending[:0], s.pending[n:]...)
+	return nil
+}
+
+// send creates the snippet with content, or appends content to it.
+func (s *stream) send(content []byte) error {
+	if s.snippet != nil {
+		return s.client.Append(s.snippet.ID, s.snippet.AppendToken, content)
+	}
+
+	resp, err := s.client.Create(content, CreateOptions{Expiry: s.opts.Expiry, Appendable: true})
+	if err != nil {
+		return err
+	}
+	if resp.AppendToken == "" {
+		return fmt.Errorf("server did not return an append token")
+	}
+	s.snippet = resp
+	if s.created != nil {
+		s.created(resp)
+	}
+	return nil
+}
+
+// finish sends whatever is left once the input is exhausted, waiting out
+// rate limits between attempts.
+func (s *stream) finish() error {
+	for len(s.pending) > 0 {
+		err := s.send(s.pending)
+		if errors.Is(err, ErrRateLimited) {
+			time.Sleep(s.opts.Interval)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		s.pending = nil
+	}
+	if s.snippet == nil {
+		return fmt.Errorf("nothing to stream: input was empty")
+	}
+	return nil
+}
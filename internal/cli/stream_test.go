@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// appendServer is a fake API that records the created content and every
+// appended chunk, in order.
+type appendServer struct {
+	mu          sync.Mutex
+	chunks      []string
+	limitNext   int // answer this many of the next appends with 429
+	appendToken string
+}
+
+func (a *appendServer) handler(t *testing.T) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /{$}", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "true", r.URL.Query().Get("appendable"))
+		body, _ := io.ReadAll(r.Body)
+
+		a.mu.Lock()
+		a.chunks = append(a.chunks, string(body))
+		a.mu.Unlock()
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"abc123XYZ789","url":"https://tafcha.dev/abc123XYZ789","append_token":"` + a.appendToken + `"}`))
+	})
+	mux.HandleFunc("POST /abc123XYZ789/append", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, a.appendToken, r.Header.Get("X-Append-Token"))
+		body, _ := io.ReadAll(r.Body)
+
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if a.limitNext > 0 {
+			a.limitNext--
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		a.chunks = append(a.chunks, string(body))
+		w.WriteHeader(http.StatusOK)
+	})
+	return mux
+}
+
+func (a *appendServer) received() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.chunks...)
+}
+
+func startStream(t *testing.T, opts StreamOptions, fake *appendServer) (*io.PipeWriter, <-chan *CreateResponse, <-chan error) {
+	t.Helper()
+
+	srv := httptest.NewServer(fake.handler(t))
+	t.Cleanup(srv.Close)
+
+	pr, pw := io.Pipe()
+	created := make(chan *CreateResponse, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- NewClient(srv.URL, 5*time.Second).Stream(pr, opts, func(resp *CreateResponse) {
+			created <- resp
+		})
+	}()
+	return pw, created, done
+}
+
+func TestClient_Stream(t *testing.T) {
+	fake := &appendServer{appendToken: "tok"}
+	pw, created, done := startStream(t, StreamOptions{Interval: 10 * time.Millisecond}, fake)
+
+	io.WriteString(pw, "first line\n")
+	select {
+	case resp := <-created:
+		assert.Equal(t, "https://tafcha.dev/abc123XYZ789", resp.URL)
+	case <-time.After(time.Second):
+		t.Fatal("snippet was not created")
+	}
+
+	// A partial line is held back until its newline arrives
+	io.WriteString(pw, "second\npart")
+	require.Eventually(t, func() bool { return len(fake.received()) == 2 }, time.Second, 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, []string{"first line\n", "second\n"}, fake.received())
+
+	io.WriteString(pw, "ial line\nunterminated")
+	pw.Close()
+
+	require.NoError(t, <-done)
+	chunks := fake.received()
+	assert.Equal(t, "first line\nsecond\npartial line\nunterminated", strings.Join(chunks, ""))
+	for _, chunk := range chunks[:len(chunks)-1] {
+		assert.True(t, strings.HasSuffix(chunk, "\n"), "chunk %q ends mid-line", chunk)
+	}
+}
+
+func TestClient_Stream_FlushBytes(t *testing.T) {
+	fake := &appendServer{appendToken: "tok"}
+	pw, created, done := startStream(t, StreamOptions{Interval: time.Hour, FlushBytes: 8}, fake)
+
+	// Neither the interval nor a newline is reached, only FlushBytes
+	io.WriteString(pw, "0123456789")
+	select {
+	case <-created:
+	case <-time.After(time.Second):
+		t.Fatal("FlushBytes did not flush")
+	}
+	assert.Equal(t, []string{"0123456789"}, fake.received())
+
+	pw.Close()
+	require.NoError(t, <-done)
+}
+
+func TestClient_Stream_RateLimited(t *testing.T) {
+	fake := &appendServer{appendToken: "tok", limitNext: 2}
+	pw, created, done := startStream(t, StreamOptions{Interval: 10 * time.Millisecond}, fake)
+
+	io.WriteString(pw, "one\n")
+	<-created
+	io.WriteString(pw, "two\n")
+	io.WriteString(pw, "three\n")
+	pw.Close()
+
+	require.NoError(t, <-done)
+	assert.Equal(t, "one\ntwo\nthree\n", strings.Join(fake.received(), ""))
+}
+
+func TestClient_Stream_Empty(t *testing.T) {
+	fake := &appendServer{appendToken: "tok"}
+	pw, _, done := startStream(t, StreamOptions{Interval: 10 * time.Millisecond}, fake)
+
+	pw.Close()
+	assert.ErrorContains(t, <-done, "input was empty")
+	assert.Empty(t, fake.received())
+}
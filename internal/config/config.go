@@ -2,10 +2,21 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/expiry"
+	"github.com/rayenfassatoui/tafcha-cli/internal/hash"
+	"github.com/rayenfassatoui/tafcha-cli/internal/id"
+	"github.com/rayenfassatoui/tafcha-cli/internal/ratelimit"
 )
 
 // Config holds all application configuration.
@@ -30,10 +41,244 @@ type Config struct {
 	MinExpiry       time.Duration
 	MaxExpiry       time.Duration
 	CleanupInterval time.Duration
+	IdleExpiry      time.Duration // 0 disables idle collection
+	DetectBinary    bool          // serve non-text snippets as attachments
+	ContentHashAlgo string        // sha256, blake3 or sha1
+	IDLength        int           // characters in a generated snippet ID
+	IDAlphabet      string        // characters a generated snippet ID is drawn from
+	ThumbnailSize   int           // max thumbnail side in pixels, 0 disables /{id}/thumb
+	LiveStreaming   bool          // enable the /ws/{id} WebSocket for appendable snippets
+	CompressMinSize int64         // smallest GET body sent gzip/deflate compressed
+	CompressStorage bool          // gzip content at rest in Postgres
+
+	// CleanupConcurrency is how many delete batches run in parallel within
+	// a cleanup run. 0 or 1 runs them one after another.
+	CleanupConcurrency int
+
+	// CleanupBatchSize is how many expired snippets each delete statement
+	// removes, keeping its locks short on a large table. Zero uses the
+	// worker's default.
+	CleanupBatchSize int
+
+	// CleanupEnabled runs the cleanup worker in this process. Turn it off
+	// on all but one replica to leave cleanup to that one.
+	CleanupEnabled bool
+
+	// CleanupJitter delays each cleanup run by a random fraction of
+	// CleanupInterval up to this much (0.0-1.0), so replicas drift apart.
+	CleanupJitter float64
+
+	// PinMaxAttempts wrong PINs lock a PIN-protected snippet for
+	// PinLockout after the last of them. Zero uses the defaults.
+	PinMaxAttempts int
+	PinLockout     time.Duration
+
+	// StorageBackend is StorageDatabase to keep content in the database, or
+	// StorageS3 to keep it in the S3-compatible bucket described by the S3
+	// fields, with only metadata in the database.
+	StorageBackend    string
+	S3Endpoint        string
+	S3Bucket          string
+	S3Region          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+
+	// QuotaLimit caps how much each creator may upload per QuotaWindow,
+	// counted in QuotaUnit: snippets created or content bytes. 0 disables
+	// the quota.
+	QuotaLimit  int64
+	QuotaUnit   string
+	QuotaWindow time.Duration
+
+	// CleanupLogBatches logs every expired-snippet delete batch with a
+	// running total, for watching progress through a large backlog.
+	CleanupLogBatches bool
+
+	// TenancyMode namespaces snippets per request host or /t/{tenant} path
+	// prefix. One of TenancyOff, TenancyHost or TenancyPath.
+	TenancyMode string
+
+	// Tenants lists the tenants accepted in host and path mode; requests
+	// naming any other tenant are answered 404. Rate limits apply per
+	// tenant, so an open list would let a client multiply its budget.
+	Tenants []string
+
+	// AppendResetsExpiry gives a snippet its original TTL again, counted
+	// from now, each time content is appended. When false appends keep the
+	// original expires_at.
+	AppendResetsExpiry bool
+
+	// ExpiryTiers replace DefaultExpiry for uploads up to a tier's size when
+	// the client sends no ?expiry=. Sorted by MaxSize, smallest first.
+	ExpiryTiers []ExpiryTier
+
+	// AllowedExpiryUnits limits ?expiry= to these units, e.g. h and d.
+	// Empty allows every unit.
+	AllowedExpiryUnits []string
+
+	// ExpiryLocation is the timezone in which ?expiry=eod and eow find the
+	// end of the day and week.
+	ExpiryLocation *time.Location
+
+	// UniqueContentPerCreator makes a create with content identical to one
+	// of the creator's active snippets return that snippet instead.
+	UniqueContentPerCreator bool
+
+	// DedupeEnabled lets a create with ?dedupe=true return any active
+	// snippet with identical content, whoever created it.
+	DedupeEnabled bool
+
+	// IdempotencyTTL is how long a create sent with an Idempotency-Key is
+	// replayed to retries with the same key. 0 ignores the header.
+	IdempotencyTTL time.Duration
+
+	// SniffGzip decompresses create bodies that start with the gzip magic
+	// bytes but declare no Content-Encoding. Off by default so that bodies
+	// are stored byte for byte.
+	SniffGzip bool
+
+	// TracingEndpoint enables OpenTelemetry tracing over OTLP/HTTP.
+	// TraceSampleRate is the fraction of new traces kept (0.0-1.0);
+	// requests that fail are traced regardless.
+	TracingEndpoint string
+	TraceSampleRate float64
+
+	// LogSampleRate is the fraction of successful GET requests logged
+	// (0.0-1.0). Errors and writes are always logged.
+	LogSampleRate float64
+
+	// MetricsEnabled serves Prometheus metrics, including storage latency
+	// histograms, on GET /metrics.
+	MetricsEnabled bool
+
+	// AdminToken enables the /admin endpoints when set.
+	AdminToken string
+
+	// StatsToken enables GET /stats for callers sending it as a bearer
+	// token. Unset keeps the endpoint off.
+	StatsToken string
+
+	// APIKeys, when set, are the keys accepted in the X-API-Key header of
+	// writes. Without one of them creating, appending, deleting and
+	// extending is refused; reads stay public.
+	APIKeys []string
+
+	// URLSigningKey verifies time-limited links (?exp=...&sig=...). With
+	// RequireSignedURLs, reads without a valid signature are refused.
+	URLSigningKey     string
+	RequireSignedURLs bool
+
+	// ReceiptSigningKey, when set, makes creates return a signed
+	// creation_receipt and enables POST /verify-receipt.
+	ReceiptSigningKey string
+
+	// CreatorHashKey keys the HMAC that turns client IPs into the stored
+	// creator identity. Without it each process picks a random key, so a
+	// creator is not recognised across restarts or replicas.
+	CreatorHashKey string
+
+	// TrailingSlash says what to do with a trailing slash on a path such as
+	// /{id}/: strip it, redirect to the path without it, or leave it (off).
+	// CaseInsensitiveRoutes accepts reserved route names such as /Healthz
+	// in any case; snippet IDs are never changed.
+	TrailingSlash         string
+	CaseInsensitiveRoutes bool
+
+	// CORSAllowedOrigins are the origins, such as https://app.example.com,
+	// whose browser scripts may call the API; "*" allows any. Empty leaves
+	// CORS disabled.
+	CORSAllowedOrigins []string
+
+	// Templates are named header/footer wrappers applied via ?template=name.
+	Templates map[string]Template
+
+	// TLS policy, see TLSConfig
+	TLSMinVersion   string   // "1.2" or "1.3"
+	TLSCipherSuites []string // allowlist of Go cipher suite names, empty for defaults
+
+	// TLSCertFile and TLSKeyFile are a PEM certificate chain and its key.
+	// With both set the server terminates TLS itself, see TLSEnabled.
+	TLSCertFile string
+	TLSKeyFile  string
 
 	// Rate limiting
-	PostRateLimit int
-	GetRateLimit  int
+	PostRateLimit   int
+	GetRateLimit    int
+	ExemptLocalhost bool // skip rate limits for loopback clients (development only)
+
+	// RateLimitMaxWait is how long a create with ?wait=true may be held
+	// for the rate limit to free up before it gets a 429. 0 disables
+	// waiting.
+	RateLimitMaxWait time.Duration
+
+	// RateLimitStore is where rate limit counters live: "memory", per
+	// instance, or "redis" at RedisURL, shared by all instances.
+	// RateLimitFailMode decides whether requests are let through ("open")
+	// or limited ("closed") while Redis cannot be reached.
+	RateLimitStore    string
+	RateLimitFailMode string
+	RedisURL          string
+}
+
+// Tenancy modes for TENANCY_MODE.
+const (
+	TenancyOff  = "off"
+	TenancyHost = "host"
+	TenancyPath = "path"
+)
+
+// Trailing slash handling for TRAILING_SLASH.
+const (
+	TrailingSlashStrip    = "strip"
+	TrailingSlashRedirect = "redirect"
+	TrailingSlashOff      = "off"
+)
+
+// Storage backends for STORAGE_BACKEND.
+const (
+	StorageDatabase = "database"
+	StorageS3       = "s3"
+)
+
+// Rate limit stores for RATE_LIMIT_STORE.
+const (
+	RateLimitStoreMemory = "memory"
+	RateLimitStoreRedis  = "redis"
+)
+
+// Rate limit fail modes for RATE_LIMIT_FAIL_MODE.
+const (
+	RateLimitFailOpen   = "open"
+	RateLimitFailClosed = "closed"
+)
+
+// Quota units for QUOTA_UNIT.
+const (
+	QuotaUnitSnippets = "snippets"
+	QuotaUnitBytes    = "bytes"
+)
+
+// Template wraps submitted content with a fixed header and footer.
+type Template struct {
+	Header string `json:"header"`
+	Footer string `json:"footer"`
+}
+
+// ExpiryTier is the default expiry for uploads of at most MaxSize bytes.
+type ExpiryTier struct {
+	MaxSize int64
+	Expiry  time.Duration
+}
+
+// DefaultExpiryFor returns the default expiry for an upload of size bytes:
+// that of the smallest tier it fits in, or DefaultExpiry if there is none.
+func (c *Config) DefaultExpiryFor(size int64) time.Duration {
+	for _, tier := range c.ExpiryTiers {
+		if size <= tier.MaxSize {
+			return tier.Expiry
+		}
+	}
+	return c.DefaultExpiry
 }
 
 // Load reads configuration from environment variables with sensible defaults.
@@ -59,11 +304,89 @@ func Load() (*Config, error) {
 		MinExpiry:       getEnvDuration("MIN_EXPIRY", 10*time.Minute),
 		MaxExpiry:       getEnvDuration("MAX_EXPIRY", 30*24*time.Hour),
 		CleanupInterval: getEnvDuration("CLEANUP_INTERVAL", 5*time.Minute),
+		IdleExpiry:      getEnvDuration("IDLE_EXPIRY", 0),
+		DetectBinary:    getEnvBool("DETECT_BINARY", true),
+		ContentHashAlgo: getEnvString("CONTENT_HASH_ALGO", string(hash.Default)),
+		IDLength:        getEnvInt("ID_LENGTH", id.Length),
+		IDAlphabet:      getEnvString("ID_ALPHABET", id.Alphabet),
+		ThumbnailSize:   getEnvInt("THUMBNAIL_SIZE", 0),
+		LiveStreaming:   getEnvBool("LIVE_STREAMING", false),
+		CompressMinSize: getEnvInt64("COMPRESS_MIN_SIZE", 1024),
+		CompressStorage: getEnvBool("COMPRESS_STORAGE", false),
+		AdminToken:      getEnvString("ADMIN_TOKEN", ""),
+		StatsToken:      getEnvString("STATS_TOKEN", ""),
+		APIKeys:         getEnvList("API_KEYS"),
+
+		AppendResetsExpiry:      getEnvBool("APPEND_RESETS_EXPIRY", false),
+		TenancyMode:             getEnvString("TENANCY_MODE", TenancyOff),
+		Tenants:                 getEnvList("TENANTS"),
+		CleanupConcurrency:      getEnvInt("CLEANUP_CONCURRENCY", 1),
+		CleanupBatchSize:        getEnvInt("CLEANUP_BATCH_SIZE", 1000),
+		CleanupEnabled:          getEnvBool("CLEANUP_ENABLED", true),
+		CleanupJitter:           getEnvFloat("CLEANUP_JITTER", 0),
+		CleanupLogBatches:       getEnvBool("CLEANUP_LOG_BATCHES", false),
+		PinMaxAttempts:          getEnvInt("PIN_MAX_ATTEMPTS", 5),
+		PinLockout:              getEnvDuration("PIN_LOCKOUT", 15*time.Minute),
+		StorageBackend:          getEnvString("STORAGE_BACKEND", StorageDatabase),
+		S3Endpoint:              getEnvString("S3_ENDPOINT", ""),
+		S3Bucket:                getEnvString("S3_BUCKET", ""),
+		S3Region:                getEnvString("S3_REGION", "us-east-1"),
+		S3AccessKeyID:           getEnvString("S3_ACCESS_KEY_ID", ""),
+		S3SecretAccessKey:       getEnvString("S3_SECRET_ACCESS_KEY", ""),
+		QuotaLimit:              getEnvInt64("QUOTA_LIMIT", 0),
+		QuotaUnit:               getEnvString("QUOTA_UNIT", QuotaUnitSnippets),
+		QuotaWindow:             getEnvDuration("QUOTA_WINDOW", 24*time.Hour),
+		UniqueContentPerCreator: getEnvBool("UNIQUE_CONTENT_PER_CREATOR", false),
+		DedupeEnabled:           getEnvBool("DEDUPE_ENABLED", false),
+		IdempotencyTTL:          getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+		SniffGzip:               getEnvBool("SNIFF_GZIP", false),
+		URLSigningKey:           getEnvString("URL_SIGNING_KEY", ""),
+		RequireSignedURLs:       getEnvBool("REQUIRE_SIGNED_URLS", false),
+		ReceiptSigningKey:       getEnvString("RECEIPT_SIGNING_KEY", ""),
+		CreatorHashKey:          getEnvString("CREATOR_HASH_KEY", ""),
+		TrailingSlash:           getEnvString("TRAILING_SLASH", TrailingSlashStrip),
+		CaseInsensitiveRoutes:   getEnvBool("CASE_INSENSITIVE_ROUTES", true),
+		CORSAllowedOrigins:      getEnvList("CORS_ALLOWED_ORIGINS"),
+		TracingEndpoint:         getEnvString("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+		TraceSampleRate:         getEnvFloat("TRACE_SAMPLE_RATE", 1.0),
+		LogSampleRate:           getEnvFloat("LOG_SAMPLE_RATE", 1.0),
+		MetricsEnabled:          getEnvBool("METRICS_ENABLED", false),
+
+		// TLS defaults
+		TLSMinVersion:   getEnvString("TLS_MIN_VERSION", "1.2"),
+		TLSCipherSuites: getEnvList("TLS_CIPHER_SUITES"),
+		TLSCertFile:     getEnvString("TLS_CERT_FILE", ""),
+		TLSKeyFile:      getEnvString("TLS_KEY_FILE", ""),
 
 		// Rate limiting defaults
-		PostRateLimit: getEnvInt("POST_RATE_LIMIT", 30),
-		GetRateLimit:  getEnvInt("GET_RATE_LIMIT", 300),
+		PostRateLimit:   getEnvInt("POST_RATE_LIMIT", 30),
+		GetRateLimit:    getEnvInt("GET_RATE_LIMIT", 300),
+		ExemptLocalhost: getEnvBool("EXEMPT_LOCALHOST", false),
+
+		RateLimitMaxWait:  getEnvDuration("RATE_LIMIT_MAX_WAIT", 0),
+		RateLimitStore:    getEnvString("RATE_LIMIT_STORE", RateLimitStoreMemory),
+		RateLimitFailMode: getEnvString("RATE_LIMIT_FAIL_MODE", RateLimitFailOpen),
+		RedisURL:          getEnvString("REDIS_URL", ""),
+	}
+
+	templates, err := getEnvTemplates("PASTE_TEMPLATES")
+	if err != nil {
+		return nil, err
 	}
+	cfg.Templates = templates
+
+	tiers, err := getEnvExpiryTiers("EXPIRY_TIERS")
+	if err != nil {
+		return nil, err
+	}
+	cfg.ExpiryTiers = tiers
+	cfg.AllowedExpiryUnits = getEnvList("ALLOWED_EXPIRY_UNITS")
+
+	loc, err := time.LoadLocation(getEnvString("EXPIRY_TIMEZONE", "UTC"))
+	if err != nil {
+		return nil, fmt.Errorf("EXPIRY_TIMEZONE: %w", err)
+	}
+	cfg.ExpiryLocation = loc
 
 	if err := cfg.Validate(); err != nil {
 		return nil, err
@@ -89,12 +412,135 @@ func (c *Config) Validate() error {
 	if c.DefaultExpiry < c.MinExpiry || c.DefaultExpiry > c.MaxExpiry {
 		return fmt.Errorf("DEFAULT_EXPIRY must be between MIN_EXPIRY and MAX_EXPIRY")
 	}
+	for _, tier := range c.ExpiryTiers {
+		if tier.Expiry < c.MinExpiry || tier.Expiry > c.MaxExpiry {
+			return fmt.Errorf("EXPIRY_TIERS: expiry for uploads up to %d bytes must be between MIN_EXPIRY and MAX_EXPIRY", tier.MaxSize)
+		}
+	}
+	for _, unit := range c.AllowedExpiryUnits {
+		if !slices.Contains(expiry.Units, unit) {
+			return fmt.Errorf("ALLOWED_EXPIRY_UNITS: unknown unit %q, expected a subset of %s",
+				unit, strings.Join(expiry.Units, ", "))
+		}
+	}
+	for _, origin := range c.CORSAllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			(u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.User != nil {
+			return fmt.Errorf("CORS_ALLOWED_ORIGINS: %q is not an origin such as https://app.example.com", origin)
+		}
+	}
+	if c.CleanupConcurrency < 0 || c.CleanupConcurrency > 16 {
+		return fmt.Errorf("CLEANUP_CONCURRENCY must be between 1 and 16")
+	}
+	if c.CleanupBatchSize < 0 {
+		return fmt.Errorf("CLEANUP_BATCH_SIZE cannot be negative")
+	}
+	if c.CleanupJitter < 0 || c.CleanupJitter > 1 {
+		return fmt.Errorf("CLEANUP_JITTER must be between 0.0 and 1.0")
+	}
+	if c.PinMaxAttempts < 0 {
+		return fmt.Errorf("PIN_MAX_ATTEMPTS cannot be negative")
+	}
+	if c.PinLockout < 0 {
+		return fmt.Errorf("PIN_LOCKOUT cannot be negative")
+	}
+	if c.RateLimitMaxWait < 0 || c.RateLimitMaxWait > 30*time.Second {
+		return fmt.Errorf("RATE_LIMIT_MAX_WAIT must be between 0 and 30s")
+	}
+	if c.CompressMinSize < 0 {
+		return fmt.Errorf("COMPRESS_MIN_SIZE cannot be negative")
+	}
+	if c.IdleExpiry < 0 {
+		return fmt.Errorf("IDLE_EXPIRY cannot be negative")
+	}
+	if c.IdempotencyTTL < 0 {
+		return fmt.Errorf("IDEMPOTENCY_TTL cannot be negative")
+	}
+	if c.ThumbnailSize < 0 || c.ThumbnailSize > 1024 {
+		return fmt.Errorf("THUMBNAIL_SIZE must be between 0 and 1024")
+	}
+	switch c.StorageBackend {
+	case "", StorageDatabase:
+	case StorageS3:
+		if c.S3Endpoint == "" || c.S3Bucket == "" || c.S3AccessKeyID == "" || c.S3SecretAccessKey == "" {
+			return fmt.Errorf("STORAGE_BACKEND=s3 needs S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY")
+		}
+	default:
+		return fmt.Errorf("STORAGE_BACKEND must be one of database, s3")
+	}
+	if c.QuotaLimit < 0 {
+		return fmt.Errorf("QUOTA_LIMIT cannot be negative")
+	}
+	if c.QuotaLimit > 0 && c.QuotaWindow <= 0 {
+		return fmt.Errorf("QUOTA_WINDOW must be positive")
+	}
+	switch c.QuotaUnit {
+	case "", QuotaUnitSnippets, QuotaUnitBytes:
+	default:
+		return fmt.Errorf("QUOTA_UNIT must be one of snippets, bytes")
+	}
+	switch c.RateLimitStore {
+	case "", RateLimitStoreMemory:
+	case RateLimitStoreRedis:
+		if c.RedisURL == "" {
+			return fmt.Errorf("REDIS_URL is required with RATE_LIMIT_STORE=redis")
+		}
+		if _, err := ratelimit.NewRedisClient(c.RedisURL); err != nil {
+			return fmt.Errorf("REDIS_URL: %w", err)
+		}
+	default:
+		return fmt.Errorf("RATE_LIMIT_STORE must be one of memory, redis")
+	}
+	switch c.RateLimitFailMode {
+	case "", RateLimitFailOpen, RateLimitFailClosed:
+	default:
+		return fmt.Errorf("RATE_LIMIT_FAIL_MODE must be one of open, closed")
+	}
+	switch c.TenancyMode {
+	case "", TenancyOff:
+	case TenancyHost, TenancyPath:
+		if len(c.Tenants) == 0 {
+			return fmt.Errorf("TENANTS must list the accepted tenants when TENANCY_MODE is %s", c.TenancyMode)
+		}
+	default:
+		return fmt.Errorf("TENANCY_MODE must be one of off, host, path")
+	}
+	switch c.TrailingSlash {
+	case "", TrailingSlashStrip, TrailingSlashRedirect, TrailingSlashOff:
+	default:
+		return fmt.Errorf("TRAILING_SLASH must be one of strip, redirect, off")
+	}
+	if _, err := hash.New(c.ContentHashAlgo); err != nil {
+		return fmt.Errorf("CONTENT_HASH_ALGO: %w", err)
+	}
+	if _, err := id.New(c.IDLength, c.IDAlphabet); err != nil {
+		return fmt.Errorf("ID_LENGTH/ID_ALPHABET: %w", err)
+	}
+	if c.TraceSampleRate < 0 || c.TraceSampleRate > 1 {
+		return fmt.Errorf("TRACE_SAMPLE_RATE must be between 0.0 and 1.0")
+	}
+	if c.LogSampleRate < 0 || c.LogSampleRate > 1 {
+		return fmt.Errorf("LOG_SAMPLE_RATE must be between 0.0 and 1.0")
+	}
+	if c.RequireSignedURLs && c.URLSigningKey == "" {
+		return fmt.Errorf("REQUIRE_SIGNED_URLS needs URL_SIGNING_KEY")
+	}
+	if _, err := c.TLSConfig(); err != nil {
+		return err
+	}
+	if err := c.validateTLSFiles(); err != nil {
+		return err
+	}
 	return nil
 }
 
 // Addr returns the server address in host:port format.
 func (c *Config) Addr() string {
-	return fmt.Sprintf("%s:%d", c.Host, c.Port)
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
 }
 
 func getEnvString(key, defaultVal string) string {
@@ -122,6 +568,24 @@ func getEnvInt64(key string, defaultVal int64) int64 {
 	return defaultVal
 }
 
+func getEnvBool(key string, defaultVal bool) bool {
+	if val := os.Getenv(key); val != "" {
+		if b, err := strconv.ParseBool(val); err == nil {
+			return b
+		}
+	}
+	return defaultVal
+}
+
+func getEnvFloat(key string, defaultVal float64) float64 {
+	if val := os.Getenv(key); val != "" {
+		if f, err := strconv.ParseFloat(val, 64); err == nil {
+			return f
+		}
+	}
+	return defaultVal
+}
+
 func getEnvDuration(key string, defaultVal time.Duration) time.Duration {
 	if val := os.Getenv(key); val != "" {
 		if d, err := time.ParseDuration(val); err == nil {
@@ -130,3 +594,54 @@ func getEnvDuration(key string, defaultVal time.Duration) time.Duration {
 	}
 	return defaultVal
 }
+
+// getEnvList splits a comma-separated value, dropping empty entries.
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvTemplates parses a JSON object of named templates, e.g.
+// {"incident":{"header":"== Incident ==\n","footer":"\n== End =="}}.
+func getEnvTemplates(key string) (map[string]Template, error) {
+	val := os.Getenv(key)
+	if val == "" {
+		return nil, nil
+	}
+
+	var templates map[string]Template
+	if err := json.Unmarshal([]byte(val), &templates); err != nil {
+		return nil, fmt.Errorf("%s must be a JSON object of templates: %w", key, err)
+	}
+	return templates, nil
+}
+
+// getEnvExpiryTiers parses comma-separated size:expiry pairs, e.g.
+// "1024:30d,65536:3d", into tiers sorted by size.
+func getEnvExpiryTiers(key string) ([]ExpiryTier, error) {
+	var tiers []ExpiryTier
+	for _, item := range getEnvList(key) {
+		sizeStr, expiryStr, ok := strings.Cut(item, ":")
+		if !ok {
+			return nil, fmt.Errorf("%s: %q must be size:expiry", key, item)
+		}
+		size, err := strconv.ParseInt(strings.TrimSpace(sizeStr), 10, 64)
+		if err != nil || size < 1 {
+			return nil, fmt.Errorf("%s: size %q must be a positive number of bytes", key, sizeStr)
+		}
+		d, err := expiry.Parse(strings.TrimSpace(expiryStr))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		tiers = append(tiers, ExpiryTier{MaxSize: size, Expiry: d})
+	}
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].MaxSize < tiers[j].MaxSize
+	})
+	return tiers, nil
+}
@@ -1,7 +1,15 @@
 package config
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -27,6 +35,20 @@ func TestLoad_Defaults(t *testing.T) {
 	assert.Equal(t, 30*24*time.Hour, cfg.MaxExpiry)
 	assert.Equal(t, 30, cfg.PostRateLimit)
 	assert.Equal(t, 300, cfg.GetRateLimit)
+	assert.True(t, cfg.DetectBinary)
+	assert.Equal(t, "sha256", cfg.ContentHashAlgo)
+	assert.Equal(t, TenancyOff, cfg.TenancyMode)
+	assert.Equal(t, "1.2", cfg.TLSMinVersion)
+	assert.Empty(t, cfg.TLSCipherSuites)
+	assert.Equal(t, 1.0, cfg.TraceSampleRate)
+	assert.Equal(t, TrailingSlashStrip, cfg.TrailingSlash)
+	assert.True(t, cfg.CaseInsensitiveRoutes)
+	assert.Equal(t, int64(1024), cfg.CompressMinSize)
+	assert.Equal(t, 1000, cfg.CleanupBatchSize)
+	assert.True(t, cfg.CleanupEnabled)
+	assert.Zero(t, cfg.CleanupJitter)
+	assert.Equal(t, 12, cfg.IDLength)
+	assert.Len(t, cfg.IDAlphabet, 62)
 }
 
 func TestLoad_CustomValues(t *testing.T) {
@@ -37,6 +59,7 @@ func TestLoad_CustomValues(t *testing.T) {
 		"MAX_CONTENT_SIZE": "2097152",
 		"DEFAULT_EXPIRY":   "24h",
 		"POST_RATE_LIMIT":  "60",
+		"DETECT_BINARY":    "false",
 	}
 
 	for k, v := range envVars {
@@ -53,6 +76,7 @@ func TestLoad_CustomValues(t *testing.T) {
 	assert.Equal(t, int64(2097152), cfg.MaxContentSize)
 	assert.Equal(t, 24*time.Hour, cfg.DefaultExpiry)
 	assert.Equal(t, 60, cfg.PostRateLimit)
+	assert.False(t, cfg.DetectBinary)
 }
 
 func TestLoad_MissingDatabaseURL(t *testing.T) {
@@ -63,6 +87,188 @@ func TestLoad_MissingDatabaseURL(t *testing.T) {
 	assert.Contains(t, err.Error(), "DATABASE_URL is required")
 }
 
+func TestLoad_Templates(t *testing.T) {
+	os.Setenv("DATABASE_URL", "postgres://localhost/test")
+	os.Setenv("PASTE_TEMPLATES", `{"incident":{"header":"# Incident\n","footer":"\n# End"}}`)
+	defer os.Unsetenv("DATABASE_URL")
+	defer os.Unsetenv("PASTE_TEMPLATES")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+
+	require.Contains(t, cfg.Templates, "incident")
+	assert.Equal(t, "# Incident\n", cfg.Templates["incident"].Header)
+	assert.Equal(t, "\n# End", cfg.Templates["incident"].Footer)
+}
+
+func TestLoad_InvalidTemplates(t *testing.T) {
+	os.Setenv("DATABASE_URL", "postgres://localhost/test")
+	os.Setenv("PASTE_TEMPLATES", "not json")
+	defer os.Unsetenv("DATABASE_URL")
+	defer os.Unsetenv("PASTE_TEMPLATES")
+
+	_, err := Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "PASTE_TEMPLATES")
+}
+
+func TestLoad_ExpiryTiers(t *testing.T) {
+	os.Setenv("DATABASE_URL", "postgres://localhost/test")
+	os.Setenv("EXPIRY_TIERS", "65536:1d, 1024:7d")
+	defer os.Unsetenv("DATABASE_URL")
+	defer os.Unsetenv("EXPIRY_TIERS")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+
+	assert.Equal(t, []ExpiryTier{
+		{MaxSize: 1024, Expiry: 7 * 24 * time.Hour},
+		{MaxSize: 65536, Expiry: 24 * time.Hour},
+	}, cfg.ExpiryTiers)
+	assert.Equal(t, 7*24*time.Hour, cfg.DefaultExpiryFor(1024))
+	assert.Equal(t, 24*time.Hour, cfg.DefaultExpiryFor(1025))
+	assert.Equal(t, cfg.DefaultExpiry, cfg.DefaultExpiryFor(65537))
+}
+
+func TestLoad_InvalidExpiryTiers(t *testing.T) {
+	os.Setenv("DATABASE_URL", "postgres://localhost/test")
+	defer os.Unsetenv("DATABASE_URL")
+	defer os.Unsetenv("EXPIRY_TIERS")
+
+	for _, val := range []string{"1024", "big:1d", "1024:soon", "1024:1y"} {
+		os.Setenv("EXPIRY_TIERS", val)
+		_, err := Load()
+		require.Error(t, err, val)
+		assert.Contains(t, err.Error(), "EXPIRY_TIERS", val)
+	}
+}
+
+func TestLoad_AllowedExpiryUnits(t *testing.T) {
+	os.Setenv("DATABASE_URL", "postgres://localhost/test")
+	os.Setenv("ALLOWED_EXPIRY_UNITS", "h, d")
+	defer os.Unsetenv("DATABASE_URL")
+	defer os.Unsetenv("ALLOWED_EXPIRY_UNITS")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"h", "d"}, cfg.AllowedExpiryUnits)
+
+	os.Setenv("ALLOWED_EXPIRY_UNITS", "h,y")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ALLOWED_EXPIRY_UNITS")
+}
+
+func TestLoad_CORSAllowedOrigins(t *testing.T) {
+	os.Setenv("DATABASE_URL", "postgres://localhost/test")
+	defer os.Unsetenv("DATABASE_URL")
+	defer os.Unsetenv("CORS_ALLOWED_ORIGINS")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.CORSAllowedOrigins, "disabled by default")
+
+	os.Setenv("CORS_ALLOWED_ORIGINS", "https://app.example.com, http://localhost:5173")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://app.example.com", "http://localhost:5173"}, cfg.CORSAllowedOrigins)
+
+	for _, bad := range []string{"app.example.com", "https://app.example.com/ui", "ftp://files.example.com"} {
+		os.Setenv("CORS_ALLOWED_ORIGINS", bad)
+		_, err = Load()
+		require.Error(t, err, bad)
+		assert.Contains(t, err.Error(), "CORS_ALLOWED_ORIGINS")
+	}
+}
+
+func TestLoad_ExpiryTimezone(t *testing.T) {
+	os.Setenv("DATABASE_URL", "postgres://localhost/test")
+	defer os.Unsetenv("DATABASE_URL")
+	defer os.Unsetenv("EXPIRY_TIMEZONE")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, time.UTC, cfg.ExpiryLocation)
+
+	os.Setenv("EXPIRY_TIMEZONE", "America/New_York")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "America/New_York", cfg.ExpiryLocation.String())
+
+	os.Setenv("EXPIRY_TIMEZONE", "Mars/Olympus_Mons")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "EXPIRY_TIMEZONE")
+}
+
+func TestLoad_RateLimitStore(t *testing.T) {
+	os.Setenv("DATABASE_URL", "postgres://localhost/test")
+	defer os.Unsetenv("DATABASE_URL")
+	defer os.Unsetenv("RATE_LIMIT_STORE")
+	defer os.Unsetenv("RATE_LIMIT_FAIL_MODE")
+	defer os.Unsetenv("REDIS_URL")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, RateLimitStoreMemory, cfg.RateLimitStore)
+	assert.Equal(t, RateLimitFailOpen, cfg.RateLimitFailMode)
+
+	os.Setenv("RATE_LIMIT_STORE", "redis")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "REDIS_URL")
+
+	os.Setenv("REDIS_URL", "http://cache:6379")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "REDIS_URL")
+
+	os.Setenv("REDIS_URL", "redis://cache:6379/1")
+	os.Setenv("RATE_LIMIT_FAIL_MODE", "closed")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, RateLimitStoreRedis, cfg.RateLimitStore)
+	assert.Equal(t, RateLimitFailClosed, cfg.RateLimitFailMode)
+
+	os.Setenv("RATE_LIMIT_FAIL_MODE", "sideways")
+	_, err = Load()
+	require.Error(t, err)
+
+	os.Setenv("RATE_LIMIT_STORE", "memcached")
+	os.Setenv("RATE_LIMIT_FAIL_MODE", "open")
+	_, err = Load()
+	require.Error(t, err)
+}
+
+func TestLoad_S3Backend(t *testing.T) {
+	os.Setenv("DATABASE_URL", "postgres://localhost/test")
+	os.Setenv("STORAGE_BACKEND", "s3")
+	defer os.Unsetenv("DATABASE_URL")
+	defer os.Unsetenv("STORAGE_BACKEND")
+
+	_, err := Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "S3_BUCKET")
+
+	for key, val := range map[string]string{
+		"S3_ENDPOINT":          "http://minio:9000",
+		"S3_BUCKET":            "snippets",
+		"S3_ACCESS_KEY_ID":     "key",
+		"S3_SECRET_ACCESS_KEY": "secret",
+	} {
+		os.Setenv(key, val)
+		defer os.Unsetenv(key)
+	}
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, StorageS3, cfg.StorageBackend)
+	assert.Equal(t, "us-east-1", cfg.S3Region)
+
+	os.Setenv("STORAGE_BACKEND", "gcs")
+	_, err = Load()
+	assert.ErrorContains(t, err, "STORAGE_BACKEND")
+}
+
 func TestValidate_InvalidPort(t *testing.T) {
 	cfg := &Config{
 		DatabaseURL:   "postgres://localhost/test",
@@ -93,6 +299,236 @@ func TestValidate_InvalidExpiryRange(t *testing.T) {
 	assert.Contains(t, err.Error(), "MIN_EXPIRY cannot be greater than MAX_EXPIRY")
 }
 
+func TestValidate_InvalidContentHashAlgo(t *testing.T) {
+	cfg := &Config{
+		DatabaseURL:     "postgres://localhost/test",
+		Port:            8080,
+		MaxContentSize:  1024,
+		MinExpiry:       time.Minute,
+		MaxExpiry:       time.Hour,
+		DefaultExpiry:   30 * time.Minute,
+		ContentHashAlgo: "md5",
+	}
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "CONTENT_HASH_ALGO")
+}
+
+func TestLoad_InvalidTenancyMode(t *testing.T) {
+	os.Setenv("DATABASE_URL", "postgres://localhost/test")
+	os.Setenv("TENANCY_MODE", "subdomain")
+	defer os.Unsetenv("DATABASE_URL")
+	defer os.Unsetenv("TENANCY_MODE")
+
+	_, err := Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "TENANCY_MODE")
+}
+
+func TestLoad_TenancyNeedsTenants(t *testing.T) {
+	os.Setenv("DATABASE_URL", "postgres://localhost/test")
+	os.Setenv("TENANCY_MODE", "host")
+	defer os.Unsetenv("DATABASE_URL")
+	defer os.Unsetenv("TENANCY_MODE")
+
+	_, err := Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "TENANTS")
+
+	os.Setenv("TENANTS", "alpha.example, beta.example")
+	defer os.Unsetenv("TENANTS")
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alpha.example", "beta.example"}, cfg.Tenants)
+}
+
+func TestLoad_InvalidTrailingSlash(t *testing.T) {
+	os.Setenv("DATABASE_URL", "postgres://localhost/test")
+	os.Setenv("TRAILING_SLASH", "keep")
+	defer os.Unsetenv("DATABASE_URL")
+	defer os.Unsetenv("TRAILING_SLASH")
+
+	_, err := Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "TRAILING_SLASH")
+}
+
+func TestLoad_InvalidIDSettings(t *testing.T) {
+	os.Setenv("DATABASE_URL", "postgres://localhost/test")
+	defer os.Unsetenv("DATABASE_URL")
+
+	for key, value := range map[string]string{"ID_LENGTH": "3", "ID_ALPHABET": "abcda"} {
+		t.Run(key, func(t *testing.T) {
+			os.Setenv(key, value)
+			defer os.Unsetenv(key)
+
+			_, err := Load()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "ID_LENGTH/ID_ALPHABET")
+		})
+	}
+}
+
+func TestLoad_CleanupBatchSize(t *testing.T) {
+	os.Setenv("DATABASE_URL", "postgres://localhost/test")
+	os.Setenv("CLEANUP_BATCH_SIZE", "250")
+	defer os.Unsetenv("DATABASE_URL")
+	defer os.Unsetenv("CLEANUP_BATCH_SIZE")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 250, cfg.CleanupBatchSize)
+
+	os.Setenv("CLEANUP_BATCH_SIZE", "-1")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "CLEANUP_BATCH_SIZE")
+}
+
+func TestLoad_CleanupScheduling(t *testing.T) {
+	os.Setenv("DATABASE_URL", "postgres://localhost/test")
+	os.Setenv("CLEANUP_ENABLED", "false")
+	os.Setenv("CLEANUP_JITTER", "0.25")
+	defer os.Unsetenv("DATABASE_URL")
+	defer os.Unsetenv("CLEANUP_ENABLED")
+	defer os.Unsetenv("CLEANUP_JITTER")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.CleanupEnabled)
+	assert.Equal(t, 0.25, cfg.CleanupJitter)
+
+	os.Setenv("CLEANUP_JITTER", "1.5")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "CLEANUP_JITTER")
+}
+
+func TestLoad_InvalidTraceSampleRate(t *testing.T) {
+	os.Setenv("DATABASE_URL", "postgres://localhost/test")
+	os.Setenv("TRACE_SAMPLE_RATE", "1.5")
+	defer os.Unsetenv("DATABASE_URL")
+	defer os.Unsetenv("TRACE_SAMPLE_RATE")
+
+	_, err := Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "TRACE_SAMPLE_RATE")
+}
+
+func TestTLSConfig(t *testing.T) {
+	os.Setenv("DATABASE_URL", "postgres://localhost/test")
+	os.Setenv("TLS_CIPHER_SUITES", "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256")
+	defer os.Unsetenv("DATABASE_URL")
+	defer os.Unsetenv("TLS_CIPHER_SUITES")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+
+	tlsCfg, err := cfg.TLSConfig()
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), tlsCfg.MinVersion)
+	assert.Equal(t, []uint16{
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	}, tlsCfg.CipherSuites)
+}
+
+// writeTestCert writes a self-signed certificate and its key to dir.
+func writeTestCert(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func TestLoad_TLSFiles(t *testing.T) {
+	os.Setenv("DATABASE_URL", "postgres://localhost/test")
+	defer os.Unsetenv("DATABASE_URL")
+	defer os.Unsetenv("TLS_CERT_FILE")
+	defer os.Unsetenv("TLS_KEY_FILE")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.TLSEnabled(), "plain HTTP by default")
+
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir)
+	os.Setenv("TLS_CERT_FILE", certFile)
+	os.Setenv("TLS_KEY_FILE", keyFile)
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.TLSEnabled())
+
+	garbage := filepath.Join(dir, "garbage.pem")
+	require.NoError(t, os.WriteFile(garbage, []byte("not a certificate"), 0o600))
+
+	tests := []struct {
+		name      string
+		cert, key string
+		want      string
+	}{
+		{name: "cert only", cert: certFile, want: "must be set together"},
+		{name: "key only", key: keyFile, want: "must be set together"},
+		{name: "missing file", cert: filepath.Join(dir, "missing.pem"), key: keyFile, want: "TLS_CERT_FILE"},
+		{name: "not a certificate", cert: garbage, key: keyFile, want: "TLS_CERT_FILE"},
+		{name: "swapped", cert: keyFile, key: certFile, want: "TLS_CERT_FILE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("TLS_CERT_FILE", tt.cert)
+			os.Setenv("TLS_KEY_FILE", tt.key)
+			_, err := Load()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestTLSConfig_MinVersion13(t *testing.T) {
+	cfg := &Config{TLSMinVersion: "1.3"}
+
+	tlsCfg, err := cfg.TLSConfig()
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), tlsCfg.MinVersion)
+	assert.Nil(t, tlsCfg.CipherSuites, "Go's secure defaults are kept")
+}
+
+func TestTLSConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{name: "old version", cfg: Config{TLSMinVersion: "1.0"}, want: "TLS_MIN_VERSION"},
+		{name: "unknown suite", cfg: Config{TLSCipherSuites: []string{"TLS_NOPE"}}, want: "TLS_CIPHER_SUITES"},
+		{name: "insecure suite", cfg: Config{TLSCipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}}, want: "TLS_CIPHER_SUITES"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.cfg.TLSConfig()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
 func TestAddr(t *testing.T) {
 	cfg := &Config{Host: "localhost", Port: 3000}
 	assert.Equal(t, "localhost:3000", cfg.Addr())
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// tlsVersions maps TLS_MIN_VERSION values to crypto/tls constants.
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSConfig builds the TLS policy from TLSMinVersion and TLSCipherSuites.
+//
+// Without an allowlist Go's default suites are used, which only includes
+// secure ones. Cipher suites cannot be configured for TLS 1.3, so an
+// allowlist only restricts TLS 1.2 handshakes.
+func (c *Config) TLSConfig() (*tls.Config, error) {
+	minVersion := c.TLSMinVersion
+	if minVersion == "" {
+		minVersion = "1.2"
+	}
+	version, ok := tlsVersions[minVersion]
+	if !ok {
+		return nil, fmt.Errorf("TLS_MIN_VERSION must be 1.2 or 1.3, got %q", c.TLSMinVersion)
+	}
+
+	cfg := &tls.Config{MinVersion: version}
+	if len(c.TLSCipherSuites) == 0 {
+		return cfg, nil
+	}
+
+	secure := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		secure[suite.Name] = suite.ID
+	}
+
+	for _, name := range c.TLSCipherSuites {
+		suiteID, ok := secure[name]
+		if !ok {
+			return nil, fmt.Errorf("TLS_CIPHER_SUITES: %q is not a supported secure cipher suite", name)
+		}
+		cfg.CipherSuites = append(cfg.CipherSuites, suiteID)
+	}
+	return cfg, nil
+}
+
+// TLSEnabled reports whether the server should serve HTTPS with
+// TLSCertFile and TLSKeyFile instead of plain HTTP.
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// validateTLSFiles checks that the certificate and key are set together and
+// load, so a bad pair fails at startup rather than on the first handshake.
+func (c *Config) validateTLSFiles() error {
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if !c.TLSEnabled() {
+		return nil
+	}
+	if _, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile); err != nil {
+		return fmt.Errorf("TLS_CERT_FILE/TLS_KEY_FILE: %w", err)
+	}
+	return nil
+}
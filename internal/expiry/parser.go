@@ -2,44 +2,116 @@
 package expiry
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 )
 
 var (
-	// Pattern matches formats like: 10m, 12h, 3d, 1w
-	durationPattern = regexp.MustCompile(`^(\d+)([mhdw])$`)
+	// Pattern matches formats like: 30s, 10m, 12h, 3d, 1w, 1M, and
+	// sequences of them such as 1d12h. Units are case-sensitive: "m" is
+	// minutes and "M" months.
+	durationPattern = regexp.MustCompile(`^(?:\d+[smhdwM])+$`)
+
+	// tokenPattern matches one value+unit token of a duration string.
+	tokenPattern = regexp.MustCompile(`(\d+)([smhdwM])`)
 
 	// Unit multipliers
 	unitMultipliers = map[string]time.Duration{
+		"s": time.Second,
 		"m": time.Minute,
 		"h": time.Hour,
 		"d": 24 * time.Hour,
 		"w": 7 * 24 * time.Hour,
+		"M": Month,
 	}
 )
 
+// Month is the length of the "M" unit: 30 days, not a calendar month.
+const Month = 30 * 24 * time.Hour
+
+// Never is what Parse returns for "never". It is a sentinel rather than a
+// usable duration: callers map it to a fixed far-future expiry instead of
+// adding it to the current time.
+const Never time.Duration = math.MaxInt64
+
 // Parse converts a human-friendly duration string to time.Duration.
 // Supported formats:
-//   - "10m" -> 10 minutes
-//   - "12h" -> 12 hours
-//   - "3d"  -> 3 days
-//   - "1w"  -> 1 week
+//   - "30s"   -> 30 seconds
+//   - "10m"   -> 10 minutes
+//   - "12h"   -> 12 hours
+//   - "3d"    -> 3 days
+//   - "1w"    -> 1 week
+//   - "1M"    -> 1 month (30 days)
+//   - "1d12h" -> 36 hours; tokens are summed and each unit may appear once
+//   - "never" -> Never
+//   - "eod"   -> until the end of today, see ParseAt
+//   - "eow"   -> until the end of this business week, see ParseAt
+//
+// Units are case-sensitive, so "10m" is ten minutes and "10M" ten months.
 //
 // Returns an error for invalid formats.
 func Parse(s string) (time.Duration, error) {
-	if s == "" {
+	return ParseAt(s, time.Now())
+}
+
+// ParseAt is like Parse but resolves "eod" and "eow" relative to now, in
+// now's location:
+//   - "eod" lasts until the next midnight.
+//   - "eow" lasts until the midnight that ends Friday. On a Saturday or
+//     Sunday that is the coming Friday.
+//
+// Midnights are computed on the calendar, so a day that is 23 or 25 hours
+// long because of a daylight saving change yields a duration to match.
+func ParseAt(s string, now time.Time) (time.Duration, error) {
+	switch s {
+	case "":
 		return 0, fmt.Errorf("empty duration string")
+	case "never":
+		return Never, nil
+	case "eod":
+		return endOfDay(now, 0).Sub(now), nil
+	case "eow":
+		daysLeft := (time.Friday - now.Weekday() + 7) % 7
+		return endOfDay(now, int(daysLeft)).Sub(now), nil
 	}
 
-	matches := durationPattern.FindStringSubmatch(s)
-	if matches == nil {
-		return 0, fmt.Errorf("invalid duration format: %q (expected format like 10m, 12h, 3d, 1w)", s)
+	if !durationPattern.MatchString(s) {
+		return 0, fmt.Errorf("invalid duration format: %q (expected format like 30s, 10m, 12h, 3d, 1w, 1M or 1d12h)", s)
 	}
 
-	value, err := strconv.Atoi(matches[1])
+	var total time.Duration
+	seen := make(map[string]bool)
+	for _, token := range tokenPattern.FindAllStringSubmatch(s, -1) {
+		unit := token[2]
+		if seen[unit] {
+			return 0, fmt.Errorf("duplicate duration unit %q in %q", unit, s)
+		}
+		seen[unit] = true
+
+		d, err := parseToken(s, token[1], unit)
+		if err != nil {
+			return 0, err
+		}
+		if total > math.MaxInt64-d {
+			return 0, fmt.Errorf("duration too large: %q", s)
+		}
+		total += d
+	}
+	return total, nil
+}
+
+// parseToken converts one value+unit token of the duration string s.
+func parseToken(s, valueStr, unit string) (time.Duration, error) {
+	value, err := strconv.ParseInt(valueStr, 10, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, fmt.Errorf("duration too large: %q", s)
+	}
 	if err != nil {
 		return 0, fmt.Errorf("invalid duration value: %w", err)
 	}
@@ -48,15 +120,45 @@ func Parse(s string) (time.Duration, error) {
 		return 0, fmt.Errorf("duration value must be positive: %d", value)
 	}
 
-	unit := matches[2]
 	multiplier, ok := unitMultipliers[unit]
 	if !ok {
 		return 0, fmt.Errorf("unknown duration unit: %s", unit)
 	}
 
+	// Guard the multiplication: time.Duration is an int64 of nanoseconds and
+	// would otherwise wrap around silently.
+	if value > math.MaxInt64/int64(multiplier) {
+		return 0, fmt.Errorf("duration too large: %q", s)
+	}
+
 	return time.Duration(value) * multiplier, nil
 }
 
+// endOfDay returns the midnight that ends the day days after now's date,
+// in now's location.
+func endOfDay(now time.Time, days int) time.Time {
+	year, month, day := now.Date()
+	return time.Date(year, month, day+days+1, 0, 0, 0, 0, now.Location())
+}
+
+// Units lists the supported duration units, smallest first.
+var Units = []string{"s", "m", "h", "d", "w", "M"}
+
+// CheckUnit returns an error if the duration string s uses a unit that is
+// not in allowed. An empty allowed permits every unit. s is expected to
+// have passed Parse.
+func CheckUnit(s string, allowed []string) error {
+	if len(allowed) == 0 || !durationPattern.MatchString(s) {
+		return nil
+	}
+	for _, token := range tokenPattern.FindAllStringSubmatch(s, -1) {
+		if !slices.Contains(allowed, token[2]) {
+			return fmt.Errorf("duration unit %q is not allowed (allowed units: %s)", token[2], strings.Join(allowed, ", "))
+		}
+	}
+	return nil
+}
+
 // MustParse is like Parse but panics on error.
 // Use only for known-valid constant values.
 func MustParse(s string) time.Duration {
@@ -67,8 +169,12 @@ func MustParse(s string) time.Duration {
 	return d
 }
 
-// Validate checks if a duration is within the allowed range.
+// Validate checks if a duration is within the allowed range. Never is
+// always accepted; whether it is allowed at all is up to the caller.
 func Validate(d, min, max time.Duration) error {
+	if d == Never {
+		return nil
+	}
 	if d < min {
 		return fmt.Errorf("duration %v is less than minimum %v", d, min)
 	}
@@ -82,13 +188,19 @@ func Validate(d, min, max time.Duration) error {
 // Uses the largest appropriate unit.
 func Format(d time.Duration) string {
 	switch {
+	case d == Never:
+		return "never"
+	case d >= Month && d%Month == 0:
+		return fmt.Sprintf("%dM", d/Month)
 	case d >= 7*24*time.Hour && d%(7*24*time.Hour) == 0:
 		return fmt.Sprintf("%dw", d/(7*24*time.Hour))
 	case d >= 24*time.Hour && d%(24*time.Hour) == 0:
 		return fmt.Sprintf("%dd", d/(24*time.Hour))
 	case d >= time.Hour && d%time.Hour == 0:
 		return fmt.Sprintf("%dh", d/time.Hour)
-	default:
+	case d >= time.Minute && d%time.Minute == 0:
 		return fmt.Sprintf("%dm", d/time.Minute)
+	default:
+		return fmt.Sprintf("%ds", d/time.Second)
 	}
 }
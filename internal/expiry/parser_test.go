@@ -13,6 +13,8 @@ func TestParse(t *testing.T) {
 		input    string
 		expected time.Duration
 	}{
+		{"30s", 30 * time.Second},
+		{"45s", 45 * time.Second},
 		{"10m", 10 * time.Minute},
 		{"30m", 30 * time.Minute},
 		{"1h", 1 * time.Hour},
@@ -23,6 +25,13 @@ func TestParse(t *testing.T) {
 		{"7d", 7 * 24 * time.Hour},
 		{"1w", 7 * 24 * time.Hour},
 		{"2w", 14 * 24 * time.Hour},
+		{"1M", 30 * 24 * time.Hour},
+		{"6M", 180 * 24 * time.Hour},
+		{"1d12h", 36 * time.Hour},
+		{"2h30m", 150 * time.Minute},
+		{"1w2d", 9 * 24 * time.Hour},
+		{"1h30s", time.Hour + 30*time.Second},
+		{"never", Never},
 	}
 
 	for _, tt := range tests {
@@ -46,8 +55,16 @@ func TestParse_Errors(t *testing.T) {
 		{"negative value", "-5m"},
 		{"decimal value", "1.5h"},
 		{"spaces", "10 m"},
-		{"mixed case", "10M"},
+		{"uppercase hours", "10H"},
+		{"uppercase seconds", "30S"},
+		{"uppercase days", "1D"},
 		{"zero value", "0m"},
+		{"duplicate unit", "1d1d"},
+		{"duplicate unit apart", "1d2h3d"},
+		{"garbage token", "1h2x"},
+		{"trailing value", "1h30"},
+		{"separator", "1h 30m"},
+		{"zero token", "1h0m"},
 	}
 
 	for _, tt := range tests {
@@ -58,6 +75,100 @@ func TestParse_Errors(t *testing.T) {
 	}
 }
 
+func TestParse_Overflow(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"value overflows int64", "99999999999999999999w"},
+		{"minutes overflow after multiplication", "153722867281m"},
+		{"hours overflow after multiplication", "2562048h"},
+		{"days overflow after multiplication", "106752d"},
+		{"weeks overflow after multiplication", "15251w"},
+		{"months overflow after multiplication", "3559M"},
+		{"sum overflows", "15250w7d"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "duration too large")
+		})
+	}
+}
+
+func TestParse_MinutesAndMonthsAreDistinct(t *testing.T) {
+	minutes, err := Parse("10m")
+	require.NoError(t, err)
+	months, err := Parse("10M")
+	require.NoError(t, err)
+
+	assert.Equal(t, 10*time.Minute, minutes)
+	assert.Equal(t, 300*24*time.Hour, months)
+}
+
+func TestParseAt_EndOfDayAndWeek(t *testing.T) {
+	tz := time.FixedZone("UTC+2", 2*60*60)
+	// Wednesday 2026-03-11, 15:30 local
+	wednesday := time.Date(2026, 3, 11, 15, 30, 0, 0, tz)
+
+	tests := []struct {
+		name string
+		spec string
+		now  time.Time
+		want time.Duration
+	}{
+		{"eod", "eod", wednesday, 8*time.Hour + 30*time.Minute},
+		{"eod just after midnight", "eod", time.Date(2026, 3, 11, 0, 0, 1, 0, tz), 24*time.Hour - time.Second},
+		{"eow midweek", "eow", wednesday, 2*24*time.Hour + 8*time.Hour + 30*time.Minute},
+		{"eow on friday", "eow", time.Date(2026, 3, 13, 23, 0, 0, 0, tz), time.Hour},
+		{"eow on saturday", "eow", time.Date(2026, 3, 14, 12, 0, 0, 0, tz), 6*24*time.Hour + 12*time.Hour},
+		{"eow on sunday", "eow", time.Date(2026, 3, 15, 12, 0, 0, 0, tz), 5*24*time.Hour + 12*time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAt(tt.spec, tt.now)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseAt_UsesLocation(t *testing.T) {
+	// 22:00 UTC is already the next day in UTC+3
+	now := time.Date(2026, 3, 11, 22, 0, 0, 0, time.UTC)
+
+	inUTC, err := ParseAt("eod", now)
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Hour, inUTC)
+
+	inPlus3, err := ParseAt("eod", now.In(time.FixedZone("UTC+3", 3*60*60)))
+	require.NoError(t, err)
+	assert.Equal(t, 23*time.Hour, inPlus3)
+}
+
+func TestParseAt_DaylightSaving(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip("no timezone data:", err)
+	}
+	// Clocks go forward on 2026-03-29, so that day has 23 hours
+	now := time.Date(2026, 3, 29, 0, 0, 0, 0, paris)
+
+	got, err := ParseAt("eod", now)
+	require.NoError(t, err)
+	assert.Equal(t, 23*time.Hour, got)
+}
+
+func TestParse_LargestRepresentable(t *testing.T) {
+	result, err := Parse("15250w")
+	require.NoError(t, err)
+	assert.Equal(t, 15250*7*24*time.Hour, result)
+	assert.Positive(t, result)
+}
+
 func TestMustParse(t *testing.T) {
 	t.Run("valid input", func(t *testing.T) {
 		result := MustParse("3d")
@@ -71,6 +182,23 @@ func TestMustParse(t *testing.T) {
 	})
 }
 
+func TestCheckUnit(t *testing.T) {
+	allowed := []string{"h", "d"}
+
+	assert.NoError(t, CheckUnit("2h", allowed))
+	assert.NoError(t, CheckUnit("3d", allowed))
+	assert.NoError(t, CheckUnit("10m", nil), "empty allowed permits every unit")
+	assert.Error(t, CheckUnit("1M", []string{"m"}), "M is not m")
+	assert.NoError(t, CheckUnit("1d12h", allowed))
+	assert.Error(t, CheckUnit("2h30m", allowed), "every token is checked")
+
+	err := CheckUnit("10m", allowed)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"m"`)
+	assert.Contains(t, err.Error(), "h, d")
+	assert.Error(t, CheckUnit("1w", allowed))
+}
+
 func TestValidate(t *testing.T) {
 	min := 10 * time.Minute
 	max := 30 * 24 * time.Hour
@@ -85,6 +213,7 @@ func TestValidate(t *testing.T) {
 		{"at maximum", max, false},
 		{"below minimum", 5 * time.Minute, true},
 		{"above maximum", 31 * 24 * time.Hour, true},
+		{"never", Never, false},
 	}
 
 	for _, tt := range tests {
@@ -104,6 +233,8 @@ func TestFormat(t *testing.T) {
 		input    time.Duration
 		expected string
 	}{
+		{30 * time.Second, "30s"},
+		{90 * time.Second, "90s"},
 		{10 * time.Minute, "10m"},
 		{90 * time.Minute, "90m"},
 		{1 * time.Hour, "1h"},
@@ -112,6 +243,10 @@ func TestFormat(t *testing.T) {
 		{72 * time.Hour, "3d"},
 		{7 * 24 * time.Hour, "1w"},
 		{14 * 24 * time.Hour, "2w"},
+		{30 * 24 * time.Hour, "1M"},
+		{31 * 24 * time.Hour, "31d"},
+		{210 * 24 * time.Hour, "7M"},
+		{Never, "never"},
 	}
 
 	for _, tt := range tests {
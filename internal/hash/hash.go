@@ -0,0 +1,98 @@
+// Package hash provides content hashing with a configurable algorithm.
+//
+// Persisted digests carry an algorithm marker ("sha256:9f86d0...") so they
+// stay verifiable after the configured algorithm changes.
+package hash
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"lukechampine.com/blake3"
+)
+
+// Algorithm names a supported hash function.
+type Algorithm string
+
+// Supported algorithms.
+const (
+	SHA256 Algorithm = "sha256"
+	BLAKE3 Algorithm = "blake3"
+	SHA1   Algorithm = "sha1"
+)
+
+// Default is the algorithm used when none is configured.
+const Default = SHA256
+
+// Hasher computes marked content digests.
+type Hasher interface {
+	// Algorithm returns the algorithm this hasher uses.
+	Algorithm() Algorithm
+
+	// Sum returns the digest of content as "<algorithm>:<hex>".
+	Sum(content []byte) string
+}
+
+type hasher struct {
+	algo Algorithm
+}
+
+// New returns a Hasher for the named algorithm.
+func New(name string) (Hasher, error) {
+	algo := Algorithm(strings.ToLower(name))
+	if _, err := digest(algo, nil); err != nil {
+		return nil, err
+	}
+	return hasher{algo: algo}, nil
+}
+
+func (h hasher) Algorithm() Algorithm {
+	return h.algo
+}
+
+func (h hasher) Sum(content []byte) string {
+	sum, _ := digest(h.algo, content)
+	return string(h.algo) + ":" + sum
+}
+
+// Split separates a marked digest into its algorithm and hex value.
+func Split(marked string) (Algorithm, string, error) {
+	algo, sum, ok := strings.Cut(marked, ":")
+	if !ok || sum == "" {
+		return "", "", fmt.Errorf("hash %q has no algorithm marker", marked)
+	}
+	if _, err := digest(Algorithm(algo), nil); err != nil {
+		return "", "", err
+	}
+	return Algorithm(algo), sum, nil
+}
+
+// Verify reports whether content matches a marked digest, using whichever
+// algorithm the digest was produced with.
+func Verify(marked string, content []byte) (bool, error) {
+	algo, want, err := Split(marked)
+	if err != nil {
+		return false, err
+	}
+	got, _ := digest(algo, content)
+	return got == want, nil
+}
+
+func digest(algo Algorithm, content []byte) (string, error) {
+	switch algo {
+	case SHA256:
+		sum := sha256.Sum256(content)
+		return hex.EncodeToString(sum[:]), nil
+	case BLAKE3:
+		sum := blake3.Sum256(content)
+		return hex.EncodeToString(sum[:]), nil
+	case SHA1:
+		sum := sha1.Sum(content)
+		return hex.EncodeToString(sum[:]), nil
+	default:
+		return "", fmt.Errorf("unsupported hash algorithm %q (expected sha256, blake3 or sha1)", algo)
+	}
+}
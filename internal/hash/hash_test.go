@@ -0,0 +1,69 @@
+package hash
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_Algorithms(t *testing.T) {
+	content := []byte("hello world")
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"sha256", "sha256:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"},
+		{"blake3", "blake3:d74981efa70a0c880b8d8c1985d075dbcbf679b99a5f9914e5aaf96b831a9e24"},
+		{"sha1", "sha1:2aae6c35c94fcfb415dbe95f408b9ce91ee846ed"},
+		{"SHA256", "sha256:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := New(tt.name)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, h.Sum(content))
+		})
+	}
+}
+
+func TestNew_Unsupported(t *testing.T) {
+	for _, name := range []string{"", "md5", "sha512"} {
+		_, err := New(name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestVerify_MixedMarkers(t *testing.T) {
+	content := []byte("stored under different algorithms")
+
+	for _, algo := range []Algorithm{SHA256, BLAKE3, SHA1} {
+		t.Run(string(algo), func(t *testing.T) {
+			h, err := New(string(algo))
+			require.NoError(t, err)
+			marked := h.Sum(content)
+
+			ok, err := Verify(marked, content)
+			require.NoError(t, err)
+			assert.True(t, ok)
+
+			ok, err = Verify(marked, []byte("tampered"))
+			require.NoError(t, err)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	algo, sum, err := Split("blake3:abcd")
+	require.NoError(t, err)
+	assert.Equal(t, BLAKE3, algo)
+	assert.Equal(t, "abcd", sum)
+
+	for _, bad := range []string{"abcd", "sha256:", "md5:abcd"} {
+		_, _, err := Split(bad)
+		assert.Error(t, err, bad)
+	}
+}
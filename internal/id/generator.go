@@ -2,29 +2,67 @@
 package id
 
 import (
+	"fmt"
+	"strings"
+
 	gonanoid "github.com/matoous/go-nanoid/v2"
 )
 
 const (
-	// Length is the number of characters in a generated ID.
+	// Length is the default number of characters in a generated ID.
 	Length = 12
 
-	// Alphabet is base62: 0-9, A-Z, a-z for URL-safe IDs.
+	// MinLength is the shortest configurable ID length.
+	MinLength = 4
+
+	// Alphabet is the default alphabet, base62: 0-9, A-Z, a-z for URL-safe IDs.
 	Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+	// MinCustomLength and MaxCustomLength bound a caller-chosen ID.
+	MinCustomLength = 3
+	MaxCustomLength = 64
 )
 
 // Generator creates unique snippet IDs.
-type Generator struct{}
+type Generator struct {
+	length   int
+	alphabet string
+}
 
-// New creates a new ID generator.
-func New() *Generator {
-	return &Generator{}
+// New creates an ID generator for IDs of length characters drawn from
+// alphabet. A zero length or empty alphabet uses Length or Alphabet.
+// Lengths run from MinLength to MaxCustomLength, and the alphabet must be
+// at least two distinct characters that are also valid in custom IDs, so
+// every generated ID is one.
+func New(length int, alphabet string) (*Generator, error) {
+	if length == 0 {
+		length = Length
+	}
+	if alphabet == "" {
+		alphabet = Alphabet
+	}
+
+	if length < MinLength || length > MaxCustomLength {
+		return nil, fmt.Errorf("length must be between %d and %d", MinLength, MaxCustomLength)
+	}
+	if len(alphabet) < 2 {
+		return nil, fmt.Errorf("alphabet needs at least 2 characters")
+	}
+	for i, c := range alphabet {
+		if !isCustomChar(c) {
+			return nil, fmt.Errorf("alphabet character %q is not a letter, digit, '-' or '_'", c)
+		}
+		if strings.ContainsRune(alphabet[:i], c) {
+			return nil, fmt.Errorf("alphabet contains %q more than once", c)
+		}
+	}
+	return &Generator{length: length, alphabet: alphabet}, nil
 }
 
-// Generate creates a new unique ID.
-// Returns a 12-character base62 string with ~71 bits of entropy.
+// Generate creates a new unique ID. With the defaults it returns a
+// 12-character base62 string with ~71 bits of entropy.
 func (g *Generator) Generate() (string, error) {
-	return gonanoid.Generate(Alphabet, Length)
+	return gonanoid.Generate(g.alphabet, g.length)
 }
 
 // MustGenerate creates a new unique ID, panicking on error.
@@ -37,7 +75,21 @@ func (g *Generator) MustGenerate() string {
 	return id
 }
 
-// IsValid checks if a string is a valid snippet ID.
+// IsValid checks if a string could have been generated by g.
+func (g *Generator) IsValid(id string) bool {
+	if len(id) != g.length {
+		return false
+	}
+	for _, c := range id {
+		if !strings.ContainsRune(g.alphabet, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsValid checks if a string is a valid snippet ID with the default length
+// and alphabet.
 func IsValid(id string) bool {
 	if len(id) != Length {
 		return false
@@ -50,6 +102,25 @@ func IsValid(id string) bool {
 	return true
 }
 
+// IsValidCustom checks if a string is a valid caller-chosen snippet ID:
+// 3-64 characters of base62, '-' or '_'. Every generated ID is also a
+// valid custom ID.
+func IsValidCustom(id string) bool {
+	if len(id) < MinCustomLength || len(id) > MaxCustomLength {
+		return false
+	}
+	for _, c := range id {
+		if !isCustomChar(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func isCustomChar(c rune) bool {
+	return isBase62(c) || c == '-' || c == '_'
+}
+
 func isBase62(c rune) bool {
 	return (c >= '0' && c <= '9') ||
 		(c >= 'A' && c <= 'Z') ||
@@ -1,6 +1,7 @@
 package id
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -8,7 +9,8 @@ import (
 )
 
 func TestGenerator_Generate(t *testing.T) {
-	gen := New()
+	gen, err := New(0, "")
+	require.NoError(t, err)
 
 	id, err := gen.Generate()
 	require.NoError(t, err)
@@ -18,7 +20,8 @@ func TestGenerator_Generate(t *testing.T) {
 }
 
 func TestGenerator_Generate_Uniqueness(t *testing.T) {
-	gen := New()
+	gen, err := New(0, "")
+	require.NoError(t, err)
 	seen := make(map[string]bool)
 
 	// Generate 1000 IDs and ensure no duplicates
@@ -31,7 +34,8 @@ func TestGenerator_Generate_Uniqueness(t *testing.T) {
 }
 
 func TestGenerator_MustGenerate(t *testing.T) {
-	gen := New()
+	gen, err := New(0, "")
+	require.NoError(t, err)
 
 	// Should not panic
 	id := gen.MustGenerate()
@@ -65,8 +69,73 @@ func TestIsValid(t *testing.T) {
 	}
 }
 
+func TestNew_Configured(t *testing.T) {
+	gen, err := New(6, "abc-_")
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		id, err := gen.Generate()
+		require.NoError(t, err)
+		assert.Len(t, id, 6)
+		assert.True(t, gen.IsValid(id), id)
+		assert.True(t, IsValidCustom(id), id)
+	}
+	assert.False(t, gen.IsValid("abcabcd"), "wrong length")
+	assert.False(t, gen.IsValid("abcabd"), "outside the alphabet")
+	assert.False(t, gen.IsValid("abc123XYZ789"), "default IDs are not valid for a configured generator")
+}
+
+func TestNew_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		length   int
+		alphabet string
+	}{
+		{"too short", 3, ""},
+		{"too long", MaxCustomLength + 1, ""},
+		{"duplicate characters", 0, "abca"},
+		{"single character", 0, "a"},
+		{"not URL safe", 0, "abc/"},
+		{"non-ascii", 0, "abcé"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.length, tt.alphabet)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestIsValidCustom(t *testing.T) {
+	tests := []struct {
+		name  string
+		id    string
+		valid bool
+	}{
+		{"generated style", "abc123XYZ789", true},
+		{"dashes and underscores", "my-release_notes", true},
+		{"minimum length", "abc", true},
+		{"maximum length", strings.Repeat("a", MaxCustomLength), true},
+		{"too short", "ab", false},
+		{"too long", strings.Repeat("a", MaxCustomLength+1), false},
+		{"empty", "", false},
+		{"contains dot", "release.notes", false},
+		{"contains slash", "release/notes", false},
+		{"contains space", "release notes", false},
+		{"non-ascii", "notes-é", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.valid, IsValidCustom(tt.id))
+		})
+	}
+}
+
 func BenchmarkGenerate(b *testing.B) {
-	gen := New()
+	gen, err := New(0, "")
+	require.NoError(b, err)
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		_, _ = gen.Generate()
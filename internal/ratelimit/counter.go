@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RedisCounter implements httprate.LimitCounter on top of Redis. Each
+// window of each key is one Redis counter that expires once the limiter
+// no longer looks at it.
+type RedisCounter struct {
+	client *RedisClient
+	prefix string
+
+	mu     sync.Mutex
+	window time.Duration
+}
+
+// NewRedisCounter returns a counter whose keys start with prefix, which
+// keeps limiters that count the same clients separate.
+func NewRedisCounter(client *RedisClient, prefix string) *RedisCounter {
+	return &RedisCounter{client: client, prefix: prefix, window: time.Minute}
+}
+
+// Config is called by the limiter with its limit and window.
+func (c *RedisCounter) Config(requestLimit int, windowLength time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.window = windowLength
+}
+
+// Increment counts one request for key in currentWindow.
+func (c *RedisCounter) Increment(key string, currentWindow time.Time) error {
+	return c.IncrementBy(key, currentWindow, 1)
+}
+
+// IncrementBy counts amount requests for key in currentWindow.
+func (c *RedisCounter) IncrementBy(key string, currentWindow time.Time, amount int) error {
+	c.mu.Lock()
+	window := c.window
+	c.mu.Unlock()
+
+	// The limiter reads the current and the previous window, so a counter
+	// is needed for two windows after the one it counts
+	ttl := max(int((3 * window).Seconds()), 1)
+	k := c.key(key, currentWindow)
+	_, err := c.client.Pipeline(
+		[]string{"INCRBY", k, strconv.Itoa(amount)},
+		[]string{"EXPIRE", k, strconv.Itoa(ttl)},
+	)
+	return err
+}
+
+// Get returns the counts for key in currentWindow and previousWindow.
+func (c *RedisCounter) Get(key string, currentWindow, previousWindow time.Time) (int, int, error) {
+	replies, err := c.client.Pipeline([]string{"MGET", c.key(key, currentWindow), c.key(key, previousWindow)})
+	if err != nil {
+		return 0, 0, err
+	}
+	values, ok := replies[0].([]any)
+	if !ok || len(values) != 2 {
+		return 0, 0, fmt.Errorf("redis: unexpected MGET reply %v", replies[0])
+	}
+
+	var counts [2]int
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+		s, ok := v.(string)
+		if !ok {
+			return 0, 0, fmt.Errorf("redis: unexpected counter value %v", v)
+		}
+		if counts[i], err = strconv.Atoi(s); err != nil {
+			return 0, 0, fmt.Errorf("redis: counter value %q: %w", s, err)
+		}
+	}
+	return counts[0], counts[1], nil
+}
+
+func (c *RedisCounter) key(key string, window time.Time) string {
+	return c.prefix + ":" + key + ":" + strconv.FormatInt(window.Unix(), 10)
+}
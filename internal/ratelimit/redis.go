@@ -0,0 +1,248 @@
+// Package ratelimit provides a Redis-backed counter for the httprate
+// limiter, so that replicas behind a load balancer share one limit.
+package ratelimit
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redisPoolSize is how many idle connections a RedisClient keeps.
+const redisPoolSize = 16
+
+// RedisError is an error reply from the server.
+type RedisError string
+
+func (e RedisError) Error() string { return "redis: " + string(e) }
+
+// RedisClient is a minimal Redis client speaking RESP over a small pool of
+// connections. It supports only what RedisCounter needs: pipelined
+// commands with integer, string, nil and array replies.
+type RedisClient struct {
+	addr     string
+	username string
+	password string
+	db       int
+	tls      *tls.Config // nil for plain TCP
+	timeout  time.Duration
+	conns    chan *redisConn
+}
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// NewRedisClient returns a client for a redis:// or rediss:// URL such as
+// redis://:password@localhost:6379/0. It does not connect until the first
+// command.
+func NewRedisClient(rawURL string) (*RedisClient, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing redis URL: %w", err)
+	}
+
+	c := &RedisClient{
+		addr:    u.Host,
+		timeout: time.Second,
+		conns:   make(chan *redisConn, redisPoolSize),
+	}
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		c.tls = &tls.Config{ServerName: u.Hostname()}
+	default:
+		return nil, fmt.Errorf("redis URL must start with redis:// or rediss://, got %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, errors.New("redis URL has no host")
+	}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil || c.db < 0 {
+			return nil, fmt.Errorf("redis URL database must be a number, got %q", db)
+		}
+	}
+	return c, nil
+}
+
+// Pipeline sends cmds in one round trip and returns their replies in
+// order. A reply that is a Redis error fails the whole call.
+func (c *RedisClient) Pipeline(cmds ...[]string) ([]any, error) {
+	conn, err := c.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	replies, err := conn.roundTrip(c.timeout, cmds)
+	var redisErr RedisError
+	if err != nil && !errors.As(err, &redisErr) {
+		// The connection is in an unknown state
+		conn.Close()
+		return nil, err
+	}
+	c.release(conn)
+	return replies, err
+}
+
+// Close closes the idle connections.
+func (c *RedisClient) Close() {
+	for {
+		select {
+		case conn := <-c.conns:
+			conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// conn returns an idle connection, or dials, authenticates and selects
+// the database on a new one.
+func (c *RedisClient) conn() (*redisConn, error) {
+	select {
+	case conn := <-c.conns:
+		return conn, nil
+	default:
+	}
+
+	dialer := &net.Dialer{Timeout: c.timeout}
+	var nc net.Conn
+	var err error
+	if c.tls != nil {
+		nc, err = tls.DialWithDialer(dialer, "tcp", c.addr, c.tls)
+	} else {
+		nc, err = dialer.Dial("tcp", c.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	conn := &redisConn{Conn: nc, r: bufio.NewReader(nc)}
+
+	var setup [][]string
+	switch {
+	case c.username != "" && c.password != "":
+		setup = append(setup, []string{"AUTH", c.username, c.password})
+	case c.password != "":
+		setup = append(setup, []string{"AUTH", c.password})
+	}
+	if c.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.db)})
+	}
+	if len(setup) > 0 {
+		if _, err := conn.roundTrip(c.timeout, setup); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// release returns conn to the pool, or closes it if the pool is full.
+func (c *RedisClient) release(conn *redisConn) {
+	select {
+	case c.conns <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+// roundTrip writes cmds and reads one reply per command. Every reply is
+// read even after an error reply, so the connection stays usable.
+func (conn *redisConn) roundTrip(timeout time.Duration, cmds [][]string) ([]any, error) {
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	var b strings.Builder
+	for _, cmd := range cmds {
+		fmt.Fprintf(&b, "*%d\r\n", len(cmd))
+		for _, arg := range cmd {
+			fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	if _, err := io.WriteString(conn, b.String()); err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+
+	replies := make([]any, len(cmds))
+	var replyErr error
+	for i := range cmds {
+		reply, err := readReply(conn.r)
+		var redisErr RedisError
+		switch {
+		case errors.As(err, &redisErr):
+			if replyErr == nil {
+				replyErr = err
+			}
+		case err != nil:
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		replies[i] = reply
+	}
+	return replies, replyErr
+}
+
+// readReply reads one RESP reply: a string, an int64, nil, a []any or a
+// RedisError.
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, RedisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("malformed bulk length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("malformed array length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unknown reply type %q", kind)
+	}
+}
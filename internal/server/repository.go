@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/config"
+	"github.com/rayenfassatoui/tafcha-cli/internal/storage"
+)
+
+// repository is the storage backend along with its schema management.
+type repository interface {
+	storage.Repository
+	Migrate(ctx context.Context) error
+	MigrateDown(ctx context.Context, n int) error
+	Ping(ctx context.Context) error
+}
+
+// database is a repository that can hold the metadata of an S3Repository.
+type database interface {
+	repository
+	storage.MetadataRepository
+}
+
+// openRepository picks the storage backend. With STORAGE_BACKEND=s3 content
+// goes to the bucket and the database below only holds metadata.
+func openRepository(ctx context.Context, cfg *config.Config, logger *slog.Logger) (repository, error) {
+	repo, err := openDatabase(ctx, cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.StorageBackend != config.StorageS3 {
+		return repo, nil
+	}
+
+	logger.Info("storing content in s3", "endpoint", cfg.S3Endpoint, "bucket", cfg.S3Bucket)
+	objects := storage.NewS3Client(storage.S3Config{
+		Endpoint:        cfg.S3Endpoint,
+		Bucket:          cfg.S3Bucket,
+		Region:          cfg.S3Region,
+		AccessKeyID:     cfg.S3AccessKeyID,
+		SecretAccessKey: cfg.S3SecretAccessKey,
+	})
+	return s3Repository{
+		S3Repository: storage.NewS3Repository(repo, objects, logger),
+		meta:         repo,
+	}, nil
+}
+
+// s3Repository adds the metadata database's schema management and health
+// check to an S3Repository.
+type s3Repository struct {
+	*storage.S3Repository
+	meta repository
+}
+
+func (r s3Repository) Migrate(ctx context.Context) error { return r.meta.Migrate(ctx) }
+
+func (r s3Repository) MigrateDown(ctx context.Context, n int) error {
+	return r.meta.MigrateDown(ctx, n)
+}
+
+func (r s3Repository) Ping(ctx context.Context) error { return r.meta.Ping(ctx) }
+
+// openDatabase picks the database from the DATABASE_URL scheme:
+// memory:// keeps snippets in process memory, sqlite://path opens a local
+// SQLite database, anything else is handed to PostgreSQL.
+func openDatabase(ctx context.Context, cfg *config.Config, logger *slog.Logger) (database, error) {
+	if storage.IsMemoryURL(cfg.DatabaseURL) {
+		logger.Warn("using in-memory storage; snippets are lost on restart")
+		return storage.NewMemoryRepository(logger), nil
+	}
+	if storage.IsSQLiteURL(cfg.DatabaseURL) {
+		logger.Info("using sqlite storage")
+		return storage.NewSQLiteRepository(ctx, cfg.DatabaseURL, logger)
+	}
+	return storage.NewPostgresRepository(ctx, storage.PostgresConfig{
+		URL:         cfg.DatabaseURL,
+		MaxConns:    int32(cfg.MaxDBConns),
+		MinConns:    int32(cfg.MinDBConns),
+		MaxConnLife: cfg.DBConnMaxLife,
+
+		CompressContent: cfg.CompressStorage,
+	}, logger)
+}
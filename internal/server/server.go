@@ -0,0 +1,185 @@
+// Package server starts the Tafcha API server. It is shared by the
+// tafcha-server binary and the "tafcha server" subcommand.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/api"
+	"github.com/rayenfassatoui/tafcha-cli/internal/config"
+	"github.com/rayenfassatoui/tafcha-cli/internal/storage"
+	"github.com/rayenfassatoui/tafcha-cli/internal/tracing"
+)
+
+// drainLogInterval is how often the in-flight request count is logged while
+// shutting down.
+var drainLogInterval = time.Second
+
+// Run serves the API described by cfg until ctx is cancelled, then shuts
+// down gracefully within cfg.ShutdownTimeout. It opens and migrates the
+// database and runs the cleanup worker alongside the HTTP server.
+func Run(ctx context.Context, cfg *config.Config, logger *slog.Logger) error {
+	logger.Info("starting tafcha server",
+		"host", cfg.Host,
+		"port", cfg.Port,
+		"base_url", cfg.BaseURL,
+	)
+
+	// Tracing is optional; spans go to the configured OTLP endpoint
+	if cfg.TracingEndpoint != "" {
+		tp, err := tracing.Setup(ctx, cfg.TracingEndpoint, cfg.TraceSampleRate)
+		if err != nil {
+			return fmt.Errorf("setting up tracing: %w", err)
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			tp.Shutdown(ctx)
+		}()
+		logger.Info("tracing enabled", "endpoint", cfg.TracingEndpoint, "sample_rate", cfg.TraceSampleRate)
+	}
+
+	// Initialize database
+	repo, err := openRepository(ctx, cfg, logger)
+	if err != nil {
+		return fmt.Errorf("connecting to database: %w", err)
+	}
+	defer repo.Close()
+
+	// Run migrations
+	if err := repo.Migrate(ctx); err != nil {
+		return fmt.Errorf("running migrations: %w", err)
+	}
+
+	// Time storage operations when metrics are served
+	served := storage.Repository(repo)
+	if cfg.MetricsEnabled {
+		served = storage.NewInstrumentedRepository(repo, storage.NewDBMetrics(prometheus.DefaultRegisterer))
+	}
+
+	// Create API server
+	server := api.NewServer(cfg, served, logger)
+
+	// Object storage takes uploads straight from clients
+	if uploader, ok := repo.(storage.Uploader); ok {
+		server.SetUploader(uploader)
+	}
+
+	// Start cleanup worker unless another replica runs it; it is stopped
+	// explicitly after the HTTP server
+	if cfg.CleanupEnabled {
+		cleanupWorker := api.NewCleanupWorker(served, api.CleanupConfig{
+			Interval:   cfg.CleanupInterval,
+			IdleExpiry: cfg.IdleExpiry,
+			MinAge:     cfg.MinExpiry,
+
+			BatchSize:   cfg.CleanupBatchSize,
+			Concurrency: cfg.CleanupConcurrency,
+			LogBatches:  cfg.CleanupLogBatches,
+			Jitter:      cfg.CleanupJitter,
+		}, logger)
+		server.SetCleanupWorker(cleanupWorker)
+		cleanupWorker.Start(context.WithoutCancel(ctx))
+		defer cleanupWorker.Stop()
+	} else {
+		logger.Info("cleanup worker disabled")
+	}
+
+	// TLS policy, used when TLS_CERT_FILE and TLS_KEY_FILE are set; values
+	// were validated when the configuration was loaded
+	tlsConfig, err := cfg.TLSConfig()
+	if err != nil {
+		return fmt.Errorf("invalid TLS configuration: %w", err)
+	}
+
+	// Configure HTTP server
+	httpServer := &http.Server{
+		Handler:      server.Handler(),
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  120 * time.Second,
+		TLSConfig:    tlsConfig,
+	}
+	httpServer.RegisterOnShutdown(server.CloseStreams)
+
+	ln, err := net.Listen("tcp", cfg.Addr())
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", cfg.Addr(), err)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if cfg.TLSEnabled() {
+			logger.Info("server listening", "addr", ln.Addr().String(), "tls", true)
+			serveErr <- httpServer.ServeTLS(ln, cfg.TLSCertFile, cfg.TLSKeyFile)
+			return
+		}
+		logger.Info("server listening", "addr", ln.Addr().String())
+		serveErr <- httpServer.Serve(ln)
+	}()
+
+	// Wait for the caller to stop us, or for the server to fail
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("serving: %w", err)
+	case <-ctx.Done():
+	}
+
+	logger.Info("shutting down server...", "active_requests", server.ActiveRequests())
+
+	// Graceful shutdown with timeout
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := drain(shutdownCtx, httpServer, server, logger); err != nil {
+		return fmt.Errorf("server forced to shutdown: %w", err)
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serving: %w", err)
+	}
+
+	logger.Info("server stopped gracefully")
+	return nil
+}
+
+// drain stops accepting connections and waits for in-flight requests,
+// logging how many are left every drainLogInterval. It returns as soon as
+// they are done, or with ctx's error once the grace period runs out.
+func drain(ctx context.Context, httpServer *http.Server, server *api.Server, logger *slog.Logger) error {
+	done := make(chan error, 1)
+	go func() { done <- httpServer.Shutdown(ctx) }()
+
+	ticker := time.NewTicker(drainLogInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-ticker.C:
+			logger.Info("waiting for in-flight requests", "active_requests", server.ActiveRequests())
+		}
+	}
+}
+
+// MigrateDown rolls back the last n database migrations of the configured
+// database.
+func MigrateDown(ctx context.Context, cfg *config.Config, logger *slog.Logger, n int) error {
+	repo, err := openRepository(ctx, cfg, logger)
+	if err != nil {
+		return fmt.Errorf("connecting to database: %w", err)
+	}
+	defer repo.Close()
+
+	if err := repo.MigrateDown(ctx, n); err != nil {
+		return fmt.Errorf("rolling back migrations: %w", err)
+	}
+	return nil
+}
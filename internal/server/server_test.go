@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rayenfassatoui/tafcha-cli/internal/api"
+	"github.com/rayenfassatoui/tafcha-cli/internal/config"
+	"github.com/rayenfassatoui/tafcha-cli/internal/storage"
+)
+
+func testConfig() *config.Config {
+	return &config.Config{
+		Host:            "127.0.0.1",
+		DatabaseURL:     "memory://",
+		BaseURL:         "http://tafcha.test",
+		MaxContentSize:  1024,
+		DefaultExpiry:   time.Hour,
+		MinExpiry:       time.Minute,
+		MaxExpiry:       24 * time.Hour,
+		PostRateLimit:   10,
+		GetRateLimit:    10,
+		ContentHashAlgo: "sha256",
+		CleanupInterval: time.Hour,
+		ShutdownTimeout: time.Second,
+		TLSMinVersion:   "1.2",
+	}
+}
+
+func TestRun_StopsWhenCancelled(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() { done <- Run(ctx, testConfig(), logger) }()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancellation")
+	}
+}
+
+func TestRun_AddressInUse(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	cfg := testConfig()
+	cfg.Port = ln.Addr().(*net.TCPAddr).Port
+
+	err = Run(context.Background(), cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "listening on")
+}
+
+func TestDrain_ReturnsWhenRequestsFinish(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	srv := api.NewServer(testConfig(), storage.NewMemoryRepository(logger), logger)
+
+	// A request that is still running when shutdown begins
+	started, release := make(chan struct{}), make(chan struct{})
+	httpServer := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go httpServer.Serve(ln)
+
+	go http.Get("http://" + ln.Addr().String())
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- drain(ctx, httpServer, srv, logger) }()
+
+	select {
+	case <-done:
+		t.Fatal("drain returned while a request was in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("drain kept waiting after the request finished")
+	}
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// compressContent gzips content for storage. It returns content unchanged
+// and false when compression is disabled or would not make it smaller.
+func compressContent(content []byte, enabled bool) ([]byte, bool, error) {
+	if !enabled || len(content) == 0 {
+		return content, false, nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(content); err != nil {
+		return nil, false, fmt.Errorf("compressing content: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, false, fmt.Errorf("compressing content: %w", err)
+	}
+
+	if buf.Len() >= len(content) {
+		return content, false, nil
+	}
+	return buf.Bytes(), true, nil
+}
+
+// decompressContent returns the original content of a stored row.
+func decompressContent(stored []byte, compressed bool) ([]byte, error) {
+	if !compressed {
+		return stored, nil
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(stored))
+	if err != nil {
+		return nil, fmt.Errorf("decompressing content: %w", err)
+	}
+	defer zr.Close()
+
+	content, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing content: %w", err)
+	}
+	return content, nil
+}
+
+// gzipSize reads the uncompressed size from the trailer of a gzip stream,
+// i.e. its last four bytes. The trailer holds the size modulo 2^32, which
+// is exact for anything under MAX_CONTENT_SIZE.
+func gzipSize(trailer []byte) int64 {
+	if len(trailer) < 4 {
+		return 0
+	}
+	return int64(binary.LittleEndian.Uint32(trailer[len(trailer)-4:]))
+}
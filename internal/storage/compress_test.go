@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressContent_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name           string
+		content        []byte
+		enabled        bool
+		wantCompressed bool
+	}{
+		{"repetitive text", []byte(strings.Repeat("log line 42\n", 200)), true, true},
+		{"too small to gain", []byte("hi"), true, false},
+		{"empty", []byte{}, true, false},
+		{"disabled", []byte(strings.Repeat("log line 42\n", 200)), false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored, compressed, err := compressContent(tt.content, tt.enabled)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCompressed, compressed)
+			if compressed {
+				assert.Less(t, len(stored), len(tt.content))
+				assert.Equal(t, int64(len(tt.content)), gzipSize(stored[len(stored)-4:]))
+			} else {
+				assert.Equal(t, tt.content, stored)
+			}
+
+			content, err := decompressContent(stored, compressed)
+			require.NoError(t, err)
+			assert.Equal(t, tt.content, content)
+		})
+	}
+}
+
+func TestDecompressContent_Corrupt(t *testing.T) {
+	_, err := decompressContent([]byte("not gzip"), true)
+	assert.Error(t, err)
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// checkCancelledContext checks that repo gives up on calls whose context
+// is already cancelled, as when the client of a request went away.
+func checkCancelledContext(t *testing.T, repo Repository) {
+	t.Helper()
+
+	_, err := repo.Create(context.Background(), &Snippet{ID: "kept", Content: []byte("x"), ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = repo.Create(ctx, &Snippet{ID: "abandoned", Content: []byte("x"), ExpiresAt: time.Now().Add(time.Hour)})
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = repo.Get(ctx, "kept")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, repo.Delete(ctx, "kept"), context.Canceled)
+
+	// Nothing was changed or counted
+	s, err := repo.Peek(context.Background(), "kept")
+	require.NoError(t, err)
+	require.NotNil(t, s)
+	assert.Zero(t, s.ViewCount)
+	s, err = repo.Peek(context.Background(), "abandoned")
+	require.NoError(t, err)
+	assert.Nil(t, s)
+}
+
+func TestSQLite_CancelledContext(t *testing.T) {
+	checkCancelledContext(t, newTestSQLite(t))
+}
+
+func TestPostgres_CancelledContext(t *testing.T) {
+	repo := newTestPostgres(t)
+	require.NoError(t, repo.Migrate(context.Background()))
+	checkCancelledContext(t, repo)
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// checkGetOrSetIdempotent runs the GetOrSetIdempotent contract against repo.
+func checkGetOrSetIdempotent(t *testing.T, repo Repository) {
+	t.Helper()
+
+	// Concurrent requests with the same key resolve to one snippet
+	var creates atomic.Int32
+	create := func() ([]byte, error) {
+		id := fmt.Sprintf("retried-%d", creates.Add(1))
+		time.Sleep(20 * time.Millisecond) // let the other callers pile up
+		if _, err := repo.Create(context.Background(), &Snippet{ID: id, Content: []byte("x"), ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+			return nil, err
+		}
+		return []byte(id), nil
+	}
+
+	const callers = 8
+	results := make([]string, callers)
+	var replays atomic.Int32
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, replayed, err := repo.GetOrSetIdempotent(context.Background(), "key", time.Hour, create)
+			assert.NoError(t, err)
+			results[i] = string(result)
+			if replayed {
+				replays.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.EqualValues(t, 1, creates.Load())
+	assert.EqualValues(t, callers-1, replays.Load())
+	for _, result := range results {
+		assert.Equal(t, "retried-1", result)
+	}
+
+	returns := func(result string) func() ([]byte, error) {
+		return func() ([]byte, error) { return []byte(result), nil }
+	}
+
+	// A failed create stores nothing, so the next caller runs its own
+	_, _, err := repo.GetOrSetIdempotent(context.Background(), "fails", time.Hour, func() ([]byte, error) {
+		return nil, errors.New("boom")
+	})
+	require.Error(t, err)
+	result, replayed, err := repo.GetOrSetIdempotent(context.Background(), "fails", time.Hour, returns("second"))
+	require.NoError(t, err)
+	assert.False(t, replayed)
+	assert.Equal(t, "second", string(result))
+
+	// Keys are scoped to the tenant
+	result, replayed, err = repo.WithTenant("acme").GetOrSetIdempotent(context.Background(), "key", time.Hour, returns("acme"))
+	require.NoError(t, err)
+	assert.False(t, replayed)
+	assert.Equal(t, "acme", string(result))
+
+	// An expired key runs create again, also after cleanup removed it
+	_, _, err = repo.GetOrSetIdempotent(context.Background(), "short", time.Millisecond, returns("first"))
+	require.NoError(t, err)
+	_, _, err = repo.GetOrSetIdempotent(context.Background(), "cleaned", time.Millisecond, returns("first"))
+	require.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+
+	result, replayed, err = repo.GetOrSetIdempotent(context.Background(), "short", time.Hour, returns("again"))
+	require.NoError(t, err)
+	assert.False(t, replayed)
+	assert.Equal(t, "again", string(result))
+
+	_, err = repo.DeleteExpired(context.Background())
+	require.NoError(t, err)
+	result, replayed, err = repo.GetOrSetIdempotent(context.Background(), "cleaned", time.Hour, returns("again"))
+	require.NoError(t, err)
+	assert.False(t, replayed)
+	assert.Equal(t, "again", string(result))
+
+	result, replayed, err = repo.GetOrSetIdempotent(context.Background(), "short", time.Hour, returns("third"))
+	require.NoError(t, err)
+	assert.True(t, replayed, "cleanup keeps live keys")
+	assert.Equal(t, "again", string(result))
+}
+
+func TestMemory_GetOrSetIdempotent(t *testing.T) {
+	checkGetOrSetIdempotent(t, newTestMemory())
+}
+
+func TestMemory_GetOrSetIdempotentWaitHonorsContext(t *testing.T) {
+	repo := newTestMemory()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	go repo.GetOrSetIdempotent(context.Background(), "key", time.Hour, func() ([]byte, error) {
+		close(started)
+		<-release
+		return []byte("late"), nil
+	})
+	defer close(release)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, _, err := repo.GetOrSetIdempotent(ctx, "key", time.Hour, func() ([]byte, error) {
+		t.Error("the key is already being created")
+		return nil, nil
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestSQLite_GetOrSetIdempotent(t *testing.T) {
+	checkGetOrSetIdempotent(t, newTestSQLite(t))
+}
+
+func TestPostgres_GetOrSetIdempotent(t *testing.T) {
+	repo := newTestPostgres(t)
+	require.NoError(t, repo.Migrate(context.Background()))
+	checkGetOrSetIdempotent(t, repo)
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DBMetrics holds the storage latency histograms.
+type DBMetrics struct {
+	duration *prometheus.HistogramVec
+}
+
+// NewDBMetrics creates the storage metrics and registers them with reg.
+func NewDBMetrics(reg prometheus.Registerer) *DBMetrics {
+	m := &DBMetrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "tafcha",
+			Subsystem: "db",
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of storage operations, by repository method.",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"operation"}),
+	}
+	reg.MustRegister(m.duration)
+	return m
+}
+
+// InstrumentedRepository wraps a Repository and records how long each
+// operation takes, so database latency can be told apart from the rest of
+// the request.
+type InstrumentedRepository struct {
+	next    Repository
+	metrics *DBMetrics
+}
+
+// NewInstrumentedRepository wraps next, observing into metrics.
+func NewInstrumentedRepository(next Repository, metrics *DBMetrics) *InstrumentedRepository {
+	return &InstrumentedRepository{next: next, metrics: metrics}
+}
+
+// observe records the time since start for operation.
+func (r *InstrumentedRepository) observe(operation string, start time.Time) {
+	r.metrics.duration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// WithTenant returns the wrapped repository's tenant view, still instrumented.
+func (r *InstrumentedRepository) WithTenant(tenant string) Repository {
+	return &InstrumentedRepository{next: r.next.WithTenant(tenant), metrics: r.metrics}
+}
+
+func (r *InstrumentedRepository) Create(ctx context.Context, snippet *Snippet) (*Snippet, error) {
+	defer r.observe("create", time.Now())
+	return r.next.Create(ctx, snippet)
+}
+
+func (r *InstrumentedRepository) CreateWithTimestamps(ctx context.Context, snippet *Snippet) (*Snippet, error) {
+	defer r.observe("create_with_timestamps", time.Now())
+	return r.next.CreateWithTimestamps(ctx, snippet)
+}
+
+func (r *InstrumentedRepository) Upsert(ctx context.Context, id string, content []byte, expiresAt time.Time) (*Snippet, error) {
+	defer r.observe("upsert", time.Now())
+	return r.next.Upsert(ctx, id, content, expiresAt)
+}
+
+func (r *InstrumentedRepository) Get(ctx context.Context, id string) (*Snippet, error) {
+	defer r.observe("get", time.Now())
+	return r.next.Get(ctx, id)
+}
+
+func (r *InstrumentedRepository) Peek(ctx context.Context, id string) (*Snippet, error) {
+	defer r.observe("peek", time.Now())
+	return r.next.Peek(ctx, id)
+}
+
+func (r *InstrumentedRepository) GetMeta(ctx context.Context, id string) (*SnippetMeta, error) {
+	defer r.observe("get_meta", time.Now())
+	return r.next.GetMeta(ctx, id)
+}
+
+func (r *InstrumentedRepository) FindByContent(ctx context.Context, creator, contentHash string) (*Snippet, error) {
+	defer r.observe("find_by_content", time.Now())
+	return r.next.FindByContent(ctx, creator, contentHash)
+}
+
+func (r *InstrumentedRepository) Stats(ctx context.Context) (*Stats, error) {
+	defer r.observe("stats", time.Now())
+	return r.next.Stats(ctx)
+}
+
+func (r *InstrumentedRepository) Append(ctx context.Context, id string, req AppendRequest) (*Snippet, error) {
+	defer r.observe("append", time.Now())
+	return r.next.Append(ctx, id, req)
+}
+
+func (r *InstrumentedRepository) Extend(ctx context.Context, id string, newExpiry time.Time) error {
+	defer r.observe("extend", time.Now())
+	return r.next.Extend(ctx, id, newExpiry)
+}
+
+func (r *InstrumentedRepository) GetOrSetIdempotent(ctx context.Context, key string, ttl time.Duration, create func() ([]byte, error)) ([]byte, bool, error) {
+	defer r.observe("get_or_set_idempotent", time.Now())
+	return r.next.GetOrSetIdempotent(ctx, key, ttl, create)
+}
+
+func (r *InstrumentedRepository) Delete(ctx context.Context, id string) error {
+	defer r.observe("delete", time.Now())
+	return r.next.Delete(ctx, id)
+}
+
+func (r *InstrumentedRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	defer r.observe("delete_expired", time.Now())
+	return r.next.DeleteExpired(ctx)
+}
+
+func (r *InstrumentedRepository) DeleteExpiredBatch(ctx context.Context, limit int) (int64, error) {
+	defer r.observe("delete_expired_batch", time.Now())
+	return r.next.DeleteExpiredBatch(ctx, limit)
+}
+
+func (r *InstrumentedRepository) DeleteIdle(ctx context.Context, accessedBefore, createdBefore time.Time) (int64, error) {
+	defer r.observe("delete_idle", time.Now())
+	return r.next.DeleteIdle(ctx, accessedBefore, createdBefore)
+}
+
+func (r *InstrumentedRepository) DeleteByCreator(ctx context.Context, creator string) (int64, error) {
+	defer r.observe("delete_by_creator", time.Now())
+	return r.next.DeleteByCreator(ctx, creator)
+}
+
+func (r *InstrumentedRepository) Close() {
+	r.next.Close()
+}
+
+// Ping forwards to the wrapped repository when it supports Ping.
+func (r *InstrumentedRepository) Ping(ctx context.Context) error {
+	if p, ok := r.next.(interface{ Ping(context.Context) error }); ok {
+		defer r.observe("ping", time.Now())
+		return p.Ping(ctx)
+	}
+	return nil
+}
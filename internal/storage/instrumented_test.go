@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// observations returns the histogram sample count per operation label.
+func observations(t *testing.T, reg *prometheus.Registry) map[string]uint64 {
+	t.Helper()
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	counts := make(map[string]uint64)
+	for _, f := range families {
+		if f.GetName() != "tafcha_db_operation_duration_seconds" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "operation" {
+					counts[label.GetValue()] = m.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+	return counts
+}
+
+func TestInstrumentedRepository(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	repo := NewInstrumentedRepository(newTestMemory(), NewDBMetrics(reg))
+
+	_, err := repo.Create(context.Background(), &Snippet{ID: "abc", Content: []byte("x"), ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = repo.Get(context.Background(), "abc")
+	require.NoError(t, err)
+	_, err = repo.WithTenant("acme").Get(context.Background(), "abc")
+	require.NoError(t, err)
+	_, err = repo.DeleteExpired(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]uint64{
+		"create":         1,
+		"get":            2,
+		"delete_expired": 1,
+	}, observations(t, reg))
+}
+
+func TestNewDBMetrics_Registered(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	NewDBMetrics(reg)
+
+	assert.Panics(t, func() { NewDBMetrics(reg) }, "the histogram is already registered")
+}
@@ -0,0 +1,437 @@
+package storage
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryRepository implements Repository with a map guarded by a mutex.
+// Snippets are lost on restart, so it suits tests and ephemeral
+// deployments only. Snippets are copied in and out, so callers never share
+// memory with the store.
+type MemoryRepository struct {
+	store  *memoryStore
+	logger *slog.Logger
+	tenant string
+}
+
+type memoryKey struct {
+	tenant string
+	id     string
+}
+
+// memoryStore is shared by all tenant views of a MemoryRepository.
+type memoryStore struct {
+	mu          sync.Mutex
+	snippets    map[memoryKey]*Snippet
+	idempotency map[memoryKey]*idempotentResult
+}
+
+// idempotentResult is the outcome of a create under an idempotency key.
+// done is closed once create has returned; result and expiresAt are set
+// under the store lock before that when it succeeded.
+type idempotentResult struct {
+	done      chan struct{}
+	result    []byte
+	expiresAt time.Time
+}
+
+// IsMemoryURL reports whether a DATABASE_URL selects the in-memory backend.
+func IsMemoryURL(url string) bool {
+	return strings.HasPrefix(url, "memory://")
+}
+
+// NewMemoryRepository creates an empty in-memory repository.
+func NewMemoryRepository(logger *slog.Logger) *MemoryRepository {
+	return &MemoryRepository{
+		store: &memoryStore{
+			snippets:    make(map[memoryKey]*Snippet),
+			idempotency: make(map[memoryKey]*idempotentResult),
+		},
+		logger: logger,
+	}
+}
+
+// copySnippet returns a deep copy of s.
+func copySnippet(s *Snippet) *Snippet {
+	c := *s
+	c.Content = append([]byte(nil), s.Content...)
+	if s.LastAccessedAt != nil {
+		t := *s.LastAccessedAt
+		c.LastAccessedAt = &t
+	}
+	if s.UpdatedAt != nil {
+		t := *s.UpdatedAt
+		c.UpdatedAt = &t
+	}
+	return &c
+}
+
+// Migrate is a no-op; there is no schema to manage.
+func (r *MemoryRepository) Migrate(ctx context.Context) error {
+	return nil
+}
+
+// MigrateDown is a no-op; there is no schema to manage.
+func (r *MemoryRepository) MigrateDown(ctx context.Context, n int) error {
+	return nil
+}
+
+// WithTenant returns a view of the repository scoped to tenant.
+func (r *MemoryRepository) WithTenant(tenant string) Repository {
+	scoped := *r
+	scoped.tenant = tenant
+	return &scoped
+}
+
+// Create stores a new snippet. Returns ErrConflict if the ID is taken.
+func (r *MemoryRepository) Create(ctx context.Context, snippet *Snippet) (*Snippet, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key := memoryKey{r.tenant, snippet.ID}
+	if _, ok := r.store.snippets[key]; ok {
+		return nil, ErrConflict
+	}
+	snippet.Tenant = r.tenant
+	snippet.CreatedAt = time.Now()
+	r.store.snippets[key] = copySnippet(snippet)
+	return snippet, nil
+}
+
+// CreateWithTimestamps stores a snippet keeping its timestamps. Returns
+// ErrConflict if the ID is taken, even by an expired snippet.
+func (r *MemoryRepository) CreateWithTimestamps(ctx context.Context, snippet *Snippet) (*Snippet, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key := memoryKey{r.tenant, snippet.ID}
+	if _, ok := r.store.snippets[key]; ok {
+		return nil, ErrConflict
+	}
+	snippet.Tenant = r.tenant
+	r.store.snippets[key] = copySnippet(snippet)
+	return snippet, nil
+}
+
+// Upsert stores content under id, replacing an existing snippet's content
+// and expiry. The stale content hash is cleared on replacement.
+func (r *MemoryRepository) Upsert(ctx context.Context, id string, content []byte, expiresAt time.Time) (*Snippet, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	now := time.Now()
+	key := memoryKey{r.tenant, id}
+	s, ok := r.store.snippets[key]
+	if !ok {
+		s = &Snippet{Tenant: r.tenant, ID: id, CreatedAt: now}
+		r.store.snippets[key] = s
+	} else {
+		s.UpdatedAt = &now
+		s.ContentHash = ""
+		s.ViewCount, s.MaxViews = 0, 0
+		s.AppendTokenHash = ""
+		s.PinHash = ""
+		s.ContentType = ""
+	}
+	s.Content = append([]byte(nil), content...)
+	s.ExpiresAt = expiresAt
+	return copySnippet(s), nil
+}
+
+// Get retrieves a snippet by ID, records the access and counts the view.
+// A snippet that reaches its view limit is removed under the same lock.
+// Returns nil if not found or expired.
+func (r *MemoryRepository) Get(ctx context.Context, id string) (*Snippet, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key := memoryKey{r.tenant, id}
+	s, ok := r.store.snippets[key]
+	if !ok || s.IsExpired() {
+		return nil, nil
+	}
+
+	now := time.Now()
+	s.LastAccessedAt = &now
+	s.ViewCount++
+	if s.MaxViews > 0 && s.ViewCount >= s.MaxViews {
+		delete(r.store.snippets, key)
+	}
+	return copySnippet(s), nil
+}
+
+// Peek retrieves a snippet by ID without recording an access or a view.
+// Returns nil if not found or expired.
+func (r *MemoryRepository) Peek(ctx context.Context, id string) (*Snippet, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	s, ok := r.store.snippets[memoryKey{r.tenant, id}]
+	if !ok || s.IsExpired() {
+		return nil, nil
+	}
+	return copySnippet(s), nil
+}
+
+// GetMeta retrieves a snippet's metadata without counting a view.
+// Returns nil if not found or expired.
+func (r *MemoryRepository) GetMeta(ctx context.Context, id string) (*SnippetMeta, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	s, ok := r.store.snippets[memoryKey{r.tenant, id}]
+	if !ok || s.IsExpired() {
+		return nil, nil
+	}
+	return snippetMeta(copySnippet(s)), nil
+}
+
+// FindByContent returns the newest active snippet from creator, or from
+// anyone for an empty creator, with the given content hash. Snippets with a
+// view limit are never returned.
+func (r *MemoryRepository) FindByContent(ctx context.Context, creator, contentHash string) (*Snippet, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var found *Snippet
+	for key, s := range r.store.snippets {
+		if key.tenant != r.tenant || (creator != "" && s.Creator != creator) || s.ContentHash != contentHash || s.MaxViews > 0 || s.IsExpired() {
+			continue
+		}
+		if found == nil || s.CreatedAt.After(found.CreatedAt) {
+			found = s
+		}
+	}
+	if found == nil {
+		return nil, nil
+	}
+	return copySnippet(found), nil
+}
+
+// Stats returns aggregate counts over active snippets across all tenants.
+func (r *MemoryRepository) Stats(ctx context.Context) (*Stats, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var st Stats
+	soon := time.Now().Add(time.Hour)
+	for _, s := range r.store.snippets {
+		if s.IsExpired() {
+			continue
+		}
+		st.ActiveSnippets++
+		st.TotalBytes += int64(len(s.Content))
+		if !s.ExpiresAt.After(soon) {
+			st.ExpiringNextHour++
+		}
+	}
+	return &st, nil
+}
+
+// Append adds content to an appendable snippet.
+func (r *MemoryRepository) Append(ctx context.Context, id string, req AppendRequest) (*Snippet, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	s, ok := r.store.snippets[memoryKey{r.tenant, id}]
+	if !ok || s.IsExpired() {
+		return nil, ErrNotFound
+	}
+	if s.AppendTokenHash == "" || subtle.ConstantTimeCompare([]byte(s.AppendTokenHash), []byte(req.TokenHash)) != 1 {
+		return nil, ErrTokenMismatch
+	}
+	if int64(len(s.Content)+len(req.Content)) > req.MaxSize {
+		return nil, ErrTooLarge
+	}
+
+	now := time.Now()
+	s.ExpiresAt = AppendExpiry(s, req.ResetExpiry, now)
+	s.UpdatedAt = &now
+	s.Content = append(s.Content, req.Content...)
+	s.ContentHash = ""
+	return copySnippet(s), nil
+}
+
+// GetOrSetIdempotent returns the result stored under key or calls create
+// to produce it. Concurrent callers wait for the one running create, or
+// until ctx is done.
+func (r *MemoryRepository) GetOrSetIdempotent(ctx context.Context, key string, ttl time.Duration, create func() ([]byte, error)) ([]byte, bool, error) {
+	k := memoryKey{tenant: r.tenant, id: key}
+	for {
+		r.store.mu.Lock()
+		e, ok := r.store.idempotency[k]
+		if ok && e.result != nil && time.Now().After(e.expiresAt) {
+			ok = false
+		}
+		if !ok {
+			e = &idempotentResult{done: make(chan struct{})}
+			r.store.idempotency[k] = e
+			r.store.mu.Unlock()
+			return r.runIdempotent(k, e, ttl, create)
+		}
+		r.store.mu.Unlock()
+
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+		r.store.mu.Lock()
+		result := e.result
+		r.store.mu.Unlock()
+		if result != nil {
+			return result, true, nil
+		}
+		// create failed; try to claim the key again
+	}
+}
+
+// runIdempotent calls create for the key claimed with e.
+func (r *MemoryRepository) runIdempotent(k memoryKey, e *idempotentResult, ttl time.Duration, create func() ([]byte, error)) ([]byte, bool, error) {
+	defer close(e.done)
+
+	result, err := create()
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if err != nil {
+		delete(r.store.idempotency, k)
+		return nil, false, err
+	}
+	e.result = append([]byte{}, result...)
+	e.expiresAt = time.Now().Add(ttl)
+	return result, false, nil
+}
+
+// deleteExpiredIdempotency forgets idempotency keys past their TTL.
+func (r *MemoryRepository) deleteExpiredIdempotency() {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	now := time.Now()
+	for k, e := range r.store.idempotency {
+		if e.result != nil && now.After(e.expiresAt) {
+			delete(r.store.idempotency, k)
+		}
+	}
+}
+
+// Extend sets the expiry of an active snippet.
+func (r *MemoryRepository) Extend(ctx context.Context, id string, newExpiry time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	s, ok := r.store.snippets[memoryKey{r.tenant, id}]
+	if !ok || s.IsExpired() {
+		return ErrNotFound
+	}
+	s.ExpiresAt = newExpiry
+	return nil
+}
+
+// Delete removes a snippet by ID.
+func (r *MemoryRepository) Delete(ctx context.Context, id string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key := memoryKey{r.tenant, id}
+	if _, ok := r.store.snippets[key]; !ok {
+		return ErrNotFound
+	}
+	delete(r.store.snippets, key)
+	return nil
+}
+
+// deleteWhere removes up to limit snippets (all if limit < 0) matching
+// match, across all tenants.
+func (r *MemoryRepository) deleteWhere(limit int, match func(*Snippet) bool) []SnippetRef {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var deleted []SnippetRef
+	for key, s := range r.store.snippets {
+		if limit >= 0 && len(deleted) == limit {
+			break
+		}
+		if match(s) {
+			delete(r.store.snippets, key)
+			deleted = append(deleted, SnippetRef{Tenant: key.tenant, ID: key.id})
+		}
+	}
+	return deleted
+}
+
+// DeleteExpired removes all expired snippets and idempotency keys.
+func (r *MemoryRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	r.deleteExpiredIdempotency()
+	count := int64(len(r.deleteWhere(-1, (*Snippet).IsExpired)))
+	if count > 0 {
+		r.logger.Info("deleted expired snippets", "count", count)
+	}
+	return count, nil
+}
+
+// DeleteExpiredBatch removes up to limit expired snippets, and all expired
+// idempotency keys.
+func (r *MemoryRepository) DeleteExpiredBatch(ctx context.Context, limit int) (int64, error) {
+	deleted, err := r.DeleteExpiredBatchReturning(ctx, limit)
+	return int64(len(deleted)), err
+}
+
+// DeleteExpiredBatchReturning is DeleteExpiredBatch, returning the removed
+// snippets.
+func (r *MemoryRepository) DeleteExpiredBatchReturning(ctx context.Context, limit int) ([]SnippetRef, error) {
+	r.deleteExpiredIdempotency()
+	return r.deleteWhere(limit, (*Snippet).IsExpired), nil
+}
+
+// DeleteIdle removes snippets last accessed (or, if never read, created)
+// before accessedBefore, as long as they were created before createdBefore.
+// Snippets that never expire are kept.
+func (r *MemoryRepository) DeleteIdle(ctx context.Context, accessedBefore, createdBefore time.Time) (int64, error) {
+	deleted, err := r.DeleteIdleReturning(ctx, accessedBefore, createdBefore)
+	return int64(len(deleted)), err
+}
+
+// DeleteIdleReturning is DeleteIdle, returning the removed snippets.
+func (r *MemoryRepository) DeleteIdleReturning(ctx context.Context, accessedBefore, createdBefore time.Time) ([]SnippetRef, error) {
+	deleted := r.deleteWhere(-1, func(s *Snippet) bool {
+		lastSeen := s.CreatedAt
+		if s.LastAccessedAt != nil {
+			lastSeen = *s.LastAccessedAt
+		}
+		return lastSeen.Before(accessedBefore) && s.CreatedAt.Before(createdBefore) &&
+			s.ExpiresAt.Before(NeverExpires)
+	})
+	if len(deleted) > 0 {
+		r.logger.Info("deleted idle snippets", "count", len(deleted))
+	}
+	return deleted, nil
+}
+
+// DeleteByCreator removes all snippets with the given creator hash.
+func (r *MemoryRepository) DeleteByCreator(ctx context.Context, creator string) (int64, error) {
+	deleted, err := r.DeleteByCreatorReturning(ctx, creator)
+	return int64(len(deleted)), err
+}
+
+// DeleteByCreatorReturning is DeleteByCreator, returning the removed
+// snippets.
+func (r *MemoryRepository) DeleteByCreatorReturning(ctx context.Context, creator string) ([]SnippetRef, error) {
+	return r.deleteWhere(-1, func(s *Snippet) bool {
+		return s.Creator == creator
+	}), nil
+}
+
+// Close is a no-op.
+func (r *MemoryRepository) Close() {}
+
+// Ping always succeeds.
+func (r *MemoryRepository) Ping(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,269 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMemory() *MemoryRepository {
+	return NewMemoryRepository(slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestMemory_CreateAndGet(t *testing.T) {
+	repo := newTestMemory()
+
+	in := &Snippet{ID: "abc", Content: []byte("hello"), ExpiresAt: time.Now().Add(time.Hour)}
+	_, err := repo.Create(context.Background(), in)
+	require.NoError(t, err)
+	in.Content[0] = 'j'
+
+	got, err := repo.Get(context.Background(), "abc")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, []byte("hello"), got.Content, "the store keeps its own copy")
+	assert.Equal(t, 1, got.ViewCount)
+	assert.NotNil(t, got.LastAccessedAt)
+
+	missing, err := repo.Get(context.Background(), "nope")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+}
+
+func TestMemory_CreateWithTimestamps(t *testing.T) {
+	repo := newTestMemory()
+	createdAt := time.Now().Add(-48 * time.Hour)
+
+	_, err := repo.CreateWithTimestamps(context.Background(), &Snippet{
+		ID: "imported", Content: []byte("x"), CreatedAt: createdAt, ExpiresAt: createdAt.Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	// Taken even though the first one expired
+	_, err = repo.CreateWithTimestamps(context.Background(), &Snippet{
+		ID: "imported", Content: []byte("y"), CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour),
+	})
+	assert.ErrorIs(t, err, ErrConflict)
+}
+
+func TestMemory_Expiry(t *testing.T) {
+	repo := newTestMemory()
+
+	_, err := repo.Create(context.Background(), &Snippet{ID: "old", Content: []byte("x"), ExpiresAt: time.Now().Add(-time.Second)})
+	require.NoError(t, err)
+	_, err = repo.Create(context.Background(), &Snippet{ID: "live", Content: []byte("x"), ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	got, err := repo.Get(context.Background(), "old")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	n, err := repo.DeleteExpired(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	got, err = repo.Peek(context.Background(), "live")
+	require.NoError(t, err)
+	assert.NotNil(t, got)
+}
+
+func TestMemory_Extend(t *testing.T) {
+	repo := newTestMemory()
+
+	_, err := repo.Create(context.Background(), &Snippet{ID: "live", Content: []byte("x"), ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = repo.Create(context.Background(), &Snippet{ID: "old", Content: []byte("x"), ExpiresAt: time.Now().Add(-time.Second)})
+	require.NoError(t, err)
+
+	later := time.Now().Add(48 * time.Hour)
+	require.NoError(t, repo.Extend(context.Background(), "live", later))
+	got, err := repo.Peek(context.Background(), "live")
+	require.NoError(t, err)
+	assert.True(t, got.ExpiresAt.Equal(later))
+
+	assert.ErrorIs(t, repo.Extend(context.Background(), "old", later), ErrNotFound, "expired snippets are not revived")
+	assert.ErrorIs(t, repo.Extend(context.Background(), "missing", later), ErrNotFound)
+	assert.ErrorIs(t, repo.WithTenant("other").Extend(context.Background(), "live", later), ErrNotFound)
+}
+
+func TestMemory_BurnAndTenants(t *testing.T) {
+	repo := newTestMemory()
+	acme := repo.WithTenant("acme")
+
+	_, err := acme.Create(context.Background(), &Snippet{ID: "s", Content: []byte("x"), ExpiresAt: time.Now().Add(time.Hour), MaxViews: 1})
+	require.NoError(t, err)
+
+	got, err := repo.Get(context.Background(), "s")
+	require.NoError(t, err)
+	assert.Nil(t, got, "other tenants do not see the snippet")
+
+	got, err = acme.Get(context.Background(), "s")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+
+	got, err = acme.Get(context.Background(), "s")
+	require.NoError(t, err)
+	assert.Nil(t, got, "burned after its last view")
+}
+
+func TestMemory_Append(t *testing.T) {
+	repo := newTestMemory()
+
+	_, err := repo.Create(context.Background(), &Snippet{ID: "log", Content: []byte("a"), ExpiresAt: time.Now().Add(time.Hour), AppendTokenHash: "t"})
+	require.NoError(t, err)
+
+	got, err := repo.Append(context.Background(), "log", AppendRequest{TokenHash: "t", Content: []byte("b"), MaxSize: 10})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("ab"), got.Content)
+
+	_, err = repo.Append(context.Background(), "log", AppendRequest{TokenHash: "x", Content: []byte("b"), MaxSize: 10})
+	assert.ErrorIs(t, err, ErrTokenMismatch)
+	_, err = repo.Append(context.Background(), "log", AppendRequest{TokenHash: "t", Content: []byte("too long"), MaxSize: 5})
+	assert.ErrorIs(t, err, ErrTooLarge)
+	assert.NoError(t, repo.Delete(context.Background(), "log"))
+	assert.ErrorIs(t, repo.Delete(context.Background(), "log"), ErrNotFound)
+}
+
+func TestMemory_Upsert(t *testing.T) {
+	repo := newTestMemory()
+	expiresAt := time.Now().Add(time.Hour)
+
+	created, err := repo.Upsert(context.Background(), "fixed", []byte("one"), expiresAt)
+	require.NoError(t, err)
+	assert.Nil(t, created.UpdatedAt, "a new snippet is inserted")
+
+	replaced, err := repo.Upsert(context.Background(), "fixed", []byte("two"), expiresAt.Add(time.Hour))
+	require.NoError(t, err)
+	assert.NotNil(t, replaced.UpdatedAt, "an existing snippet is replaced")
+	assert.Equal(t, []byte("two"), replaced.Content)
+	assert.Equal(t, created.CreatedAt, replaced.CreatedAt)
+	assert.Len(t, repo.store.snippets, 1)
+
+	_, err = repo.Create(context.Background(), &Snippet{ID: "fixed", Content: []byte("x"), ExpiresAt: expiresAt})
+	assert.ErrorIs(t, err, ErrConflict, "Create refuses a duplicate ID")
+}
+
+func TestMemory_UpsertResetsLimits(t *testing.T) {
+	repo := newTestMemory()
+
+	_, err := repo.Create(context.Background(), &Snippet{
+		ID:              "fixed",
+		Content:         []byte("one"),
+		ExpiresAt:       time.Now().Add(time.Hour),
+		MaxViews:        2,
+		AppendTokenHash: "token-hash",
+		PinHash:         "pin-hash",
+		ContentType:     "text/csv",
+	})
+	require.NoError(t, err)
+	_, err = repo.Get(context.Background(), "fixed")
+	require.NoError(t, err)
+
+	replaced, err := repo.Upsert(context.Background(), "fixed", []byte("two"), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, replaced.ViewCount)
+	assert.Zero(t, replaced.MaxViews)
+	assert.Empty(t, replaced.AppendTokenHash)
+	assert.Empty(t, replaced.PinHash)
+	assert.Empty(t, replaced.ContentType)
+
+	for i := 0; i < 3; i++ {
+		got, err := repo.Get(context.Background(), "fixed")
+		require.NoError(t, err)
+		require.NotNil(t, got, "the old view limit no longer applies")
+	}
+}
+
+func TestMemory_GetMeta(t *testing.T) {
+	repo := newTestMemory()
+
+	_, err := repo.Create(context.Background(), &Snippet{ID: "meta", Content: []byte("hello"), ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	meta, err := repo.GetMeta(context.Background(), "meta")
+	require.NoError(t, err)
+	require.NotNil(t, meta)
+	assert.Equal(t, int64(5), meta.SizeBytes)
+	assert.Equal(t, meta.CreatedAt, meta.LastModified())
+	assert.Zero(t, meta.ViewCount)
+
+	for i := 1; i <= 2; i++ {
+		got, err := repo.Get(context.Background(), "meta")
+		require.NoError(t, err)
+		assert.Equal(t, i, got.ViewCount)
+	}
+	meta, err = repo.GetMeta(context.Background(), "meta")
+	require.NoError(t, err)
+	assert.Equal(t, 2, meta.ViewCount)
+}
+
+// Run with -race to check the locking.
+func TestMemory_Concurrent(t *testing.T) {
+	repo := newTestMemory()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				id := fmt.Sprintf("w%d-%d", w, i)
+				expiresAt := time.Now().Add(time.Hour)
+				if i%2 == 0 {
+					expiresAt = time.Now().Add(-time.Second)
+				}
+				_, err := repo.Create(context.Background(), &Snippet{ID: id, Content: []byte(id), ExpiresAt: expiresAt})
+				assert.NoError(t, err)
+
+				got, err := repo.Get(context.Background(), id)
+				assert.NoError(t, err)
+				if i%2 == 1 && assert.NotNil(t, got) {
+					got.Content[0] = '!'
+				}
+
+				_, err = repo.DeleteExpired(context.Background())
+				assert.NoError(t, err)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	n, err := repo.DeleteExpired(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	assert.Len(t, repo.store.snippets, 8*50)
+	got, err := repo.Peek(context.Background(), "w0-1")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("w0-1"), got.Content)
+}
+
+func TestMemory_Stats(t *testing.T) {
+	repo := newTestMemory()
+
+	empty, err := repo.Stats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &Stats{}, empty)
+
+	now := time.Now()
+	for _, s := range []*Snippet{
+		{ID: "soon", Content: []byte("12345"), ExpiresAt: now.Add(30 * time.Minute)},
+		{ID: "later", Content: []byte("1234567890"), ExpiresAt: now.Add(24 * time.Hour)},
+		{ID: "expired", Content: []byte("gone"), ExpiresAt: now.Add(-time.Minute)},
+	} {
+		_, err = repo.Create(context.Background(), s)
+		require.NoError(t, err)
+	}
+	_, err = repo.WithTenant("acme").Create(context.Background(), &Snippet{ID: "soon", Content: []byte("abc"), ExpiresAt: now.Add(time.Minute)})
+	require.NoError(t, err)
+
+	stats, err := repo.Stats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &Stats{ActiveSnippets: 3, TotalBytes: 18, ExpiringNextHour: 2}, stats)
+}
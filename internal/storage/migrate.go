@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// migration is one schema change: an up script and the down script that
+// reverts it, named <version>.sql and <version>.down.sql.
+type migration struct {
+	Version string
+	Up      string
+	Down    string
+}
+
+// loadMigrations lists the migrations in dir, sorted by version.
+func loadMigrations(fsys fs.FS, dir string) ([]migration, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, fmt.Errorf("listing migration files: %w", err)
+	}
+
+	byVersion := make(map[string]*migration)
+	get := func(version string) *migration {
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{Version: version}
+			byVersion[version] = m
+		}
+		return m
+	}
+	for _, file := range files {
+		name := path.Base(file)
+		if version, ok := strings.CutSuffix(name, ".down.sql"); ok {
+			get(version).Down = file
+			continue
+		}
+		get(strings.TrimSuffix(name, ".sql")).Up = file
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("down migration %s has no matching up migration", m.Down)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
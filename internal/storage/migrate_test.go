@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMigrations_EmbeddedArePaired(t *testing.T) {
+	migrations, err := loadMigrations(migrationsFS, "migrations")
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for i, m := range migrations {
+		assert.NotEmpty(t, m.Down, "%s needs a .down.sql", m.Version)
+		if i > 0 {
+			assert.Less(t, migrations[i-1].Version, m.Version)
+		}
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/002_second.sql":      {Data: []byte("up 2")},
+		"m/001_first.down.sql":  {Data: []byte("down 1")},
+		"m/001_first.sql":       {Data: []byte("up 1")},
+		"m/003_no_down.sql":     {Data: []byte("up 3")},
+		"m/002_second.down.sql": {Data: []byte("down 2")},
+	}
+
+	migrations, err := loadMigrations(fsys, "m")
+	require.NoError(t, err)
+	assert.Equal(t, []migration{
+		{Version: "001_first", Up: "m/001_first.sql", Down: "m/001_first.down.sql"},
+		{Version: "002_second", Up: "m/002_second.sql", Down: "m/002_second.down.sql"},
+		{Version: "003_no_down", Up: "m/003_no_down.sql"},
+	}, migrations)
+
+	_, err = loadMigrations(fstest.MapFS{"m/004_orphan.down.sql": {}}, "m")
+	assert.Error(t, err)
+}
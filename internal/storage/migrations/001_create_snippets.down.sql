@@ -0,0 +1,2 @@
+-- Drops every snippet
+DROP TABLE IF EXISTS snippets;
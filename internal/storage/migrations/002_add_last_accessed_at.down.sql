@@ -0,0 +1 @@
+ALTER TABLE snippets DROP COLUMN IF EXISTS last_accessed_at;
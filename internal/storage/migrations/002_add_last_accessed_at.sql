@@ -0,0 +1,2 @@
+-- Track when a snippet was last read so idle snippets can be collected early
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS last_accessed_at TIMESTAMPTZ;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_snippets_creator;
+ALTER TABLE snippets DROP COLUMN IF EXISTS creator;
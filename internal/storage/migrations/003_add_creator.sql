@@ -0,0 +1,4 @@
+-- Hashed creator identity (client IP) for moderation and bulk deletion
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS creator VARCHAR(64);
+
+CREATE INDEX IF NOT EXISTS idx_snippets_creator ON snippets(creator);
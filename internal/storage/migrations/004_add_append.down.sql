@@ -0,0 +1,2 @@
+ALTER TABLE snippets DROP COLUMN IF EXISTS append_token_hash;
+ALTER TABLE snippets DROP COLUMN IF EXISTS updated_at;
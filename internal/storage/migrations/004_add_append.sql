@@ -0,0 +1,3 @@
+-- Appendable snippets: hashed append token and last modification time
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS append_token_hash VARCHAR(64);
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
@@ -0,0 +1,4 @@
+-- Fails, leaving the schema unchanged, if an ID is used by more than one tenant
+ALTER TABLE snippets DROP CONSTRAINT IF EXISTS snippets_pkey;
+ALTER TABLE snippets DROP COLUMN IF EXISTS tenant;
+ALTER TABLE snippets ADD PRIMARY KEY (id);
@@ -0,0 +1,14 @@
+-- Tenant namespace: the same ID may exist once per tenant
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS tenant VARCHAR(255) NOT NULL DEFAULT '';
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_index i
+        JOIN pg_class c ON c.oid = i.indrelid
+        WHERE c.relname = 'snippets' AND i.indisprimary AND i.indnatts = 2
+    ) THEN
+        ALTER TABLE snippets DROP CONSTRAINT IF EXISTS snippets_pkey;
+        ALTER TABLE snippets ADD PRIMARY KEY (tenant, id);
+    END IF;
+END $$;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_snippets_creator_content_hash;
+ALTER TABLE snippets DROP COLUMN IF EXISTS content_hash;
@@ -0,0 +1,6 @@
+-- Content hash for duplicate detection, marked with its algorithm (e.g. "sha256:...")
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS content_hash TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_snippets_creator_content_hash
+    ON snippets (tenant, creator, content_hash)
+    WHERE content_hash IS NOT NULL;
@@ -0,0 +1 @@
+ALTER TABLE snippets DROP COLUMN IF EXISTS delete_token_hash;
@@ -0,0 +1,2 @@
+-- Hashed secret required to delete a snippet over the API
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS delete_token_hash VARCHAR(64);
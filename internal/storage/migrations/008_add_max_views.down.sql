@@ -0,0 +1,2 @@
+ALTER TABLE snippets DROP COLUMN IF EXISTS max_views;
+ALTER TABLE snippets DROP COLUMN IF EXISTS view_count;
@@ -0,0 +1,3 @@
+-- Burn-after-reading: optional view limit and the number of views so far
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS max_views INTEGER;
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS view_count INTEGER NOT NULL DEFAULT 0;
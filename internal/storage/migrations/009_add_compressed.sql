@@ -0,0 +1,2 @@
+-- Content stored gzip-compressed (COMPRESS_STORAGE); older rows stay raw
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS compressed BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,2 @@
+-- Declared media type served on GET; NULL means text/plain
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS content_type TEXT;
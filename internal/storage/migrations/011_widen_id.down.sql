@@ -0,0 +1 @@
+ALTER TABLE snippets ALTER COLUMN id TYPE VARCHAR(12);
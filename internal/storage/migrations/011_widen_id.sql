@@ -0,0 +1,2 @@
+-- Custom IDs are up to 64 characters
+ALTER TABLE snippets ALTER COLUMN id TYPE VARCHAR(64);
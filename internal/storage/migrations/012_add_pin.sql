@@ -0,0 +1,2 @@
+-- Hash of the PIN needed to read the snippet; NULL means no PIN
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS pin_hash VARCHAR(64);
@@ -0,0 +1,11 @@
+-- Results of creates sent with an Idempotency-Key, replayed to retries on
+-- any replica. result is NULL while the first request is still creating.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    tenant     VARCHAR(255) NOT NULL DEFAULT '',
+    key        VARCHAR(255) NOT NULL,
+    result     BYTEA,
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (tenant, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
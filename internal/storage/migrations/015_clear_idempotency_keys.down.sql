@@ -0,0 +1 @@
+-- Cleared records cannot be restored; they were only kept for retries.
@@ -0,0 +1 @@
+ALTER TABLE snippets ALTER COLUMN pin_hash TYPE VARCHAR(64);
@@ -0,0 +1,2 @@
+-- PIN hashes are salted Argon2id, longer than the SHA-256 they replace
+ALTER TABLE snippets ALTER COLUMN pin_hash TYPE VARCHAR(255);
@@ -0,0 +1,26 @@
+-- SQLite schema, equivalent to the PostgreSQL migrations combined.
+-- Timestamps are stored as Unix microseconds.
+CREATE TABLE IF NOT EXISTS snippets (
+    tenant            TEXT    NOT NULL DEFAULT '',
+    id                TEXT    NOT NULL,
+    content           BLOB    NOT NULL,
+    expires_at        INTEGER NOT NULL,
+    created_at        INTEGER NOT NULL,
+    last_accessed_at  INTEGER,
+    creator           TEXT,
+    append_token_hash TEXT,
+    updated_at        INTEGER,
+    content_hash      TEXT,
+    delete_token_hash TEXT,
+    max_views         INTEGER,
+    view_count        INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (tenant, id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_snippets_expires_at ON snippets(expires_at);
+
+CREATE INDEX IF NOT EXISTS idx_snippets_creator ON snippets(creator);
+
+CREATE INDEX IF NOT EXISTS idx_snippets_creator_content_hash
+    ON snippets (tenant, creator, content_hash)
+    WHERE content_hash IS NOT NULL;
@@ -0,0 +1,2 @@
+-- Mirrors the Postgres schema; SQLite always stores content raw
+ALTER TABLE snippets ADD COLUMN compressed INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_snippets_content_hash;
@@ -0,0 +1,4 @@
+-- Index for ?dedupe=true, which looks up content across all creators
+CREATE INDEX IF NOT EXISTS idx_snippets_content_hash
+    ON snippets (tenant, content_hash)
+    WHERE content_hash IS NOT NULL;
@@ -0,0 +1,10 @@
+-- Results of creates sent with an Idempotency-Key, replayed to retries
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    tenant     TEXT NOT NULL DEFAULT '',
+    key        TEXT NOT NULL,
+    result     BLOB NOT NULL,
+    expires_at INTEGER NOT NULL,
+    PRIMARY KEY (tenant, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1,3 @@
+-- Records written before idempotency keys were scoped to their caller hold
+-- tokens in plaintext under the raw key; no retry can match them any more.
+DELETE FROM idempotency_keys;
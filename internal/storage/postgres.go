@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"crypto/subtle"
 	"embed"
 	"errors"
 	"fmt"
@@ -9,6 +10,7 @@ import (
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -17,8 +19,10 @@ var migrationsFS embed.FS
 
 // PostgresRepository implements Repository using PostgreSQL.
 type PostgresRepository struct {
-	pool   *pgxpool.Pool
-	logger *slog.Logger
+	pool     *pgxpool.Pool
+	logger   *slog.Logger
+	tenant   string
+	compress bool
 }
 
 // PostgresConfig holds database connection configuration.
@@ -27,6 +31,10 @@ type PostgresConfig struct {
 	MaxConns    int32
 	MinConns    int32
 	MaxConnLife time.Duration
+
+	// CompressContent gzips content before it is stored, when that makes
+	// it smaller. Rows are flagged, so both forms can be read either way.
+	CompressContent bool
 }
 
 // NewPostgresRepository creates a new PostgreSQL repository.
@@ -52,67 +60,288 @@ func NewPostgresRepository(ctx context.Context, cfg PostgresConfig, logger *slog
 	}
 
 	repo := &PostgresRepository{
-		pool:   pool,
-		logger: logger,
+		pool:     pool,
+		logger:   logger,
+		compress: cfg.CompressContent,
 	}
 
 	return repo, nil
 }
 
-// Migrate runs database migrations.
+// createMigrationsTable records which migrations have been applied so they
+// can be rolled back in reverse order.
+const createMigrationsTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    TEXT PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)
+`
+
+// migrationLockKey serializes migrations across server instances starting
+// at the same time, via pg_advisory_xact_lock. It spells "tafc".
+const migrationLockKey int64 = 0x74616663
+
+// Migrate applies the migrations that have not been applied yet, in lexical
+// order. Each runs in its own transaction together with its
+// schema_migrations row, so a failed migration leaves no trace and is
+// retried on the next start.
 func (r *PostgresRepository) Migrate(ctx context.Context) error {
-	migrationSQL, err := migrationsFS.ReadFile("migrations/001_create_snippets.sql")
+	migrations, err := loadMigrations(migrationsFS, "migrations")
 	if err != nil {
-		return fmt.Errorf("reading migration file: %w", err)
+		return err
+	}
+
+	if _, err := r.pool.Exec(ctx, createMigrationsTable); err != nil {
+		return fmt.Errorf("creating schema_migrations: %w", err)
+	}
+
+	applied := 0
+	for _, m := range migrations {
+		upSQL, err := migrationsFS.ReadFile(m.Up)
+		if err != nil {
+			return fmt.Errorf("reading migration file %s: %w", m.Up, err)
+		}
+
+		var ran bool
+		err = pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockKey); err != nil {
+				return err
+			}
+			var done bool
+			if err := tx.QueryRow(ctx,
+				`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, m.Version,
+			).Scan(&done); err != nil {
+				return err
+			}
+			if done {
+				return nil
+			}
+			if _, err := tx.Exec(ctx, string(upSQL)); err != nil {
+				return err
+			}
+			ran = true
+			_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, m.Version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("executing migration %s: %w", m.Up, err)
+		}
+		if ran {
+			applied++
+			r.logger.Info("applied migration", "version", m.Version)
+		}
 	}
 
-	_, err = r.pool.Exec(ctx, string(migrationSQL))
+	r.logger.Info("database migration completed", "migrations", len(migrations), "applied", applied)
+	return nil
+}
+
+// MigrateDown rolls back the n most recently applied migrations, newest
+// first. Each down migration runs in its own transaction together with the
+// removal of its schema_migrations row.
+func (r *PostgresRepository) MigrateDown(ctx context.Context, n int) error {
+	migrations, err := loadMigrations(migrationsFS, "migrations")
 	if err != nil {
-		return fmt.Errorf("executing migration: %w", err)
+		return err
+	}
+	byVersion := make(map[string]migration, len(migrations))
+	for _, m := range migrations {
+		byVersion[m.Version] = m
+	}
+
+	if _, err := r.pool.Exec(ctx, createMigrationsTable); err != nil {
+		return fmt.Errorf("creating schema_migrations: %w", err)
+	}
+
+	rows, err := r.pool.Query(ctx, `SELECT version FROM schema_migrations ORDER BY version DESC LIMIT $1`, n)
+	if err != nil {
+		return fmt.Errorf("listing applied migrations: %w", err)
+	}
+	applied, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return fmt.Errorf("listing applied migrations: %w", err)
+	}
+
+	for _, version := range applied {
+		m, ok := byVersion[version]
+		if !ok || m.Down == "" {
+			return fmt.Errorf("migration %s has no down migration", version)
+		}
+		downSQL, err := migrationsFS.ReadFile(m.Down)
+		if err != nil {
+			return fmt.Errorf("reading migration file %s: %w", m.Down, err)
+		}
+
+		err = pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, string(downSQL)); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("rolling back migration %s: %w", version, err)
+		}
+		r.logger.Info("rolled back migration", "version", version)
 	}
 
-	r.logger.Info("database migration completed")
 	return nil
 }
 
+// WithTenant returns a copy of the repository scoped to tenant.
+func (r *PostgresRepository) WithTenant(tenant string) Repository {
+	scoped := *r
+	scoped.tenant = tenant
+	return &scoped
+}
+
 // Create stores a new snippet.
-func (r *PostgresRepository) Create(id string, content []byte, expiresAt time.Time) (*Snippet, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (r *PostgresRepository) Create(ctx context.Context, snippet *Snippet) (*Snippet, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
+	stored, compressed, err := compressContent(snippet.Content, r.compress)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		INSERT INTO snippets (id, content, expires_at, created_at)
-		VALUES ($1, $2, $3, NOW())
+		INSERT INTO snippets (tenant, id, content, expires_at, creator, append_token_hash, content_hash,
+		                      delete_token_hash, max_views, compressed, content_type, pin_hash, created_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, 0), $10,
+		        NULLIF($11, ''), NULLIF($12, ''), NOW())
 		RETURNING created_at
 	`
 
-	var createdAt time.Time
-	err := r.pool.QueryRow(ctx, query, id, content, expiresAt).Scan(&createdAt)
+	snippet.Tenant = r.tenant
+	err = r.pool.QueryRow(ctx, query,
+		r.tenant, snippet.ID, stored, snippet.ExpiresAt, snippet.Creator, snippet.AppendTokenHash, snippet.ContentHash,
+		snippet.DeleteTokenHash, snippet.MaxViews, compressed, snippet.ContentType, snippet.PinHash,
+	).Scan(&snippet.CreatedAt)
+	if isUniqueViolation(err) {
+		return nil, ErrConflict
+	}
 	if err != nil {
 		return nil, fmt.Errorf("inserting snippet: %w", err)
 	}
 
-	return &Snippet{
-		ID:        id,
-		Content:   content,
-		ExpiresAt: expiresAt,
-		CreatedAt: createdAt,
-	}, nil
+	return snippet, nil
+}
+
+// isUniqueViolation reports whether err is a Postgres unique_violation.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
 }
 
-// Get retrieves a snippet by ID. Returns nil if not found or expired.
-func (r *PostgresRepository) Get(id string) (*Snippet, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// CreateWithTimestamps stores a snippet with explicit creation and expiry
+// times instead of stamping NOW().
+func (r *PostgresRepository) CreateWithTimestamps(ctx context.Context, snippet *Snippet) (*Snippet, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
+	stored, compressed, err := compressContent(snippet.Content, r.compress)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		SELECT id, content, expires_at, created_at
-		FROM snippets
-		WHERE id = $1 AND expires_at > NOW()
+		INSERT INTO snippets (tenant, id, content, expires_at, creator, content_hash, compressed, created_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, $8)
 	`
 
+	snippet.Tenant = r.tenant
+	_, err = r.pool.Exec(ctx, query,
+		r.tenant, snippet.ID, stored, snippet.ExpiresAt, snippet.Creator, snippet.ContentHash, compressed, snippet.CreatedAt,
+	)
+	if isUniqueViolation(err) {
+		return nil, ErrConflict
+	}
+	if err != nil {
+		return nil, fmt.Errorf("importing snippet: %w", err)
+	}
+
+	return snippet, nil
+}
+
+// Upsert stores content under id, replacing an existing snippet's content
+// and expiry. The stale content hash is cleared on replacement.
+func (r *PostgresRepository) Upsert(ctx context.Context, id string, content []byte, expiresAt time.Time) (*Snippet, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	stored, compressed, err := compressContent(content, r.compress)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO snippets (tenant, id, content, expires_at, compressed, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (tenant, id) DO UPDATE
+		SET content = EXCLUDED.content, expires_at = EXCLUDED.expires_at, compressed = EXCLUDED.compressed,
+		    updated_at = NOW(), content_hash = NULL,
+		    view_count = 0, max_views = NULL, append_token_hash = NULL, pin_hash = NULL,
+		    content_type = NULL
+		RETURNING ` + snippetColumns
+
+	s, err := scanSnippet(r.pool.QueryRow(ctx, query, r.tenant, id, stored, expiresAt, compressed))
+	if err != nil {
+		return nil, fmt.Errorf("upserting snippet: %w", err)
+	}
+
+	return s, nil
+}
+
+// snippetColumns lists the columns scanned by scanSnippet, in order.
+const snippetColumns = `
+	tenant, id, content, expires_at, created_at, last_accessed_at, COALESCE(creator, ''),
+	COALESCE(content_hash, ''), COALESCE(append_token_hash, ''), COALESCE(delete_token_hash, ''),
+	updated_at, COALESCE(max_views, 0), view_count, compressed, COALESCE(content_type, ''),
+	COALESCE(pin_hash, '')`
+
+// scanSnippet scans snippetColumns, decompressing the content if needed.
+func scanSnippet(row pgx.Row) (*Snippet, error) {
 	var s Snippet
-	err := r.pool.QueryRow(ctx, query, id).Scan(&s.ID, &s.Content, &s.ExpiresAt, &s.CreatedAt)
+	var compressed bool
+	err := row.Scan(
+		&s.Tenant, &s.ID, &s.Content, &s.ExpiresAt, &s.CreatedAt, &s.LastAccessedAt, &s.Creator,
+		&s.ContentHash, &s.AppendTokenHash, &s.DeleteTokenHash,
+		&s.UpdatedAt, &s.MaxViews, &s.ViewCount, &compressed, &s.ContentType,
+		&s.PinHash,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if s.Content, err = decompressContent(s.Content, compressed); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Get retrieves a snippet by ID, records the access time and counts the
+// view. A snippet that reaches its view limit is deleted in the same
+// transaction; the row lock taken by the UPDATE makes concurrent readers
+// wait and then see the snippet as gone.
+// Returns nil if not found or expired.
+func (r *PostgresRepository) Get(ctx context.Context, id string) (*Snippet, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("beginning get: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE snippets
+		SET last_accessed_at = NOW(), view_count = view_count + 1
+		WHERE tenant = $1 AND id = $2 AND expires_at > NOW()
+		  AND (max_views IS NULL OR view_count < max_views)
+		RETURNING ` + snippetColumns
+
+	s, err := scanSnippet(tx.QueryRow(ctx, query, r.tenant, id))
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, nil
 	}
@@ -120,37 +349,422 @@ func (r *PostgresRepository) Get(id string) (*Snippet, error) {
 		return nil, fmt.Errorf("querying snippet: %w", err)
 	}
 
+	if s.MaxViews > 0 && s.ViewCount >= s.MaxViews {
+		if _, err := tx.Exec(ctx, "DELETE FROM snippets WHERE tenant = $1 AND id = $2", r.tenant, id); err != nil {
+			return nil, fmt.Errorf("burning snippet: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("committing get: %w", err)
+	}
+
+	return s, nil
+}
+
+// Peek retrieves a snippet by ID without recording an access or a view.
+// Returns nil if not found or expired.
+func (r *PostgresRepository) Peek(ctx context.Context, id string) (*Snippet, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + snippetColumns + `
+		FROM snippets
+		WHERE tenant = $1 AND id = $2 AND expires_at > NOW()`
+
+	s, err := scanSnippet(r.pool.QueryRow(ctx, query, r.tenant, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying snippet: %w", err)
+	}
+
+	return s, nil
+}
+
+// GetMeta retrieves a snippet's metadata without reading the content column;
+// the size of compressed content comes from its gzip trailer.
+// Returns nil if not found or expired.
+func (r *PostgresRepository) GetMeta(ctx context.Context, id string) (*SnippetMeta, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT tenant, id, expires_at, created_at, updated_at, octet_length(content),
+		       CASE WHEN compressed THEN substring(content FROM octet_length(content) - 3) END,
+		       view_count, COALESCE(content_type, '')
+		FROM snippets
+		WHERE tenant = $1 AND id = $2 AND expires_at > NOW()`
+
+	var m SnippetMeta
+	var trailer []byte
+	err := r.pool.QueryRow(ctx, query, r.tenant, id).Scan(
+		&m.Tenant, &m.ID, &m.ExpiresAt, &m.CreatedAt, &m.UpdatedAt, &m.SizeBytes, &trailer, &m.ViewCount, &m.ContentType,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying snippet metadata: %w", err)
+	}
+	if trailer != nil {
+		m.SizeBytes = gzipSize(trailer)
+	}
+
+	return &m, nil
+}
+
+// FindByContent returns the newest active snippet from creator, or from
+// anyone for an empty creator, with the given content hash, without
+// recording an access. Snippets with a view limit are never returned.
+func (r *PostgresRepository) FindByContent(ctx context.Context, creator, contentHash string) (*Snippet, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + snippetColumns + `
+		FROM snippets
+		WHERE tenant = $1 AND ($2 = '' OR creator = $2) AND content_hash = $3 AND expires_at > NOW()
+		  AND max_views IS NULL
+		ORDER BY created_at DESC
+		LIMIT 1`
+
+	s, err := scanSnippet(r.pool.QueryRow(ctx, query, r.tenant, creator, contentHash))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying snippet by content: %w", err)
+	}
+
+	return s, nil
+}
+
+// Stats returns aggregate counts over active snippets across all tenants.
+func (r *PostgresRepository) Stats(ctx context.Context) (*Stats, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT COUNT(*), COALESCE(SUM(octet_length(content)), 0),
+		       COUNT(*) FILTER (WHERE expires_at <= NOW() + INTERVAL '1 hour')
+		FROM snippets
+		WHERE expires_at > NOW()`
+
+	var st Stats
+	err := r.pool.QueryRow(ctx, query).Scan(&st.ActiveSnippets, &st.TotalBytes, &st.ExpiringNextHour)
+	if err != nil {
+		return nil, fmt.Errorf("querying stats: %w", err)
+	}
+	return &st, nil
+}
+
+// Append adds content to an appendable snippet inside a transaction so
+// concurrent appends are serialized and the size limit holds. The content
+// is rewritten as a whole, since compressed rows cannot be appended to in
+// place.
+func (r *PostgresRepository) Append(ctx context.Context, id string, req AppendRequest) (*Snippet, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("beginning append: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		SELECT tenant, id, expires_at, created_at, updated_at,
+		       COALESCE(append_token_hash, ''), content, compressed
+		FROM snippets
+		WHERE tenant = $1 AND id = $2 AND expires_at > NOW()
+		FOR UPDATE
+	`
+
+	var s Snippet
+	var compressed bool
+	err = tx.QueryRow(ctx, query, r.tenant, id).Scan(
+		&s.Tenant, &s.ID, &s.ExpiresAt, &s.CreatedAt, &s.UpdatedAt, &s.AppendTokenHash, &s.Content, &compressed,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("locking snippet: %w", err)
+	}
+
+	if s.AppendTokenHash == "" || subtle.ConstantTimeCompare([]byte(s.AppendTokenHash), []byte(req.TokenHash)) != 1 {
+		return nil, ErrTokenMismatch
+	}
+	if s.Content, err = decompressContent(s.Content, compressed); err != nil {
+		return nil, err
+	}
+	if int64(len(s.Content)+len(req.Content)) > req.MaxSize {
+		return nil, ErrTooLarge
+	}
+
+	now := time.Now()
+	expiresAt := AppendExpiry(&s, req.ResetExpiry, now)
+	s.Content = append(s.Content, req.Content...)
+	stored, compressed, err := compressContent(s.Content, r.compress)
+	if err != nil {
+		return nil, err
+	}
+
+	update := `
+		UPDATE snippets
+		SET content = $3, compressed = $4, expires_at = $5, updated_at = $6, content_hash = NULL
+		WHERE tenant = $1 AND id = $2
+		RETURNING expires_at, updated_at
+	`
+	err = tx.QueryRow(ctx, update, r.tenant, id, stored, compressed, expiresAt, now).Scan(&s.ExpiresAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("appending to snippet: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("committing append: %w", err)
+	}
+
 	return &s, nil
 }
 
+// Extend sets the expiry of an active snippet.
+func (r *PostgresRepository) Extend(ctx context.Context, id string, newExpiry time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.pool.Exec(ctx, `
+		UPDATE snippets SET expires_at = $3
+		WHERE tenant = $1 AND id = $2 AND expires_at > NOW()`,
+		r.tenant, id, newExpiry)
+	if err != nil {
+		return fmt.Errorf("extending snippet: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// idempotencyClaim is how long a claimed idempotency key waits for its
+// create before another request may take it over, and how long callers
+// wait for the result. idempotencyPoll is how often they check.
+const (
+	idempotencyClaim = 30 * time.Second
+	idempotencyPoll  = 50 * time.Millisecond
+)
+
+// GetOrSetIdempotent returns the result stored under key or calls create
+// to produce it. The key is claimed with a row whose result is NULL until
+// create returns, so that no connection is held meanwhile; concurrent
+// callers on any replica poll that row until the result appears, or take
+// over the claim if it lapses or is released by a failed create.
+func (r *PostgresRepository) GetOrSetIdempotent(ctx context.Context, key string, ttl time.Duration, create func() ([]byte, error)) ([]byte, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, idempotencyClaim)
+	defer cancel()
+
+	for {
+		claim, err := r.pool.Exec(ctx, `
+			INSERT INTO idempotency_keys (tenant, key, expires_at) VALUES ($1, $2, $3)
+			ON CONFLICT (tenant, key) DO UPDATE
+			SET result = NULL, expires_at = EXCLUDED.expires_at
+			WHERE idempotency_keys.expires_at <= NOW()`,
+			r.tenant, key, time.Now().Add(idempotencyClaim))
+		if err != nil {
+			return nil, false, fmt.Errorf("claiming idempotency key: %w", err)
+		}
+		if claim.RowsAffected() == 1 {
+			return r.runIdempotent(ctx, key, ttl, create)
+		}
+
+		var result []byte
+		err = r.pool.QueryRow(ctx,
+			`SELECT result FROM idempotency_keys WHERE tenant = $1 AND key = $2`,
+			r.tenant, key).Scan(&result)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			// The claim was just released; try to take it
+			continue
+		case err != nil:
+			return nil, false, fmt.Errorf("querying idempotency key: %w", err)
+		case result != nil:
+			return result, true, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, false, fmt.Errorf("waiting for idempotency key: %w", ctx.Err())
+		case <-time.After(idempotencyPoll):
+		}
+	}
+}
+
+// runIdempotent calls create for a claimed key and stores its result, or
+// releases the claim if it fails. The claim is settled even if ctx was
+// cancelled meanwhile.
+func (r *PostgresRepository) runIdempotent(ctx context.Context, key string, ttl time.Duration, create func() ([]byte, error)) ([]byte, bool, error) {
+	result, createErr := create()
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	if createErr != nil {
+		_, err := r.pool.Exec(ctx,
+			`DELETE FROM idempotency_keys WHERE tenant = $1 AND key = $2 AND result IS NULL`,
+			r.tenant, key)
+		if err != nil {
+			r.logger.Warn("failed to release idempotency key", "error", err)
+		}
+		return nil, false, createErr
+	}
+
+	_, err := r.pool.Exec(ctx,
+		`UPDATE idempotency_keys SET result = $3, expires_at = $4 WHERE tenant = $1 AND key = $2`,
+		r.tenant, key, append([]byte{}, result...), time.Now().Add(ttl))
+	if err != nil {
+		// The create itself succeeded; a retry will just not be replayed
+		r.logger.Warn("failed to store idempotency key", "error", err)
+	}
+	return result, false, nil
+}
+
 // Delete removes a snippet by ID.
-func (r *PostgresRepository) Delete(id string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (r *PostgresRepository) Delete(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	_, err := r.pool.Exec(ctx, "DELETE FROM snippets WHERE id = $1", id)
+	result, err := r.pool.Exec(ctx, "DELETE FROM snippets WHERE tenant = $1 AND id = $2", r.tenant, id)
 	if err != nil {
 		return fmt.Errorf("deleting snippet: %w", err)
 	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
 	return nil
 }
 
-// DeleteExpired removes all expired snippets.
-func (r *PostgresRepository) DeleteExpired() (int64, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+// DeleteByCreator removes all snippets with the given creator hash.
+func (r *PostgresRepository) DeleteByCreator(ctx context.Context, creator string) (int64, error) {
+	deleted, err := r.DeleteByCreatorReturning(ctx, creator)
+	return int64(len(deleted)), err
+}
+
+// DeleteByCreatorReturning is DeleteByCreator, returning the removed
+// snippets.
+func (r *PostgresRepository) DeleteByCreatorReturning(ctx context.Context, creator string) ([]SnippetRef, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	result, err := r.pool.Exec(ctx, "DELETE FROM snippets WHERE expires_at <= NOW()")
+	deleted, err := r.deleteReturning(ctx, "DELETE FROM snippets WHERE creator = $1 RETURNING tenant, id", creator)
 	if err != nil {
-		return 0, fmt.Errorf("deleting expired snippets: %w", err)
+		return nil, fmt.Errorf("deleting snippets by creator: %w", err)
 	}
+	return deleted, nil
+}
 
-	count := result.RowsAffected()
+// deleteReturning runs a DELETE ... RETURNING tenant, id statement.
+func (r *PostgresRepository) deleteReturning(ctx context.Context, query string, args ...any) ([]SnippetRef, error) {
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (SnippetRef, error) {
+		var ref SnippetRef
+		err := row.Scan(&ref.Tenant, &ref.ID)
+		return ref, err
+	})
+}
+
+// DeleteExpired removes all expired snippets and idempotency keys.
+func (r *PostgresRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	count, err := deleteInBatches(ctx, r.DeleteExpiredBatch)
 	if count > 0 {
 		r.logger.Info("deleted expired snippets", "count", count)
 	}
+	return count, err
+}
+
+// DeleteExpiredBatch removes up to limit expired snippets. Rows already
+// locked by a concurrent batch are skipped, so parallel batches do not wait
+// on each other.
+func (r *PostgresRepository) DeleteExpiredBatch(ctx context.Context, limit int) (int64, error) {
+	deleted, err := r.DeleteExpiredBatchReturning(ctx, limit)
+	return int64(len(deleted)), err
+}
+
+// DeleteExpiredBatchReturning is DeleteExpiredBatch, returning the removed
+// snippets.
+func (r *PostgresRepository) DeleteExpiredBatchReturning(ctx context.Context, limit int) ([]SnippetRef, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := `
+		DELETE FROM snippets
+		WHERE ctid IN (
+			SELECT ctid FROM snippets
+			WHERE expires_at <= NOW()
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING tenant, id
+	`
+
+	deleted, err := r.deleteReturning(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("deleting expired snippet batch: %w", err)
+	}
+
+	// The last, short batch of a run also removes expired idempotency keys
+	if len(deleted) < limit {
+		if err := r.deleteExpiredIdempotency(ctx); err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// deleteExpiredIdempotency removes idempotency keys past their TTL, along
+// with claims whose create never finished.
+func (r *PostgresRepository) deleteExpiredIdempotency(ctx context.Context) error {
+	_, err := r.pool.Exec(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= NOW()")
+	if err != nil {
+		return fmt.Errorf("deleting expired idempotency keys: %w", err)
+	}
+	return nil
+}
+
+// DeleteIdle removes snippets last accessed (or, if never read, created)
+// before accessedBefore, as long as they were created before createdBefore.
+// Snippets that never expire are kept.
+func (r *PostgresRepository) DeleteIdle(ctx context.Context, accessedBefore, createdBefore time.Time) (int64, error) {
+	deleted, err := r.DeleteIdleReturning(ctx, accessedBefore, createdBefore)
+	return int64(len(deleted)), err
+}
+
+// DeleteIdleReturning is DeleteIdle, returning the removed snippets.
+func (r *PostgresRepository) DeleteIdleReturning(ctx context.Context, accessedBefore, createdBefore time.Time) ([]SnippetRef, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := `
+		DELETE FROM snippets
+		WHERE COALESCE(last_accessed_at, created_at) < $1
+		  AND created_at < $2
+		  AND expires_at < $3
+		RETURNING tenant, id
+	`
+
+	deleted, err := r.deleteReturning(ctx, query, accessedBefore, createdBefore, NeverExpires)
+	if err != nil {
+		return nil, fmt.Errorf("deleting idle snippets: %w", err)
+	}
+
+	if len(deleted) > 0 {
+		r.logger.Info("deleted idle snippets", "count", len(deleted))
+	}
 
-	return count, nil
+	return deleted, nil
 }
 
 // Close releases database connections.
@@ -0,0 +1,267 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestPostgres connects to the database in TAFCHA_TEST_DATABASE_URL.
+// The tests drop and recreate the snippets table, so never point it at a
+// database holding real data.
+func newTestPostgres(t *testing.T) *PostgresRepository {
+	t.Helper()
+
+	url := os.Getenv("TAFCHA_TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TAFCHA_TEST_DATABASE_URL not set")
+	}
+
+	ctx := context.Background()
+	repo, err := NewPostgresRepository(ctx, PostgresConfig{URL: url, MaxConns: 2}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	t.Cleanup(repo.Close)
+
+	// Start from an empty schema
+	_, err = repo.pool.Exec(ctx, `DROP TABLE IF EXISTS snippets, idempotency_keys, schema_migrations`)
+	require.NoError(t, err)
+
+	return repo
+}
+
+func columnExists(t *testing.T, repo *PostgresRepository, column string) bool {
+	t.Helper()
+
+	var exists bool
+	err := repo.pool.QueryRow(context.Background(), `
+		SELECT EXISTS (
+			SELECT 1 FROM information_schema.columns
+			WHERE table_name = 'snippets' AND column_name = $1
+		)`, column).Scan(&exists)
+	require.NoError(t, err)
+	return exists
+}
+
+func tableExists(t *testing.T, repo *PostgresRepository, table string) bool {
+	t.Helper()
+
+	var exists bool
+	err := repo.pool.QueryRow(context.Background(), `SELECT to_regclass($1) IS NOT NULL`, table).Scan(&exists)
+	require.NoError(t, err)
+	return exists
+}
+
+func appliedMigrations(t *testing.T, repo *PostgresRepository) int {
+	t.Helper()
+
+	var n int
+	require.NoError(t, repo.pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM schema_migrations`).Scan(&n))
+	return n
+}
+
+func TestPostgres_MigrateDown(t *testing.T) {
+	repo := newTestPostgres(t)
+	ctx := context.Background()
+
+	migrations, err := loadMigrations(migrationsFS, "migrations")
+	require.NoError(t, err)
+
+	require.NoError(t, repo.Migrate(ctx))
+	assert.Equal(t, len(migrations), appliedMigrations(t, repo))
+	assert.True(t, tableExists(t, repo, "idempotency_keys"))
+
+	// Roll back the newest migration only
+	require.NoError(t, repo.MigrateDown(ctx, 1))
+	assert.Equal(t, len(migrations)-1, appliedMigrations(t, repo))
+	assert.False(t, tableExists(t, repo, "idempotency_keys"))
+	assert.True(t, columnExists(t, repo, "pin_hash"))
+
+	// Migrating again restores it
+	require.NoError(t, repo.Migrate(ctx))
+	assert.True(t, tableExists(t, repo, "idempotency_keys"))
+
+	// Every down migration runs cleanly back to an empty schema
+	require.NoError(t, repo.MigrateDown(ctx, len(migrations)))
+	assert.Zero(t, appliedMigrations(t, repo))
+	assert.False(t, columnExists(t, repo, "id"))
+}
+
+func TestPostgres_MigrateAppliesOnlyPending(t *testing.T) {
+	repo := newTestPostgres(t)
+	ctx := context.Background()
+
+	require.NoError(t, repo.Migrate(ctx))
+	var firstRun time.Time
+	require.NoError(t, repo.pool.QueryRow(ctx, `SELECT MAX(applied_at) FROM schema_migrations`).Scan(&firstRun))
+	_, err := repo.Create(context.Background(), &Snippet{ID: "survivor", Content: []byte("x"), ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	// A migration that ran again would fail, reset data or add rows
+	require.NoError(t, repo.Migrate(ctx))
+	migrations, err := loadMigrations(migrationsFS, "migrations")
+	require.NoError(t, err)
+	assert.Equal(t, len(migrations), appliedMigrations(t, repo))
+
+	var secondRun time.Time
+	require.NoError(t, repo.pool.QueryRow(ctx, `SELECT MAX(applied_at) FROM schema_migrations`).Scan(&secondRun))
+	assert.True(t, firstRun.Equal(secondRun), "no migration was re-applied")
+
+	got, err := repo.Peek(context.Background(), "survivor")
+	require.NoError(t, err)
+	assert.NotNil(t, got)
+}
+
+func TestPostgres_Upsert(t *testing.T) {
+	repo := newTestPostgres(t)
+	require.NoError(t, repo.Migrate(context.Background()))
+	expiresAt := time.Now().Add(time.Hour)
+
+	created, err := repo.Upsert(context.Background(), "fixed", []byte("one"), expiresAt)
+	require.NoError(t, err)
+	assert.Nil(t, created.UpdatedAt, "a new snippet is inserted")
+
+	replaced, err := repo.Upsert(context.Background(), "fixed", []byte("two"), expiresAt.Add(time.Hour))
+	require.NoError(t, err)
+	assert.NotNil(t, replaced.UpdatedAt, "an existing snippet is replaced")
+	assert.Equal(t, []byte("two"), replaced.Content)
+	assert.True(t, created.CreatedAt.Equal(replaced.CreatedAt))
+}
+
+func TestPostgres_UpsertResetsLimits(t *testing.T) {
+	repo := newTestPostgres(t)
+	require.NoError(t, repo.Migrate(context.Background()))
+
+	_, err := repo.Create(context.Background(), &Snippet{
+		ID:              "fixed",
+		Content:         []byte("one"),
+		ExpiresAt:       time.Now().Add(time.Hour),
+		MaxViews:        2,
+		AppendTokenHash: "token-hash",
+		PinHash:         "pin-hash",
+		ContentType:     "text/csv",
+	})
+	require.NoError(t, err)
+	_, err = repo.Get(context.Background(), "fixed")
+	require.NoError(t, err)
+
+	replaced, err := repo.Upsert(context.Background(), "fixed", []byte("two"), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, replaced.ViewCount)
+	assert.Zero(t, replaced.MaxViews)
+	assert.Empty(t, replaced.AppendTokenHash)
+	assert.Empty(t, replaced.PinHash)
+	assert.Empty(t, replaced.ContentType)
+
+	for i := 0; i < 3; i++ {
+		got, err := repo.Get(context.Background(), "fixed")
+		require.NoError(t, err)
+		require.NotNil(t, got, "the old view limit no longer applies")
+	}
+}
+
+func TestPostgres_Extend(t *testing.T) {
+	repo := newTestPostgres(t)
+	require.NoError(t, repo.Migrate(context.Background()))
+
+	_, err := repo.Create(context.Background(), &Snippet{ID: "extendme", Content: []byte("x"), ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	later := time.Now().Add(48 * time.Hour)
+	require.NoError(t, repo.Extend(context.Background(), "extendme", later))
+	got, err := repo.Peek(context.Background(), "extendme")
+	require.NoError(t, err)
+	assert.WithinDuration(t, later, got.ExpiresAt, time.Millisecond)
+
+	assert.ErrorIs(t, repo.Extend(context.Background(), "missing", later), ErrNotFound)
+}
+
+func TestPostgres_CreateCustomIDConflict(t *testing.T) {
+	repo := newTestPostgres(t)
+	require.NoError(t, repo.Migrate(context.Background()))
+	expiresAt := time.Now().Add(time.Hour)
+
+	customID := "my-release-notes-with-a-long-name"
+	_, err := repo.Create(context.Background(), &Snippet{ID: customID, Content: []byte("one"), ExpiresAt: expiresAt})
+	require.NoError(t, err)
+
+	_, err = repo.Create(context.Background(), &Snippet{ID: customID, Content: []byte("two"), ExpiresAt: expiresAt})
+	assert.ErrorIs(t, err, ErrConflict)
+}
+
+func TestPostgres_CompressedStorage(t *testing.T) {
+	repo := newTestPostgres(t)
+	ctx := context.Background()
+	require.NoError(t, repo.Migrate(ctx))
+	expiresAt := time.Now().Add(time.Hour)
+	large := []byte(strings.Repeat("compress me\n", 500))
+
+	// Written before compression was turned on
+	_, err := repo.Create(context.Background(), &Snippet{ID: "rawsnippet01", Content: large, ExpiresAt: expiresAt})
+	require.NoError(t, err)
+
+	repo.compress = true
+	_, err = repo.Create(context.Background(), &Snippet{ID: "zipsnippet01", Content: large, ExpiresAt: expiresAt})
+	require.NoError(t, err)
+	_, err = repo.Create(context.Background(), &Snippet{ID: "tinysnippet1", Content: []byte("tiny"), ExpiresAt: expiresAt})
+	require.NoError(t, err)
+
+	for id, want := range map[string]struct {
+		content    []byte
+		compressed bool
+	}{
+		"rawsnippet01": {large, false},
+		"zipsnippet01": {large, true},
+		"tinysnippet1": {[]byte("tiny"), false},
+	} {
+		var compressed bool
+		var stored int
+		require.NoError(t, repo.pool.QueryRow(ctx,
+			`SELECT compressed, octet_length(content) FROM snippets WHERE id = $1`, id).Scan(&compressed, &stored))
+		assert.Equal(t, want.compressed, compressed, id)
+
+		s, err := repo.Get(context.Background(), id)
+		require.NoError(t, err)
+		assert.Equal(t, want.content, s.Content, id)
+
+		meta, err := repo.GetMeta(context.Background(), id)
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(want.content)), meta.SizeBytes, id)
+	}
+
+	// Appending to a compressed row keeps it readable
+	_, err = repo.pool.Exec(ctx, `UPDATE snippets SET append_token_hash = 'h' WHERE id = 'zipsnippet01'`)
+	require.NoError(t, err)
+	appended, err := repo.Append(context.Background(), "zipsnippet01", AppendRequest{TokenHash: "h", Content: []byte("tail"), MaxSize: 1 << 20})
+	require.NoError(t, err)
+	assert.Equal(t, append(append([]byte(nil), large...), "tail"...), appended.Content)
+
+	got, err := repo.Peek(context.Background(), "zipsnippet01")
+	require.NoError(t, err)
+	assert.Equal(t, appended.Content, got.Content)
+}
+
+func TestPostgres_Stats(t *testing.T) {
+	repo := newTestPostgres(t)
+	require.NoError(t, repo.Migrate(context.Background()))
+
+	now := time.Now()
+	for _, s := range []*Snippet{
+		{ID: "soon", Content: []byte("12345"), ExpiresAt: now.Add(30 * time.Minute)},
+		{ID: "later", Content: []byte("1234567890"), ExpiresAt: now.Add(24 * time.Hour)},
+		{ID: "expired", Content: []byte("gone"), ExpiresAt: now.Add(-time.Minute)},
+	} {
+		_, err := repo.Create(context.Background(), s)
+		require.NoError(t, err)
+	}
+
+	stats, err := repo.Stats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &Stats{ActiveSnippets: 2, TotalBytes: 15, ExpiringNextHour: 1}, stats)
+}
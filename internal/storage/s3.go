@@ -0,0 +1,621 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// s3Timeout bounds every object store call made for one repository call,
+// and each page of a sweep.
+const s3Timeout = 30 * time.Second
+
+// bucketBytesMaxAge is how old the bucket size reported by Stats may be
+// before the bucket is listed again.
+const bucketBytesMaxAge = 5 * time.Minute
+
+// orphanSweepInterval is how often expiry cleanup also walks the whole
+// bucket for objects whose snippet is gone, such as those left behind when
+// a deletion failed halfway.
+const orphanSweepInterval = 24 * time.Hour
+
+// Object key prefixes. The default tenant's snippets are stored as
+// snippets/{id}, other tenants' as tenants/{tenant}/{id}.
+const (
+	defaultTenantPrefix = "snippets/"
+	tenantPrefix        = "tenants/"
+)
+
+// S3Repository implements Repository with snippet content in an
+// S3-compatible bucket and everything else in a metadata repository, whose
+// snippets are stored with empty content. Metadata is written before an
+// object appears and removed before it goes away, so a snippet is only
+// ever visible with its content.
+//
+// Bulk deletions remove exactly the objects of the snippets the metadata
+// repository reports as deleted. Objects missed that way are found by an
+// orphan sweep over the whole bucket, which expiry cleanup runs at most
+// once per orphanSweepInterval. Appends are read-modify-write on the object
+// and serialized within the process only, so they must not be spread over
+// replicas.
+type S3Repository struct {
+	meta    Repository
+	root    MetadataRepository // meta for all tenants, for bulk deletions
+	objects ObjectStore
+	logger  *slog.Logger
+	tenant  string
+
+	appendMu    *sync.Mutex
+	sweep       *sweepState
+	bucketBytes *bucketBytes
+}
+
+// sweepState serializes orphan sweeps and records when the last one
+// finished.
+type sweepState struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+// bucketBytes caches the total size of the bucket's objects.
+type bucketBytes struct {
+	mu       sync.Mutex
+	total    int64
+	measured time.Time
+}
+
+// NewS3Repository stores content in objects and metadata in meta.
+func NewS3Repository(meta MetadataRepository, objects ObjectStore, logger *slog.Logger) *S3Repository {
+	return &S3Repository{
+		meta:        meta,
+		root:        meta,
+		objects:     objects,
+		logger:      logger,
+		appendMu:    &sync.Mutex{},
+		sweep:       &sweepState{},
+		bucketBytes: &bucketBytes{},
+	}
+}
+
+// objectKey returns where a snippet's content is stored.
+func objectKey(tenant, id string) string {
+	if tenant == "" {
+		return defaultTenantPrefix + id
+	}
+	return tenantPrefix + tenant + "/" + id
+}
+
+// parseObjectKey reverses objectKey. ok is false for keys this repository
+// did not write.
+func parseObjectKey(key string) (tenant, id string, ok bool) {
+	if id, found := strings.CutPrefix(key, defaultTenantPrefix); found {
+		return "", id, id != "" && !strings.Contains(id, "/")
+	}
+	if rest, found := strings.CutPrefix(key, tenantPrefix); found {
+		tenant, id, found = strings.Cut(rest, "/")
+		return tenant, id, found && tenant != "" && id != "" && !strings.Contains(id, "/")
+	}
+	return "", "", false
+}
+
+// withoutContent returns a copy of s for the metadata repository.
+func withoutContent(s *Snippet) *Snippet {
+	c := *s
+	c.Content = []byte{}
+	return &c
+}
+
+// WithTenant returns a view of the repository scoped to tenant.
+func (r *S3Repository) WithTenant(tenant string) Repository {
+	scoped := *r
+	scoped.meta = r.meta.WithTenant(tenant)
+	scoped.tenant = tenant
+	return &scoped
+}
+
+// Create records the snippet's metadata, which fails with ErrConflict for
+// a taken ID, and then uploads its content.
+func (r *S3Repository) Create(ctx context.Context, snippet *Snippet) (*Snippet, error) {
+	stored, err := r.meta.Create(ctx, withoutContent(snippet))
+	if err != nil {
+		return nil, err
+	}
+	return r.putContent(ctx, stored, snippet.Content)
+}
+
+// CreateWithTimestamps records the snippet's metadata keeping its
+// timestamps, which fails with ErrConflict for a taken ID, and then uploads
+// its content.
+func (r *S3Repository) CreateWithTimestamps(ctx context.Context, snippet *Snippet) (*Snippet, error) {
+	stored, err := r.meta.CreateWithTimestamps(ctx, withoutContent(snippet))
+	if err != nil {
+		return nil, err
+	}
+	return r.putContent(ctx, stored, snippet.Content)
+}
+
+// putContent uploads the content of a snippet whose metadata was just
+// written, removing the metadata again if the upload fails, also when it
+// failed because ctx was cancelled.
+func (r *S3Repository) putContent(ctx context.Context, stored *Snippet, content []byte) (*Snippet, error) {
+	putCtx, cancel := context.WithTimeout(ctx, s3Timeout)
+	defer cancel()
+
+	if err := r.objects.PutObject(putCtx, objectKey(r.tenant, stored.ID), content); err != nil {
+		if delErr := r.meta.Delete(context.WithoutCancel(ctx), stored.ID); delErr != nil {
+			r.logger.Error("failed to remove metadata after a failed upload",
+				"error", delErr,
+				"snippet_id", stored.ID)
+		}
+		return nil, fmt.Errorf("storing content: %w", err)
+	}
+	stored.Content = content
+	return stored, nil
+}
+
+// Upsert stores content under id, replacing an existing snippet's content
+// and expiry.
+func (r *S3Repository) Upsert(ctx context.Context, id string, content []byte, expiresAt time.Time) (*Snippet, error) {
+	stored, err := r.meta.Upsert(ctx, id, []byte{}, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s3Timeout)
+	defer cancel()
+
+	if err := r.objects.PutObject(ctx, objectKey(r.tenant, id), content); err != nil {
+		return nil, fmt.Errorf("storing content: %w", err)
+	}
+	stored.Content = content
+	return stored, nil
+}
+
+// Get retrieves a snippet by ID, counting it as a view, and streams its
+// content from the bucket. The object of a snippet that used up its last
+// view is deleted after it was read.
+func (r *S3Repository) Get(ctx context.Context, id string) (*Snippet, error) {
+	s, err := r.meta.Get(ctx, id)
+	if err != nil || s == nil {
+		return nil, err
+	}
+	if err := r.loadContent(ctx, s); err != nil || s.Content == nil {
+		return nil, err
+	}
+
+	if s.MaxViews > 0 && s.ViewCount >= s.MaxViews {
+		r.deleteObject(id)
+	}
+	return s, nil
+}
+
+// Peek retrieves a snippet by ID without counting a view.
+func (r *S3Repository) Peek(ctx context.Context, id string) (*Snippet, error) {
+	s, err := r.meta.Peek(ctx, id)
+	if err != nil || s == nil {
+		return nil, err
+	}
+	if err := r.loadContent(ctx, s); err != nil || s.Content == nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// loadContent reads s.Content from the bucket. A missing object leaves
+// Content nil, which callers report as a missing snippet.
+func (r *S3Repository) loadContent(ctx context.Context, s *Snippet) error {
+	ctx, cancel := context.WithTimeout(ctx, s3Timeout)
+	defer cancel()
+
+	body, err := r.objects.GetObject(ctx, objectKey(r.tenant, s.ID))
+	if errors.Is(err, ErrObjectNotFound) {
+		r.logger.Warn("snippet content missing from bucket", "snippet_id", s.ID)
+		s.Content = nil
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading content: %w", err)
+	}
+	defer body.Close()
+
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("reading content: %w", err)
+	}
+	s.Content = content
+	return nil
+}
+
+// GetMeta retrieves a snippet's metadata, with its size taken from the
+// object.
+func (r *S3Repository) GetMeta(ctx context.Context, id string) (*SnippetMeta, error) {
+	m, err := r.meta.GetMeta(ctx, id)
+	if err != nil || m == nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s3Timeout)
+	defer cancel()
+
+	obj, err := r.objects.HeadObject(ctx, objectKey(r.tenant, id))
+	if errors.Is(err, ErrObjectNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading content size: %w", err)
+	}
+	m.SizeBytes = obj.Size
+	return m, nil
+}
+
+// FindByContent returns an active snippet by creator with matching content.
+// Content hashes are kept in the metadata repository.
+func (r *S3Repository) FindByContent(ctx context.Context, creator, contentHash string) (*Snippet, error) {
+	s, err := r.meta.FindByContent(ctx, creator, contentHash)
+	if err != nil || s == nil {
+		return nil, err
+	}
+	if err := r.loadContent(ctx, s); err != nil || s.Content == nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Stats returns the metadata repository's counts, with TotalBytes summed
+// over the bucket by the last orphan sweep or listing, which is at most
+// bucketBytesMaxAge old. That includes objects of expired snippets the next
+// cleanup has yet to remove.
+func (r *S3Repository) Stats(ctx context.Context) (*Stats, error) {
+	stats, err := r.meta.Stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.bucketBytes.mu.Lock()
+	total, measured := r.bucketBytes.total, r.bucketBytes.measured
+	r.bucketBytes.mu.Unlock()
+	if time.Since(measured) > bucketBytesMaxAge {
+		total = 0
+		err := r.walkObjects(ctx, func(_ context.Context, obj ObjectInfo) error {
+			total += obj.Size
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		r.setBucketBytes(total)
+	}
+	stats.TotalBytes = total
+	return stats, nil
+}
+
+func (r *S3Repository) setBucketBytes(total int64) {
+	r.bucketBytes.mu.Lock()
+	r.bucketBytes.total, r.bucketBytes.measured = total, time.Now()
+	r.bucketBytes.mu.Unlock()
+}
+
+// Append adds content to an appendable snippet. The token and size are
+// checked and the combined content is written to the bucket before the
+// metadata repository moves the expiry, so a rejected or failed append
+// leaves the snippet as it was. Should the metadata update fail after the
+// upload, the previous content is put back.
+func (r *S3Repository) Append(ctx context.Context, id string, req AppendRequest) (*Snippet, error) {
+	r.appendMu.Lock()
+	defer r.appendMu.Unlock()
+
+	current, err := r.Peek(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, ErrNotFound
+	}
+	if current.AppendTokenHash == "" || subtle.ConstantTimeCompare([]byte(current.AppendTokenHash), []byte(req.TokenHash)) != 1 {
+		return nil, ErrTokenMismatch
+	}
+	if int64(len(current.Content)+len(req.Content)) > req.MaxSize {
+		return nil, ErrTooLarge
+	}
+
+	putCtx, cancel := context.WithTimeout(ctx, s3Timeout)
+	defer cancel()
+
+	key := objectKey(r.tenant, id)
+	content := append(bytes.Clone(current.Content), req.Content...)
+	if err := r.objects.PutObject(putCtx, key, content); err != nil {
+		return nil, fmt.Errorf("appending to snippet: %w", err)
+	}
+
+	s, err := r.meta.Append(ctx, id, AppendRequest{
+		TokenHash:   req.TokenHash,
+		Content:     []byte{},
+		MaxSize:     req.MaxSize,
+		ResetExpiry: req.ResetExpiry,
+	})
+	if err != nil {
+		restoreCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s3Timeout)
+		defer cancel()
+		if putErr := r.objects.PutObject(restoreCtx, key, current.Content); putErr != nil {
+			r.logger.Error("failed to restore content after a failed append",
+				"error", putErr,
+				"snippet_id", id)
+		}
+		return nil, err
+	}
+	s.Content = content
+	return s, nil
+}
+
+// ReserveUpload creates the snippet's metadata with an expiry at the end of
+// the upload window and presigns the upload of its object. Until the
+// upload is completed the snippet is served once its object exists, like
+// any other, but only for that window.
+func (r *S3Repository) ReserveUpload(ctx context.Context, tenant string, snippet *Snippet, size int64, ttl time.Duration) (string, error) {
+	reserved := withoutContent(snippet)
+	reserved.ExpiresAt = time.Now().Add(ttl)
+
+	meta := r.root.WithTenant(tenant)
+	if _, err := meta.Create(ctx, reserved); err != nil {
+		return "", err
+	}
+
+	uploadURL, err := r.objects.PresignPut(objectKey(tenant, snippet.ID), size, ttl)
+	if err != nil {
+		if delErr := meta.Delete(context.WithoutCancel(ctx), snippet.ID); delErr != nil {
+			r.logger.Error("failed to remove metadata after a failed presign",
+				"error", delErr,
+				"snippet_id", snippet.ID)
+		}
+		return "", fmt.Errorf("presigning upload: %w", err)
+	}
+	return uploadURL, nil
+}
+
+// CompleteUpload looks up the uploaded object of a reserved snippet and,
+// if it is no larger than maxSize, moves the snippet's expiry from the end
+// of the upload window to the one expiresAt picks.
+func (r *S3Repository) CompleteUpload(ctx context.Context, tenant, id, tokenHash string, maxSize int64, expiresAt func(size int64) time.Time) (*SnippetMeta, error) {
+	meta := r.root.WithTenant(tenant)
+	s, err := meta.Peek(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return nil, ErrNotFound
+	}
+	if s.DeleteTokenHash == "" || subtle.ConstantTimeCompare([]byte(s.DeleteTokenHash), []byte(tokenHash)) != 1 {
+		return nil, ErrTokenMismatch
+	}
+
+	headCtx, cancel := context.WithTimeout(ctx, s3Timeout)
+	defer cancel()
+
+	obj, err := r.objects.HeadObject(headCtx, objectKey(tenant, id))
+	if errors.Is(err, ErrObjectNotFound) {
+		return nil, ErrNotUploaded
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading content size: %w", err)
+	}
+	if obj.Size > maxSize {
+		if err := r.WithTenant(tenant).Delete(ctx, id); err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		return nil, ErrTooLarge
+	}
+
+	m := snippetMeta(s)
+	m.ExpiresAt, m.SizeBytes = expiresAt(obj.Size), obj.Size
+	if err := meta.Extend(ctx, id, m.ExpiresAt); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Extend changes the expiry in the metadata; the object is unaffected.
+func (r *S3Repository) Extend(ctx context.Context, id string, newExpiry time.Time) error {
+	return r.meta.Extend(ctx, id, newExpiry)
+}
+
+// GetOrSetIdempotent keeps idempotency keys in the metadata repository.
+func (r *S3Repository) GetOrSetIdempotent(ctx context.Context, key string, ttl time.Duration, create func() ([]byte, error)) ([]byte, bool, error) {
+	return r.meta.GetOrSetIdempotent(ctx, key, ttl, create)
+}
+
+// Delete removes a snippet's metadata and then its object.
+func (r *S3Repository) Delete(ctx context.Context, id string) error {
+	if err := r.meta.Delete(ctx, id); err != nil {
+		return err
+	}
+	r.deleteObject(id)
+	return nil
+}
+
+// deleteObject removes a snippet's object whose metadata is already gone.
+// A failure is only logged since the next orphan sweep retries it.
+func (r *S3Repository) deleteObject(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+	defer cancel()
+
+	if err := r.objects.DeleteObject(ctx, objectKey(r.tenant, id)); err != nil {
+		r.logger.Warn("failed to delete snippet content",
+			"error", err,
+			"snippet_id", id)
+	}
+}
+
+// DeleteExpired removes all expired snippets and then their objects.
+func (r *S3Repository) DeleteExpired(ctx context.Context) (int64, error) {
+	total, err := deleteInBatches(ctx, r.DeleteExpiredBatch)
+	if total > 0 {
+		r.logger.Info("deleted expired snippets", "count", total)
+	}
+	return total, err
+}
+
+// DeleteExpiredBatch removes at most limit expired snippets and then their
+// objects. The last, short batch of a cleanup run also starts an orphan
+// sweep when the previous one is more than orphanSweepInterval old.
+func (r *S3Repository) DeleteExpiredBatch(ctx context.Context, limit int) (int64, error) {
+	started := time.Now()
+	deleted, err := r.root.DeleteExpiredBatchReturning(ctx, limit)
+	r.deleteObjects(ctx, deleted, started)
+	if err != nil || len(deleted) >= limit {
+		return int64(len(deleted)), err
+	}
+	return int64(len(deleted)), r.sweepOrphans(ctx)
+}
+
+// DeleteIdle removes idle snippets and then their objects.
+func (r *S3Repository) DeleteIdle(ctx context.Context, accessedBefore, createdBefore time.Time) (int64, error) {
+	started := time.Now()
+	deleted, err := r.root.DeleteIdleReturning(ctx, accessedBefore, createdBefore)
+	r.deleteObjects(ctx, deleted, started)
+	return int64(len(deleted)), err
+}
+
+// DeleteByCreator removes all of a creator's snippets and then their
+// objects.
+func (r *S3Repository) DeleteByCreator(ctx context.Context, creator string) (int64, error) {
+	started := time.Now()
+	deleted, err := r.root.DeleteByCreatorReturning(ctx, creator)
+	r.deleteObjects(ctx, deleted, started)
+	return int64(len(deleted)), err
+}
+
+// deleteObjects removes the objects of snippets whose metadata was deleted
+// at or after started. Failures are logged and left to the orphan sweep.
+func (r *S3Repository) deleteObjects(ctx context.Context, deleted []SnippetRef, started time.Time) {
+	for _, ref := range deleted {
+		key := objectKey(ref.Tenant, ref.ID)
+		if _, err := r.deleteStaleObject(ctx, key, started); err != nil {
+			r.logger.Warn("failed to delete snippet content",
+				"error", err,
+				"tenant", ref.Tenant,
+				"snippet_id", ref.ID)
+		}
+	}
+}
+
+// deleteStaleObject deletes the object at key unless it was written at or
+// after cutoff, which means a new snippet took the ID after the old one's
+// metadata was removed. S3 keeps modification times to the second, so
+// cutoff is rounded down to match. Reports whether the object was deleted.
+func (r *S3Repository) deleteStaleObject(ctx context.Context, key string, cutoff time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, s3Timeout)
+	defer cancel()
+
+	obj, err := r.objects.HeadObject(ctx, key)
+	if errors.Is(err, ErrObjectNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !obj.LastModified.Before(cutoff.Truncate(time.Second)) {
+		return false, nil
+	}
+	return true, r.objects.DeleteObject(ctx, key)
+}
+
+// sweepOrphans deletes every object whose snippet is gone, one page of the
+// bucket at a time, unless another sweep is running or the last one is less
+// than orphanSweepInterval old. Objects written after the sweep started
+// are left alone, as their snippet may have been created since. A sweep
+// that fails partway leaves the remaining objects to the next one.
+func (r *S3Repository) sweepOrphans(ctx context.Context) error {
+	if !r.sweep.mu.TryLock() {
+		return nil
+	}
+	defer r.sweep.mu.Unlock()
+
+	started := time.Now()
+	if started.Sub(r.sweep.last) < orphanSweepInterval {
+		return nil
+	}
+
+	removed := 0
+	var kept int64
+	err := r.walkObjects(ctx, func(ctx context.Context, obj ObjectInfo) error {
+		tenant, id, _ := parseObjectKey(obj.Key)
+		m, err := r.root.WithTenant(tenant).GetMeta(ctx, id)
+		if err != nil {
+			return err
+		}
+		if m != nil {
+			kept += obj.Size
+			return nil
+		}
+		deleted, err := r.deleteStaleObject(ctx, obj.Key, started)
+		if err != nil {
+			return err
+		}
+		if deleted {
+			removed++
+		} else {
+			kept += obj.Size
+		}
+		return nil
+	})
+	if removed > 0 {
+		r.logger.Info("removed content of deleted snippets", "objects", removed)
+	}
+	if err != nil {
+		return fmt.Errorf("sweeping objects: %w", err)
+	}
+	r.sweep.last = started
+	r.setBucketBytes(kept)
+	return nil
+}
+
+// walkObjects calls fn for every object this repository wrote, for all
+// tenants. Each page is listed and handled under its own s3Timeout, which
+// fn receives as ctx.
+func (r *S3Repository) walkObjects(ctx context.Context, fn func(ctx context.Context, obj ObjectInfo) error) error {
+	for _, prefix := range []string{defaultTenantPrefix, tenantPrefix} {
+		after := ""
+		for more := true; more; {
+			var err error
+			more, after, err = r.walkPage(ctx, prefix, after, fn)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// walkPage handles the page of objects after the key after, returning
+// whether more follow and the key to continue from.
+func (r *S3Repository) walkPage(ctx context.Context, prefix, after string, fn func(ctx context.Context, obj ObjectInfo) error) (bool, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s3Timeout)
+	defer cancel()
+
+	listed, more, err := r.objects.ListObjects(ctx, prefix, after)
+	if err != nil {
+		return false, "", err
+	}
+	for _, obj := range listed {
+		after = obj.Key
+		if _, _, ok := parseObjectKey(obj.Key); !ok {
+			continue
+		}
+		if err := fn(ctx, obj); err != nil {
+			return false, "", err
+		}
+	}
+	return more, after, nil
+}
+
+// Close releases the metadata repository.
+func (r *S3Repository) Close() {
+	r.meta.Close()
+}
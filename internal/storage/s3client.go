@@ -0,0 +1,390 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrObjectNotFound is returned by an ObjectStore for a missing key.
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// ObjectStore is the subset of an S3-compatible API that S3Repository
+// uses. S3Client implements it.
+type ObjectStore interface {
+	// PutObject stores data under key, replacing any existing object.
+	PutObject(ctx context.Context, key string, data []byte) error
+
+	// GetObject streams the object at key. The caller closes the reader.
+	// Returns ErrObjectNotFound if there is none.
+	GetObject(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// HeadObject describes the object at key, or returns
+	// ErrObjectNotFound.
+	HeadObject(ctx context.Context, key string) (ObjectInfo, error)
+
+	// DeleteObject removes the object at key. Deleting a missing object
+	// is not an error.
+	DeleteObject(ctx context.Context, key string) error
+
+	// ListObjects returns one page of the objects whose key starts with
+	// prefix, in key order and beginning after the key startAfter. more
+	// reports whether further pages follow.
+	ListObjects(ctx context.Context, prefix, startAfter string) (objects []ObjectInfo, more bool, err error)
+
+	// PresignPut returns a URL that lets whoever holds it upload exactly
+	// size bytes to key, until expires has passed.
+	PresignPut(key string, size int64, expires time.Duration) (string, error)
+}
+
+// S3Config locates an S3-compatible bucket.
+type S3Config struct {
+	Endpoint        string // e.g. https://s3.eu-west-1.amazonaws.com or http://localhost:9000
+	Bucket          string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// S3Client is a minimal S3 API client. It sends path-style requests
+// (endpoint/bucket/key), which every S3-compatible server accepts, signed
+// with AWS Signature Version 4.
+type S3Client struct {
+	cfg        S3Config
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+// NewS3Client returns a client for the bucket in cfg.
+func NewS3Client(cfg S3Config) *S3Client {
+	cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	return &S3Client{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: time.Minute},
+		now:        time.Now,
+	}
+}
+
+// emptyPayloadHash is the SHA-256 of an empty body.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// PutObject stores data under key.
+func (c *S3Client) PutObject(ctx context.Context, key string, data []byte) error {
+	sum := sha256.Sum256(data)
+	resp, err := c.do(ctx, http.MethodPut, c.objectPath(key), "", bytes.NewReader(data), hex.EncodeToString(sum[:]))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp, "putting "+key)
+	}
+	return nil
+}
+
+// GetObject streams the object at key.
+func (c *S3Client) GetObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodGet, c.objectPath(key), "", nil, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrObjectNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, s3Error(resp, "getting "+key)
+	}
+	return resp.Body, nil
+}
+
+// HeadObject returns the size and modification time of the object at key.
+func (c *S3Client) HeadObject(ctx context.Context, key string) (ObjectInfo, error) {
+	resp, err := c.do(ctx, http.MethodHead, c.objectPath(key), "", nil, emptyPayloadHash)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		modified, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+		if err != nil {
+			return ObjectInfo{}, fmt.Errorf("s3: heading %s: parsing Last-Modified: %w", key, err)
+		}
+		return ObjectInfo{Key: key, Size: resp.ContentLength, LastModified: modified}, nil
+	case http.StatusNotFound:
+		return ObjectInfo{}, ErrObjectNotFound
+	default:
+		return ObjectInfo{}, fmt.Errorf("s3: heading %s: %s", key, resp.Status)
+	}
+}
+
+// DeleteObject removes the object at key.
+func (c *S3Client) DeleteObject(ctx context.Context, key string) error {
+	resp, err := c.do(ctx, http.MethodDelete, c.objectPath(key), "", nil, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s3Error(resp, "deleting "+key)
+	}
+	return nil
+}
+
+// listBucketResult is the part of a ListObjectsV2 response that is used.
+type listBucketResult struct {
+	Contents    []listedObject `xml:"Contents"`
+	IsTruncated bool           `xml:"IsTruncated"`
+}
+
+// listedObject is one entry of a ListObjectsV2 response.
+type listedObject struct {
+	Key          string    `xml:"Key"`
+	Size         int64     `xml:"Size"`
+	LastModified time.Time `xml:"LastModified"`
+}
+
+// ListObjects returns one page of up to 1000 objects whose key starts with
+// prefix, after the key startAfter.
+func (c *S3Client) ListObjects(ctx context.Context, prefix, startAfter string) ([]ObjectInfo, bool, error) {
+	params := map[string]string{"list-type": "2", "prefix": prefix}
+	if startAfter != "" {
+		params["start-after"] = startAfter
+	}
+	resp, err := c.do(ctx, http.MethodGet, "/"+c.cfg.Bucket, canonicalQuery(params), nil, emptyPayloadHash)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, s3Error(resp, "listing "+prefix)
+	}
+
+	var page listBucketResult
+	if err := xml.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, false, fmt.Errorf("s3: decoding list: %w", err)
+	}
+	objects := make([]ObjectInfo, 0, len(page.Contents))
+	for _, obj := range page.Contents {
+		objects = append(objects, ObjectInfo{Key: obj.Key, Size: obj.Size, LastModified: obj.LastModified})
+	}
+	return objects, page.IsTruncated && len(objects) > 0, nil
+}
+
+// PresignPut returns a URL for a PUT of size bytes to key, valid for
+// expires. The size is signed, so a body of any other length is refused.
+func (c *S3Client) PresignPut(key string, size int64, expires time.Duration) (string, error) {
+	u, err := url.Parse(c.cfg.Endpoint + c.objectPath(key))
+	if err != nil {
+		return "", fmt.Errorf("s3: presigning %s: %w", key, err)
+	}
+	c.presign(http.MethodPut, u, map[string]string{"content-length": strconv.FormatInt(size, 10)}, expires)
+	return u.String(), nil
+}
+
+func (c *S3Client) objectPath(key string) string {
+	return "/" + c.cfg.Bucket + "/" + s3Escape(key, false)
+}
+
+// do sends a signed request. path and query must already be escaped the
+// way Signature Version 4 expects.
+func (c *S3Client) do(ctx context.Context, method, path, query string, body io.Reader, payloadHash string) (*http.Response, error) {
+	target := c.cfg.Endpoint + path
+	if query != "" {
+		target += "?" + query
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, fmt.Errorf("s3: creating request: %w", err)
+	}
+	c.sign(req, payloadHash)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3: %s %s: %w", method, path, err)
+	}
+	return resp, nil
+}
+
+// sign adds Signature Version 4 headers to req, signing the host and every
+// header already set on it.
+func (c *S3Client) sign(req *http.Request, payloadHash string) {
+	now := c.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := c.scope(day)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.cfg.AccessKeyID, scope, signedHeaders, c.signature(canonicalRequest, amzDate, day)))
+}
+
+// presign adds Signature Version 4 query parameters to u, which is then
+// valid for a method request with the given headers until expires has
+// passed. The payload is not signed.
+func (c *S3Client) presign(method string, u *url.URL, headers map[string]string, expires time.Duration) {
+	now := c.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+
+	signed := map[string]string{"host": u.Host}
+	for name, value := range headers {
+		signed[strings.ToLower(name)] = value
+	}
+	names := make([]string, 0, len(signed))
+	for name := range signed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + signed[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	query := canonicalQuery(map[string]string{
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    c.cfg.AccessKeyID + "/" + c.scope(day),
+		"X-Amz-Date":          amzDate,
+		"X-Amz-Expires":       strconv.FormatInt(int64(expires/time.Second), 10),
+		"X-Amz-SignedHeaders": signedHeaders,
+	})
+	canonicalRequest := strings.Join([]string{
+		method,
+		u.EscapedPath(),
+		query,
+		canonicalHeaders.String(),
+		signedHeaders,
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	u.RawQuery = query + "&X-Amz-Signature=" + c.signature(canonicalRequest, amzDate, day)
+}
+
+// scope is the credential scope of a signature made on day.
+func (c *S3Client) scope(day string) string {
+	return day + "/" + c.cfg.Region + "/s3/aws4_request"
+}
+
+// signature signs canonicalRequest as of amzDate, which falls on day.
+func (c *S3Client) signature(canonicalRequest, amzDate, day string) string {
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + c.scope(day) + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.cfg.SecretAccessKey), day)
+	key = hmacSHA256(key, c.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes params sorted by name, as Signature Version 4
+// requires.
+func canonicalQuery(params map[string]string) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = s3Escape(name, true) + "=" + s3Escape(params[name], true)
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Escape percent-encodes everything but unreserved characters, and "/"
+// too when escapeSlash is set.
+func s3Escape(s string, escapeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !escapeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3Error turns an unexpected response into an error, including the S3
+// error code when the body has one.
+func s3Error(resp *http.Response, action string) error {
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.NewDecoder(io.LimitReader(resp.Body, 4<<10)).Decode(&body) == nil && body.Code != "" {
+		return fmt.Errorf("s3: %s: %s: %s: %s", action, resp.Status, body.Code, body.Message)
+	}
+	return fmt.Errorf("s3: %s: %s", action, resp.Status)
+}
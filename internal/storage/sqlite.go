@@ -0,0 +1,736 @@
+package storage
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3" // also registers the "sqlite3" driver
+)
+
+//go:embed migrations/sqlite/*.sql
+var sqliteMigrationsFS embed.FS
+
+// SQLiteRepository implements Repository using a local SQLite database.
+// It suits single-node deployments; timestamps are stored as Unix
+// microseconds and compared against the clock of this process.
+type SQLiteRepository struct {
+	db     *sql.DB
+	logger *slog.Logger
+	tenant string
+
+	// idempotencyMu serializes idempotent creates. A database file has a
+	// single writing process, so this is enough to make them atomic.
+	idempotencyMu *sync.Mutex
+}
+
+// IsSQLiteURL reports whether a DATABASE_URL selects the SQLite backend.
+func IsSQLiteURL(url string) bool {
+	return strings.HasPrefix(url, "sqlite://")
+}
+
+// NewSQLiteRepository opens the SQLite database named by url, which has the
+// form sqlite://path (sqlite:///abs/path for an absolute path, or
+// sqlite://:memory: for a throwaway in-memory database).
+func NewSQLiteRepository(ctx context.Context, url string, logger *slog.Logger) (*SQLiteRepository, error) {
+	path, ok := strings.CutPrefix(url, "sqlite://")
+	if !ok || path == "" {
+		return nil, fmt.Errorf("parsing database URL: expected sqlite://path")
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("opening sqlite database: %w", err)
+	}
+
+	// SQLite allows a single writer; one connection serializes transactions
+	// instead of failing them with SQLITE_BUSY, and keeps :memory: databases
+	// alive for the life of the repository.
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxLifetime(0)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("pinging database: %w", err)
+	}
+
+	return &SQLiteRepository{db: db, logger: logger, idempotencyMu: &sync.Mutex{}}, nil
+}
+
+// createSQLiteMigrationsTable is createMigrationsTable in SQLite syntax.
+const createSQLiteMigrationsTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    TEXT PRIMARY KEY,
+		applied_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)
+`
+
+// Migrate applies the SQLite migrations that have not been applied yet.
+func (r *SQLiteRepository) Migrate(ctx context.Context) error {
+	migrations, err := loadMigrations(sqliteMigrationsFS, "migrations/sqlite")
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.db.ExecContext(ctx, createSQLiteMigrationsTable); err != nil {
+		return fmt.Errorf("creating schema_migrations: %w", err)
+	}
+
+	applied := 0
+	for _, m := range migrations {
+		upSQL, err := sqliteMigrationsFS.ReadFile(m.Up)
+		if err != nil {
+			return fmt.Errorf("reading migration file %s: %w", m.Up, err)
+		}
+
+		var ran bool
+		err = r.inTx(ctx, func(tx *sql.Tx) error {
+			var done int
+			if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations WHERE version = ?`, m.Version).Scan(&done); err != nil {
+				return err
+			}
+			if done > 0 {
+				return nil
+			}
+			if _, err := tx.ExecContext(ctx, string(upSQL)); err != nil {
+				return err
+			}
+			ran = true
+			_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES (?)`, m.Version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("executing migration %s: %w", m.Up, err)
+		}
+		if ran {
+			applied++
+			r.logger.Info("applied migration", "version", m.Version)
+		}
+	}
+
+	r.logger.Info("database migration completed", "migrations", len(migrations), "applied", applied)
+	return nil
+}
+
+// MigrateDown rolls back the n most recently applied migrations, newest
+// first, each in its own transaction.
+func (r *SQLiteRepository) MigrateDown(ctx context.Context, n int) error {
+	migrations, err := loadMigrations(sqliteMigrationsFS, "migrations/sqlite")
+	if err != nil {
+		return err
+	}
+	byVersion := make(map[string]migration, len(migrations))
+	for _, m := range migrations {
+		byVersion[m.Version] = m
+	}
+
+	if _, err := r.db.ExecContext(ctx, createSQLiteMigrationsTable); err != nil {
+		return fmt.Errorf("creating schema_migrations: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT version FROM schema_migrations ORDER BY version DESC LIMIT ?`, n)
+	if err != nil {
+		return fmt.Errorf("listing applied migrations: %w", err)
+	}
+	var applied []string
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return fmt.Errorf("listing applied migrations: %w", err)
+		}
+		applied = append(applied, version)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("listing applied migrations: %w", err)
+	}
+
+	for _, version := range applied {
+		m, ok := byVersion[version]
+		if !ok || m.Down == "" {
+			return fmt.Errorf("migration %s has no down migration", version)
+		}
+		downSQL, err := sqliteMigrationsFS.ReadFile(m.Down)
+		if err != nil {
+			return fmt.Errorf("reading migration file %s: %w", m.Down, err)
+		}
+
+		err = r.inTx(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, string(downSQL)); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("rolling back migration %s: %w", version, err)
+		}
+		r.logger.Info("rolled back migration", "version", version)
+	}
+
+	return nil
+}
+
+// inTx runs fn in a transaction, committing when it returns nil.
+func (r *SQLiteRepository) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// WithTenant returns a copy of the repository scoped to tenant.
+func (r *SQLiteRepository) WithTenant(tenant string) Repository {
+	scoped := *r
+	scoped.tenant = tenant
+	return &scoped
+}
+
+// Create stores a new snippet.
+func (r *SQLiteRepository) Create(ctx context.Context, snippet *Snippet) (*Snippet, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO snippets (tenant, id, content, expires_at, creator, append_token_hash, content_hash,
+		                      delete_token_hash, max_views, content_type, pin_hash, created_at)
+		VALUES (?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, 0), NULLIF(?, ''),
+		        NULLIF(?, ''), ?)
+	`
+
+	now := time.UnixMicro(time.Now().UnixMicro())
+	snippet.Tenant = r.tenant
+	_, err := r.db.ExecContext(ctx, query,
+		r.tenant, snippet.ID, snippet.Content, snippet.ExpiresAt.UnixMicro(), snippet.Creator, snippet.AppendTokenHash,
+		snippet.ContentHash, snippet.DeleteTokenHash, snippet.MaxViews, snippet.ContentType, snippet.PinHash,
+		now.UnixMicro(),
+	)
+	if isSQLiteConstraint(err) {
+		return nil, ErrConflict
+	}
+	if err != nil {
+		return nil, fmt.Errorf("inserting snippet: %w", err)
+	}
+	snippet.CreatedAt = now
+
+	return snippet, nil
+}
+
+// CreateWithTimestamps stores a snippet with explicit creation and expiry
+// times instead of stamping the current time.
+func (r *SQLiteRepository) CreateWithTimestamps(ctx context.Context, snippet *Snippet) (*Snippet, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO snippets (tenant, id, content, expires_at, creator, content_hash, created_at)
+		VALUES (?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), ?)
+	`
+
+	snippet.Tenant = r.tenant
+	_, err := r.db.ExecContext(ctx, query,
+		r.tenant, snippet.ID, snippet.Content, snippet.ExpiresAt.UnixMicro(), snippet.Creator, snippet.ContentHash,
+		snippet.CreatedAt.UnixMicro(),
+	)
+	if isSQLiteConstraint(err) {
+		return nil, ErrConflict
+	}
+	if err != nil {
+		return nil, fmt.Errorf("importing snippet: %w", err)
+	}
+
+	return snippet, nil
+}
+
+// isSQLiteConstraint reports whether err is a primary key or unique
+// constraint violation.
+func isSQLiteConstraint(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) &&
+		(sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey || sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique)
+}
+
+// scanSQLiteSnippet scans the columns listed in snippetColumns, converting
+// the stored microsecond timestamps.
+func scanSQLiteSnippet(row *sql.Row) (*Snippet, error) {
+	var s Snippet
+	var expiresAt, createdAt int64
+	var lastAccessedAt, updatedAt sql.NullInt64
+	var compressed bool
+	err := row.Scan(
+		&s.Tenant, &s.ID, &s.Content, &expiresAt, &createdAt, &lastAccessedAt, &s.Creator,
+		&s.ContentHash, &s.AppendTokenHash, &s.DeleteTokenHash,
+		&updatedAt, &s.MaxViews, &s.ViewCount, &compressed, &s.ContentType,
+		&s.PinHash,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if s.Content, err = decompressContent(s.Content, compressed); err != nil {
+		return nil, err
+	}
+
+	s.ExpiresAt = time.UnixMicro(expiresAt)
+	s.CreatedAt = time.UnixMicro(createdAt)
+	s.LastAccessedAt = nullMicros(lastAccessedAt)
+	s.UpdatedAt = nullMicros(updatedAt)
+	return &s, nil
+}
+
+func nullMicros(v sql.NullInt64) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	t := time.UnixMicro(v.Int64)
+	return &t
+}
+
+// Upsert stores content under id, replacing an existing snippet's content
+// and expiry. The stale content hash is cleared on replacement.
+func (r *SQLiteRepository) Upsert(ctx context.Context, id string, content []byte, expiresAt time.Time) (*Snippet, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO snippets (tenant, id, content, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (tenant, id) DO UPDATE
+		SET content = excluded.content, expires_at = excluded.expires_at,
+		    updated_at = excluded.created_at, content_hash = NULL,
+		    view_count = 0, max_views = NULL, append_token_hash = NULL, pin_hash = NULL,
+		    content_type = NULL
+		RETURNING ` + snippetColumns
+
+	now := time.Now().UnixMicro()
+	s, err := scanSQLiteSnippet(r.db.QueryRowContext(ctx, query, r.tenant, id, content, expiresAt.UnixMicro(), now))
+	if err != nil {
+		return nil, fmt.Errorf("upserting snippet: %w", err)
+	}
+
+	return s, nil
+}
+
+// Get retrieves a snippet by ID, records the access time and counts the
+// view. A snippet that reaches its view limit is deleted in the same
+// transaction. Returns nil if not found or expired.
+func (r *SQLiteRepository) Get(ctx context.Context, id string) (*Snippet, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var s *Snippet
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		now := time.Now().UnixMicro()
+		query := `
+			UPDATE snippets
+			SET last_accessed_at = ?, view_count = view_count + 1
+			WHERE tenant = ? AND id = ? AND expires_at > ?
+			  AND (max_views IS NULL OR view_count < max_views)
+			RETURNING ` + snippetColumns
+
+		var err error
+		s, err = scanSQLiteSnippet(tx.QueryRowContext(ctx, query, now, r.tenant, id, now))
+		if err != nil {
+			return err
+		}
+
+		if s.MaxViews > 0 && s.ViewCount >= s.MaxViews {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM snippets WHERE tenant = ? AND id = ?", r.tenant, id); err != nil {
+				return fmt.Errorf("burning snippet: %w", err)
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying snippet: %w", err)
+	}
+
+	return s, nil
+}
+
+// Peek retrieves a snippet by ID without recording an access or a view.
+// Returns nil if not found or expired.
+func (r *SQLiteRepository) Peek(ctx context.Context, id string) (*Snippet, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + snippetColumns + `
+		FROM snippets
+		WHERE tenant = ? AND id = ? AND expires_at > ?`
+
+	s, err := scanSQLiteSnippet(r.db.QueryRowContext(ctx, query, r.tenant, id, time.Now().UnixMicro()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying snippet: %w", err)
+	}
+
+	return s, nil
+}
+
+// GetMeta retrieves a snippet's metadata without reading the content column.
+// Returns nil if not found or expired.
+func (r *SQLiteRepository) GetMeta(ctx context.Context, id string) (*SnippetMeta, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT tenant, id, expires_at, created_at, updated_at, length(CAST(content AS BLOB)),
+		       view_count, COALESCE(content_type, '')
+		FROM snippets
+		WHERE tenant = ? AND id = ? AND expires_at > ?`
+
+	var m SnippetMeta
+	var expiresAt, createdAt int64
+	var updatedAt sql.NullInt64
+	err := r.db.QueryRowContext(ctx, query, r.tenant, id, time.Now().UnixMicro()).Scan(
+		&m.Tenant, &m.ID, &expiresAt, &createdAt, &updatedAt, &m.SizeBytes, &m.ViewCount, &m.ContentType,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying snippet metadata: %w", err)
+	}
+
+	m.ExpiresAt = time.UnixMicro(expiresAt)
+	m.CreatedAt = time.UnixMicro(createdAt)
+	m.UpdatedAt = nullMicros(updatedAt)
+	return &m, nil
+}
+
+// FindByContent returns the newest active snippet from creator, or from
+// anyone for an empty creator, with the given content hash, without
+// recording an access. Snippets with a view limit are never returned.
+func (r *SQLiteRepository) FindByContent(ctx context.Context, creator, contentHash string) (*Snippet, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + snippetColumns + `
+		FROM snippets
+		WHERE tenant = ? AND (? = '' OR creator = ?) AND content_hash = ? AND expires_at > ?
+		  AND max_views IS NULL
+		ORDER BY created_at DESC
+		LIMIT 1`
+
+	s, err := scanSQLiteSnippet(r.db.QueryRowContext(ctx, query, r.tenant, creator, creator, contentHash, time.Now().UnixMicro()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying snippet by content: %w", err)
+	}
+
+	return s, nil
+}
+
+// Stats returns aggregate counts over active snippets across all tenants.
+func (r *SQLiteRepository) Stats(ctx context.Context) (*Stats, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT COUNT(*), COALESCE(SUM(length(content)), 0),
+		       COALESCE(SUM(expires_at <= ?), 0)
+		FROM snippets
+		WHERE expires_at > ?`
+
+	now := time.Now()
+	var st Stats
+	err := r.db.QueryRowContext(ctx, query, now.Add(time.Hour).UnixMicro(), now.UnixMicro()).
+		Scan(&st.ActiveSnippets, &st.TotalBytes, &st.ExpiringNextHour)
+	if err != nil {
+		return nil, fmt.Errorf("querying stats: %w", err)
+	}
+	return &st, nil
+}
+
+// Append adds content to an appendable snippet. The single connection
+// serializes concurrent appends, so the size limit holds.
+func (r *SQLiteRepository) Append(ctx context.Context, id string, req AppendRequest) (*Snippet, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var s Snippet
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		now := time.UnixMicro(time.Now().UnixMicro())
+		query := `
+			SELECT tenant, id, expires_at, created_at, updated_at,
+			       COALESCE(append_token_hash, ''), length(CAST(content AS BLOB))
+			FROM snippets
+			WHERE tenant = ? AND id = ? AND expires_at > ?
+		`
+
+		var expiresAt, createdAt int64
+		var updatedAt sql.NullInt64
+		var size int64
+		err := tx.QueryRowContext(ctx, query, r.tenant, id, now.UnixMicro()).Scan(
+			&s.Tenant, &s.ID, &expiresAt, &createdAt, &updatedAt, &s.AppendTokenHash, &size,
+		)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("locking snippet: %w", err)
+		}
+		s.ExpiresAt = time.UnixMicro(expiresAt)
+		s.CreatedAt = time.UnixMicro(createdAt)
+		s.UpdatedAt = nullMicros(updatedAt)
+
+		if s.AppendTokenHash == "" || subtle.ConstantTimeCompare([]byte(s.AppendTokenHash), []byte(req.TokenHash)) != 1 {
+			return ErrTokenMismatch
+		}
+		if size+int64(len(req.Content)) > req.MaxSize {
+			return ErrTooLarge
+		}
+
+		expires := AppendExpiry(&s, req.ResetExpiry, now)
+
+		update := `
+			UPDATE snippets
+			SET content = CAST(content || ? AS BLOB), expires_at = ?, updated_at = ?, content_hash = NULL
+			WHERE tenant = ? AND id = ?
+			RETURNING content
+		`
+		if err := tx.QueryRowContext(ctx, update, req.Content, expires.UnixMicro(), now.UnixMicro(), r.tenant, id).Scan(&s.Content); err != nil {
+			return fmt.Errorf("appending to snippet: %w", err)
+		}
+		s.ExpiresAt = expires
+		s.UpdatedAt = &now
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrTokenMismatch) || errors.Is(err, ErrTooLarge) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("appending to snippet: %w", err)
+	}
+
+	return &s, nil
+}
+
+// Extend sets the expiry of an active snippet.
+func (r *SQLiteRepository) Extend(ctx context.Context, id string, newExpiry time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE snippets SET expires_at = ?
+		WHERE tenant = ? AND id = ? AND expires_at > ?`,
+		newExpiry.UnixMicro(), r.tenant, id, time.Now().UnixMicro())
+	if err != nil {
+		return fmt.Errorf("extending snippet: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetOrSetIdempotent returns the result stored under key or calls create
+// to produce it, one call at a time.
+func (r *SQLiteRepository) GetOrSetIdempotent(ctx context.Context, key string, ttl time.Duration, create func() ([]byte, error)) ([]byte, bool, error) {
+	r.idempotencyMu.Lock()
+	defer r.idempotencyMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var result []byte
+	err := r.db.QueryRowContext(ctx, `
+		SELECT result FROM idempotency_keys
+		WHERE tenant = ? AND key = ? AND expires_at > ?`,
+		r.tenant, key, time.Now().UnixMicro()).Scan(&result)
+	if err == nil {
+		return result, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, fmt.Errorf("querying idempotency key: %w", err)
+	}
+
+	if result, err = create(); err != nil {
+		return nil, false, err
+	}
+
+	ctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO idempotency_keys (tenant, key, result, expires_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (tenant, key) DO UPDATE
+		SET result = excluded.result, expires_at = excluded.expires_at`,
+		r.tenant, key, append([]byte{}, result...), time.Now().Add(ttl).UnixMicro())
+	if err != nil {
+		// The create itself succeeded; a retry will just not be replayed
+		r.logger.Warn("failed to store idempotency key", "error", err)
+	}
+	return result, false, nil
+}
+
+// Delete removes a snippet by ID.
+func (r *SQLiteRepository) Delete(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, "DELETE FROM snippets WHERE tenant = ? AND id = ?", r.tenant, id)
+	if err != nil {
+		return fmt.Errorf("deleting snippet: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteByCreator removes all snippets with the given creator hash.
+func (r *SQLiteRepository) DeleteByCreator(ctx context.Context, creator string) (int64, error) {
+	deleted, err := r.DeleteByCreatorReturning(ctx, creator)
+	return int64(len(deleted)), err
+}
+
+// DeleteByCreatorReturning is DeleteByCreator, returning the removed
+// snippets.
+func (r *SQLiteRepository) DeleteByCreatorReturning(ctx context.Context, creator string) ([]SnippetRef, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	deleted, err := r.deleteReturning(ctx, "DELETE FROM snippets WHERE creator = ? RETURNING tenant, id", creator)
+	if err != nil {
+		return nil, fmt.Errorf("deleting snippets by creator: %w", err)
+	}
+	return deleted, nil
+}
+
+// deleteReturning runs a DELETE ... RETURNING tenant, id statement.
+func (r *SQLiteRepository) deleteReturning(ctx context.Context, query string, args ...any) ([]SnippetRef, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deleted []SnippetRef
+	for rows.Next() {
+		var ref SnippetRef
+		if err := rows.Scan(&ref.Tenant, &ref.ID); err != nil {
+			return nil, err
+		}
+		deleted = append(deleted, ref)
+	}
+	return deleted, rows.Err()
+}
+
+// DeleteExpired removes all expired snippets and idempotency keys.
+func (r *SQLiteRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	count, err := deleteInBatches(ctx, r.DeleteExpiredBatch)
+	if count > 0 {
+		r.logger.Info("deleted expired snippets", "count", count)
+	}
+	return count, err
+}
+
+// DeleteExpiredBatch removes up to limit expired snippets. Batches run one
+// at a time on the single connection, so they never overlap.
+func (r *SQLiteRepository) DeleteExpiredBatch(ctx context.Context, limit int) (int64, error) {
+	deleted, err := r.DeleteExpiredBatchReturning(ctx, limit)
+	return int64(len(deleted)), err
+}
+
+// DeleteExpiredBatchReturning is DeleteExpiredBatch, returning the removed
+// snippets.
+func (r *SQLiteRepository) DeleteExpiredBatchReturning(ctx context.Context, limit int) ([]SnippetRef, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := `
+		DELETE FROM snippets
+		WHERE rowid IN (
+			SELECT rowid FROM snippets
+			WHERE expires_at <= ?
+			LIMIT ?
+		)
+		RETURNING tenant, id
+	`
+
+	deleted, err := r.deleteReturning(ctx, query, time.Now().UnixMicro(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("deleting expired snippet batch: %w", err)
+	}
+
+	// The last, short batch of a run also removes expired idempotency keys
+	if len(deleted) < limit {
+		if err := r.deleteExpiredIdempotency(ctx); err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// deleteExpiredIdempotency removes idempotency keys past their TTL.
+func (r *SQLiteRepository) deleteExpiredIdempotency(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= ?", time.Now().UnixMicro())
+	if err != nil {
+		return fmt.Errorf("deleting expired idempotency keys: %w", err)
+	}
+	return nil
+}
+
+// DeleteIdle removes snippets last accessed (or, if never read, created)
+// before accessedBefore, as long as they were created before createdBefore.
+// Snippets that never expire are kept.
+func (r *SQLiteRepository) DeleteIdle(ctx context.Context, accessedBefore, createdBefore time.Time) (int64, error) {
+	deleted, err := r.DeleteIdleReturning(ctx, accessedBefore, createdBefore)
+	return int64(len(deleted)), err
+}
+
+// DeleteIdleReturning is DeleteIdle, returning the removed snippets.
+func (r *SQLiteRepository) DeleteIdleReturning(ctx context.Context, accessedBefore, createdBefore time.Time) ([]SnippetRef, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := `
+		DELETE FROM snippets
+		WHERE COALESCE(last_accessed_at, created_at) < ?
+		  AND created_at < ?
+		  AND expires_at < ?
+		RETURNING tenant, id
+	`
+
+	deleted, err := r.deleteReturning(ctx, query, accessedBefore.UnixMicro(), createdBefore.UnixMicro(), NeverExpires.UnixMicro())
+	if err != nil {
+		return nil, fmt.Errorf("deleting idle snippets: %w", err)
+	}
+
+	if len(deleted) > 0 {
+		r.logger.Info("deleted idle snippets", "count", len(deleted))
+	}
+
+	return deleted, nil
+}
+
+// Close releases the database connection.
+func (r *SQLiteRepository) Close() {
+	r.db.Close()
+}
+
+// Ping checks database connectivity.
+func (r *SQLiteRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
@@ -0,0 +1,492 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSQLite(t *testing.T) *SQLiteRepository {
+	t.Helper()
+
+	url := "sqlite://" + filepath.Join(t.TempDir(), "tafcha.db")
+	repo, err := NewSQLiteRepository(context.Background(), url, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	t.Cleanup(repo.Close)
+
+	require.NoError(t, repo.Migrate(context.Background()))
+	return repo
+}
+
+func TestIsSQLiteURL(t *testing.T) {
+	assert.True(t, IsSQLiteURL("sqlite:///var/lib/tafcha.db"))
+	assert.True(t, IsSQLiteURL("sqlite://:memory:"))
+	assert.False(t, IsSQLiteURL("postgres://localhost/tafcha"))
+	assert.False(t, IsSQLiteURL("postgresql://localhost/tafcha"))
+}
+
+func TestNewSQLiteRepository_InvalidURL(t *testing.T) {
+	_, err := NewSQLiteRepository(context.Background(), "sqlite://", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	assert.Error(t, err)
+}
+
+func TestSQLite_MigrateIsIdempotent(t *testing.T) {
+	repo := newTestSQLite(t)
+	ctx := context.Background()
+
+	require.NoError(t, repo.Migrate(ctx))
+
+	migrations, err := loadMigrations(sqliteMigrationsFS, "migrations/sqlite")
+	require.NoError(t, err)
+	var n int
+	require.NoError(t, repo.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&n))
+	assert.Equal(t, len(migrations), n)
+
+	// The ALTER TABLE migrations would fail if they ran a second time
+	require.NoError(t, repo.Migrate(ctx))
+	require.NoError(t, repo.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&n))
+	assert.Equal(t, len(migrations), n)
+	assert.NoError(t, repo.Ping(ctx))
+}
+
+func TestSQLite_MigrateDown(t *testing.T) {
+	repo := newTestSQLite(t)
+	ctx := context.Background()
+
+	migrations, err := loadMigrations(sqliteMigrationsFS, "migrations/sqlite")
+	require.NoError(t, err)
+	require.NoError(t, repo.MigrateDown(ctx, len(migrations)))
+	_, err = repo.Peek(context.Background(), "any")
+	assert.Error(t, err, "snippets table is dropped")
+
+	require.NoError(t, repo.Migrate(ctx))
+	got, err := repo.Peek(context.Background(), "any")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestSQLite_CreateAndGet(t *testing.T) {
+	repo := newTestSQLite(t)
+
+	expiresAt := time.Now().Add(time.Hour)
+	created, err := repo.Create(context.Background(), &Snippet{
+		ID:              "abc123",
+		Content:         []byte("hello"),
+		ExpiresAt:       expiresAt,
+		Creator:         "creator",
+		ContentHash:     "blake3:ff",
+		DeleteTokenHash: "delete",
+		ContentType:     "application/json",
+		PinHash:         "pin",
+	})
+	require.NoError(t, err)
+	assert.False(t, created.CreatedAt.IsZero())
+
+	got, err := repo.Get(context.Background(), "abc123")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, []byte("hello"), got.Content)
+	assert.Equal(t, expiresAt.UnixMicro(), got.ExpiresAt.UnixMicro())
+	assert.Equal(t, "creator", got.Creator)
+	assert.Equal(t, "blake3:ff", got.ContentHash)
+	assert.Equal(t, "delete", got.DeleteTokenHash)
+	assert.Equal(t, "application/json", got.ContentType)
+	assert.Equal(t, "pin", got.PinHash)
+	assert.Equal(t, 1, got.ViewCount)
+	assert.NotNil(t, got.LastAccessedAt)
+	assert.Nil(t, got.UpdatedAt)
+
+	missing, err := repo.Get(context.Background(), "nope")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+}
+
+func TestSQLite_Upsert(t *testing.T) {
+	repo := newTestSQLite(t)
+	expiresAt := time.Now().Add(time.Hour)
+
+	created, err := repo.Upsert(context.Background(), "fixed", []byte("one"), expiresAt)
+	require.NoError(t, err)
+	assert.Nil(t, created.UpdatedAt, "a new snippet is inserted")
+	assert.Equal(t, []byte("one"), created.Content)
+
+	_, err = repo.Create(context.Background(), &Snippet{ID: "fixed", Content: []byte("x"), ExpiresAt: expiresAt})
+	assert.ErrorIs(t, err, ErrConflict, "Create still refuses a duplicate ID")
+
+	replaced, err := repo.Upsert(context.Background(), "fixed", []byte("two"), expiresAt.Add(time.Hour))
+	require.NoError(t, err)
+	assert.NotNil(t, replaced.UpdatedAt, "an existing snippet is replaced")
+	assert.Equal(t, []byte("two"), replaced.Content)
+	assert.Equal(t, expiresAt.Add(time.Hour).UnixMicro(), replaced.ExpiresAt.UnixMicro())
+	assert.True(t, created.CreatedAt.Equal(replaced.CreatedAt))
+}
+
+func TestSQLite_UpsertResetsLimits(t *testing.T) {
+	repo := newTestSQLite(t)
+
+	_, err := repo.Create(context.Background(), &Snippet{
+		ID:              "fixed",
+		Content:         []byte("one"),
+		ExpiresAt:       time.Now().Add(time.Hour),
+		MaxViews:        2,
+		AppendTokenHash: "token-hash",
+		PinHash:         "pin-hash",
+		ContentType:     "text/csv",
+	})
+	require.NoError(t, err)
+	_, err = repo.Get(context.Background(), "fixed")
+	require.NoError(t, err)
+
+	replaced, err := repo.Upsert(context.Background(), "fixed", []byte("two"), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, replaced.ViewCount)
+	assert.Zero(t, replaced.MaxViews)
+	assert.Empty(t, replaced.AppendTokenHash)
+	assert.Empty(t, replaced.PinHash)
+	assert.Empty(t, replaced.ContentType)
+
+	for i := 0; i < 3; i++ {
+		got, err := repo.Get(context.Background(), "fixed")
+		require.NoError(t, err)
+		require.NotNil(t, got, "the old view limit no longer applies")
+	}
+}
+
+func TestSQLite_GetMeta(t *testing.T) {
+	repo := newTestSQLite(t)
+
+	_, err := repo.Create(context.Background(), &Snippet{ID: "meta", Content: []byte("hello"), ExpiresAt: time.Now().Add(time.Hour), MaxViews: 1, ContentType: "text/csv"})
+	require.NoError(t, err)
+
+	meta, err := repo.GetMeta(context.Background(), "meta")
+	require.NoError(t, err)
+	require.NotNil(t, meta)
+	assert.Equal(t, int64(5), meta.SizeBytes)
+	assert.Equal(t, "text/csv", meta.ContentType)
+	assert.Nil(t, meta.UpdatedAt)
+	assert.Zero(t, meta.ViewCount)
+
+	got, err := repo.Get(context.Background(), "meta")
+	require.NoError(t, err)
+	assert.NotNil(t, got, "GetMeta did not use up the only view")
+
+	meta, err = repo.GetMeta(context.Background(), "missing")
+	require.NoError(t, err)
+	assert.Nil(t, meta)
+}
+
+func TestSQLite_ViewCount(t *testing.T) {
+	repo := newTestSQLite(t)
+
+	_, err := repo.Create(context.Background(), &Snippet{ID: "counted", Content: []byte("x"), ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	for i := 1; i <= 3; i++ {
+		got, err := repo.Get(context.Background(), "counted")
+		require.NoError(t, err)
+		require.NotNil(t, got)
+		assert.Equal(t, i, got.ViewCount, "Get returns the count including itself")
+	}
+
+	_, err = repo.Peek(context.Background(), "counted")
+	require.NoError(t, err)
+
+	meta, err := repo.GetMeta(context.Background(), "counted")
+	require.NoError(t, err)
+	require.NotNil(t, meta)
+	assert.Equal(t, 3, meta.ViewCount, "Peek and GetMeta are not views")
+}
+
+func TestSQLite_GetFiltersExpired(t *testing.T) {
+	repo := newTestSQLite(t)
+
+	_, err := repo.Create(context.Background(), &Snippet{ID: "old", Content: []byte("x"), ExpiresAt: time.Now().Add(-time.Minute)})
+	require.NoError(t, err)
+
+	got, err := repo.Get(context.Background(), "old")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	peeked, err := repo.Peek(context.Background(), "old")
+	require.NoError(t, err)
+	assert.Nil(t, peeked)
+}
+
+func TestSQLite_GetBurnsAtMaxViews(t *testing.T) {
+	repo := newTestSQLite(t)
+
+	_, err := repo.Create(context.Background(), &Snippet{ID: "burn", Content: []byte("x"), ExpiresAt: time.Now().Add(time.Hour), MaxViews: 2})
+	require.NoError(t, err)
+
+	for i := 1; i <= 2; i++ {
+		got, err := repo.Get(context.Background(), "burn")
+		require.NoError(t, err)
+		require.NotNil(t, got, "view %d", i)
+		assert.Equal(t, i, got.ViewCount)
+	}
+
+	got, err := repo.Get(context.Background(), "burn")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestSQLite_PeekDoesNotCountViews(t *testing.T) {
+	repo := newTestSQLite(t)
+
+	_, err := repo.Create(context.Background(), &Snippet{ID: "peek", Content: []byte("x"), ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	got, err := repo.Peek(context.Background(), "peek")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, 0, got.ViewCount)
+	assert.Nil(t, got.LastAccessedAt)
+}
+
+func TestSQLite_Tenants(t *testing.T) {
+	repo := newTestSQLite(t)
+	acme := repo.WithTenant("acme")
+
+	_, err := acme.Create(context.Background(), &Snippet{ID: "same", Content: []byte("acme"), ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = repo.Create(context.Background(), &Snippet{ID: "same", Content: []byte("default"), ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	got, err := acme.Peek(context.Background(), "same")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "acme", got.Tenant)
+	assert.Equal(t, []byte("acme"), got.Content)
+
+	require.NoError(t, repo.Delete(context.Background(), "same"))
+	got, err = acme.Peek(context.Background(), "same")
+	require.NoError(t, err)
+	assert.NotNil(t, got, "deleting in one tenant leaves the other alone")
+}
+
+func TestSQLite_CreateWithTimestamps(t *testing.T) {
+	repo := newTestSQLite(t)
+
+	createdAt := time.Now().Add(-time.Hour).Truncate(time.Microsecond)
+	_, err := repo.CreateWithTimestamps(context.Background(), &Snippet{
+		ID: "imported", Content: []byte("x"), CreatedAt: createdAt, ExpiresAt: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	got, err := repo.Peek(context.Background(), "imported")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.True(t, createdAt.Equal(got.CreatedAt))
+
+	_, err = repo.CreateWithTimestamps(context.Background(), &Snippet{
+		ID: "imported", Content: []byte("y"), CreatedAt: createdAt, ExpiresAt: time.Now().Add(time.Hour),
+	})
+	assert.ErrorIs(t, err, ErrConflict)
+}
+
+func TestSQLite_FindByContent(t *testing.T) {
+	repo := newTestSQLite(t)
+	future := time.Now().Add(time.Hour)
+
+	_, err := repo.Create(context.Background(), &Snippet{ID: "limited", Content: []byte("x"), ExpiresAt: future, Creator: "c", ContentHash: "h", MaxViews: 1})
+	require.NoError(t, err)
+
+	got, err := repo.FindByContent(context.Background(), "c", "h")
+	require.NoError(t, err)
+	assert.Nil(t, got, "snippets with a view limit are not reused")
+
+	_, err = repo.Create(context.Background(), &Snippet{ID: "plain", Content: []byte("x"), ExpiresAt: future, Creator: "c", ContentHash: "h"})
+	require.NoError(t, err)
+
+	got, err = repo.FindByContent(context.Background(), "c", "h")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "plain", got.ID)
+
+	got, err = repo.FindByContent(context.Background(), "other", "h")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	got, err = repo.FindByContent(context.Background(), "", "h")
+	require.NoError(t, err)
+	require.NotNil(t, got, "an empty creator matches anyone")
+	assert.Equal(t, "plain", got.ID)
+}
+
+func TestSQLite_Append(t *testing.T) {
+	repo := newTestSQLite(t)
+
+	_, err := repo.Create(context.Background(), &Snippet{
+		ID: "log", Content: []byte("one\n"), ExpiresAt: time.Now().Add(time.Hour),
+		AppendTokenHash: "token", ContentHash: "h",
+	})
+	require.NoError(t, err)
+
+	got, err := repo.Append(context.Background(), "log", AppendRequest{TokenHash: "token", Content: []byte("two\n"), MaxSize: 100})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("one\ntwo\n"), got.Content)
+	assert.NotNil(t, got.UpdatedAt)
+
+	peeked, err := repo.Peek(context.Background(), "log")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("one\ntwo\n"), peeked.Content)
+	assert.Equal(t, "", peeked.ContentHash, "appending clears the content hash")
+
+	_, err = repo.Append(context.Background(), "log", AppendRequest{TokenHash: "wrong", Content: []byte("x"), MaxSize: 100})
+	assert.ErrorIs(t, err, ErrTokenMismatch)
+
+	_, err = repo.Append(context.Background(), "log", AppendRequest{TokenHash: "token", Content: []byte("three\n"), MaxSize: 10})
+	assert.ErrorIs(t, err, ErrTooLarge)
+
+	_, err = repo.Append(context.Background(), "missing", AppendRequest{TokenHash: "token", Content: []byte("x"), MaxSize: 100})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSQLite_Delete(t *testing.T) {
+	repo := newTestSQLite(t)
+
+	_, err := repo.Create(context.Background(), &Snippet{ID: "gone", Content: []byte("x"), ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	require.NoError(t, repo.Delete(context.Background(), "gone"))
+	assert.ErrorIs(t, repo.Delete(context.Background(), "gone"), ErrNotFound)
+}
+
+func TestSQLite_DeleteExpired(t *testing.T) {
+	repo := newTestSQLite(t)
+
+	for _, id := range []string{"e1", "e2", "e3"} {
+		_, err := repo.Create(context.Background(), &Snippet{ID: id, Content: []byte("x"), ExpiresAt: time.Now().Add(-time.Minute)})
+		require.NoError(t, err)
+	}
+	_, err := repo.Create(context.Background(), &Snippet{ID: "live", Content: []byte("x"), ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	n, err := repo.DeleteExpiredBatch(context.Background(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	n, err = repo.DeleteExpired(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	got, err := repo.Peek(context.Background(), "live")
+	require.NoError(t, err)
+	assert.NotNil(t, got)
+}
+
+func TestSQLite_DeleteExpiredInBatches(t *testing.T) {
+	defer func(n int) { deleteBatchSize = n }(deleteBatchSize)
+	deleteBatchSize = 10
+
+	repo := newTestSQLite(t)
+	for i := 0; i < 95; i++ {
+		_, err := repo.Create(context.Background(), &Snippet{ID: fmt.Sprintf("expired%03d", i), Content: []byte("x"), ExpiresAt: time.Now().Add(-time.Minute)})
+		require.NoError(t, err)
+	}
+	_, err := repo.Create(context.Background(), &Snippet{ID: "live", Content: []byte("x"), ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	var batches int
+	n, err := deleteInBatches(context.Background(), func(ctx context.Context, limit int) (int64, error) {
+		batches++
+		return repo.DeleteExpiredBatch(ctx, limit)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(95), n)
+	assert.Equal(t, 10, batches)
+
+	var left int
+	require.NoError(t, repo.db.QueryRow("SELECT COUNT(*) FROM snippets").Scan(&left))
+	assert.Equal(t, 1, left, "only the live snippet is left")
+}
+
+func TestSQLite_Extend(t *testing.T) {
+	repo := newTestSQLite(t)
+
+	_, err := repo.Create(context.Background(), &Snippet{ID: "live", Content: []byte("x"), ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = repo.Create(context.Background(), &Snippet{ID: "old", Content: []byte("x"), ExpiresAt: time.Now().Add(-time.Second)})
+	require.NoError(t, err)
+
+	later := time.Now().Add(48 * time.Hour)
+	require.NoError(t, repo.Extend(context.Background(), "live", later))
+	got, err := repo.Peek(context.Background(), "live")
+	require.NoError(t, err)
+	assert.WithinDuration(t, later, got.ExpiresAt, time.Millisecond)
+
+	assert.ErrorIs(t, repo.Extend(context.Background(), "old", later), ErrNotFound)
+	assert.ErrorIs(t, repo.Extend(context.Background(), "missing", later), ErrNotFound)
+}
+
+func TestSQLite_DeleteIdleAndByCreator(t *testing.T) {
+	repo := newTestSQLite(t)
+	future := time.Now().Add(time.Hour)
+
+	_, err := repo.CreateWithTimestamps(context.Background(), &Snippet{ID: "idle", Content: []byte("x"), CreatedAt: time.Now().Add(-48 * time.Hour), ExpiresAt: future})
+	require.NoError(t, err)
+	_, err = repo.Create(context.Background(), &Snippet{ID: "fresh", Content: []byte("x"), ExpiresAt: future, Creator: "c"})
+	require.NoError(t, err)
+
+	_, err = repo.CreateWithTimestamps(context.Background(), &Snippet{ID: "permanent", Content: []byte("x"), CreatedAt: time.Now().Add(-48 * time.Hour), ExpiresAt: NeverExpires})
+	require.NoError(t, err)
+
+	n, err := repo.DeleteIdle(context.Background(), time.Now().Add(-24*time.Hour), time.Now().Add(-24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	n, err = repo.DeleteExpired(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	got, err := repo.Peek(context.Background(), "permanent")
+	require.NoError(t, err)
+	assert.NotNil(t, got, "snippets that never expire survive cleanup")
+
+	n, err = repo.DeleteByCreator(context.Background(), "c")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
+
+func TestSQLite_DeleteReturning(t *testing.T) {
+	repo := newTestSQLite(t)
+
+	_, err := repo.Create(context.Background(), &Snippet{ID: "old", Content: []byte("x"), ExpiresAt: time.Now().Add(-time.Minute)})
+	require.NoError(t, err)
+	_, err = repo.WithTenant("acme").Create(context.Background(), &Snippet{ID: "mine", Content: []byte("x"), ExpiresAt: time.Now().Add(time.Hour), Creator: "c"})
+	require.NoError(t, err)
+
+	deleted, err := repo.DeleteExpiredBatchReturning(context.Background(), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []SnippetRef{{ID: "old"}}, deleted)
+
+	deleted, err = repo.DeleteByCreatorReturning(context.Background(), "c")
+	require.NoError(t, err)
+	assert.Equal(t, []SnippetRef{{Tenant: "acme", ID: "mine"}}, deleted)
+}
+
+func TestSQLite_Stats(t *testing.T) {
+	repo := newTestSQLite(t)
+
+	now := time.Now()
+	for _, s := range []*Snippet{
+		{ID: "soon", Content: []byte("12345"), ExpiresAt: now.Add(30 * time.Minute)},
+		{ID: "later", Content: []byte("1234567890"), ExpiresAt: now.Add(24 * time.Hour)},
+		{ID: "expired", Content: []byte("gone"), ExpiresAt: now.Add(-time.Minute)},
+	} {
+		_, err := repo.Create(context.Background(), s)
+		require.NoError(t, err)
+	}
+	_, err := repo.WithTenant("acme").Create(context.Background(), &Snippet{ID: "soon", Content: []byte("abc"), ExpiresAt: now.Add(time.Minute)})
+	require.NoError(t, err)
+
+	stats, err := repo.Stats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &Stats{ActiveSnippets: 3, TotalBytes: 18, ExpiringNextHour: 2}, stats)
+}
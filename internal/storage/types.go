@@ -1,14 +1,133 @@
 // Package storage provides database operations for snippets.
 package storage
 
-import "time"
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Errors returned by repository operations that modify existing snippets.
+var (
+	ErrNotFound      = errors.New("snippet not found or expired")
+	ErrTokenMismatch = errors.New("token does not match")
+	ErrTooLarge      = errors.New("content exceeds maximum size")
+	ErrConflict      = errors.New("snippet ID already exists")
+	ErrNotUploaded   = errors.New("content has not been uploaded")
+)
 
 // Snippet represents a stored text snippet.
 type Snippet struct {
+	Tenant    string    `json:"-"` // empty for the default tenant
 	ID        string    `json:"id"`
 	Content   []byte    `json:"-"`          // Not exposed in JSON responses
 	ExpiresAt time.Time `json:"expires_at"`
 	CreatedAt time.Time `json:"created_at"`
+
+	// LastAccessedAt is nil until the snippet is first read.
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+
+	// Creator is an opaque hash identifying who created the snippet.
+	Creator string `json:"-"`
+
+	// AppendTokenHash is set for appendable snippets only.
+	AppendTokenHash string `json:"-"`
+
+	// DeleteTokenHash authorizes DELETE /{id}. Empty for imported snippets.
+	DeleteTokenHash string `json:"-"`
+
+	// PinHash is set for snippets that need a PIN to be read.
+	PinHash string `json:"-"`
+
+	// ContentHash is the algorithm-marked hash of Content at creation time
+	// (see hash.Split). Appending clears it since the content changed.
+	ContentHash string `json:"-"`
+
+	// UpdatedAt is nil until content is first appended.
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+
+	// MaxViews deletes the snippet once ViewCount reaches it. 0 means unlimited.
+	MaxViews int `json:"-"`
+
+	// ViewCount is how many times Get returned the snippet, including the
+	// read that returned this value.
+	ViewCount int `json:"view_count"`
+
+	// ContentType is the media type declared at creation, e.g.
+	// application/json. Empty means text/plain.
+	ContentType string `json:"-"`
+}
+
+// SnippetMeta describes a snippet without its content.
+type SnippetMeta struct {
+	Tenant    string
+	ID        string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UpdatedAt *time.Time // nil until content is first appended or replaced
+	SizeBytes int64
+	ViewCount int
+
+	ContentType string // empty means text/plain
+}
+
+// snippetMeta returns the metadata of a snippet already held in memory.
+func snippetMeta(s *Snippet) *SnippetMeta {
+	return &SnippetMeta{
+		Tenant:    s.Tenant,
+		ID:        s.ID,
+		ExpiresAt: s.ExpiresAt,
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+		SizeBytes: int64(len(s.Content)),
+		ViewCount: s.ViewCount,
+
+		ContentType: s.ContentType,
+	}
+}
+
+// LastModified returns when the content last changed.
+func (m *SnippetMeta) LastModified() time.Time {
+	if m.UpdatedAt != nil {
+		return *m.UpdatedAt
+	}
+	return m.CreatedAt
+}
+
+// Stats are aggregate counts over the active snippets of all tenants.
+type Stats struct {
+	ActiveSnippets   int64
+	TotalBytes       int64 // content bytes as stored, i.e. after compression
+	ExpiringNextHour int64
+}
+
+// AppendRequest describes content to add to an appendable snippet.
+type AppendRequest struct {
+	TokenHash   string
+	Content     []byte
+	MaxSize     int64 // limit on the combined content
+	ResetExpiry bool  // see AppendExpiry
+}
+
+// NeverExpires is the expires_at of snippets that never expire. Expiry
+// cleanup never reaches it, and idle cleanup skips such snippets.
+var NeverExpires = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// deleteBatchSize is how many expired snippets DeleteExpired removes per
+// statement.
+var deleteBatchSize = 1000
+
+// deleteInBatches calls batch with deleteBatchSize until a batch comes back
+// short, and returns the total removed.
+func deleteInBatches(ctx context.Context, batch func(context.Context, int) (int64, error)) (int64, error) {
+	var total int64
+	for {
+		n, err := batch(ctx, deleteBatchSize)
+		total += n
+		if err != nil || n < int64(deleteBatchSize) {
+			return total, err
+		}
+	}
 }
 
 // IsExpired checks if the snippet has expired.
@@ -16,20 +135,156 @@ func (s *Snippet) IsExpired() bool {
 	return time.Now().After(s.ExpiresAt)
 }
 
+// AppendExpiry returns the expiry a snippet gets after an append. A snippet
+// that never expires keeps NeverExpires either way.
+//
+// Without reset the original expires_at is kept, so appending never extends
+// a snippet's life. With reset the snippet gets its original TTL again,
+// counted from now. The TTL is measured from the last modification (or
+// creation), which keeps it constant across repeated resets.
+func AppendExpiry(s *Snippet, reset bool, now time.Time) time.Time {
+	if !reset || !s.ExpiresAt.Before(NeverExpires) {
+		return s.ExpiresAt
+	}
+	since := s.CreatedAt
+	if s.UpdatedAt != nil {
+		since = *s.UpdatedAt
+	}
+	return now.Add(s.ExpiresAt.Sub(since))
+}
+
 // Repository defines the interface for snippet storage operations.
+//
+// Create, CreateWithTimestamps, Get, Append, Extend and Delete act within
+// the repository's tenant. Bulk deletions act across all tenants.
+//
+// Methods taking a context give up when it is cancelled, as when the client
+// of a request disconnects; database backends apply their own query
+// timeout on top of it.
 type Repository interface {
-	// Create stores a new snippet.
-	Create(id string, content []byte, expiresAt time.Time) (*Snippet, error)
+	// WithTenant returns a view of the repository scoped to tenant.
+	// The returned repository shares the underlying connections.
+	WithTenant(tenant string) Repository
+
+	// Create stores a new snippet. CreatedAt is set by the repository.
+	// Returns ErrConflict if a snippet with the same ID exists, even an
+	// expired one that has not been cleaned up yet.
+	Create(ctx context.Context, snippet *Snippet) (*Snippet, error)
+
+	// CreateWithTimestamps stores a snippet keeping its CreatedAt and
+	// ExpiresAt exactly as given. Only used for admin imports. Returns
+	// ErrConflict like Create.
+	CreateWithTimestamps(ctx context.Context, snippet *Snippet) (*Snippet, error)
+
+	// Upsert stores content under id, replacing the content and expiry of an
+	// existing snippet with that ID instead of failing. A replaced snippet
+	// starts over without a view limit, append token, PIN or content type. Only for flows that
+	// intend to overwrite; random IDs go through Create so that collisions
+	// are detected. UpdatedAt is set on the returned snippet when an
+	// existing one was replaced.
+	Upsert(ctx context.Context, id string, content []byte, expiresAt time.Time) (*Snippet, error)
+
+	// Get retrieves a snippet by ID, counting it as a view. A snippet that
+	// reaches MaxViews is deleted atomically with the read, so it is
+	// returned exactly MaxViews times. Returns nil if not found or expired.
+	Get(ctx context.Context, id string) (*Snippet, error)
+
+	// Peek retrieves a snippet by ID without counting a view or recording
+	// an access. Returns nil if not found or expired.
+	Peek(ctx context.Context, id string) (*Snippet, error)
+
+	// GetMeta retrieves a snippet's metadata by ID without reading its
+	// content, counting a view or recording an access. Returns nil if not
+	// found or expired.
+	GetMeta(ctx context.Context, id string) (*SnippetMeta, error)
+
+	// FindByContent returns an active snippet by creator whose content hash
+	// matches contentHash, ignoring snippets with a view limit. An empty
+	// creator matches every creator. Returns nil if there is none.
+	FindByContent(ctx context.Context, creator, contentHash string) (*Snippet, error)
+
+	// Stats returns aggregate counts over active snippets across all
+	// tenants.
+	Stats(ctx context.Context) (*Stats, error)
+
+	// Append adds content to an appendable snippet. Returns ErrNotFound,
+	// ErrTokenMismatch or ErrTooLarge when the append is not possible.
+	Append(ctx context.Context, id string, req AppendRequest) (*Snippet, error)
+
+	// Extend sets the expiry of an active snippet to newExpiry, which may
+	// also be earlier than the current one. Returns ErrNotFound if there is
+	// no such snippet or it has already expired.
+	Extend(ctx context.Context, id string, newExpiry time.Time) error
 
-	// Get retrieves a snippet by ID. Returns nil if not found or expired.
-	Get(id string) (*Snippet, error)
+	// GetOrSetIdempotent returns the result stored under key, with replayed
+	// set, unless it has expired. Otherwise it calls create and stores the
+	// result for ttl. Concurrent calls with the same key, including from
+	// other replicas sharing the database, wait for the first and replay its
+	// result; if create fails nothing is stored and the next caller runs
+	// create itself. Keys are scoped to the tenant.
+	GetOrSetIdempotent(ctx context.Context, key string, ttl time.Duration, create func() ([]byte, error)) (result []byte, replayed bool, err error)
 
-	// Delete removes a snippet by ID.
-	Delete(id string) error
+	// Delete removes a snippet by ID. Returns ErrNotFound if it does not exist.
+	Delete(ctx context.Context, id string) error
 
 	// DeleteExpired removes all expired snippets. Returns the count of deleted snippets.
-	DeleteExpired() (int64, error)
+	// Database backends delete in batches so no statement holds its locks
+	// for long on a large table.
+	DeleteExpired(ctx context.Context) (int64, error)
+
+	// DeleteExpiredBatch removes at most limit expired snippets and returns
+	// how many it removed. Concurrent calls delete disjoint rows.
+	DeleteExpiredBatch(ctx context.Context, limit int) (int64, error)
+
+	// DeleteIdle removes snippets not accessed since accessedBefore that were
+	// created before createdBefore. Returns the count of deleted snippets.
+	DeleteIdle(ctx context.Context, accessedBefore, createdBefore time.Time) (int64, error)
+
+	// DeleteByCreator removes all snippets with the given creator hash.
+	// Returns the count of deleted snippets.
+	DeleteByCreator(ctx context.Context, creator string) (int64, error)
 
 	// Close releases database connections.
 	Close()
 }
+
+// SnippetRef identifies a snippet in any tenant.
+type SnippetRef struct {
+	Tenant string
+	ID     string
+}
+
+// MetadataRepository is a Repository whose bulk deletions can also report
+// the snippets they removed. S3Repository keeps its metadata in one, so it
+// can remove exactly the objects of those snippets.
+type MetadataRepository interface {
+	Repository
+
+	// DeleteExpiredBatchReturning is DeleteExpiredBatch, returning the
+	// removed snippets.
+	DeleteExpiredBatchReturning(ctx context.Context, limit int) ([]SnippetRef, error)
+
+	// DeleteIdleReturning is DeleteIdle, returning the removed snippets.
+	DeleteIdleReturning(ctx context.Context, accessedBefore, createdBefore time.Time) ([]SnippetRef, error)
+
+	// DeleteByCreatorReturning is DeleteByCreator, returning the removed
+	// snippets.
+	DeleteByCreatorReturning(ctx context.Context, creator string) ([]SnippetRef, error)
+}
+
+// Uploader lets clients upload snippet content straight to object storage
+// rather than through the API. S3Repository implements it.
+type Uploader interface {
+	// ReserveUpload records the metadata of snippet in tenant and returns
+	// a URL its content of size bytes can be uploaded to within ttl. The
+	// snippet expires at the end of that window unless the upload is
+	// completed.
+	ReserveUpload(ctx context.Context, tenant string, snippet *Snippet, size int64, ttl time.Duration) (string, error)
+
+	// CompleteUpload checks that a reserved snippet's content was uploaded
+	// and gives it the expiry expiresAt picks for the uploaded size.
+	// tokenHash must match its DeleteTokenHash. Returns ErrNotFound for an
+	// unknown or lapsed reservation, ErrTokenMismatch, ErrNotUploaded, or
+	// ErrTooLarge for content over maxSize, which removes the snippet.
+	CompleteUpload(ctx context.Context, tenant, id, tokenHash string, maxSize int64, expiresAt func(size int64) time.Time) (*SnippetMeta, error)
+}
@@ -0,0 +1,93 @@
+// Package tracing configures OpenTelemetry tracing for the server.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Setup installs a global tracer provider exporting to the OTLP/HTTP
+// endpoint. Call Shutdown on the result to flush pending spans.
+func Setup(ctx context.Context, endpoint string, sampleRate float64) (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("creating trace exporter: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(NewSampler(sampleRate)),
+		sdktrace.WithSpanProcessor(KeepErrors(sdktrace.NewBatchSpanProcessor(exporter))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName("tafcha-server"))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return tp, nil
+}
+
+// NewSampler samples sampleRate of new traces and follows the caller's
+// decision for traces that already have a parent. Spans that are not
+// sampled are still recorded so KeepErrors can export them if they fail.
+func NewSampler(sampleRate float64) sdktrace.Sampler {
+	notSampled := recordOnly{sdktrace.NeverSample()}
+	return sdktrace.ParentBased(
+		recordOnly{sdktrace.TraceIDRatioBased(sampleRate)},
+		sdktrace.WithRemoteParentNotSampled(notSampled),
+		sdktrace.WithLocalParentNotSampled(notSampled),
+	)
+}
+
+// recordOnly downgrades Drop decisions to RecordOnly.
+type recordOnly struct {
+	sdktrace.Sampler
+}
+
+func (s recordOnly) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	result := s.Sampler.ShouldSample(p)
+	if result.Decision == sdktrace.Drop {
+		result.Decision = sdktrace.RecordOnly
+	}
+	return result
+}
+
+func (s recordOnly) Description() string {
+	return "RecordOnly{" + s.Sampler.Description() + "}"
+}
+
+// KeepErrors wraps a span processor so that it receives sampled spans plus
+// any unsampled span that ended with an error status.
+func KeepErrors(next sdktrace.SpanProcessor) sdktrace.SpanProcessor {
+	return keepErrors{next}
+}
+
+type keepErrors struct {
+	sdktrace.SpanProcessor
+}
+
+func (p keepErrors) OnEnd(s sdktrace.ReadOnlySpan) {
+	switch {
+	case s.SpanContext().IsSampled():
+		p.SpanProcessor.OnEnd(s)
+	case s.Status().Code == codes.Error:
+		p.SpanProcessor.OnEnd(forceSampled{s})
+	}
+}
+
+// forceSampled reports a span as sampled so downstream processors export it.
+type forceSampled struct {
+	sdktrace.ReadOnlySpan
+}
+
+func (s forceSampled) SpanContext() trace.SpanContext {
+	sc := s.ReadOnlySpan.SpanContext()
+	return sc.WithTraceFlags(sc.TraceFlags().WithSampled(true))
+}
@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func decide(t *testing.T, sampler sdktrace.Sampler, parent context.Context) sdktrace.SamplingDecision {
+	t.Helper()
+
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	require.NoError(t, err)
+	return sampler.ShouldSample(sdktrace.SamplingParameters{
+		ParentContext: parent,
+		TraceID:       traceID,
+		Name:          "GET /{id}",
+	}).Decision
+}
+
+func TestNewSampler(t *testing.T) {
+	ctx := context.Background()
+
+	assert.Equal(t, sdktrace.RecordAndSample, decide(t, NewSampler(1), ctx))
+	assert.Equal(t, sdktrace.RecordOnly, decide(t, NewSampler(0), ctx))
+}
+
+func TestNewSampler_FollowsParent(t *testing.T) {
+	parent := func(sampled bool) context.Context {
+		flags := trace.TraceFlags(0).WithSampled(sampled)
+		sc := trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{1},
+			SpanID:     trace.SpanID{1},
+			TraceFlags: flags,
+			Remote:     true,
+		})
+		return trace.ContextWithRemoteSpanContext(context.Background(), sc)
+	}
+
+	assert.Equal(t, sdktrace.RecordAndSample, decide(t, NewSampler(0), parent(true)))
+	assert.Equal(t, sdktrace.RecordOnly, decide(t, NewSampler(1), parent(false)))
+}
+
+func TestKeepErrors(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(NewSampler(0)),
+		sdktrace.WithSpanProcessor(KeepErrors(sdktrace.NewSimpleSpanProcessor(exporter))),
+	)
+	tracer := tp.Tracer("test")
+
+	_, ok := tracer.Start(context.Background(), "ok")
+	ok.End()
+
+	_, failed := tracer.Start(context.Background(), "failed")
+	failed.SetStatus(codes.Error, "boom")
+	failed.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "failed", spans[0].Name)
+}
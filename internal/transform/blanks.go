@@ -0,0 +1,57 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+)
+
+// minBlankRun is the shortest run of blank lines that CollapseBlanks
+// reduces to one.
+const minBlankRun = 3
+
+func newCollapseBlanks(arg string) (Func, error) {
+	if arg != "" {
+		return nil, fmt.Errorf("takes no argument")
+	}
+	return func(content []byte) ([]byte, error) {
+		return []byte(CollapseBlanks(string(content))), nil
+	}, nil
+}
+
+// CollapseBlanks replaces every run of three or more blank lines with a
+// single blank line. Lines holding only spaces and tabs count as blank.
+// The first line of a run is kept as is, so "\n" and "\r\n" endings are
+// preserved; shorter runs are left alone.
+func CollapseBlanks(s string) string {
+	lines := strings.SplitAfter(s, "\n")
+	var b strings.Builder
+	b.Grow(len(s))
+
+	for i := 0; i < len(lines); {
+		if !isBlank(lines[i]) {
+			b.WriteString(lines[i])
+			i++
+			continue
+		}
+
+		end := i
+		for end < len(lines) && isBlank(lines[end]) {
+			end++
+		}
+		if end-i >= minBlankRun {
+			b.WriteString(lines[i])
+		} else {
+			for _, line := range lines[i:end] {
+				b.WriteString(line)
+			}
+		}
+		i = end
+	}
+	return b.String()
+}
+
+// isBlank reports whether a line, including its ending, is whitespace only.
+// The empty string after a final newline is not a line.
+func isBlank(line string) bool {
+	return line != "" && strings.Trim(line, " \t\r\n") == ""
+}
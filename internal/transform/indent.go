@@ -0,0 +1,82 @@
+package transform
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Bounds for the tab width used by the indentation transforms.
+const (
+	MinTabWidth = 1
+	MaxTabWidth = 16
+)
+
+func parseTabWidth(arg string) (int, error) {
+	width, err := strconv.Atoi(arg)
+	if err != nil || width < MinTabWidth || width > MaxTabWidth {
+		return 0, fmt.Errorf("tab width must be between %d and %d", MinTabWidth, MaxTabWidth)
+	}
+	return width, nil
+}
+
+func newTabsToSpaces(arg string) (Func, error) {
+	width, err := parseTabWidth(arg)
+	if err != nil {
+		return nil, err
+	}
+	return func(content []byte) ([]byte, error) {
+		return []byte(TabsToSpaces(string(content), width)), nil
+	}, nil
+}
+
+func newSpacesToTabs(arg string) (Func, error) {
+	width, err := parseTabWidth(arg)
+	if err != nil {
+		return nil, err
+	}
+	return func(content []byte) ([]byte, error) {
+		return []byte(SpacesToTabs(string(content), width)), nil
+	}, nil
+}
+
+// TabsToSpaces expands tabs in each line's leading whitespace to spaces,
+// with tab stops every width columns. Tabs and spaces after the first
+// other character are left alone, so aligned columns inside a line keep
+// their original bytes.
+func TabsToSpaces(s string, width int) string {
+	return reindent(s, width, func(column int) string {
+		return strings.Repeat(" ", column)
+	})
+}
+
+// SpacesToTabs rewrites each line's leading whitespace as tabs, with tab
+// stops every width columns, followed by spaces for any remainder that does
+// not reach the next stop. Like TabsToSpaces it only touches indentation.
+func SpacesToTabs(s string, width int) string {
+	return reindent(s, width, func(column int) string {
+		return strings.Repeat("\t", column/width) + strings.Repeat(" ", column%width)
+	})
+}
+
+// reindent replaces the leading spaces and tabs of every line with
+// indent(column), where column is the visual width of that whitespace.
+func reindent(s string, width int, indent func(column int) string) string {
+	lines := strings.SplitAfter(s, "\n")
+	var b strings.Builder
+	b.Grow(len(s))
+
+	for _, line := range lines {
+		column, n := 0, 0
+		for ; n < len(line) && (line[n] == ' ' || line[n] == '\t'); n++ {
+			if line[n] == '\t' {
+				column += width - column%width
+			} else {
+				column++
+			}
+		}
+		b.WriteString(indent(column))
+		b.WriteString(line[n:])
+	}
+	return b.String()
+}
@@ -0,0 +1,26 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+func newMinifyJSON(arg string) (Func, error) {
+	if arg != "" {
+		return nil, fmt.Errorf("takes no argument")
+	}
+	return MinifyJSON, nil
+}
+
+// MinifyJSON removes the insignificant whitespace from a single JSON
+// value. Keys keep their order and numbers their exact text. It returns an
+// error if content is not valid JSON.
+func MinifyJSON(content []byte) ([]byte, error) {
+	var b bytes.Buffer
+	b.Grow(len(content))
+	if err := json.Compact(&b, content); err != nil {
+		return nil, fmt.Errorf("content is not valid JSON: %w", err)
+	}
+	return b.Bytes(), nil
+}
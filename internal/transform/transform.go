@@ -0,0 +1,64 @@
+// Package transform rewrites snippet content at creation time.
+//
+// Transforms are requested as a comma-separated list of name[:arg] specs,
+// e.g. "wrap:80", and applied in order.
+package transform
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Func rewrites content. It returns an error for content the transform
+// cannot handle, e.g. invalid JSON for minify-json.
+type Func func(content []byte) ([]byte, error)
+
+// builders constructs a transform from its optional argument.
+var builders = map[string]func(arg string) (Func, error){
+	"wrap":            newWrap,
+	"collapse-blanks": newCollapseBlanks,
+	"tabs-to-spaces":  newTabsToSpaces,
+	"spaces-to-tabs":  newSpacesToTabs,
+	"minify-json":     newMinifyJSON,
+}
+
+// Parse turns a spec such as "wrap:80" into a single transform that applies
+// each listed transform in order.
+func Parse(spec string) (Func, error) {
+	var funcs []Func
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, arg, _ := strings.Cut(part, ":")
+		build, ok := builders[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown transform %q", name)
+		}
+		fn, err := build(arg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		funcs = append(funcs, func(content []byte) ([]byte, error) {
+			out, err := fn(content)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			return out, nil
+		})
+	}
+	if len(funcs) == 0 {
+		return nil, fmt.Errorf("empty transform")
+	}
+
+	return func(content []byte) ([]byte, error) {
+		for _, fn := range funcs {
+			var err error
+			if content, err = fn(content); err != nil {
+				return nil, err
+			}
+		}
+		return content, nil
+	}, nil
+}
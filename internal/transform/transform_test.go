@@ -0,0 +1,220 @@
+package transform
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		width int
+		want  string
+	}{
+		{
+			name:  "short lines untouched",
+			input: "short\nlines stay\n",
+			width: 20,
+			want:  "short\nlines stay\n",
+		},
+		{
+			name:  "wraps at word boundary",
+			input: "the quick brown fox jumps over the lazy dog\n",
+			width: 20,
+			want:  "the quick brown fox\njumps over the lazy\ndog\n",
+		},
+		{
+			name:  "mixed long and short lines",
+			input: "tiny\naaaa bbbb cccc dddd eeee ffff\nend",
+			width: 20,
+			want:  "tiny\naaaa bbbb cccc dddd\neeee ffff\nend",
+		},
+		{
+			name:  "long word is split",
+			input: strings.Repeat("x", 45),
+			width: 20,
+			want:  strings.Repeat("x", 20) + "\n" + strings.Repeat("x", 20) + "\n" + strings.Repeat("x", 5),
+		},
+		{
+			name:  "crlf endings are kept",
+			input: "aaaa bbbb cccc dddd eeee\r\nok\r\n",
+			width: 20,
+			want:  "aaaa bbbb cccc dddd\r\neeee\r\nok\r\n",
+		},
+		{
+			name:  "multibyte counted as characters",
+			input: "ééééé ééééé ééééé ééééé",
+			width: 20,
+			want:  "ééééé ééééé ééééé\nééééé",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Wrap(tt.input, tt.width))
+		})
+	}
+}
+
+func TestWrap_NeverSplitsRunes(t *testing.T) {
+	input := strings.Repeat("日本語", 30) // no spaces: forced character breaks
+
+	out := Wrap(input, 20)
+
+	assert.True(t, utf8.ValidString(out))
+	for _, line := range strings.Split(out, "\n") {
+		assert.LessOrEqual(t, utf8.RuneCountInString(line), 20)
+	}
+	assert.Equal(t, input, strings.ReplaceAll(out, "\n", ""))
+}
+
+func TestCollapseBlanks(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "long run collapsed",
+			input: "a\n\n\n\n\n\nb\n",
+			want:  "a\n\nb\n",
+		},
+		{
+			name:  "short runs kept",
+			input: "a\n\nb\n\n\nc\n",
+			want:  "a\n\nb\n\n\nc\n",
+		},
+		{
+			name:  "whitespace-only lines are blank",
+			input: "a\n  \n\t\n \t \nb",
+			want:  "a\n  \nb",
+		},
+		{
+			name:  "crlf endings",
+			input: "a\r\n\r\n\r\n\r\n\r\nb\r\n",
+			want:  "a\r\n\r\nb\r\n",
+		},
+		{
+			name:  "mixed endings",
+			input: "a\n\r\n\n\r\nb\r\n\n\n\n",
+			want:  "a\n\r\nb\r\n\n",
+		},
+		{
+			name:  "leading and trailing runs",
+			input: "\n\n\n\nlog line\n\n\n\n\n",
+			want:  "\nlog line\n\n",
+		},
+		{
+			name:  "no blank lines",
+			input: "one\ntwo",
+			want:  "one\ntwo",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, CollapseBlanks(tt.input))
+		})
+	}
+}
+
+func TestTabsToSpaces(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"leading tabs", "\tif x {\n\t\treturn\n\t}\n", "    if x {\n        return\n    }\n"},
+		{"mixed indentation", "  \tfoo\n\t  bar\n", "    foo\n      bar\n"},
+		{"mid-line tabs untouched", "\tname\tvalue\n", "    name\tvalue\n"},
+		{"crlf endings", "\tx\r\n\ty", "    x\r\n    y"},
+		{"no indentation", "plain\n", "plain\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, TabsToSpaces(tt.input, 4))
+		})
+	}
+}
+
+func TestSpacesToTabs(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"leading spaces", "    if x {\n        return\n    }\n", "\tif x {\n\t\treturn\n\t}\n"},
+		{"partial stop kept as spaces", "      foo\n  bar\n", "\t  foo\n  bar\n"},
+		{"mixed indentation", "  \t  foo\n", "\t  foo\n"},
+		{"mid-line spaces untouched", "    a    = 1\n", "\ta    = 1\n"},
+		{"crlf endings", "    x\r\n", "\tx\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, SpacesToTabs(tt.input, 4))
+		})
+	}
+}
+
+func TestParse_Chain(t *testing.T) {
+	fn, err := Parse("collapse-blanks,wrap:20")
+	require.NoError(t, err)
+
+	input := "aaaa bbbb cccc dddd eeee\n\n\n\n\nend"
+	out, err := fn([]byte(input))
+	require.NoError(t, err)
+	assert.Equal(t, "aaaa bbbb cccc dddd\neeee\n\nend", string(out))
+
+	_, err = Parse("collapse-blanks:2")
+	assert.Error(t, err)
+}
+
+func TestParse(t *testing.T) {
+	fn, err := Parse("wrap:20")
+	require.NoError(t, err)
+	out, err := fn([]byte("aaaa bbbb cccc dddd eeee"))
+	require.NoError(t, err)
+	assert.Equal(t, "aaaa bbbb cccc dddd\neeee", string(out))
+
+	fn, err = Parse("tabs-to-spaces:2")
+	require.NoError(t, err)
+	out, err = fn([]byte("\t\tx\ty"))
+	require.NoError(t, err)
+	assert.Equal(t, "    x\ty", string(out))
+
+	for _, spec := range []string{"", "wrap", "wrap:5", "wrap:9999", "wrap:wide", "shout", "tabs-to-spaces", "spaces-to-tabs:0", "spaces-to-tabs:17", "minify-json:2"} {
+		_, err := Parse(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestMinifyJSON(t *testing.T) {
+	input := "{\n  \"name\": \"tafcha\",\n  \"tags\": [ \"a b\", \"c\" ],\n  \"size\": 1.50,\n  \"nested\": { \"ok\": true }\n}\n"
+
+	out, err := MinifyJSON([]byte(input))
+	require.NoError(t, err)
+	assert.Equal(t, `{"name":"tafcha","tags":["a b","c"],"size":1.50,"nested":{"ok":true}}`, string(out))
+}
+
+func TestMinifyJSON_Invalid(t *testing.T) {
+	for _, input := range []string{"{\"a\": 1,}", "not json", "{\"a\": 1} {\"b\": 2}", "[1, 2"} {
+		_, err := MinifyJSON([]byte(input))
+		assert.Error(t, err, input)
+	}
+}
+
+func TestParse_PropagatesErrors(t *testing.T) {
+	fn, err := Parse("collapse-blanks,minify-json")
+	require.NoError(t, err)
+
+	_, err = fn([]byte("{oops"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "minify-json: content is not valid JSON")
+}
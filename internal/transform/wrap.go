@@ -0,0 +1,85 @@
+package transform
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Bounds for the wrap width, in characters.
+const (
+	MinWrapWidth = 20
+	MaxWrapWidth = 500
+)
+
+func newWrap(arg string) (Func, error) {
+	width, err := strconv.Atoi(arg)
+	if err != nil || width < MinWrapWidth || width > MaxWrapWidth {
+		return nil, fmt.Errorf("width must be between %d and %d", MinWrapWidth, MaxWrapWidth)
+	}
+	return func(content []byte) ([]byte, error) {
+		return []byte(Wrap(string(content), width)), nil
+	}, nil
+}
+
+// Wrap hard-wraps lines longer than width characters at spaces. Words
+// longer than width are split between characters, never inside a UTF-8
+// sequence. Lines that already fit, and their line endings, are unchanged.
+func Wrap(s string, width int) string {
+	lines := strings.SplitAfter(s, "\n")
+	var b strings.Builder
+	b.Grow(len(s))
+
+	for _, line := range lines {
+		body := strings.TrimRight(line, "\r\n")
+		ending := line[len(body):]
+
+		runes := []rune(body)
+		if len(runes) <= width {
+			b.WriteString(line)
+			continue
+		}
+
+		// Continuation lines reuse the original line ending
+		sep := "\n"
+		if strings.HasPrefix(ending, "\r") {
+			sep = "\r\n"
+		}
+		for i, segment := range wrapRunes(runes, width) {
+			if i > 0 {
+				b.WriteString(sep)
+			}
+			b.WriteString(segment)
+		}
+		b.WriteString(ending)
+	}
+	return b.String()
+}
+
+// wrapRunes splits a single line into segments of at most width runes,
+// breaking at the last space that fits when there is one.
+func wrapRunes(line []rune, width int) []string {
+	var segments []string
+	for len(line) > width {
+		cut := -1
+		for i := width; i > 0; i-- {
+			if line[i] == ' ' {
+				cut = i
+				break
+			}
+		}
+
+		if cut <= 0 {
+			segments = append(segments, string(line[:width]))
+			line = line[width:]
+			continue
+		}
+
+		segments = append(segments, strings.TrimRight(string(line[:cut]), " "))
+		line = line[cut:]
+		for len(line) > 0 && line[0] == ' ' {
+			line = line[1:]
+		}
+	}
+	return append(segments, string(line))
+}
@@ -0,0 +1,63 @@
+// Package urlsign creates and verifies time-limited snippet links.
+//
+// A signed link carries exp (Unix seconds) and sig, an HMAC-SHA256 over the
+// snippet ID and exp keyed with a secret shared by the server and whoever
+// issues the links.
+package urlsign
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Errors returned by Verify.
+var (
+	ErrMissing = errors.New("signature and expiry are required")
+	ErrInvalid = errors.New("invalid signature")
+	ErrExpired = errors.New("signed link has expired")
+)
+
+// Sign returns the signature for snippetID valid until exp.
+func Sign(secret []byte, snippetID string, exp time.Time) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(snippetID + "." + strconv.FormatInt(exp.Unix(), 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Query returns the exp and sig query parameters for snippetID.
+func Query(secret []byte, snippetID string, exp time.Time) url.Values {
+	return url.Values{
+		"exp": {strconv.FormatInt(exp.Unix(), 10)},
+		"sig": {Sign(secret, snippetID, exp)},
+	}
+}
+
+// Verify checks the exp and sig parameters of query for snippetID.
+// The signature is checked before the expiry so a tampered exp is reported
+// as invalid rather than expired.
+func Verify(secret []byte, snippetID string, query url.Values, now time.Time) error {
+	rawExp, sig := query.Get("exp"), query.Get("sig")
+	if rawExp == "" || sig == "" {
+		return ErrMissing
+	}
+
+	unix, err := strconv.ParseInt(rawExp, 10, 64)
+	if err != nil {
+		return ErrInvalid
+	}
+	exp := time.Unix(unix, 0)
+
+	want := Sign(secret, snippetID, exp)
+	if !hmac.Equal([]byte(sig), []byte(want)) {
+		return ErrInvalid
+	}
+	if !now.Before(exp) {
+		return ErrExpired
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package urlsign
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	secret := []byte("shared-secret")
+	now := time.Unix(1_700_000_000, 0)
+	valid := Query(secret, "abc123XYZ789", now.Add(5*time.Minute))
+
+	tampered := func(key, val string) url.Values {
+		q := url.Values{}
+		for k, v := range valid {
+			q[k] = v
+		}
+		q.Set(key, val)
+		return q
+	}
+
+	tests := []struct {
+		name  string
+		id    string
+		query url.Values
+		now   time.Time
+		want  error
+	}{
+		{name: "valid", id: "abc123XYZ789", query: valid, now: now},
+		{name: "expired", id: "abc123XYZ789", query: valid, now: now.Add(5 * time.Minute), want: ErrExpired},
+		{name: "other snippet", id: "zzz123XYZ789", query: valid, now: now, want: ErrInvalid},
+		{name: "extended expiry", id: "abc123XYZ789", query: tampered("exp", "1900000000"), now: now, want: ErrInvalid},
+		{name: "tampered signature", id: "abc123XYZ789", query: tampered("sig", "AAAA"), now: now, want: ErrInvalid},
+		{name: "bad expiry", id: "abc123XYZ789", query: tampered("exp", "soon"), now: now, want: ErrInvalid},
+		{name: "missing", id: "abc123XYZ789", query: url.Values{}, now: now, want: ErrMissing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Verify(secret, tt.id, tt.query, tt.now))
+		})
+	}
+}
+
+func TestVerify_WrongSecret(t *testing.T) {
+	now := time.Now()
+	query := Query([]byte("one"), "abc123XYZ789", now.Add(time.Minute))
+
+	assert.Equal(t, ErrInvalid, Verify([]byte("two"), "abc123XYZ789", query, now))
+}